@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"scratchpad/internal/auth"
+)
+
+const keysUsage = `usage: server keys <command> [flags]
+
+commands:
+  create --name NAME --scopes read,write[,delete,admin] [--categories a,b] [--workspace ID]
+  list
+  revoke ID
+
+Keys are stored in the backend selected by STORAGE and MONGODB_URI.`
+
+// runKeys implements the "keys" subcommand for minting, listing and
+// revoking API keys. It returns the process exit code.
+func runKeys(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, keysUsage)
+		return 2
+	}
+
+	storage := getEnv("STORAGE", "mongo")
+	if storage == "memory" {
+		fmt.Fprintln(os.Stderr, "keys: STORAGE=memory keeps keys inside the server process; use mongo or sqlite")
+		return 1
+	}
+	mongoURI := getEnv("MONGODB_URI", "mongodb://oracle-vm:27017")
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	backend, err := openStores(ctx, storage, mongoURI, logger)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "keys:", err)
+		return 1
+	}
+	if err := backend.keys.EnsureIndexes(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "keys:", err)
+		return 1
+	}
+	svc := auth.NewService(backend.keys)
+
+	switch args[0] {
+	case "create":
+		err = createKey(ctx, svc, args[1:], os.Stdout)
+	case "list":
+		err = listKeys(ctx, svc, os.Stdout)
+	case "revoke":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "usage: server keys revoke ID")
+			return 2
+		}
+		if err = svc.Revoke(ctx, args[1]); err == nil {
+			fmt.Println("revoked", args[1])
+		}
+	default:
+		fmt.Fprintln(os.Stderr, keysUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "keys:", err)
+		return 1
+	}
+	return 0
+}
+
+func createKey(ctx context.Context, svc *auth.Service, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("keys create", flag.ContinueOnError)
+	name := fs.String("name", "", "what the key is for, e.g. claude-desktop")
+	scopes := fs.String("scopes", "read", "comma-separated scopes: read, write, delete, admin")
+	categories := fs.String("categories", "", "comma-separated categories to restrict the key to (default: all)")
+	ws := fs.String("workspace", "", "workspace to bind the key to (default: any)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	scopeList, err := auth.ParseScopes(*scopes)
+	if err != nil {
+		return err
+	}
+	input := auth.MintKeyInput{Name: *name, Scopes: scopeList, Workspace: *ws}
+	if *categories != "" {
+		input.Categories = strings.Split(*categories, ",")
+	}
+
+	minted, err := svc.Mint(ctx, input)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "id:     %s\nname:   %s\nscopes: %s\n", minted.ID.Hex(), minted.Name, joinScopes(minted.Scopes))
+	if len(minted.Categories) > 0 {
+		fmt.Fprintf(out, "categories: %s\n", strings.Join(minted.Categories, ","))
+	}
+	if minted.Workspace != "" {
+		fmt.Fprintf(out, "workspace: %s\n", minted.Workspace)
+	}
+	fmt.Fprintf(out, "\n%s\n\nStore this key now; it cannot be shown again.\n", minted.Secret)
+	return nil
+}
+
+func listKeys(ctx context.Context, svc *auth.Service, out io.Writer) error {
+	keys, err := svc.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tSCOPES\tCATEGORIES\tWORKSPACE\tCREATED\tSTATUS")
+	for _, k := range keys {
+		categories, ws, status := "*", "*", "active"
+		if len(k.Categories) > 0 {
+			categories = strings.Join(k.Categories, ",")
+		}
+		if k.Workspace != "" {
+			ws = k.Workspace
+		}
+		if k.RevokedAt != nil {
+			status = "revoked " + k.RevokedAt.Local().Format("2006-01-02")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s…\t%s\t%s\t%s\t%s\t%s\n",
+			k.ID.Hex(), k.Name, k.Prefix, joinScopes(k.Scopes), categories, ws,
+			k.CreatedAt.Local().Format("2006-01-02"), status)
+	}
+	return tw.Flush()
+}
+
+func joinScopes(scopes []auth.Scope) string {
+	parts := make([]string, len(scopes))
+	for i, s := range scopes {
+		parts[i] = string(s)
+	}
+	return strings.Join(parts, ",")
+}
@@ -3,32 +3,84 @@ package main
 import (
 	"context"
 	"embed"
+	"errors"
+	"flag"
 	"io/fs"
 	"log"
 	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
-	"scratchpad/internal/db"
+	"scratchpad/internal/auth"
 	mcpserver "scratchpad/internal/mcp"
 	"scratchpad/internal/notes"
-
-	"github.com/mark3labs/mcp-go/server"
+	"scratchpad/internal/webhooks"
+	"scratchpad/internal/workspace"
 )
 
 //go:embed static
 var staticFS embed.FS
 
 func main() {
+	// Subcommands
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		os.Exit(runKeys(os.Args[2:]))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "vault" {
+		os.Exit(runVault(os.Args[2:]))
+	}
+
+	// Flags
+	mcpStdio := flag.Bool("mcp-stdio", false, "serve MCP over stdin/stdout instead of starting the HTTP server")
+	stdioWorkspace := flag.String("workspace", workspace.Default, "workspace served in --mcp-stdio mode")
+	flag.Parse()
+
 	// Config
+	storage := getEnv("STORAGE", "mongo")
 	mongoURI := getEnv("MONGODB_URI", "mongodb://oracle-vm:27017")
 	port := getEnv("PORT", "7521")
+	trashRetention, err := time.ParseDuration(getEnv("TRASH_RETENTION", "720h"))
+	if err != nil {
+		log.Fatalf("invalid TRASH_RETENTION: %v", err)
+	}
+	idempotencyTTL, err := time.ParseDuration(getEnv("IDEMPOTENCY_TTL", "24h"))
+	if err != nil || idempotencyTTL <= 0 {
+		log.Fatalf("invalid IDEMPOTENCY_TTL: %q", os.Getenv("IDEMPOTENCY_TTL"))
+	}
+	eventFeed := getEnv("EVENT_FEED", "local")
+	embeddingsURL := os.Getenv("EMBEDDINGS_URL")
+	embeddingsModel := os.Getenv("EMBEDDINGS_MODEL")
+	if embeddingsURL != "" && embeddingsModel == "" {
+		log.Fatalf("EMBEDDINGS_URL needs EMBEDDINGS_MODEL")
+	}
+	duplicatePolicy := notes.DuplicatePolicy(getEnv("DUPLICATE_POLICY", string(notes.DuplicatesAllow)))
+	webhookMaxAttempts, err := strconv.Atoi(getEnv("WEBHOOK_MAX_ATTEMPTS", strconv.Itoa(webhooks.DefaultMaxAttempts)))
+	if err != nil {
+		log.Fatalf("invalid WEBHOOK_MAX_ATTEMPTS: %v", err)
+	}
+	webhookRetryBase, err := time.ParseDuration(getEnv("WEBHOOK_RETRY_BASE", webhooks.DefaultRetryBase.String()))
+	if err != nil {
+		log.Fatalf("invalid WEBHOOK_RETRY_BASE: %v", err)
+	}
+	mcpReadOnly, err := strconv.ParseBool(getEnv("MCP_READ_ONLY", "false"))
+	if err != nil {
+		log.Fatalf("invalid MCP_READ_ONLY: %v", err)
+	}
+	authRequired, err := strconv.ParseBool(getEnv("AUTH_REQUIRED", "true"))
+	if err != nil {
+		log.Fatalf("invalid AUTH_REQUIRED: %v", err)
+	}
 
-	// Logger
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+	// Logger; stdout belongs to the protocol in stdio mode
+	logOut := os.Stdout
+	if *mcpStdio {
+		logOut = os.Stderr
+	}
+	logger := slog.New(slog.NewTextHandler(logOut, &slog.HandlerOptions{
 		Level: slog.LevelInfo,
 	}))
 
@@ -36,24 +88,102 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Connect to MongoDB
-	logger.Info("connecting to MongoDB", "uri", mongoURI)
-	database, err := db.Connect(ctx, mongoURI, "scratchpad")
+	// Open storage
+	backend, err := openStores(ctx, storage, mongoURI, logger)
 	if err != nil {
-		log.Fatalf("failed to connect to MongoDB: %v", err)
+		log.Fatal(err)
 	}
-	logger.Info("connected to MongoDB")
 
 	// Wire dependencies
-	noteRepo := notes.NewRepo(database)
-	if err := noteRepo.EnsureIndexes(ctx); err != nil {
-		logger.Warn("failed to ensure indexes", "error", err)
+	if err := backend.ensureIndexes(ctx, logger); err != nil {
+		log.Fatal(err)
+	}
+	noteSvc := notes.NewService(backend.notes)
+	noteSvc.SetIdempotencyTTL(idempotencyTTL)
+	if err := noteSvc.SetDuplicatePolicy(duplicatePolicy); err != nil {
+		log.Fatalf("invalid DUPLICATE_POLICY: %v", err)
+	}
+	if embeddingsURL != "" {
+		noteSvc.SetEmbedder(notes.NewHTTPEmbedder(embeddingsURL, embeddingsModel))
+		logger.Info("semantic search uses an embedding server", "url", embeddingsURL, "model", embeddingsModel)
+	}
+	bus := notes.NewBus(notes.DefaultBusBacklog)
+	noteHandler := notes.NewHandler(noteSvc, bus, logger)
+	authSvc := auth.NewService(backend.keys)
+	authHandler := auth.NewHandler(authSvc, logger)
+	authMw := auth.NewMiddleware(authSvc, authRequired, logger)
+	webhookSvc := webhooks.NewService(backend.webhooks, logger)
+	if err := webhookSvc.SetRetryPolicy(webhookMaxAttempts, webhookRetryBase); err != nil {
+		log.Fatalf("invalid webhook retry policy: %v", err)
+	}
+	noteSvc.OnChange(webhookSvc.NoteChanged)
+	webhookHandler := webhooks.NewHandler(webhookSvc, logger)
+
+	// Background purge of notes that outlived their trash retention
+	purgeCtx, stopPurge := context.WithCancel(context.Background())
+	defer stopPurge()
+	if trashRetention > 0 {
+		go runTrashPurge(purgeCtx, noteSvc, trashRetention, logger)
+	}
+
+	// Background delivery of queued webhook events
+	webhookCtx, stopWebhooks := context.WithCancel(context.Background())
+	defer stopWebhooks()
+	go webhookSvc.Run(webhookCtx)
+
+	// Live feed: straight from this instance's Service, or from a MongoDB
+	// change stream so every instance sees changes made through the others
+	feedCtx, stopFeed := context.WithCancel(context.Background())
+	defer stopFeed()
+	switch eventFeed {
+	case "local":
+		noteSvc.OnChange(bus.Publish)
+	case "changestream":
+		watcher, ok := backend.notes.(interface {
+			WatchEvents(ctx context.Context, fn notes.Listener, onError func(error))
+		})
+		if !ok {
+			log.Fatalf("EVENT_FEED=changestream needs STORAGE=mongo")
+		}
+		go watcher.WatchEvents(feedCtx, bus.Publish, func(err error) {
+			logger.Warn("note change stream failed, retrying", "error", err)
+		})
+	default:
+		log.Fatalf("invalid EVENT_FEED %q (expected local or changestream)", eventFeed)
 	}
-	noteSvc := notes.NewService(noteRepo)
-	noteHandler := notes.NewHandler(noteSvc, logger)
 
 	// Create MCP server
-	mcpSrv := mcpserver.NewServer(noteSvc)
+	mcpSrv := mcpserver.NewServer(noteSvc, mcpserver.Options{ReadOnly: mcpReadOnly})
+	if mcpReadOnly {
+		logger.Info("MCP server is read-only, write tools disabled")
+	}
+
+	if *mcpStdio {
+		ws, err := workspace.Normalize(*stdioWorkspace)
+		if err != nil {
+			log.Fatal(err)
+		}
+		logger.Info("serving MCP over stdio", "workspace", ws)
+		stdioCtx, stop := signal.NotifyContext(workspace.With(context.Background(), ws), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+
+		errLog := slog.NewLogLogger(logger.Handler(), slog.LevelError)
+		if err := mcpSrv.ServeStdio(stdioCtx, os.Stdin, os.Stdout, errLog); err != nil && !errors.Is(err, context.Canceled) {
+			log.Fatalf("MCP stdio error: %v", err)
+		}
+		logger.Info("MCP stdio session ended")
+		return
+	}
+
+	// Keys kept in memory can't be minted from the command line, so the
+	// first one is minted here
+	if authRequired && storage == "memory" {
+		minted, err := authSvc.Mint(ctx, auth.MintKeyInput{Name: "bootstrap", Scopes: []auth.Scope{auth.ScopeAdmin}})
+		if err != nil {
+			log.Fatalf("failed to mint bootstrap key: %v", err)
+		}
+		logger.Warn("minted an admin API key for this in-memory run", "key", minted.Secret)
+	}
 
 	// HTTP router
 	mux := http.NewServeMux()
@@ -65,24 +195,68 @@ func main() {
 	}
 	mux.Handle("GET /static/", http.StripPrefix("/static/", http.FileServer(http.FS(sub))))
 
+	// Login for the web UI
+	mux.HandleFunc("GET /login", authHandler.LoginPage)
+	mux.HandleFunc("POST /login", authHandler.Login)
+	mux.HandleFunc("GET /logout", authHandler.Logout)
+
 	// REST API endpoints
-	mux.HandleFunc("POST /api/notes", noteHandler.CreateNote)
-	mux.HandleFunc("GET /api/notes", noteHandler.ListNotes)
-	mux.HandleFunc("GET /api/notes/search", noteHandler.SearchNotes)
-	mux.HandleFunc("GET /api/notes/{id}", noteHandler.GetNote)
-	mux.HandleFunc("DELETE /api/notes/{id}", noteHandler.DeleteNote)
-	mux.HandleFunc("GET /api/categories", noteHandler.ListCategories)
+	read, write, del, admin := auth.ScopeRead, auth.ScopeWrite, auth.ScopeDelete, auth.ScopeAdmin
+	mux.Handle("POST /api/notes", authMw.Require(write, noteHandler.CreateNote))
+	mux.Handle("POST /api/notes/bulk", authMw.Require(write, noteHandler.CreateNotesBulk))
+	mux.Handle("GET /api/notes", authMw.Require(read, noteHandler.ListNotes))
+	mux.Handle("GET /api/notes/search", authMw.Require(read, noteHandler.SearchNotes))
+	mux.Handle("GET /api/notes/semantic", authMw.Require(read, noteHandler.SemanticSearch))
+	mux.Handle("GET /api/notes/{id}", authMw.Require(read, noteHandler.GetNote))
+	mux.Handle("PUT /api/notes/{id}", authMw.Require(write, noteHandler.ReplaceNote))
+	mux.Handle("PATCH /api/notes/{id}", authMw.Require(write, noteHandler.PatchNote))
+	mux.Handle("DELETE /api/notes/{id}", authMw.Require(del, noteHandler.DeleteNote))
+	mux.Handle("GET /api/notes/{id}/similar", authMw.Require(read, noteHandler.SimilarNotes))
+	mux.Handle("GET /api/notes/{id}/related", authMw.Require(read, noteHandler.RelatedNotes))
+	mux.Handle("GET /api/notes/{id}/revisions", authMw.Require(read, noteHandler.ListRevisions))
+	mux.Handle("GET /api/notes/{id}/revisions/{rev}", authMw.Require(read, noteHandler.GetRevision))
+	mux.Handle("POST /api/notes/{id}/revisions/{rev}/restore", authMw.Require(write, noteHandler.RestoreRevision))
+	mux.Handle("POST /api/notes/{id}/restore", authMw.Require(write, noteHandler.RestoreNote))
+	mux.Handle("GET /api/categories", authMw.Require(read, noteHandler.ListCategories))
+	mux.Handle("GET /api/tags", authMw.Require(read, noteHandler.ListTags))
+	mux.Handle("GET /api/trash", authMw.Require(read, noteHandler.ListTrash))
+	mux.Handle("GET /api/duplicates", authMw.Require(read, noteHandler.FindDuplicates))
+	mux.Handle("GET /api/export", authMw.Require(read, noteHandler.Export))
+	mux.Handle("POST /api/import", authMw.Require(write, noteHandler.Import))
+	mux.Handle("GET /api/events", authMw.Require(read, noteHandler.Events))
+	mux.Handle("GET /api/export/vault", authMw.Require(read, noteHandler.ExportVault))
+	mux.Handle("POST /api/import/vault", authMw.Require(write, noteHandler.ImportVault))
+
+	// API key management
+	mux.Handle("GET /api/keys", authMw.Require(admin, authHandler.ListKeys))
+	mux.Handle("POST /api/keys", authMw.Require(admin, authHandler.CreateKey))
+	mux.Handle("DELETE /api/keys/{id}", authMw.Require(admin, authHandler.RevokeKey))
+
+	// Webhooks
+	mux.Handle("GET /api/webhooks", authMw.Require(admin, webhookHandler.ListWebhooks))
+	mux.Handle("POST /api/webhooks", authMw.Require(admin, webhookHandler.CreateWebhook))
+	mux.Handle("GET /api/webhooks/dead-letters", authMw.Require(admin, webhookHandler.DeadLetters))
+	mux.Handle("GET /api/webhooks/{id}", authMw.Require(admin, webhookHandler.GetWebhook))
+	mux.Handle("DELETE /api/webhooks/{id}", authMw.Require(admin, webhookHandler.DeleteWebhook))
+	mux.Handle("POST /api/webhooks/{id}/ping", authMw.Require(admin, webhookHandler.PingWebhook))
+	mux.Handle("GET /api/webhooks/{id}/deliveries", authMw.Require(admin, webhookHandler.ListDeliveries))
+	mux.Handle("POST /api/webhooks/deliveries/{id}/retry", authMw.Require(admin, webhookHandler.RetryDelivery))
 
 	// HTMX Web UI (read-only)
-	mux.HandleFunc("GET /", noteHandler.HomePage)
-	mux.HandleFunc("GET /category/{name}", noteHandler.CategoryPage)
-	mux.HandleFunc("GET /search", noteHandler.SearchPage)
-	mux.HandleFunc("GET /fragments/notes", noteHandler.NotesFragment)
-	mux.HandleFunc("GET /fragments/search", noteHandler.SearchFragment)
+	mux.Handle("GET /", authMw.Require(read, noteHandler.HomePage))
+	mux.Handle("GET /category/{name}", authMw.Require(read, noteHandler.CategoryPage))
+	mux.Handle("GET /search", authMw.Require(read, noteHandler.SearchPage))
+	mux.Handle("GET /note/{id}", authMw.Require(read, noteHandler.NotePage))
+	mux.Handle("GET /note/{id}/history", authMw.Require(read, noteHandler.HistoryPage))
+	mux.Handle("GET /trash", authMw.Require(read, noteHandler.TrashPage))
+	mux.Handle("GET /fragments/notes", authMw.Require(read, noteHandler.NotesFragment))
+	mux.Handle("GET /fragments/search", authMw.Require(read, noteHandler.SearchFragment))
+	mux.Handle("GET /fragments/events", authMw.Require(read, noteHandler.EventsFragment))
 
 	// MCP endpoint (HTTP transport)
-	// MCP uses POST for requests and GET for SSE streams
-	mcpHTTP := server.NewStreamableHTTPServer(mcpSrv)
+	// MCP uses POST for requests and GET for SSE streams. Connecting needs
+	// the read scope; tools that change notes check for more per call.
+	mcpHTTP := authMw.Require(read, mcpSrv.HTTPHandler().ServeHTTP)
 	mux.Handle("POST /mcp", mcpHTTP)
 	mux.Handle("GET /mcp", mcpHTTP)
 	mux.Handle("DELETE /mcp", mcpHTTP)
@@ -96,11 +270,13 @@ func main() {
 	// Start server
 	srv := &http.Server{
 		Addr:         ":" + port,
-		Handler:      mux,
+		Handler:      workspace.Middleware(mux),
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 30 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
+	// Live feed streams never go idle, so end them for Shutdown to finish
+	srv.RegisterOnShutdown(bus.Close)
 
 	// Graceful shutdown
 	go func() {
@@ -117,6 +293,9 @@ func main() {
 		}
 	}()
 
+	if !authRequired {
+		logger.Warn("AUTH_REQUIRED is off, requests without an API key can read notes, and change them until the first key is minted")
+	}
 	logger.Info("server starting", "port", port)
 	logger.Info("endpoints available",
 		"web", "http://localhost:"+port,
@@ -131,6 +310,28 @@ func main() {
 	logger.Info("server stopped")
 }
 
+// runTrashPurge periodically removes notes that have been in the trash
+// longer than retention, until ctx is cancelled
+func runTrashPurge(ctx context.Context, svc *notes.Service, retention time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		purged, err := svc.PurgeTrash(ctx, retention)
+		if err != nil {
+			logger.Error("failed to purge trash", "error", err)
+		} else if purged > 0 {
+			logger.Info("purged trashed notes", "count", purged, "retention", retention)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
 func getEnv(key, defaultVal string) string {
 	if val := os.Getenv(key); val != "" {
 		return val
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"scratchpad/internal/auth"
+	"scratchpad/internal/db"
+	"scratchpad/internal/notes"
+	"scratchpad/internal/webhooks"
+)
+
+// stores holds the persistence for one backend; notes, API keys and
+// webhooks always live side by side
+type stores struct {
+	notes    notes.NoteStore
+	keys     auth.KeyStore
+	webhooks webhooks.Store
+
+	// schemaRequired is set when EnsureIndexes creates the tables themselves
+	// rather than only indexes, so the stores are unusable if it fails
+	schemaRequired bool
+}
+
+// openStores builds the stores selected by the STORAGE setting:
+//
+//	mongo          MongoDB at mongoURI (default)
+//	sqlite:<path>  embedded SQLite file, e.g. sqlite:./scratchpad.db
+//	memory         in-process store, lost on restart
+func openStores(ctx context.Context, storage, mongoURI string, logger *slog.Logger) (*stores, error) {
+	kind, arg, _ := strings.Cut(storage, ":")
+
+	switch kind {
+	case "mongo", "mongodb":
+		logger.Info("connecting to MongoDB", "uri", mongoURI)
+		database, err := db.Connect(ctx, mongoURI, "scratchpad")
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to MongoDB: %w", err)
+		}
+		logger.Info("connected to MongoDB")
+		return &stores{
+			notes:    notes.NewRepo(database),
+			keys:     auth.NewKeyRepo(database),
+			webhooks: webhooks.NewRepo(database),
+		}, nil
+
+	case "sqlite":
+		if arg == "" {
+			arg = "./scratchpad.db"
+		}
+		logger.Info("opening SQLite database", "path", arg)
+		database, err := db.OpenSQLite(ctx, arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open SQLite: %w", err)
+		}
+		return &stores{
+			notes:    notes.NewSQLiteRepo(database),
+			keys:     auth.NewSQLiteKeyRepo(database),
+			webhooks: webhooks.NewSQLiteRepo(database),
+
+			schemaRequired: true,
+		}, nil
+
+	case "memory":
+		logger.Warn("using in-memory storage, notes will be lost on restart")
+		return &stores{
+			notes:    notes.NewMemoryRepo(),
+			keys:     auth.NewMemoryKeyRepo(),
+			webhooks: webhooks.NewMemoryRepo(),
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("unknown STORAGE %q (expected mongo, sqlite:<path> or memory)", storage)
+	}
+}
+
+// ensureIndexes prepares every store. Missing indexes are only logged, so
+// the server still starts against a database it can't alter; a schema that
+// can't be created is an error.
+func (s *stores) ensureIndexes(ctx context.Context, logger *slog.Logger) error {
+	steps := []struct {
+		what   string
+		ensure func(context.Context) error
+	}{
+		{"indexes", s.notes.EnsureIndexes},
+		{"API key indexes", s.keys.EnsureIndexes},
+		{"webhook indexes", s.webhooks.EnsureIndexes},
+	}
+	for _, step := range steps {
+		err := step.ensure(ctx)
+		if err == nil {
+			continue
+		}
+		if s.schemaRequired {
+			return fmt.Errorf("failed to ensure %s: %w", step.what, err)
+		}
+		logger.Warn("failed to ensure "+step.what, "error", err)
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"scratchpad/internal/notes"
+	"scratchpad/internal/workspace"
+)
+
+const vaultUsage = `usage: server vault <command> [flags] DIR
+
+commands:
+  export [--category NAME] [--since DATE] [--until DATE] [--workspace ID] DIR
+  import [--mode skip|overwrite|new-id] [--workspace ID] DIR
+
+DIR holds one markdown file per note, laid out as category/<date>-<slug>.md,
+and can be opened in Obsidian. Notes are read from and written to the backend
+selected by STORAGE and MONGODB_URI.`
+
+// runVault implements the "vault" subcommand for exporting notes to a
+// directory of markdown files and importing them back. It returns the
+// process exit code.
+func runVault(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, vaultUsage)
+		return 2
+	}
+
+	storage := getEnv("STORAGE", "mongo")
+	if storage == "memory" {
+		fmt.Fprintln(os.Stderr, "vault: STORAGE=memory keeps notes inside the server process; use mongo or sqlite")
+		return 1
+	}
+	mongoURI := getEnv("MONGODB_URI", "mongodb://oracle-vm:27017")
+	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+
+	openCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	backend, err := openStores(openCtx, storage, mongoURI, logger)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "vault:", err)
+		return 1
+	}
+	if err := backend.notes.EnsureIndexes(openCtx); err != nil {
+		fmt.Fprintln(os.Stderr, "vault:", err)
+		return 1
+	}
+	svc := notes.NewService(backend.notes)
+
+	switch args[0] {
+	case "export":
+		err = exportVault(svc, args[1:], os.Stdout)
+	case "import":
+		err = importVault(svc, args[1:], os.Stdout)
+	default:
+		fmt.Fprintln(os.Stderr, vaultUsage)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "vault:", err)
+		return 1
+	}
+	return 0
+}
+
+func exportVault(svc *notes.Service, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("vault export", flag.ContinueOnError)
+	category := fs.String("category", "", "only export this category")
+	since := fs.String("since", "", "only notes created on or after this date (YYYY-MM-DD or RFC3339)")
+	until := fs.String("until", "", "only notes created before this date (YYYY-MM-DD or RFC3339)")
+	ws := fs.String("workspace", workspace.Default, "workspace to export")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("export needs exactly one directory")
+	}
+
+	q := notes.ExportQuery{Category: *category}
+	if *since != "" {
+		t, err := parseDateFlag(*since)
+		if err != nil {
+			return err
+		}
+		q.Since = &t
+	}
+	if *until != "" {
+		t, err := parseDateFlag(*until)
+		if err != nil {
+			return err
+		}
+		q.Until = &t
+	}
+
+	dw := &dirWriter{root: fs.Arg(0)}
+	count, err := svc.ExportVault(workspace.With(context.Background(), *ws), q, dw)
+	if closeErr := dw.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "exported %d notes to %s\n", count, fs.Arg(0))
+	return nil
+}
+
+func importVault(svc *notes.Service, args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("vault import", flag.ContinueOnError)
+	mode := fs.String("mode", string(notes.ImportSkip), "what to do with notes whose ID is taken: skip, overwrite or new-id")
+	ws := fs.String("workspace", workspace.Default, "workspace to import into")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("import needs exactly one directory")
+	}
+
+	var counts notes.ImportCounts
+	ctx := workspace.With(context.Background(), *ws)
+	err := svc.ImportVault(ctx, os.DirFS(fs.Arg(0)), notes.ImportMode(*mode), func(res notes.VaultResult) {
+		counts.Add(res.Status)
+		if res.Status == notes.BulkFailed {
+			fmt.Fprintf(out, "failed   %s: %s\n", res.Path, res.Error)
+		}
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "created %d, updated %d, skipped %d, failed %d\n",
+		counts.Created, counts.Updated, counts.Skipped, counts.Failed)
+	return nil
+}
+
+// parseDateFlag parses a date flag, RFC3339 or YYYY-MM-DD
+func parseDateFlag(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return t, fmt.Errorf("invalid date %q, use YYYY-MM-DD or RFC3339", v)
+	}
+	return t, nil
+}
+
+// dirWriter writes a vault export into a directory, one file at a time,
+// giving each file the modification time from its header
+type dirWriter struct {
+	root     string
+	file     *os.File
+	modified time.Time
+}
+
+func (d *dirWriter) CreateHeader(fh *zip.FileHeader) (io.Writer, error) {
+	if err := d.Close(); err != nil {
+		return nil, err
+	}
+	p := filepath.Join(d.root, filepath.FromSlash(fh.Name))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return nil, err
+	}
+	d.file = f
+	d.modified = fh.Modified
+	return f, nil
+}
+
+// Close closes the file being written, if any
+func (d *dirWriter) Close() error {
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	if err == nil && !d.modified.IsZero() {
+		err = os.Chtimes(d.file.Name(), d.modified, d.modified)
+	}
+	d.file = nil
+	return err
+}
@@ -0,0 +1,205 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"scratchpad/internal/workspace"
+	"scratchpad/views/pages"
+)
+
+// loginCookieMaxAge is how long a browser stays logged in
+const loginCookieMaxAge = 30 * 24 * time.Hour
+
+type Handler struct {
+	svc *Service
+	log *slog.Logger
+}
+
+func NewHandler(svc *Service, log *slog.Logger) *Handler {
+	return &Handler{svc: svc, log: log}
+}
+
+// --- REST API Handlers ---
+
+// ListKeys handles GET /api/keys
+func (h *Handler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.svc.List(r.Context())
+	if err != nil {
+		h.log.Error("failed to list API keys", "error", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	visible := []*APIKey{}
+	for _, k := range keys {
+		if canManage(r.Context(), k) {
+			visible = append(visible, k)
+		}
+	}
+	keys = visible
+	jsonResponse(w, keys, http.StatusOK)
+}
+
+// CreateKey handles POST /api/keys. The response is the only place the
+// new key's secret is ever shown.
+func (h *Handler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	var input MintKeyInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		jsonError(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	// A key limited to a workspace or some categories can't hand out wider access
+	if caller, ok := FromContext(r.Context()); ok && caller.Workspace != "" {
+		if input.Workspace == "" {
+			input.Workspace = caller.Workspace
+		}
+		if input.Workspace != caller.Workspace {
+			jsonError(w, "new key must stay in workspace "+caller.Workspace, http.StatusForbidden)
+			return
+		}
+	}
+	if caller, ok := FromContext(r.Context()); ok && !withinCategories(caller, input.Categories) {
+		jsonError(w, "new key's categories must be within your own", http.StatusForbidden)
+		return
+	}
+
+	minted, err := h.svc.Mint(r.Context(), input)
+	if errors.Is(err, ErrInvalidInput) {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		h.log.Error("failed to mint API key", "error", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	jsonResponse(w, minted, http.StatusCreated)
+}
+
+// RevokeKey handles DELETE /api/keys/{id}
+func (h *Handler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if caller, ok := FromContext(r.Context()); ok && (caller.Workspace != "" || len(caller.Categories) > 0) {
+		keys, err := h.svc.List(r.Context())
+		if err != nil {
+			h.log.Error("failed to list API keys", "error", err)
+			jsonError(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		i := slices.IndexFunc(keys, func(k *APIKey) bool { return k.ID.Hex() == id })
+		if i < 0 || !canManage(r.Context(), keys[i]) {
+			jsonError(w, "API key not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	err := h.svc.Revoke(r.Context(), id)
+	if errors.Is(err, ErrKeyNotFound) {
+		jsonError(w, "API key not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.log.Error("failed to revoke API key", "error", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- HTMX Web UI Handlers ---
+
+// LoginPage handles GET /login
+func (h *Handler) LoginPage(w http.ResponseWriter, r *http.Request) {
+	pages.LoginPage("", safeNext(r.URL.Query().Get("next"))).Render(r.Context(), w)
+}
+
+// Login handles POST /login, storing the key in a cookie for the web UI
+func (h *Handler) Login(w http.ResponseWriter, r *http.Request) {
+	next := safeNext(r.FormValue("next"))
+
+	key, err := h.svc.Authenticate(r.Context(), strings.TrimSpace(r.FormValue("key")))
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		h.log.Error("failed to authenticate API key", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if err != nil || !key.HasScope(ScopeRead) {
+		msg := "Unknown or revoked API key."
+		if err == nil {
+			msg = "This API key lacks the read scope."
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		pages.LoginPage(msg, next).Render(r.Context(), w)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    strings.TrimSpace(r.FormValue("key")),
+		Path:     "/",
+		MaxAge:   int(loginCookieMaxAge.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// Logout handles GET /logout
+func (h *Handler) Logout(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, workspace.Path(r.Context(), "/login"), http.StatusSeeOther)
+}
+
+// canManage reports whether the caller may see and revoke k: keys bound to
+// a workspace only manage keys bound to the same one, and keys limited to
+// some categories only manage keys limited to those or fewer
+func canManage(ctx context.Context, k *APIKey) bool {
+	caller, ok := FromContext(ctx)
+	if !ok {
+		return true
+	}
+	return (caller.Workspace == "" || caller.Workspace == k.Workspace) && withinCategories(caller, k.Categories)
+}
+
+// withinCategories reports whether categories grant no more than the
+// caller's own; an empty list means every category
+func withinCategories(caller *APIKey, categories []string) bool {
+	if len(caller.Categories) == 0 {
+		return true
+	}
+	own := normalizeCategories(caller.Categories)
+	requested := normalizeCategories(categories)
+	return len(requested) > 0 && !slices.ContainsFunc(requested, func(c string) bool {
+		return !slices.Contains(own, c)
+	})
+}
+
+// safeNext keeps post-login redirects on this site
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+func jsonResponse(w http.ResponseWriter, data any, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
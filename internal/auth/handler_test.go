@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestKeyManagementCategories(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(NewMemoryKeyRepo())
+	h := NewHandler(svc, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	mintWith := func(name string, categories ...string) *APIKey {
+		minted, err := svc.Mint(ctx, MintKeyInput{Name: name, Scopes: []Scope{ScopeAdmin}, Categories: categories})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return minted.APIKey
+	}
+	caller := mintWith("caller", "work", "ideas")
+	mintWith("unrestricted")
+	mintWith("narrower", "Work")
+	mintWith("wider", "work", "private")
+	other := mintWith("other", "private")
+
+	req := func(method, target, body string) *http.Request {
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		return r.WithContext(WithKey(r.Context(), caller))
+	}
+
+	w := httptest.NewRecorder()
+	h.ListKeys(w, req(http.MethodGet, "/api/keys", ""))
+	var listed []*APIKey
+	if err := json.NewDecoder(w.Body).Decode(&listed); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, k := range listed {
+		names = append(names, k.Name)
+	}
+	slices.Sort(names)
+	if want := []string{"caller", "narrower"}; !slices.Equal(names, want) {
+		t.Errorf("listed %v, want %v", names, want)
+	}
+
+	r := req(http.MethodDelete, "/api/keys/"+other.ID.Hex(), "")
+	r.SetPathValue("id", other.ID.Hex())
+	w = httptest.NewRecorder()
+	h.RevokeKey(w, r)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("revoke a key for other categories = %d, want %d", w.Code, http.StatusNotFound)
+	}
+
+	tests := []struct {
+		body string
+		want int
+	}{
+		{`{"name":"n","scopes":["read"],"categories":["ideas"]}`, http.StatusCreated},
+		{`{"name":"n","scopes":["read"]}`, http.StatusForbidden},
+		{`{"name":"n","scopes":["read"],"categories":["ideas","private"]}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		h.CreateKey(w, req(http.MethodPost, "/api/keys", tt.body))
+		if w.Code != tt.want {
+			t.Errorf("create %s = %d, want %d", tt.body, w.Code, tt.want)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryKeyRepo is a KeyStore kept in process memory, paired with
+// notes.MemoryRepo; keys do not survive a restart.
+type MemoryKeyRepo struct {
+	mu   sync.RWMutex
+	keys map[primitive.ObjectID]*APIKey
+}
+
+func NewMemoryKeyRepo() *MemoryKeyRepo {
+	return &MemoryKeyRepo{keys: make(map[primitive.ObjectID]*APIKey)}
+}
+
+// EnsureIndexes is a no-op for the in-memory store
+func (r *MemoryKeyRepo) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+// Insert stores a new key
+func (r *MemoryKeyRepo) Insert(ctx context.Context, k *APIKey) error {
+	k.ID = primitive.NewObjectID()
+	k.CreatedAt = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.keys[k.ID] = cloneKey(k)
+	return nil
+}
+
+// FindByHash retrieves a key by the hash of its secret
+func (r *MemoryKeyRepo) FindByHash(ctx context.Context, hash string) (*APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, k := range r.keys {
+		if k.Hash == hash {
+			return cloneKey(k), nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+// List returns all keys, newest first
+func (r *MemoryKeyRepo) List(ctx context.Context) ([]*APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]*APIKey, 0, len(r.keys))
+	for _, k := range r.keys {
+		keys = append(keys, cloneKey(k))
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// Revoke marks an active key revoked
+func (r *MemoryKeyRepo) Revoke(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	k, ok := r.keys[id]
+	if !ok || k.RevokedAt != nil {
+		return ErrKeyNotFound
+	}
+	now := time.Now()
+	k.RevokedAt = &now
+	return nil
+}
+
+func cloneKey(k *APIKey) *APIKey {
+	c := *k
+	c.Scopes = slices.Clone(k.Scopes)
+	c.Categories = slices.Clone(k.Categories)
+	if k.RevokedAt != nil {
+		t := *k.RevokedAt
+		c.RevokedAt = &t
+	}
+	return &c
+}
@@ -0,0 +1,191 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"scratchpad/internal/notes"
+	"scratchpad/internal/workspace"
+)
+
+const (
+	// CookieName holds the key for browser sessions started at /login
+	CookieName = "scratchpad_key"
+	// HeaderAPIKey is an alternative to "Authorization: Bearer <key>"
+	HeaderAPIKey = "X-API-Key"
+)
+
+type keyContextKey struct{}
+
+// WithKey attaches the authenticated key to ctx
+func WithKey(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, keyContextKey{}, key)
+}
+
+// FromContext returns the key the request authenticated with. ok is false
+// for anonymous requests, which only get through when auth is not required,
+// and for trusted transports such as MCP over stdio.
+func FromContext(ctx context.Context) (*APIKey, bool) {
+	key, ok := ctx.Value(keyContextKey{}).(*APIKey)
+	return key, ok
+}
+
+type anonymousContextKey struct{}
+
+// AnonymousScopes returns the scopes an anonymous request was let through
+// with. ok is false for requests that presented a key and for trusted
+// transports.
+func AnonymousScopes(ctx context.Context) (scopes []Scope, ok bool) {
+	scopes, ok = ctx.Value(anonymousContextKey{}).([]Scope)
+	return scopes, ok
+}
+
+// Middleware authenticates requests by API key and enforces scopes
+type Middleware struct {
+	svc      *Service
+	required bool
+	log      *slog.Logger
+
+	keysMinted atomic.Bool // set once a key has been seen to exist
+}
+
+// NewMiddleware returns a Middleware. When required is false, requests
+// without credentials may still read notes, and write and delete them
+// until the first key is minted; admin routes always need a key. Requests
+// that do present a key are held to its scopes and categories.
+func NewMiddleware(svc *Service, required bool, log *slog.Logger) *Middleware {
+	return &Middleware{svc: svc, required: required, log: log}
+}
+
+// Require wraps h so it only runs for callers whose key grants scope. The
+// key's category restrictions are applied to the request context, and a
+// key bound to a workspace selects it.
+func (m *Middleware) Require(scope Scope, h http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secret := credentials(r)
+		if secret == "" {
+			scopes, err := m.anonymousScopes(r.Context())
+			if err != nil {
+				m.log.Error("failed to check for API keys", "error", err)
+				httpError(w, r, "internal error", http.StatusInternalServerError)
+				return
+			}
+			if !slices.Contains(scopes, scope) {
+				unauthorized(w, r, "API key required")
+				return
+			}
+			h.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), anonymousContextKey{}, scopes)))
+			return
+		}
+
+		key, err := m.svc.Authenticate(r.Context(), secret)
+		if errors.Is(err, ErrKeyNotFound) {
+			unauthorized(w, r, "invalid or revoked API key")
+			return
+		}
+		if err != nil {
+			m.log.Error("failed to authenticate API key", "error", err)
+			httpError(w, r, "internal error", http.StatusInternalServerError)
+			return
+		}
+		if !key.HasScope(scope) {
+			httpError(w, r, "API key lacks the "+string(scope)+" scope", http.StatusForbidden)
+			return
+		}
+
+		ctx := WithKey(r.Context(), key)
+		ctx = notes.WithCategoryAccess(ctx, key.Categories)
+		if key.Workspace != "" {
+			if ws, ok := workspace.Selected(ctx); ok && ws != key.Workspace {
+				httpError(w, r, "API key is limited to workspace "+key.Workspace, http.StatusForbidden)
+				return
+			}
+			ctx = workspace.With(ctx, key.Workspace)
+		}
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// anonymousScopes returns what a request without a key may do: nothing
+// when auth is required, otherwise read, write and delete until the first
+// key is minted and only read after that. Admin is never granted, so a
+// key can't be minted by someone who doesn't already hold one.
+func (m *Middleware) anonymousScopes(ctx context.Context) ([]Scope, error) {
+	if m.required {
+		return nil, nil
+	}
+	readOnly := []Scope{ScopeRead}
+	if m.keysMinted.Load() {
+		return readOnly, nil
+	}
+	keys, err := m.svc.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) > 0 {
+		m.keysMinted.Store(true)
+		return readOnly, nil
+	}
+	return []Scope{ScopeRead, ScopeWrite, ScopeDelete}, nil
+}
+
+// credentials extracts the API key from the Authorization or X-API-Key
+// header, or, for safe methods only, the login cookie. Keeping the cookie
+// away from writes means a browser session can't be used for cross-site
+// requests that change notes.
+func credentials(r *http.Request) string {
+	if v, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(v)
+	}
+	if v := r.Header.Get(HeaderAPIKey); v != "" {
+		return strings.TrimSpace(v)
+	}
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		if c, err := r.Cookie(CookieName); err == nil {
+			return c.Value
+		}
+	}
+	return ""
+}
+
+// isAPIRequest reports whether r expects JSON rather than HTML
+func isAPIRequest(r *http.Request) bool {
+	return strings.HasPrefix(r.URL.Path, "/api/") || r.URL.Path == "/mcp"
+}
+
+// unauthorized answers API calls with a JSON 401, sends HTMX requests to
+// the login page via HX-Redirect and redirects page loads there directly
+func unauthorized(w http.ResponseWriter, r *http.Request, msg string) {
+	switch {
+	case isAPIRequest(r):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="scratchpad"`)
+		jsonError(w, msg, http.StatusUnauthorized)
+	case r.Header.Get("HX-Request") == "true":
+		w.Header().Set("HX-Redirect", workspace.Path(r.Context(), "/login"))
+		http.Error(w, msg, http.StatusUnauthorized)
+	default:
+		next := workspace.Path(r.Context(), r.URL.RequestURI())
+		http.Redirect(w, r, workspace.Path(r.Context(), "/login")+"?next="+url.QueryEscape(next), http.StatusSeeOther)
+	}
+}
+
+func httpError(w http.ResponseWriter, r *http.Request, msg string, status int) {
+	if isAPIRequest(r) {
+		jsonError(w, msg, status)
+		return
+	}
+	http.Error(w, msg, status)
+}
+
+func jsonError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"scratchpad/internal/workspace"
+)
+
+func newTestMiddleware(t *testing.T, required bool) (*Middleware, *Service) {
+	t.Helper()
+	svc := NewService(NewMemoryKeyRepo())
+	return NewMiddleware(svc, required, slog.New(slog.NewTextHandler(io.Discard, nil))), svc
+}
+
+func mint(t *testing.T, svc *Service, scopes ...Scope) string {
+	t.Helper()
+	minted, err := svc.Mint(context.Background(), MintKeyInput{Name: "test", Scopes: scopes})
+	if err != nil {
+		t.Fatalf("mint key: %v", err)
+	}
+	return minted.Secret
+}
+
+// serve runs one request to an API route requiring scope and returns the
+// status code
+func serve(m *Middleware, scope Scope, key string) int {
+	h := m.Require(scope, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	r := httptest.NewRequest(http.MethodPost, "/api/notes", nil)
+	if key != "" {
+		r.Header.Set("Authorization", "Bearer "+key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestRequireWithoutKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		required bool
+		minted   bool
+		scope    Scope
+		want     int
+	}{
+		{"required, read", true, false, ScopeRead, http.StatusUnauthorized},
+		{"required, write", true, false, ScopeWrite, http.StatusUnauthorized},
+		{"optional, no keys, read", false, false, ScopeRead, http.StatusNoContent},
+		{"optional, no keys, write", false, false, ScopeWrite, http.StatusNoContent},
+		{"optional, no keys, delete", false, false, ScopeDelete, http.StatusNoContent},
+		{"optional, no keys, admin", false, false, ScopeAdmin, http.StatusUnauthorized},
+		{"optional, keys, read", false, true, ScopeRead, http.StatusNoContent},
+		{"optional, keys, write", false, true, ScopeWrite, http.StatusUnauthorized},
+		{"optional, keys, delete", false, true, ScopeDelete, http.StatusUnauthorized},
+		{"optional, keys, admin", false, true, ScopeAdmin, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, svc := newTestMiddleware(t, tt.required)
+			if tt.minted {
+				mint(t, svc, ScopeRead)
+			}
+			if got := serve(m, tt.scope, ""); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireClosesWritesOnceKeyMinted(t *testing.T) {
+	m, svc := newTestMiddleware(t, false)
+	if got := serve(m, ScopeWrite, ""); got != http.StatusNoContent {
+		t.Fatalf("write before any key: status %d, want %d", got, http.StatusNoContent)
+	}
+	mint(t, svc, ScopeAdmin)
+	if got := serve(m, ScopeWrite, ""); got != http.StatusUnauthorized {
+		t.Errorf("write after minting: status %d, want %d", got, http.StatusUnauthorized)
+	}
+}
+
+func TestRequireWithKey(t *testing.T) {
+	m, svc := newTestMiddleware(t, true)
+	reader := mint(t, svc, ScopeRead)
+	admin := mint(t, svc, ScopeAdmin)
+
+	tests := []struct {
+		name  string
+		key   string
+		scope Scope
+		want  int
+	}{
+		{"reader reads", reader, ScopeRead, http.StatusNoContent},
+		{"reader writes", reader, ScopeWrite, http.StatusForbidden},
+		{"admin writes", admin, ScopeWrite, http.StatusNoContent},
+		{"admin administers", admin, ScopeAdmin, http.StatusNoContent},
+		{"unknown key", "sp_nope", ScopeRead, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := serve(m, tt.scope, tt.key); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireKeyWorkspace(t *testing.T) {
+	m, svc := newTestMiddleware(t, true)
+	minted, err := svc.Mint(context.Background(), MintKeyInput{Name: "team", Scopes: []Scope{ScopeRead}, Workspace: "Team"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		selected string
+		want     int
+	}{
+		{"no selection", "", http.StatusNoContent},
+		{"own workspace", "team", http.StatusNoContent},
+		{"other workspace", "other", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := m.Require(ScopeRead, func(w http.ResponseWriter, r *http.Request) {
+				got = workspace.FromContext(r.Context())
+				w.WriteHeader(http.StatusNoContent)
+			})
+			r := httptest.NewRequest(http.MethodGet, "/api/notes", nil)
+			r.Header.Set("Authorization", "Bearer "+minted.Secret)
+			if tt.selected != "" {
+				r = r.WithContext(workspace.With(r.Context(), tt.selected))
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if tt.want == http.StatusNoContent && got != "team" {
+				t.Errorf("handler ran in workspace %q, want team", got)
+			}
+		})
+	}
+}
+
+func TestAnonymousScopesInContext(t *testing.T) {
+	m, svc := newTestMiddleware(t, false)
+	var got []Scope
+	h := m.Require(ScopeRead, func(w http.ResponseWriter, r *http.Request) {
+		got, _ = AnonymousScopes(r.Context())
+	})
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/mcp", nil))
+	if want := []Scope{ScopeRead, ScopeWrite, ScopeDelete}; !slices.Equal(got, want) {
+		t.Errorf("before any key: scopes %v, want %v", got, want)
+	}
+	mint(t, svc, ScopeRead)
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/mcp", nil))
+	if want := []Scope{ScopeRead}; !slices.Equal(got, want) {
+		t.Errorf("after minting: scopes %v, want %v", got, want)
+	}
+}
+
+func TestMintNormalizesCategories(t *testing.T) {
+	svc := NewService(NewMemoryKeyRepo())
+	minted, err := svc.Mint(context.Background(), MintKeyInput{
+		Name:       "analytics",
+		Scopes:     []Scope{ScopeRead},
+		Categories: []string{"Twitter Analytics", " twitter-analytics ", "", "Work"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"twitter-analytics", "work"}; !slices.Equal(minted.Categories, want) {
+		t.Errorf("categories = %q, want %q", minted.Categories, want)
+	}
+}
@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// KeyRepo is a KeyStore backed by the api_keys collection
+type KeyRepo struct {
+	coll *mongo.Collection
+}
+
+func NewKeyRepo(db *mongo.Database) *KeyRepo {
+	return &KeyRepo{coll: db.Collection("api_keys")}
+}
+
+// EnsureIndexes creates the unique index used to look keys up by hash
+func (r *KeyRepo) EnsureIndexes(ctx context.Context) error {
+	_, err := r.coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "hash", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("create api key indexes: %w", err)
+	}
+	return nil
+}
+
+// Insert stores a new key
+func (r *KeyRepo) Insert(ctx context.Context, k *APIKey) error {
+	k.ID = primitive.NewObjectID()
+	k.CreatedAt = time.Now()
+
+	if _, err := r.coll.InsertOne(ctx, k); err != nil {
+		return fmt.Errorf("insert api key: %w", err)
+	}
+	return nil
+}
+
+// FindByHash retrieves a key by the hash of its secret
+func (r *KeyRepo) FindByHash(ctx context.Context, hash string) (*APIKey, error) {
+	var k APIKey
+	err := r.coll.FindOne(ctx, bson.M{"hash": hash}).Decode(&k)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find api key: %w", err)
+	}
+	return &k, nil
+}
+
+// List returns all keys, newest first
+func (r *KeyRepo) List(ctx context.Context) ([]*APIKey, error) {
+	cursor, err := r.coll.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var keys []*APIKey
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, fmt.Errorf("decode api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// Revoke marks an active key revoked
+func (r *KeyRepo) Revoke(ctx context.Context, id primitive.ObjectID) error {
+	res, err := r.coll.UpdateOne(ctx,
+		bson.M{"_id": id, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	if err != nil {
+		return fmt.Errorf("revoke api key %s: %w", id.Hex(), err)
+	}
+	if res.MatchedCount == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
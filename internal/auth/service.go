@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"scratchpad/internal/notes"
+	"scratchpad/internal/workspace"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// secretPrefix marks scratchpad keys so they are easy to spot in configs
+	// and secret scanners
+	secretPrefix = "sp_"
+	secretBytes  = 24
+	// displayPrefixLen is how much of the secret is kept in the clear
+	displayPrefixLen = len(secretPrefix) + 8
+)
+
+type Service struct {
+	store KeyStore
+}
+
+func NewService(store KeyStore) *Service {
+	return &Service{store: store}
+}
+
+// Mint creates a key and returns it with its secret, which is not stored
+// and cannot be recovered later
+func (s *Service) Mint(ctx context.Context, input MintKeyInput) (*MintedKey, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidInput)
+	}
+	if len(input.Scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required", ErrInvalidInput)
+	}
+	var scopes []Scope
+	for _, scope := range input.Scopes {
+		if !slices.Contains(AllScopes, scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidInput, scope)
+		}
+		if !slices.Contains(scopes, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	categories := normalizeCategories(input.Categories)
+
+	var ws string
+	if input.Workspace != "" {
+		var err error
+		if ws, err = workspace.Normalize(input.Workspace); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidInput, err)
+		}
+	}
+
+	raw := make([]byte, secretBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	secret := secretPrefix + hex.EncodeToString(raw)
+
+	key := &APIKey{
+		Name:       name,
+		Prefix:     secret[:displayPrefixLen],
+		Hash:       hashSecret(secret),
+		Scopes:     scopes,
+		Categories: categories,
+		Workspace:  ws,
+	}
+	if err := s.store.Insert(ctx, key); err != nil {
+		return nil, err
+	}
+	return &MintedKey{APIKey: key, Secret: secret}, nil
+}
+
+// normalizeCategories puts categories in the form notes are stored and
+// matched under, dropping empties and duplicates
+func normalizeCategories(categories []string) []string {
+	var normalized []string
+	for _, c := range categories {
+		if c = notes.NormalizeCategory(c); c != "" && !slices.Contains(normalized, c) {
+			normalized = append(normalized, c)
+		}
+	}
+	return normalized
+}
+
+// Authenticate resolves a secret to its key. Unknown and revoked keys both
+// yield ErrKeyNotFound.
+func (s *Service) Authenticate(ctx context.Context, secret string) (*APIKey, error) {
+	if !strings.HasPrefix(secret, secretPrefix) {
+		return nil, ErrKeyNotFound
+	}
+	key, err := s.store.FindByHash(ctx, hashSecret(secret))
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// List returns every key, revoked ones included, newest first
+func (s *Service) List(ctx context.Context) ([]*APIKey, error) {
+	return s.store.List(ctx)
+}
+
+// Revoke disables a key; requests using it are rejected from then on
+func (s *Service) Revoke(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrKeyNotFound
+	}
+	return s.store.Revoke(ctx, oid)
+}
+
+// ParseScopes parses a comma-separated scope list such as "read,write"
+func ParseScopes(s string) ([]Scope, error) {
+	var scopes []Scope
+	for _, part := range strings.Split(s, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+		scope := Scope(part)
+		if !slices.Contains(AllScopes, scope) {
+			return nil, fmt.Errorf("%w: unknown scope %q", ErrInvalidInput, part)
+		}
+		scopes = append(scopes, scope)
+	}
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: no scopes given", ErrInvalidInput)
+	}
+	return scopes, nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The notes store owns PRAGMA user_version for the shared database, so the
+// keys table is created idempotently instead of through a migration.
+const sqliteKeySchema = `CREATE TABLE IF NOT EXISTS api_keys (
+	id         TEXT    PRIMARY KEY,
+	name       TEXT    NOT NULL,
+	prefix     TEXT    NOT NULL,
+	hash       TEXT    NOT NULL UNIQUE,
+	scopes     TEXT    NOT NULL,
+	categories TEXT    NOT NULL DEFAULT '[]',
+	created_at INTEGER NOT NULL,
+	revoked_at INTEGER
+);`
+
+// sqliteKeyColumnAdds bring tables created by older versions up to date
+var sqliteKeyColumnAdds = map[string]string{
+	"workspace": "ALTER TABLE api_keys ADD COLUMN workspace TEXT NOT NULL DEFAULT ''",
+}
+
+const sqliteKeyColumns = "id, name, prefix, hash, scopes, categories, workspace, created_at, revoked_at"
+
+// SQLiteKeyRepo is a KeyStore kept in the same SQLite database as the notes
+type SQLiteKeyRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteKeyRepo(db *sql.DB) *SQLiteKeyRepo {
+	return &SQLiteKeyRepo{db: db}
+}
+
+// EnsureIndexes creates the api_keys table
+func (r *SQLiteKeyRepo) EnsureIndexes(ctx context.Context) error {
+	if _, err := r.db.ExecContext(ctx, sqliteKeySchema); err != nil {
+		return fmt.Errorf("create api_keys table: %w", err)
+	}
+	for column, stmt := range sqliteKeyColumnAdds {
+		var exists int
+		err := r.db.QueryRowContext(ctx,
+			"SELECT COUNT(*) FROM pragma_table_info('api_keys') WHERE name = ?", column).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("inspect api_keys table: %w", err)
+		}
+		if exists == 0 {
+			if _, err := r.db.ExecContext(ctx, stmt); err != nil {
+				return fmt.Errorf("add api_keys.%s: %w", column, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Insert stores a new key
+func (r *SQLiteKeyRepo) Insert(ctx context.Context, k *APIKey) error {
+	k.ID = primitive.NewObjectID()
+	k.CreatedAt = time.Now()
+
+	scopes, _ := json.Marshal(k.Scopes)
+	categories, _ := json.Marshal(k.Categories)
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO api_keys (id, name, prefix, hash, scopes, categories, workspace, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		k.ID.Hex(), k.Name, k.Prefix, k.Hash, string(scopes), string(categories), k.Workspace, k.CreatedAt.UnixMilli(),
+	)
+	if err != nil {
+		return fmt.Errorf("insert api key: %w", err)
+	}
+	return nil
+}
+
+// FindByHash retrieves a key by the hash of its secret
+func (r *SQLiteKeyRepo) FindByHash(ctx context.Context, hash string) (*APIKey, error) {
+	row := r.db.QueryRowContext(ctx, "SELECT "+sqliteKeyColumns+" FROM api_keys WHERE hash = ?", hash)
+
+	k, err := scanSQLiteKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find api key: %w", err)
+	}
+	return k, nil
+}
+
+// List returns all keys, newest first
+func (r *SQLiteKeyRepo) List(ctx context.Context) ([]*APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+sqliteKeyColumns+" FROM api_keys ORDER BY created_at DESC")
+	if err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []*APIKey
+	for rows.Next() {
+		k, err := scanSQLiteKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("decode api key: %w", err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
+
+// Revoke marks an active key revoked
+func (r *SQLiteKeyRepo) Revoke(ctx context.Context, id primitive.ObjectID) error {
+	res, err := r.db.ExecContext(ctx,
+		"UPDATE api_keys SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL",
+		time.Now().UnixMilli(), id.Hex(),
+	)
+	if err != nil {
+		return fmt.Errorf("revoke api key %s: %w", id.Hex(), err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrKeyNotFound
+	}
+	return nil
+}
+
+type sqliteScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSQLiteKey(s sqliteScanner) (*APIKey, error) {
+	var k APIKey
+	var id, scopes, categories string
+	var createdAt int64
+	var revokedAt sql.NullInt64
+	if err := s.Scan(&id, &k.Name, &k.Prefix, &k.Hash, &scopes, &categories, &k.Workspace, &createdAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(scopes), &k.Scopes); err != nil {
+		return nil, fmt.Errorf("decode api key scopes: %w", err)
+	}
+	if err := json.Unmarshal([]byte(categories), &k.Categories); err != nil {
+		return nil, fmt.Errorf("decode api key categories: %w", err)
+	}
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("decode api key id %q: %w", id, err)
+	}
+	k.ID = oid
+	k.CreatedAt = time.UnixMilli(createdAt).UTC()
+	if revokedAt.Valid {
+		t := time.UnixMilli(revokedAt.Int64).UTC()
+		k.RevokedAt = &t
+	}
+	return &k, nil
+}
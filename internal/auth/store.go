@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrKeyNotFound  = errors.New("API key not found")
+	ErrInvalidInput = errors.New("invalid input")
+)
+
+// KeyStore persists API keys. KeyRepo (MongoDB), SQLiteKeyRepo and
+// MemoryKeyRepo implement it alongside the matching notes stores.
+type KeyStore interface {
+	// EnsureIndexes prepares the underlying storage (indexes, tables)
+	EnsureIndexes(ctx context.Context) error
+	// Insert assigns ID and CreatedAt and stores a new key
+	Insert(ctx context.Context, k *APIKey) error
+	// FindByHash returns ErrKeyNotFound when no key, revoked or not, has the hash
+	FindByHash(ctx context.Context, hash string) (*APIKey, error)
+	// List returns all keys, newest first
+	List(ctx context.Context) ([]*APIKey, error)
+	// Revoke marks a key revoked, returning ErrKeyNotFound when no active
+	// key has the given ID
+	Revoke(ctx context.Context, id primitive.ObjectID) error
+}
+
+var (
+	_ KeyStore = (*KeyRepo)(nil)
+	_ KeyStore = (*SQLiteKeyRepo)(nil)
+	_ KeyStore = (*MemoryKeyRepo)(nil)
+)
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"slices"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Scope is a permission granted to an API key
+type Scope string
+
+const (
+	ScopeRead   Scope = "read"   // list, search and read notes (REST, web UI, MCP)
+	ScopeWrite  Scope = "write"  // create, edit and restore notes
+	ScopeDelete Scope = "delete" // move notes to the trash
+	ScopeAdmin  Scope = "admin"  // everything, plus managing API keys
+)
+
+// AllScopes lists the valid scopes in order of increasing power
+var AllScopes = []Scope{ScopeRead, ScopeWrite, ScopeDelete, ScopeAdmin}
+
+// APIKey is a stored API key. Only a hash of the secret is kept; the secret
+// itself is shown once, when the key is minted.
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Name       string             `bson:"name" json:"name"`
+	Prefix     string             `bson:"prefix" json:"prefix"` // start of the secret, to tell keys apart
+	Hash       string             `bson:"hash" json:"-"`
+	Scopes     []Scope            `bson:"scopes" json:"scopes"`
+	Categories []string           `bson:"categories,omitempty" json:"categories,omitempty"` // empty means all categories
+	Workspace  string             `bson:"workspace,omitempty" json:"workspace,omitempty"`   // empty means any workspace
+	CreatedAt  time.Time          `bson:"created_at" json:"createdAt"`
+	RevokedAt  *time.Time         `bson:"revoked_at,omitempty" json:"revokedAt,omitempty"`
+}
+
+// HasScope reports whether the key grants scope; admin grants everything
+func (k *APIKey) HasScope(scope Scope) bool {
+	return slices.Contains(k.Scopes, ScopeAdmin) || slices.Contains(k.Scopes, scope)
+}
+
+// MintKeyInput is the input for minting a new API key
+type MintKeyInput struct {
+	Name       string   `json:"name"`
+	Scopes     []Scope  `json:"scopes"`
+	Categories []string `json:"categories,omitempty"`
+	Workspace  string   `json:"workspace,omitempty"`
+}
+
+// MintedKey is a freshly minted key together with its secret
+type MintedKey struct {
+	*APIKey
+	Secret string `json:"secret"`
+}
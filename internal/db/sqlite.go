@@ -0,0 +1,33 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	_ "modernc.org/sqlite" // pure-Go driver, registers "sqlite"
+)
+
+// OpenSQLite opens (or creates) a SQLite database file with WAL journaling
+// and a busy timeout so concurrent HTTP and MCP requests don't trip over
+// each other's write locks.
+func OpenSQLite(ctx context.Context, path string) (*sql.DB, error) {
+	// Escaping the path keeps a "?", "#" or "%" in it part of the file name.
+	// The DSN is built by hand because url.URL would render a relative path
+	// as file://./name, and SQLite reads the "." as a URI authority.
+	dsn := "file:" + (&url.URL{Path: path}).EscapedPath() +
+		"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)"
+
+	database, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+
+	if err := database.PingContext(ctx); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("ping sqlite: %w", err)
+	}
+
+	return database, nil
+}
@@ -0,0 +1,44 @@
+package db
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenSQLite(t *testing.T) {
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	tests := []struct {
+		name, path, file string
+	}{
+		{"relative", "./scratchpad.db", "scratchpad.db"},
+		{"bare name", "notes.db", "notes.db"},
+		{"absolute", filepath.Join(dir, "abs.db"), "abs.db"},
+		{"reserved characters", "./what?#100%.db", "what?#100%.db"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			database, err := OpenSQLite(context.Background(), tt.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer database.Close()
+			if _, err := database.Exec("CREATE TABLE t (x INTEGER)"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, tt.file)); err != nil {
+				t.Errorf("database file: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"scratchpad/internal/notes"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	// maxPromptNotes caps how many notes a prompt embeds; it matches the
+	// store's maximum page size
+	maxPromptNotes = 200
+
+	maxCompareNotes = 10
+)
+
+// addPrompts registers the canned workflows. Each prompt expands into a
+// single user message holding the instructions followed by the notes they
+// apply to, fetched when the prompt is requested.
+func addPrompts(s *server.MCPServer, svc *notes.Service) {
+	// Prompt: summarize_category - Summarize a category, optionally since a date
+	s.AddPrompt(
+		mcp.NewPrompt("summarize_category",
+			mcp.WithPromptDescription("Summarize the notes in a category, optionally only those written since a date"),
+			mcp.WithArgument("category",
+				mcp.RequiredArgument(),
+				mcp.ArgumentDescription("Category name (e.g., 'twitter-analytics')"),
+			),
+			mcp.WithArgument("since",
+				mcp.ArgumentDescription("Only include notes created after this date (YYYY-MM-DD or RFC3339)"),
+			),
+		),
+		handleSummarizeCategory(svc),
+	)
+
+	// Prompt: weekly_digest - Digest of recent notes across categories
+	s.AddPrompt(
+		mcp.NewPrompt("weekly_digest",
+			mcp.WithPromptDescription("Write a digest of everything added to the scratchpad over the past week, grouped by category"),
+			mcp.WithArgument("since",
+				mcp.ArgumentDescription("Start of the digest period (YYYY-MM-DD or RFC3339, default: 7 days ago)"),
+			),
+			mcp.WithArgument("category",
+				mcp.ArgumentDescription("Limit the digest to one category"),
+			),
+		),
+		handleWeeklyDigest(svc),
+	)
+
+	// Prompt: compare_notes - Compare a handful of notes
+	s.AddPrompt(
+		mcp.NewPrompt("compare_notes",
+			mcp.WithPromptDescription("Compare two or more notes, pointing out agreements, contradictions and gaps"),
+			mcp.WithArgument("ids",
+				mcp.RequiredArgument(),
+				mcp.ArgumentDescription(fmt.Sprintf("Note IDs separated by commas or spaces (2 to %d)", maxCompareNotes)),
+			),
+		),
+		handleCompareNotes(svc),
+	)
+
+	// Prompt: rank_backlog - Turn a category of ideas into a ranked backlog
+	s.AddPrompt(
+		mcp.NewPrompt("rank_backlog",
+			mcp.WithPromptDescription("Turn the notes in a category (e.g. content-ideas) into a deduplicated, ranked backlog"),
+			mcp.WithArgument("category",
+				mcp.RequiredArgument(),
+				mcp.ArgumentDescription("Category holding the ideas"),
+			),
+			mcp.WithArgument("criteria",
+				mcp.ArgumentDescription("What to rank by (default: expected impact versus effort)"),
+			),
+		),
+		handleRankBacklog(svc),
+	)
+}
+
+func handleSummarizeCategory(svc *notes.Service) server.PromptHandlerFunc {
+	return func(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		category := strings.TrimSpace(req.Params.Arguments["category"])
+		if category == "" {
+			return nil, fmt.Errorf("category is required")
+		}
+
+		q := notes.SearchQuery{Category: category, Limit: maxPromptNotes}
+		scope := fmt.Sprintf("the %q category", category)
+		if since := req.Params.Arguments["since"]; since != "" {
+			t, err := parseDate(since)
+			if err != nil {
+				return nil, fmt.Errorf("invalid 'since' date format: %v", err)
+			}
+			q.Since = &t
+			scope += " since " + t.Format("Jan 2, 2006")
+		}
+
+		result, err := svc.Search(ctx, q)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get notes: %w", err)
+		}
+
+		instructions := "Summarize the notes below from " + scope + ". " +
+			"Lead with the key takeaways, then cover recurring themes, notable data points and open questions. " +
+			"Cite note IDs for specific claims."
+		return notesPrompt("Summary of "+scope, instructions, result.Notes(), false), nil
+	}
+}
+
+func handleWeeklyDigest(svc *notes.Service) server.PromptHandlerFunc {
+	return func(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		since := time.Now().AddDate(0, 0, -7)
+		if s := req.Params.Arguments["since"]; s != "" {
+			t, err := parseDate(s)
+			if err != nil {
+				return nil, fmt.Errorf("invalid 'since' date format: %v", err)
+			}
+			since = t
+		}
+
+		q := notes.SearchQuery{
+			Category: strings.TrimSpace(req.Params.Arguments["category"]),
+			Since:    &since,
+			Limit:    maxPromptNotes,
+		}
+		result, err := svc.Search(ctx, q)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get notes: %w", err)
+		}
+
+		instructions := "Write a digest of the notes below, added since " + since.Format("Mon Jan 2, 2006") + ". " +
+			"Group it by category; for each, give a short overview and the highlights worth revisiting. " +
+			"Finish with follow-ups or decisions the notes call for. Cite note IDs."
+		return notesPrompt("Digest since "+since.Format("Jan 2, 2006"), instructions, result.Notes(), true), nil
+	}
+}
+
+func handleCompareNotes(svc *notes.Service) server.PromptHandlerFunc {
+	return func(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		ids := strings.FieldsFunc(req.Params.Arguments["ids"], func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})
+		if len(ids) < 2 || len(ids) > maxCompareNotes {
+			return nil, fmt.Errorf("ids must list between 2 and %d note IDs", maxCompareNotes)
+		}
+
+		noteList := make([]*notes.Note, 0, len(ids))
+		for _, id := range ids {
+			note, err := svc.GetByID(ctx, id)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get note %s: %w", id, err)
+			}
+			noteList = append(noteList, note)
+		}
+
+		instructions := "Compare the notes below. Identify where they agree, where they contradict each other, " +
+			"and what one covers that the others miss. Close with a reconciled view and cite note IDs throughout."
+		return notesPrompt(fmt.Sprintf("Comparison of %d notes", len(noteList)), instructions, noteList, true), nil
+	}
+}
+
+func handleRankBacklog(svc *notes.Service) server.PromptHandlerFunc {
+	return func(ctx context.Context, req mcp.GetPromptRequest) (*mcp.GetPromptResult, error) {
+		category := strings.TrimSpace(req.Params.Arguments["category"])
+		if category == "" {
+			return nil, fmt.Errorf("category is required")
+		}
+		criteria := strings.TrimSpace(req.Params.Arguments["criteria"])
+		if criteria == "" {
+			criteria = "expected impact versus effort"
+		}
+
+		noteList, err := svc.List(ctx, notes.ListQuery{Category: category, Limit: maxPromptNotes})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get notes: %w", err)
+		}
+
+		instructions := fmt.Sprintf("Turn the notes below from the %q category into a ranked backlog. ", category) +
+			"Merge duplicates, give each item a one-line title and a short rationale, and rank by " + criteria + ". " +
+			"Present it as a numbered list and cite the note IDs each item came from."
+		return notesPrompt("Backlog from "+category, instructions, noteList, false), nil
+	}
+}
+
+// notesPrompt builds a prompt result whose single user message carries the
+// instructions followed by the notes as markdown
+func notesPrompt(description, instructions string, noteList []*notes.Note, withCategory bool) *mcp.GetPromptResult {
+	var b strings.Builder
+	b.WriteString(instructions)
+	b.WriteString("\n\n")
+	switch {
+	case len(noteList) == 0:
+		b.WriteString("There are no matching notes; say so rather than inventing content.\n")
+	case len(noteList) == maxPromptNotes:
+		fmt.Fprintf(&b, "Only the newest %d matching notes are included.\n", maxPromptNotes)
+	}
+	writeNotes(&b, noteList, withCategory)
+
+	return mcp.NewGetPromptResult(description, []mcp.PromptMessage{
+		mcp.NewPromptMessage(mcp.RoleUser, mcp.NewTextContent(b.String())),
+	})
+}
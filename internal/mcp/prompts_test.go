@@ -0,0 +1,159 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"scratchpad/internal/notes"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// getPrompt runs a prompts/get request through the server, returning the
+// text of the prompt's single message or the error message it failed with
+func getPrompt(t *testing.T, s *Server, ctx context.Context, name string, args map[string]string) (text, errMsg string) {
+	t.Helper()
+	msg, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "prompts/get",
+		"params":  map[string]any{"name": name, "arguments": args},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch resp := s.HandleMessage(ctx, msg).(type) {
+	case mcp.JSONRPCError:
+		return "", resp.Error.Message
+	case mcp.JSONRPCResponse:
+		result, ok := resp.Result.(mcp.GetPromptResult)
+		if !ok || len(result.Messages) != 1 || result.Messages[0].Role != mcp.RoleUser {
+			t.Fatalf("%s: got result %#v, want one user message", name, resp.Result)
+		}
+		content, ok := mcp.AsTextContent(result.Messages[0].Content)
+		if !ok {
+			t.Fatalf("%s: got content %#v, want text", name, result.Messages[0].Content)
+		}
+		return content.Text, ""
+	default:
+		t.Fatalf("%s: got %#v", name, resp)
+		return "", ""
+	}
+}
+
+// importNote stores a note created at a given time
+func importNote(t *testing.T, svc *notes.Service, category, content string, createdAt time.Time) *notes.Note {
+	t.Helper()
+	n := &notes.Note{Category: category, Content: content, CreatedAt: createdAt}
+	if _, err := svc.ImportNote(context.Background(), n, notes.ImportNewID); err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestSummarizeCategoryPrompt(t *testing.T) {
+	s, svc := newTestServer(t)
+	ctx := context.Background()
+	importNote(t, svc, "ideas", "An old idea", time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC))
+	recent := importNote(t, svc, "ideas", "A recent idea", time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	createNote(t, svc, "work", "Unrelated work")
+
+	text, errMsg := getPrompt(t, s, ctx, "summarize_category", map[string]string{"category": "ideas"})
+	if !strings.HasPrefix(text, `Summarize the notes below from the "ideas" category.`) ||
+		!strings.Contains(text, "An old idea") || !strings.Contains(text, "A recent idea") || strings.Contains(text, "Unrelated work") {
+		t.Errorf("summary = %q (%s), want both ideas and nothing else", text, errMsg)
+	}
+
+	text, errMsg = getPrompt(t, s, ctx, "summarize_category", map[string]string{"category": "ideas", "since": "2024-02-01"})
+	if !strings.Contains(text, "since Feb 1, 2024") || strings.Contains(text, "An old idea") || !strings.Contains(text, recent.ID.Hex()) {
+		t.Errorf("summary since = %q (%s), want only the recent idea", text, errMsg)
+	}
+
+	text, _ = getPrompt(t, s, ctx, "summarize_category", map[string]string{"category": "empty"})
+	if !strings.Contains(text, "There are no matching notes") {
+		t.Errorf("empty summary = %q, want it to say there are no notes", text)
+	}
+
+	for _, args := range []map[string]string{
+		{},
+		{"category": "  "},
+		{"category": "ideas", "since": "last week"},
+	} {
+		if _, errMsg := getPrompt(t, s, ctx, "summarize_category", args); errMsg == "" {
+			t.Errorf("summarize_category %v succeeded, want an error", args)
+		}
+	}
+}
+
+func TestWeeklyDigestPrompt(t *testing.T) {
+	s, svc := newTestServer(t)
+	ctx := context.Background()
+	importNote(t, svc, "ideas", "A month-old idea", time.Now().AddDate(0, -1, 0))
+	createNote(t, svc, "ideas", "This week's idea")
+	createNote(t, svc, "work", "This week's work")
+
+	text, errMsg := getPrompt(t, s, ctx, "weekly_digest", nil)
+	for _, want := range []string{"This week's idea", "This week's work", "Category: ideas", "Category: work"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("digest = %q (%s), want it to contain %q", text, errMsg, want)
+		}
+	}
+	if strings.Contains(text, "A month-old idea") {
+		t.Errorf("digest = %q, want only the past week", text)
+	}
+
+	text, _ = getPrompt(t, s, ctx, "weekly_digest", map[string]string{"category": "work"})
+	if strings.Contains(text, "This week's idea") || !strings.Contains(text, "This week's work") {
+		t.Errorf("work digest = %q, want only work", text)
+	}
+
+	since := time.Now().AddDate(0, -2, 0).Format(time.RFC3339)
+	if text, _ := getPrompt(t, s, ctx, "weekly_digest", map[string]string{"since": since}); !strings.Contains(text, "A month-old idea") {
+		t.Errorf("digest since %s = %q, want the month-old idea", since, text)
+	}
+}
+
+func TestCompareNotesPrompt(t *testing.T) {
+	s, svc := newTestServer(t)
+	ctx := context.Background()
+	a := createNote(t, svc, "ideas", "Tabs are better")
+	b := createNote(t, svc, "work", "Spaces are better")
+
+	text, errMsg := getPrompt(t, s, ctx, "compare_notes", map[string]string{"ids": a.ID.Hex() + ", " + b.ID.Hex()})
+	if !strings.Contains(text, "Tabs are better") || !strings.Contains(text, "Spaces are better") || !strings.Contains(text, "Category: work") {
+		t.Errorf("comparison = %q (%s), want both notes with their categories", text, errMsg)
+	}
+
+	tooMany := strings.Repeat(a.ID.Hex()+" ", maxCompareNotes+1)
+	for _, ids := range []string{a.ID.Hex(), tooMany, a.ID.Hex() + " 6650a1b2c3d4e5f601234567"} {
+		if _, errMsg := getPrompt(t, s, ctx, "compare_notes", map[string]string{"ids": ids}); errMsg == "" {
+			t.Errorf("compare_notes %q succeeded, want an error", ids)
+		}
+	}
+
+	restricted := notes.WithCategoryAccess(ctx, []string{"ideas"})
+	if text, errMsg := getPrompt(t, s, restricted, "compare_notes", map[string]string{"ids": a.ID.Hex() + " " + b.ID.Hex()}); errMsg == "" {
+		t.Errorf("restricted comparison = %q, want an error for the other category's note", text)
+	}
+}
+
+func TestRankBacklogPrompt(t *testing.T) {
+	s, svc := newTestServer(t)
+	ctx := context.Background()
+	createNote(t, svc, "content-ideas", "Thread about MCP")
+
+	text, errMsg := getPrompt(t, s, ctx, "rank_backlog", map[string]string{"category": "content-ideas"})
+	if !strings.Contains(text, "rank by expected impact versus effort") || !strings.Contains(text, "Thread about MCP") {
+		t.Errorf("backlog = %q (%s), want the default criteria and the idea", text, errMsg)
+	}
+	text, _ = getPrompt(t, s, ctx, "rank_backlog", map[string]string{"category": "content-ideas", "criteria": "reach"})
+	if !strings.Contains(text, "rank by reach.") {
+		t.Errorf("backlog = %q, want it ranked by reach", text)
+	}
+	if _, errMsg := getPrompt(t, s, ctx, "rank_backlog", nil); errMsg == "" {
+		t.Error("rank_backlog without a category succeeded")
+	}
+}
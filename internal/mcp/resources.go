@@ -0,0 +1,174 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"scratchpad/internal/notes"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	resourceScheme   = "scratchpad://"
+	categoriesURI    = resourceScheme + "categories"
+	markdownMIMEType = "text/markdown"
+
+	// categoryResourceLimit caps how many notes a category resource holds;
+	// it matches the store's maximum page size
+	categoryResourceLimit = 200
+)
+
+func categoryURI(name string) string {
+	return resourceScheme + "category/" + url.PathEscape(name)
+}
+
+func noteURI(id string) string {
+	return resourceScheme + "note/" + id
+}
+
+// parseResourceURI splits a scratchpad URI into its kind ("categories",
+// "category" or "note") and argument. ok is false for anything else.
+func parseResourceURI(uri string) (kind, arg string, ok bool) {
+	if uri == categoriesURI {
+		return "categories", "", true
+	}
+	rest, found := strings.CutPrefix(uri, resourceScheme)
+	if !found {
+		return "", "", false
+	}
+	kind, arg, _ = strings.Cut(rest, "/")
+	if kind != "category" && kind != "note" {
+		return "", "", false
+	}
+	arg, err := url.PathUnescape(arg)
+	if err != nil || arg == "" || strings.Contains(arg, "/") {
+		return "", "", false
+	}
+	return kind, arg, true
+}
+
+// addResources registers the category index and the category/note resource
+// templates. Every resource is rendered as markdown so clients can attach it
+// to a conversation as-is. Individual categories are also listed as
+// resources; see Server.SyncCategoryResources.
+func addResources(s *server.MCPServer, svc *notes.Service) {
+	s.AddResource(
+		mcp.NewResource(categoriesURI, "Categories",
+			mcp.WithResourceDescription("Every category in the scratchpad with its note count and last activity"),
+			mcp.WithMIMEType(markdownMIMEType),
+		),
+		handleCategoriesResource(svc),
+	)
+
+	s.AddResourceTemplate(
+		mcp.NewResourceTemplate(resourceScheme+"category/{name}", "Category",
+			mcp.WithTemplateDescription(fmt.Sprintf("The newest notes (up to %d) in a category, newest first", categoryResourceLimit)),
+			mcp.WithTemplateMIMEType(markdownMIMEType),
+		),
+		handleCategoryResource(svc),
+	)
+
+	s.AddResourceTemplate(
+		mcp.NewResourceTemplate(resourceScheme+"note/{id}", "Note",
+			mcp.WithTemplateDescription("A single note's markdown content"),
+			mcp.WithTemplateMIMEType(markdownMIMEType),
+		),
+		handleNoteResource(svc),
+	)
+}
+
+func handleCategoriesResource(svc *notes.Service) server.ResourceHandlerFunc {
+	return func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		categories, err := svc.ListCategories(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list categories: %w", err)
+		}
+
+		var b strings.Builder
+		b.WriteString("# Categories\n\n")
+		if len(categories) == 0 {
+			b.WriteString("_No notes yet._\n")
+		}
+		for _, cat := range categories {
+			fmt.Fprintf(&b, "- [%s](%s) - %d notes, last %s\n",
+				cat.Name, categoryURI(cat.Name), cat.Count, cat.LastNote.Format("Jan 2, 2006 15:04"))
+		}
+
+		return markdownContents(req.Params.URI, b.String()), nil
+	}
+}
+
+func handleCategoryResource(svc *notes.Service) server.ResourceTemplateHandlerFunc {
+	return func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		kind, name, ok := parseResourceURI(req.Params.URI)
+		if !ok || kind != "category" {
+			return nil, fmt.Errorf("invalid category in %q", req.Params.URI)
+		}
+
+		noteList, err := svc.List(ctx, notes.ListQuery{Category: name, Limit: categoryResourceLimit})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get notes: %w", err)
+		}
+		total, err := svc.Count(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to count notes: %w", err)
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "# %s\n\n", name)
+		switch {
+		case total == 0:
+			b.WriteString("_No notes in this category._\n")
+		case int64(len(noteList)) < total:
+			fmt.Fprintf(&b, "_Newest %d of %d notes._\n", len(noteList), total)
+		default:
+			fmt.Fprintf(&b, "_%d notes._\n", total)
+		}
+		writeNotes(&b, noteList, false)
+
+		return markdownContents(req.Params.URI, b.String()), nil
+	}
+}
+
+func handleNoteResource(svc *notes.Service) server.ResourceTemplateHandlerFunc {
+	return func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		_, id, _ := parseResourceURI(req.Params.URI)
+		note, err := svc.GetByID(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get note: %w", err)
+		}
+
+		return markdownContents(req.Params.URI, note.Content), nil
+	}
+}
+
+// writeNotes renders notes as markdown sections separated by rules, each
+// headed by its date and ID, optionally with its category
+func writeNotes(b *strings.Builder, noteList []*notes.Note, withCategory bool) {
+	for _, note := range noteList {
+		fmt.Fprintf(b, "\n---\n\n## %s · [%s](%s)\n\n",
+			note.CreatedAt.Format("Jan 2, 2006 15:04"), note.ID.Hex(), noteURI(note.ID.Hex()))
+		if withCategory {
+			fmt.Fprintf(b, "Category: %s\n\n", note.Category)
+		}
+		if len(note.Tags) > 0 {
+			fmt.Fprintf(b, "Tags: #%s\n\n", strings.Join(note.Tags, " #"))
+		}
+		b.WriteString(strings.TrimRight(note.Content, "\n"))
+		b.WriteString("\n")
+	}
+}
+
+func markdownContents(uri, text string) []mcp.ResourceContents {
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      uri,
+			MIMEType: markdownMIMEType,
+			Text:     text,
+		},
+	}
+}
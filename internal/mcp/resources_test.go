@@ -0,0 +1,150 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"scratchpad/internal/notes"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// readResource runs a resources/read request through the server, returning
+// the markdown it holds or the error message it failed with
+func readResource(t *testing.T, s *Server, ctx context.Context, uri string) (text, errMsg string) {
+	t.Helper()
+	msg, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "resources/read",
+		"params":  map[string]any{"uri": uri},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	switch resp := s.HandleMessage(ctx, msg).(type) {
+	case mcp.JSONRPCError:
+		return "", resp.Error.Message
+	case mcp.JSONRPCResponse:
+		result, ok := resp.Result.(mcp.ReadResourceResult)
+		if !ok || len(result.Contents) != 1 {
+			t.Fatalf("%s: got result %#v", uri, resp.Result)
+		}
+		contents, ok := result.Contents[0].(mcp.TextResourceContents)
+		if !ok || contents.MIMEType != markdownMIMEType || contents.URI != uri {
+			t.Fatalf("%s: got contents %#v, want markdown for the URI", uri, result.Contents[0])
+		}
+		return contents.Text, ""
+	default:
+		t.Fatalf("%s: got %#v", uri, resp)
+		return "", ""
+	}
+}
+
+func TestParseResourceURI(t *testing.T) {
+	tests := []struct {
+		uri       string
+		kind, arg string
+		ok        bool
+	}{
+		{"scratchpad://categories", "categories", "", true},
+		{"scratchpad://category/ideas", "category", "ideas", true},
+		{"scratchpad://category/content%20ideas", "category", "content ideas", true},
+		{"scratchpad://note/6650a1b2c3d4e5f601234567", "note", "6650a1b2c3d4e5f601234567", true},
+		{"scratchpad://category/", "", "", false},
+		{"scratchpad://category/a%2Fb", "", "", false},
+		{"scratchpad://category/%zz", "", "", false},
+		{"scratchpad://tags/ideas", "", "", false},
+		{"file://category/ideas", "", "", false},
+	}
+	for _, tt := range tests {
+		kind, arg, ok := parseResourceURI(tt.uri)
+		if kind != tt.kind || arg != tt.arg || ok != tt.ok {
+			t.Errorf("parseResourceURI(%q) = %q, %q, %v; want %q, %q, %v", tt.uri, kind, arg, ok, tt.kind, tt.arg, tt.ok)
+		}
+	}
+}
+
+func TestReadResources(t *testing.T) {
+	s, svc := newTestServer(t)
+	ctx := context.Background()
+
+	if text, errMsg := readResource(t, s, ctx, categoriesURI); !strings.Contains(text, "_No notes yet._") {
+		t.Errorf("empty categories = %q (%s)", text, errMsg)
+	}
+
+	first := createNote(t, svc, "ideas", "First idea")
+	second, err := svc.Create(ctx, notes.CreateNoteInput{Category: "ideas", Content: "Second idea\n", Tags: []string{"mcp", "threads"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	createNote(t, svc, "work", "Ship it")
+
+	text, errMsg := readResource(t, s, ctx, categoriesURI)
+	for _, want := range []string{"[ideas](scratchpad://category/ideas) - 2 notes", "[work](scratchpad://category/work) - 1 notes"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("categories = %q (%s), want it to list %q", text, errMsg, want)
+		}
+	}
+
+	text, errMsg = readResource(t, s, ctx, categoryURI("ideas"))
+	for _, want := range []string{
+		"# ideas\n\n_2 notes._\n",
+		"[" + first.ID.Hex() + "](" + noteURI(first.ID.Hex()) + ")",
+		"Tags: #mcp #threads\n\nSecond idea\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("category = %q (%s), want it to contain %q", text, errMsg, want)
+		}
+	}
+	if strings.Index(text, "Second idea") > strings.Index(text, "First idea") {
+		t.Errorf("category = %q, want the newest note first", text)
+	}
+	if text, _ := readResource(t, s, ctx, categoryURI("empty")); !strings.Contains(text, "_No notes in this category._") {
+		t.Errorf("empty category = %q", text)
+	}
+
+	if text, errMsg := readResource(t, s, ctx, noteURI(second.Note.ID.Hex())); text != "Second idea\n" {
+		t.Errorf("note = %q (%s), want its content as-is", text, errMsg)
+	}
+	for _, uri := range []string{noteURI("6650a1b2c3d4e5f601234567"), noteURI("not-an-id")} {
+		if _, errMsg := readResource(t, s, ctx, uri); errMsg == "" {
+			t.Errorf("%s read without an error", uri)
+		}
+	}
+}
+
+func TestReadCategoryResourceCapped(t *testing.T) {
+	s, svc := newTestServer(t)
+	for i := range categoryResourceLimit + 5 {
+		createNote(t, svc, "log", fmt.Sprintf("entry %d", i))
+	}
+	text, errMsg := readResource(t, s, context.Background(), categoryURI("log"))
+	want := fmt.Sprintf("_Newest %d of %d notes._", categoryResourceLimit, categoryResourceLimit+5)
+	if !strings.Contains(text, want) {
+		t.Errorf("category = %.100q (%s), want it to say %q", text, errMsg, want)
+	}
+	if n := strings.Count(text, "\n---\n"); n != categoryResourceLimit {
+		t.Errorf("category holds %d notes, want %d", n, categoryResourceLimit)
+	}
+}
+
+func TestReadResourcesRestricted(t *testing.T) {
+	s, svc := newTestServer(t)
+	createNote(t, svc, "ideas", "An idea")
+	private := createNote(t, svc, "private", "A secret")
+	restricted := notes.WithCategoryAccess(context.Background(), []string{"ideas"})
+
+	if text, _ := readResource(t, s, restricted, categoriesURI); strings.Contains(text, "private") {
+		t.Errorf("restricted categories = %q, want only ideas", text)
+	}
+	if text, _ := readResource(t, s, restricted, categoryURI("private")); strings.Contains(text, "A secret") {
+		t.Errorf("restricted read of another category = %q", text)
+	}
+	if text, errMsg := readResource(t, s, restricted, noteURI(private.ID.Hex())); errMsg == "" {
+		t.Errorf("restricted read of another category's note = %q, want an error", text)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 	"time"
 
 	"scratchpad/internal/notes"
@@ -12,13 +13,51 @@ import (
 	"github.com/mark3labs/mcp-go/server"
 )
 
+// Options configures the MCP server
+type Options struct {
+	// ReadOnly leaves out the tools that create, change or delete notes
+	ReadOnly bool
+}
+
+// Server is the scratchpad MCP server. It embeds the mcp-go server and adds
+// the resource subscription tracking mcp-go leaves to its users.
+type Server struct {
+	*server.MCPServer
+	svc  *notes.Service
+	subs *subscriptions
+
+	sessionsMu sync.Mutex
+	sessions   map[string]*session // by session ID
+}
+
 // NewServer creates an MCP server with tools for scratchpad operations
-func NewServer(svc *notes.Service) *server.MCPServer {
-	s := server.NewMCPServer(
+func NewServer(svc *notes.Service, opts Options) *Server {
+	hooks := &server.Hooks{}
+	mcpSrv := server.NewMCPServer(
 		"Scratchpad",
 		"1.0.0",
 		server.WithToolCapabilities(true),
+		server.WithResourceCapabilities(true, true),
+		server.WithPromptCapabilities(false),
+		server.WithHooks(hooks),
+		server.WithToolHandlerMiddleware(requireToolScope),
 	)
+	s := &Server{
+		MCPServer: mcpSrv,
+		svc:       svc,
+		subs:      newSubscriptions(),
+		sessions:  make(map[string]*session),
+	}
+	// HTTP sessions; ServeStdio binds the stdio session itself
+	hooks.AddOnRegisterSession(func(ctx context.Context, cs server.ClientSession) {
+		if sr, ok := cs.(server.SessionWithResources); ok {
+			s.bindSession(ctx, cs.SessionID(), sr)
+		}
+	})
+	hooks.AddOnUnregisterSession(func(ctx context.Context, cs server.ClientSession) {
+		s.unbindSession(cs.SessionID())
+	})
+	svc.OnChange(s.noteChanged)
 
 	// Tool: list_categories - List all categories with counts
 	s.AddTool(
@@ -28,6 +67,14 @@ func NewServer(svc *notes.Service) *server.MCPServer {
 		handleListCategories(svc),
 	)
 
+	// Tool: list_tags - List all tags with counts
+	s.AddTool(
+		mcp.NewTool("list_tags",
+			mcp.WithDescription("List all note tags with usage counts, most used first. Use this to discover tags before filtering get_notes or search_notes by them."),
+		),
+		handleListTags(svc),
+	)
+
 	// Tool: get_notes - Get notes by category
 	s.AddTool(
 		mcp.NewTool("get_notes",
@@ -36,6 +83,14 @@ func NewServer(svc *notes.Service) *server.MCPServer {
 				mcp.Required(),
 				mcp.Description("Category name (e.g., 'twitter-analytics', 'content-ideas')"),
 			),
+			mcp.WithArray("tags",
+				mcp.WithStringItems(),
+				mcp.Description("Optional: Only return notes carrying these tags"),
+			),
+			mcp.WithString("tag_mode",
+				mcp.Enum("any", "all"),
+				mcp.Description("Optional: Match notes with any of the tags or all of them (default: any)"),
+			),
 			mcp.WithNumber("limit",
 				mcp.Description("Maximum number of notes to return (default: 50, max: 200)"),
 			),
@@ -49,14 +104,22 @@ func NewServer(svc *notes.Service) *server.MCPServer {
 	// Tool: search_notes - Full-text search
 	s.AddTool(
 		mcp.NewTool("search_notes",
-			mcp.WithDescription("Full-text search across notes with optional category and date filtering. Use this to find specific information across all notes or within a category."),
+			mcp.WithDescription("Full-text search across notes with optional category, tag and date filtering. Use this to find specific information across all notes or within a category."),
 			mcp.WithString("query",
 				mcp.Required(),
-				mcp.Description("Search query - searches note content"),
+				mcp.Description(`Search query. Words must all appear (matching at the start of a word, case-insensitive); "quoted phrases" match exactly; -word excludes; OR between terms, with (parentheses) to group. Filters can be mixed in: category:name, tag:name, created:>2026-01-01, updated:<=2026-03, created:2026-01-01..2026-02-01. Example: tag:idea (rust OR go) -draft`),
 			),
 			mcp.WithString("category",
 				mcp.Description("Optional: Filter by category name"),
 			),
+			mcp.WithArray("tags",
+				mcp.WithStringItems(),
+				mcp.Description("Optional: Only return notes carrying these tags"),
+			),
+			mcp.WithString("tag_mode",
+				mcp.Enum("any", "all"),
+				mcp.Description("Optional: Match notes with any of the tags or all of them (default: any)"),
+			),
 			mcp.WithString("since",
 				mcp.Description("Optional: Only return notes created after this date (ISO format: YYYY-MM-DD or RFC3339)"),
 			),
@@ -66,10 +129,40 @@ func NewServer(svc *notes.Service) *server.MCPServer {
 			mcp.WithNumber("limit",
 				mcp.Description("Maximum number of notes to return (default: 50, max: 200)"),
 			),
+			mcp.WithNumber("offset",
+				mcp.Description("Number of hits to skip for pagination; the result says whether it hasMore (default: 0)"),
+			),
+			mcp.WithBoolean("omit_content",
+				mcp.Description("Optional: Leave out full note bodies and return only the snippets around matches, to save context; fetch a note with get_note when you need all of it (default: false)"),
+			),
 		),
 		handleSearchNotes(svc),
 	)
 
+	// Tool: semantic_search - Search by meaning
+	s.AddTool(
+		mcp.NewTool("semantic_search",
+			mcp.WithDescription("Find notes by meaning rather than exact words, e.g. \"what did we learn about audience growth\". Use this for open questions; use search_notes when you know the words or need filters."),
+			mcp.WithString("query",
+				mcp.Required(),
+				mcp.Description("What to look for, in plain language"),
+			),
+			mcp.WithString("category",
+				mcp.Description("Optional: Filter by category name"),
+			),
+			mcp.WithNumber("text_weight",
+				mcp.Description(fmt.Sprintf("Optional: How much matching the query's exact words counts, from 0 (meaning only) to 1 (keywords only) (default: %g)", notes.DefaultTextWeight)),
+			),
+			mcp.WithNumber("limit",
+				mcp.Description("Maximum number of notes to return (default: 10, max: 50)"),
+			),
+			mcp.WithBoolean("omit_content",
+				mcp.Description("Optional: Leave out full note bodies and return only snippets, to save context (default: false)"),
+			),
+		),
+		handleSemanticSearch(svc),
+	)
+
 	// Tool: get_recent_notes - Get most recent notes across all categories
 	s.AddTool(
 		mcp.NewTool("get_recent_notes",
@@ -96,6 +189,51 @@ func NewServer(svc *notes.Service) *server.MCPServer {
 		handleGetNote(svc),
 	)
 
+	// Tool: get_related_notes - Notes sharing a note's distinctive terms
+	s.AddTool(
+		mcp.NewTool("get_related_notes",
+			mcp.WithDescription("Find notes related to a note, in any category, by the distinctive terms they share. Use this after reading a note to pull in connected material."),
+			mcp.WithString("id",
+				mcp.Required(),
+				mcp.Description("The note ID (24-character hex string)"),
+			),
+			mcp.WithString("category",
+				mcp.Description("Optional: Only look in this category (default: every category)"),
+			),
+			mcp.WithNumber("limit",
+				mcp.Description("Maximum number of notes to return (default: 5, max: 50)"),
+			),
+			mcp.WithBoolean("omit_content",
+				mcp.Description("Optional: Leave out full note bodies and return only snippets, to save context (default: false)"),
+			),
+		),
+		handleGetRelatedNotes(svc),
+	)
+
+	// Tool: find_duplicates - Report clusters of near-duplicate notes
+	s.AddTool(
+		mcp.NewTool("find_duplicates",
+			mcp.WithDescription("Find groups of notes that say the same thing in nearly the same words, within a category. Use this to clean up notes that were pushed more than once."),
+			mcp.WithString("category",
+				mcp.Description("Optional: Only look in this category (default: every category, each on its own)"),
+			),
+			mcp.WithNumber("min_similarity",
+				mcp.Description(fmt.Sprintf("Optional: How alike two notes must be, from 0 to 1 (default: %g)", notes.DefaultMinSimilarity)),
+			),
+			mcp.WithNumber("limit",
+				mcp.Description("Maximum number of groups to return (default: 20, max: 100)"),
+			),
+		),
+		handleFindDuplicates(svc),
+	)
+
+	if !opts.ReadOnly {
+		addWriteTools(mcpSrv, svc)
+	}
+
+	addResources(mcpSrv, svc)
+	addPrompts(mcpSrv, svc)
+
 	return s
 }
 
@@ -106,13 +244,62 @@ type CategoryResult struct {
 	LastNote time.Time `json:"lastNote"`
 }
 
+// TagResult represents a tag with its usage count
+type TagResult struct {
+	Name  string `json:"name"`
+	Count int64  `json:"count"`
+}
+
 // NoteResult represents a note in API responses
 type NoteResult struct {
 	ID        string    `json:"id"`
 	Category  string    `json:"category"`
-	Content   string    `json:"content"`
+	Content   string    `json:"content,omitempty"`
+	Tags      []string  `json:"tags,omitempty"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
+	Version   int64     `json:"version"`
+}
+
+// SearchHitResult represents a search hit: the note, its relevance score and
+// excerpts of where it matched
+type SearchHitResult struct {
+	NoteResult
+	Score    float64         `json:"score"`
+	Snippets []notes.Snippet `json:"snippets"`
+}
+
+// SemanticHitResult represents a note found by semantic search, with how
+// close it is in meaning and by keywords
+type SemanticHitResult struct {
+	NoteResult
+	Score      float64         `json:"score"`
+	Similarity float64         `json:"similarity"`
+	TextScore  float64         `json:"textScore"`
+	Snippets   []notes.Snippet `json:"snippets"`
+}
+
+// RelatedNoteResult represents a related note with the terms it shares
+type RelatedNoteResult struct {
+	NoteResult
+	Score    float64         `json:"score"`
+	Terms    []string        `json:"terms"`
+	Snippets []notes.Snippet `json:"snippets"`
+}
+
+// SearchPageResult represents a page of search hits out of Total matches
+type SearchPageResult struct {
+	Total   int64             `json:"total"`
+	Offset  int               `json:"offset"`
+	HasMore bool              `json:"hasMore"`
+	Hits    []SearchHitResult `json:"hits"`
+}
+
+// DuplicateClusterResult represents a group of near-duplicate notes
+type DuplicateClusterResult struct {
+	Category      string       `json:"category"`
+	MinSimilarity float64      `json:"minSimilarity"`
+	Notes         []NoteResult `json:"notes"`
 }
 
 func handleListCategories(svc *notes.Service) server.ToolHandlerFunc {
@@ -137,6 +324,23 @@ func handleListCategories(svc *notes.Service) server.ToolHandlerFunc {
 	}
 }
 
+func handleListTags(svc *notes.Service) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		tags, err := svc.ListTags(ctx)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to list tags: %v", err)), nil
+		}
+
+		results := make([]TagResult, len(tags))
+		for i, tag := range tags {
+			results[i] = TagResult{Name: tag.Name, Count: tag.Count}
+		}
+
+		data, _ := json.MarshalIndent(results, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	}
+}
+
 func handleGetNotes(svc *notes.Service) server.ToolHandlerFunc {
 	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		category, err := req.RequireString("category")
@@ -149,6 +353,8 @@ func handleGetNotes(svc *notes.Service) server.ToolHandlerFunc {
 
 		noteList, err := svc.List(ctx, notes.ListQuery{
 			Category: category,
+			Tags:     req.GetStringSlice("tags", nil),
+			TagMode:  notes.TagMode(req.GetString("tag_mode", "")),
 			Limit:    limit,
 			Offset:   offset,
 		})
@@ -170,9 +376,13 @@ func handleSearchNotes(svc *notes.Service) server.ToolHandlerFunc {
 		}
 
 		q := notes.SearchQuery{
-			Query:    query,
-			Category: req.GetString("category", ""),
-			Limit:    req.GetInt("limit", 50),
+			Query:       query,
+			Category:    req.GetString("category", ""),
+			Tags:        req.GetStringSlice("tags", nil),
+			TagMode:     notes.TagMode(req.GetString("tag_mode", "")),
+			Limit:       req.GetInt("limit", 50),
+			Offset:      req.GetInt("offset", 0),
+			OmitContent: req.GetBool("omit_content", false),
 		}
 
 		// Parse since date
@@ -193,12 +403,57 @@ func handleSearchNotes(svc *notes.Service) server.ToolHandlerFunc {
 			q.Until = &t
 		}
 
-		noteList, err := svc.Search(ctx, q)
+		result, err := svc.Search(ctx, q)
 		if err != nil {
 			return mcp.NewToolResultError(fmt.Sprintf("failed to search notes: %v", err)), nil
 		}
 
-		results := notesToResults(noteList)
+		results := SearchPageResult{
+			Total:   result.Total,
+			Offset:  result.Offset,
+			HasMore: result.HasMore,
+			Hits:    make([]SearchHitResult, len(result.Hits)),
+		}
+		for i, hit := range result.Hits {
+			results.Hits[i] = SearchHitResult{
+				NoteResult: noteToResult(hit.Note),
+				Score:      hit.Score,
+				Snippets:   hit.Snippets,
+			}
+		}
+		data, _ := json.MarshalIndent(results, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	}
+}
+
+func handleSemanticSearch(svc *notes.Service) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		query, err := req.RequireString("query")
+		if err != nil {
+			return mcp.NewToolResultError("query is required"), nil
+		}
+
+		hits, err := svc.SemanticSearch(ctx, notes.SemanticQuery{
+			Query:       query,
+			Category:    req.GetString("category", ""),
+			TextWeight:  req.GetFloat("text_weight", notes.DefaultTextWeight),
+			Limit:       req.GetInt("limit", 10),
+			OmitContent: req.GetBool("omit_content", false),
+		})
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to search notes: %v", err)), nil
+		}
+
+		results := make([]SemanticHitResult, len(hits))
+		for i, hit := range hits {
+			results[i] = SemanticHitResult{
+				NoteResult: noteToResult(hit.Note),
+				Score:      hit.Score,
+				Similarity: hit.Similarity,
+				TextScore:  hit.TextScore,
+				Snippets:   hit.Snippets,
+			}
+		}
 		data, _ := json.MarshalIndent(results, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
 	}
@@ -242,15 +497,61 @@ func handleGetNote(svc *notes.Service) server.ToolHandlerFunc {
 			return mcp.NewToolResultError(fmt.Sprintf("failed to get note: %v", err)), nil
 		}
 
-		result := NoteResult{
-			ID:        note.ID.Hex(),
-			Category:  note.Category,
-			Content:   note.Content,
-			CreatedAt: note.CreatedAt,
-			UpdatedAt: note.UpdatedAt,
+		data, _ := json.MarshalIndent(noteToResult(note), "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	}
+}
+
+func handleGetRelatedNotes(svc *notes.Service) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, err := req.RequireString("id")
+		if err != nil {
+			return mcp.NewToolResultError("id is required"), nil
+		}
+
+		related, err := svc.Related(ctx, id, notes.RelatedQuery{
+			Category:    req.GetString("category", ""),
+			Limit:       req.GetInt("limit", 5),
+			OmitContent: req.GetBool("omit_content", false),
+		})
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to find related notes: %v", err)), nil
 		}
 
-		data, _ := json.MarshalIndent(result, "", "  ")
+		results := make([]RelatedNoteResult, len(related))
+		for i, rel := range related {
+			results[i] = RelatedNoteResult{
+				NoteResult: noteToResult(rel.Note),
+				Score:      rel.Score,
+				Terms:      rel.Terms,
+				Snippets:   rel.Snippets,
+			}
+		}
+		data, _ := json.MarshalIndent(results, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	}
+}
+
+func handleFindDuplicates(svc *notes.Service) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		clusters, err := svc.FindDuplicates(ctx, notes.DuplicatesQuery{
+			Category:      req.GetString("category", ""),
+			MinSimilarity: req.GetFloat("min_similarity", 0),
+			Limit:         req.GetInt("limit", 20),
+		})
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to find duplicates: %v", err)), nil
+		}
+
+		results := make([]DuplicateClusterResult, len(clusters))
+		for i, c := range clusters {
+			results[i] = DuplicateClusterResult{
+				Category:      c.Category,
+				MinSimilarity: c.MinSimilarity,
+				Notes:         notesToResults(c.Notes),
+			}
+		}
+		data, _ := json.MarshalIndent(results, "", "  ")
 		return mcp.NewToolResultText(string(data)), nil
 	}
 }
@@ -260,17 +561,23 @@ func handleGetNote(svc *notes.Service) server.ToolHandlerFunc {
 func notesToResults(noteList []*notes.Note) []NoteResult {
 	results := make([]NoteResult, len(noteList))
 	for i, note := range noteList {
-		results[i] = NoteResult{
-			ID:        note.ID.Hex(),
-			Category:  note.Category,
-			Content:   note.Content,
-			CreatedAt: note.CreatedAt,
-			UpdatedAt: note.UpdatedAt,
-		}
+		results[i] = noteToResult(note)
 	}
 	return results
 }
 
+func noteToResult(note *notes.Note) NoteResult {
+	return NoteResult{
+		ID:        note.ID.Hex(),
+		Category:  note.Category,
+		Content:   note.Content,
+		Tags:      note.Tags,
+		CreatedAt: note.CreatedAt,
+		UpdatedAt: note.UpdatedAt,
+		Version:   note.Version,
+	}
+}
+
 func parseDate(s string) (time.Time, error) {
 	// Try RFC3339 first
 	t, err := time.Parse(time.RFC3339, s)
@@ -0,0 +1,78 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"sync"
+
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// stdioSessionID is the fixed session ID mcp-go gives its single stdio client
+const stdioSessionID = "stdio"
+
+// ServeStdio serves MCP over stdin/stdout until ctx is cancelled or stdin
+// closes. Like HTTPHandler, it answers resources/subscribe and
+// resources/unsubscribe itself before passing everything else to mcp-go.
+// Nothing but protocol messages may be written to stdout while it runs.
+// The session works in the workspace selected for ctx.
+func (s *Server) ServeStdio(ctx context.Context, stdin io.Reader, stdout io.Writer, errLog *log.Logger) error {
+	s.bindSession(ctx, stdioSessionID, nil)
+	defer s.unbindSession(stdioSessionID)
+
+	out := &lockedWriter{w: stdout}
+	pr, pw := io.Pipe()
+
+	go func() {
+		reader := bufio.NewReader(stdin)
+		for {
+			line, err := reader.ReadBytes('\n')
+			if len(line) > 0 && !s.answerStdio(out, line) {
+				if _, werr := pw.Write(line); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+		}
+	}()
+
+	stdioSrv := server.NewStdioServer(s.MCPServer)
+	stdioSrv.SetErrorLogger(errLog)
+	return stdioSrv.Listen(ctx, pr, out)
+}
+
+// answerStdio writes the response to a subscription request, reporting
+// whether line was one
+func (s *Server) answerStdio(out io.Writer, line []byte) bool {
+	var req rpcRequest
+	if json.Unmarshal(line, &req) != nil {
+		return false
+	}
+	resp, ok := s.subscriptionResponse(stdioSessionID, req)
+	if !ok {
+		return false
+	}
+
+	data, _ := json.Marshal(resp)
+	out.Write(append(data, '\n'))
+	return true
+}
+
+// lockedWriter serializes writes so responses written here and by mcp-go
+// never interleave; each message is written with a single Write call
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
@@ -0,0 +1,149 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// stdioClient talks to ServeStdio over pipes, one JSON message per line
+type stdioClient struct {
+	t   *testing.T
+	in  *io.PipeWriter
+	out chan map[string]any
+}
+
+func startStdio(t *testing.T, s *Server) (*stdioClient, <-chan error) {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.ServeStdio(ctx, inR, outW, log.New(io.Discard, "", 0))
+		outW.Close()
+	}()
+
+	c := &stdioClient{t: t, in: inW, out: make(chan map[string]any, 100)}
+	go func() {
+		defer close(c.out)
+		scanner := bufio.NewScanner(outR)
+		for scanner.Scan() {
+			var msg map[string]any
+			if json.Unmarshal(scanner.Bytes(), &msg) == nil {
+				c.out <- msg
+			}
+		}
+	}()
+	return c, done
+}
+
+func (c *stdioClient) send(msg map[string]any) {
+	c.t.Helper()
+	msg["jsonrpc"] = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if _, err := c.in.Write(append(data, '\n')); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// next waits for the next message written to stdout
+func (c *stdioClient) next() map[string]any {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.out:
+		if !ok {
+			c.t.Fatal("stdout closed")
+		}
+		return msg
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("timed out waiting for a message")
+		return nil
+	}
+}
+
+// call sends a request and returns its response
+func (c *stdioClient) call(id int, method string, params map[string]any) map[string]any {
+	c.t.Helper()
+	c.send(map[string]any{"id": id, "method": method, "params": params})
+	for {
+		msg := c.next()
+		if msg["id"] == float64(id) {
+			return msg
+		}
+	}
+}
+
+// updated waits for the next resources/updated notification, returning its URI
+func (c *stdioClient) updated() string {
+	c.t.Helper()
+	for {
+		msg := c.next()
+		if msg["method"] == mcp.MethodNotificationResourceUpdated {
+			params, _ := msg["params"].(map[string]any)
+			uri, _ := params["uri"].(string)
+			return uri
+		}
+	}
+}
+
+func TestServeStdioSubscriptions(t *testing.T) {
+	s, svc := newTestServer(t)
+	c, done := startStdio(t, s)
+
+	if resp := c.call(1, "initialize", map[string]any{
+		"protocolVersion": mcp.LATEST_PROTOCOL_VERSION,
+		"clientInfo":      map[string]any{"name": "test", "version": "1"},
+		"capabilities":    map[string]any{},
+	}); resp["error"] != nil {
+		t.Fatalf("initialize: %v", resp["error"])
+	}
+	c.send(map[string]any{"method": "notifications/initialized"})
+
+	for i, uri := range []string{categoryURI("ideas"), "scratchpad://nope"} {
+		resp := c.call(2+i, methodResourcesSubscribe, map[string]any{"uri": uri})
+		if wantErr := uri == "scratchpad://nope"; (resp["error"] != nil) != wantErr {
+			t.Errorf("subscribe %s = %v, want error %v", uri, resp, wantErr)
+		}
+	}
+
+	createNote(t, svc, "work", "not watched")
+	createNote(t, svc, "ideas", "watched")
+	if uri := c.updated(); uri != categoryURI("ideas") {
+		t.Errorf("got an update for %s, want %s", uri, categoryURI("ideas"))
+	}
+
+	// Everything else still reaches mcp-go
+	if resp := c.call(4, "resources/read", map[string]any{"uri": categoryURI("ideas")}); resp["result"] == nil {
+		t.Errorf("read = %v, want a result", resp)
+	}
+
+	if resp := c.call(5, methodResourcesUnsubscribe, map[string]any{"uri": categoryURI("ideas")}); resp["error"] != nil {
+		t.Fatalf("unsubscribe: %v", resp["error"])
+	}
+	if s.subs.subscribed(stdioSessionID, categoryURI("ideas")) {
+		t.Error("still subscribed after unsubscribing")
+	}
+
+	c.call(6, methodResourcesSubscribe, map[string]any{"uri": categoriesURI})
+	c.in.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("ServeStdio kept running after stdin closed")
+	}
+	if s.subs.subscribed(stdioSessionID, categoriesURI) {
+		t.Error("subscriptions outlived ServeStdio")
+	}
+}
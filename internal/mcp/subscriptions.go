@@ -0,0 +1,359 @@
+package mcp
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	"scratchpad/internal/notes"
+	"scratchpad/internal/workspace"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+const (
+	methodResourcesSubscribe   = "resources/subscribe"
+	methodResourcesUnsubscribe = "resources/unsubscribe"
+
+	// maxRPCBodyBytes bounds how much of a POST body the subscription
+	// middleware will buffer before handing it to the transport; larger
+	// bodies are refused
+	maxRPCBodyBytes = 4 << 20
+)
+
+// subscriptions records which sessions subscribed to which resource URIs.
+// mcp-go advertises the subscribe capability but leaves the methods and the
+// bookkeeping to the server, so it lives here.
+type subscriptions struct {
+	mu    sync.Mutex
+	byURI map[string]map[string]bool // uri -> session IDs
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{byURI: make(map[string]map[string]bool)}
+}
+
+func (s *subscriptions) add(sessionID, uri string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.byURI[uri] == nil {
+		s.byURI[uri] = make(map[string]bool)
+	}
+	s.byURI[uri][sessionID] = true
+}
+
+func (s *subscriptions) remove(sessionID, uri string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.byURI[uri], sessionID)
+	if len(s.byURI[uri]) == 0 {
+		delete(s.byURI, uri)
+	}
+}
+
+// dropSession forgets every subscription held by a session
+func (s *subscriptions) dropSession(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for uri, sessions := range s.byURI {
+		delete(sessions, sessionID)
+		if len(sessions) == 0 {
+			delete(s.byURI, uri)
+		}
+	}
+}
+
+func (s *subscriptions) subscribed(sessionID, uri string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.byURI[uri][sessionID]
+}
+
+// session is what the server keeps per connected client: the context it
+// connected with, which pins its workspace and category access, and the
+// categories currently listed to it as resources
+type session struct {
+	ctx        context.Context
+	categories map[string]bool
+}
+
+// bindSession starts tracking a client and lists its workspace's categories
+// as resources. ctx is the context the client connected with; cs is its
+// mcp-go session, nil for stdio. The client asks for the resource list once
+// initialized, so this first listing sends no list_changed notifications.
+func (s *Server) bindSession(ctx context.Context, sessionID string, cs server.SessionWithResources) {
+	sess := &session{ctx: context.WithoutCancel(ctx), categories: make(map[string]bool)}
+	s.sessionsMu.Lock()
+	s.sessions[sessionID] = sess
+	s.sessionsMu.Unlock()
+
+	categories, err := s.svc.ListCategories(sess.ctx)
+	if err != nil {
+		return
+	}
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	var resources []server.ServerResource
+	for _, cat := range categories {
+		if !sess.categories[cat.Name] {
+			sess.categories[cat.Name] = true
+			resources = append(resources, s.categoryResource(cat.Name))
+		}
+	}
+	switch {
+	case len(resources) == 0:
+	case cs == nil:
+		s.AddResources(resources...)
+	default:
+		listed := maps.Clone(cs.GetSessionResources())
+		if listed == nil {
+			listed = make(map[string]server.ServerResource, len(resources))
+		}
+		for _, r := range resources {
+			listed[r.Resource.URI] = r
+		}
+		cs.SetSessionResources(listed)
+	}
+}
+
+func (s *Server) unbindSession(sessionID string) {
+	s.sessionsMu.Lock()
+	delete(s.sessions, sessionID)
+	s.sessionsMu.Unlock()
+	s.subs.dropSession(sessionID)
+}
+
+// session returns a tracked session
+func (s *Server) session(sessionID string) (*session, bool) {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	sess, ok := s.sessions[sessionID]
+	return sess, ok
+}
+
+// sessionWorkspace returns the workspace a tracked session is bound to
+func (s *Server) sessionWorkspace(sessionID string) (string, bool) {
+	sess, ok := s.session(sessionID)
+	if !ok {
+		return "", false
+	}
+	return workspace.FromContext(sess.ctx), true
+}
+
+// sessionsIn returns the tracked sessions bound to a workspace
+func (s *Server) sessionsIn(ws string) map[string]*session {
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	in := make(map[string]*session)
+	for id, sess := range s.sessions {
+		if workspace.FromContext(sess.ctx) == ws {
+			in[id] = sess
+		}
+	}
+	return in
+}
+
+// noteChanged tells subscribers in the note's workspace which resources a
+// change touched and keeps their listed category resources in step. A
+// session only hears about changes in categories it can read.
+func (s *Server) noteChanged(ctx context.Context, e notes.Event) {
+	categories := []string{e.Note.Category}
+	if e.PrevCategory != "" {
+		categories = append(categories, e.PrevCategory)
+	}
+	// Counts and last activity only move when notes come or go
+	listChanged := e.Type != notes.EventNoteUpdated || e.PrevCategory != ""
+
+	for sessionID, sess := range s.sessionsIn(e.Note.Workspace) {
+		var uris []string
+		for _, name := range categories {
+			s.syncCategory(sessionID, sess, name)
+			if notes.CanAccess(sess.ctx, name) {
+				uris = append(uris, categoryURI(name))
+			}
+		}
+		if len(uris) == 0 {
+			continue
+		}
+		uris = append(uris, noteURI(e.Note.ID.Hex()))
+		if listChanged {
+			uris = append(uris, categoriesURI)
+		}
+		for _, uri := range uris {
+			if s.subs.subscribed(sessionID, uri) {
+				s.notifyUpdated(sessionID, uri)
+			}
+		}
+	}
+}
+
+func (s *Server) notifyUpdated(sessionID, uri string) {
+	err := s.SendNotificationToSpecificClient(sessionID, mcp.MethodNotificationResourceUpdated, map[string]any{"uri": uri})
+	if errors.Is(err, server.ErrSessionNotFound) {
+		s.subs.dropSession(sessionID)
+	}
+}
+
+// syncCategory lists or unlists a category's resource for a session when
+// the category appears or empties out, as seen from the session's context
+func (s *Server) syncCategory(sessionID string, sess *session, name string) {
+	count, err := s.svc.Count(sess.ctx, name)
+	if err != nil {
+		return
+	}
+
+	s.sessionsMu.Lock()
+	defer s.sessionsMu.Unlock()
+	switch {
+	case count > 0 && !sess.categories[name]:
+		s.listCategory(sessionID, sess, name)
+	case count == 0 && sess.categories[name]:
+		delete(sess.categories, name)
+		if sessionID == stdioSessionID {
+			s.DeleteResources(categoryURI(name))
+		} else {
+			s.DeleteSessionResources(sessionID, categoryURI(name))
+		}
+	}
+}
+
+// listCategory adds a category resource for one session; both calls send
+// notifications/resources/list_changed. The stdio client is the only one in
+// its process and mcp-go keeps no per-session resources for it, so its
+// categories are registered server-wide. Must be called with sessionsMu held.
+func (s *Server) listCategory(sessionID string, sess *session, name string) {
+	sess.categories[name] = true
+	r := s.categoryResource(name)
+	if sessionID == stdioSessionID {
+		s.AddResources(r)
+	} else {
+		s.AddSessionResources(sessionID, r)
+	}
+}
+
+// categoryResource is the resource listing a category's notes
+func (s *Server) categoryResource(name string) server.ServerResource {
+	return server.ServerResource{
+		Resource: mcp.NewResource(categoryURI(name), name,
+			mcp.WithResourceDescription("Notes in the "+name+" category, newest first"),
+			mcp.WithMIMEType(markdownMIMEType),
+		),
+		Handler: server.ResourceHandlerFunc(handleCategoryResource(s.svc)),
+	}
+}
+
+// rpcRequest is the part of a JSON-RPC request the middleware looks at
+type rpcRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params struct {
+		URI string `json:"uri"`
+	} `json:"params"`
+}
+
+// HTTPHandler returns the streamable HTTP transport with resources/subscribe
+// and resources/unsubscribe answered in front of it. Subscriptions are keyed
+// by the Mcp-Session-Id header and dropped when the session is deleted.
+//
+// A session stays in the workspace it was initialized in: later requests
+// run in that workspace, and naming a different one is rejected.
+func (s *Server) HTTPHandler() http.Handler {
+	transport := server.NewStreamableHTTPServer(s.MCPServer)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sessionID := r.Header.Get(server.HeaderKeySessionID)
+		if bound, ok := s.sessionWorkspace(sessionID); ok {
+			if ws, selected := workspace.Selected(r.Context()); selected && ws != bound {
+				http.Error(w, fmt.Sprintf("session is bound to workspace %q", bound), http.StatusBadRequest)
+				return
+			}
+			r = r.WithContext(workspace.With(r.Context(), bound))
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			// Notifications arrive on this stream, which outlives the
+			// server's write timeout
+			http.NewResponseController(w).SetWriteDeadline(time.Time{})
+		case http.MethodDelete:
+			s.subs.dropSession(sessionID)
+		case http.MethodPost:
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRPCBodyBytes))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, "failed to read request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			var req rpcRequest
+			if json.Unmarshal(body, &req) == nil {
+				if resp, ok := s.subscriptionResponse(sessionID, req); ok {
+					w.Header().Set("Content-Type", "application/json")
+					json.NewEncoder(w).Encode(resp)
+					return
+				}
+			}
+		}
+
+		transport.ServeHTTP(w, r)
+	})
+}
+
+// subscriptionResponse handles subscribe/unsubscribe requests and returns
+// the JSON-RPC response to send. ok is false for any other method.
+// Resources the session can't read are refused as unknown.
+func (s *Server) subscriptionResponse(sessionID string, req rpcRequest) (resp map[string]any, ok bool) {
+	var subscribe bool
+	switch req.Method {
+	case methodResourcesSubscribe:
+		subscribe = true
+	case methodResourcesUnsubscribe:
+	default:
+		return nil, false
+	}
+
+	resp = map[string]any{"jsonrpc": mcp.JSONRPC_VERSION, "id": req.ID}
+	sess, bound := s.session(sessionID)
+	if !bound {
+		resp["error"] = map[string]any{"code": mcp.INVALID_REQUEST, "message": "subscriptions require an initialized session"}
+		return resp, true
+	}
+
+	// Store the canonical form so it matches the URIs notifications use
+	uri := req.Params.URI
+	kind, arg, valid := parseResourceURI(uri)
+	switch kind {
+	case "category":
+		uri = categoryURI(arg)
+		valid = notes.CanAccess(sess.ctx, notes.NormalizeCategory(arg))
+	case "note":
+		uri = noteURI(arg)
+		_, err := s.svc.GetByID(sess.ctx, arg)
+		valid = err == nil
+	}
+
+	switch {
+	case !valid && subscribe:
+		resp["error"] = map[string]any{"code": mcp.INVALID_PARAMS, "message": "unknown resource URI " + req.Params.URI}
+	case subscribe:
+		s.subs.add(sessionID, uri)
+		resp["result"] = map[string]any{}
+	default:
+		s.subs.remove(sessionID, uri)
+		resp["result"] = map[string]any{}
+	}
+	return resp, true
+}
@@ -0,0 +1,214 @@
+package mcp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"scratchpad/internal/notes"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// testSession is an HTTP-like client session that collects the
+// notifications sent to it
+type testSession struct {
+	id            string
+	notifications chan mcp.JSONRPCNotification
+
+	mu        sync.Mutex
+	resources map[string]server.ServerResource
+}
+
+func newTestSession(id string) *testSession {
+	return &testSession{id: id, notifications: make(chan mcp.JSONRPCNotification, 100)}
+}
+
+func (s *testSession) Initialize()       {}
+func (s *testSession) Initialized() bool { return true }
+func (s *testSession) SessionID() string { return s.id }
+
+func (s *testSession) NotificationChannel() chan<- mcp.JSONRPCNotification {
+	return s.notifications
+}
+
+func (s *testSession) GetSessionResources() map[string]server.ServerResource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.resources
+}
+
+func (s *testSession) SetSessionResources(resources map[string]server.ServerResource) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resources = resources
+}
+
+// received drains the notifications sent so far, returning the URIs of
+// resources/updated ones and how many list_changed arrived
+func (s *testSession) received() (updated []string, listChanged int) {
+	for {
+		select {
+		case n := <-s.notifications:
+			switch n.Method {
+			case mcp.MethodNotificationResourceUpdated:
+				updated = append(updated, n.Params.AdditionalFields["uri"].(string))
+			case mcp.MethodNotificationResourcesListChanged:
+				listChanged++
+			}
+		default:
+			return updated, listChanged
+		}
+	}
+}
+
+func newTestServer(t *testing.T) (*Server, *notes.Service) {
+	t.Helper()
+	svc := notes.NewService(notes.NewMemoryRepo())
+	return NewServer(svc, Options{}), svc
+}
+
+// connect registers a session the way the HTTP transport does after
+// initialize; ctx carries what the client authenticated as
+func connect(t *testing.T, s *Server, ctx context.Context, id string) *testSession {
+	t.Helper()
+	sess := newTestSession(id)
+	if err := s.RegisterSession(ctx, sess); err != nil {
+		t.Fatalf("register session: %v", err)
+	}
+	t.Cleanup(func() { s.UnregisterSession(ctx, id) })
+	return sess
+}
+
+func createNote(t *testing.T, svc *notes.Service, category, content string) *notes.Note {
+	t.Helper()
+	res, err := svc.Create(context.Background(), notes.CreateNoteInput{Category: category, Content: content})
+	if err != nil {
+		t.Fatalf("create note: %v", err)
+	}
+	return res.Note
+}
+
+func TestBindSessionListsCategoriesQuietly(t *testing.T) {
+	s, svc := newTestServer(t)
+	for _, c := range []string{"ideas", "work", "reading", "journal"} {
+		createNote(t, svc, c, "note in "+c)
+	}
+
+	sess := connect(t, s, context.Background(), "s1")
+	if _, listChanged := sess.received(); listChanged != 0 {
+		t.Errorf("got %d list_changed notifications on connect, want 0", listChanged)
+	}
+	if got := len(sess.GetSessionResources()); got != 4 {
+		t.Errorf("got %d category resources, want 4", got)
+	}
+
+	createNote(t, svc, "ideas", "another idea")
+	if _, listChanged := sess.received(); listChanged != 0 {
+		t.Errorf("got %d list_changed notifications for a note in a listed category, want 0", listChanged)
+	}
+	createNote(t, svc, "travel", "a new category")
+	if _, listChanged := sess.received(); listChanged != 1 {
+		t.Errorf("got %d list_changed notifications for a new category, want 1", listChanged)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	s, svc := newTestServer(t)
+	own := createNote(t, svc, "ideas", "visible")
+	other := createNote(t, svc, "secret", "hidden")
+
+	restricted := notes.WithCategoryAccess(context.Background(), []string{"ideas"})
+	connect(t, s, restricted, "restricted")
+
+	tests := []struct {
+		name      string
+		sessionID string
+		uri       string
+		wantErr   bool
+	}{
+		{"categories", "restricted", categoriesURI, false},
+		{"readable category", "restricted", categoryURI("ideas"), false},
+		{"readable note", "restricted", noteURI(own.ID.Hex()), false},
+		{"unreadable category", "restricted", categoryURI("secret"), true},
+		{"unreadable note", "restricted", noteURI(other.ID.Hex()), true},
+		{"missing note", "restricted", noteURI("000000000000000000000000"), true},
+		{"unknown uri", "restricted", "scratchpad://nope", true},
+		{"no session", "", categoriesURI, true},
+		{"unknown session", "made-up", categoriesURI, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := rpcRequest{Method: methodResourcesSubscribe}
+			req.Params.URI = tt.uri
+			resp, ok := s.subscriptionResponse(tt.sessionID, req)
+			if !ok {
+				t.Fatal("subscribe was not handled")
+			}
+			if _, gotErr := resp["error"]; gotErr != tt.wantErr {
+				t.Errorf("error = %v, want error %v", resp["error"], tt.wantErr)
+			}
+			if subscribed := s.subs.subscribed(tt.sessionID, tt.uri); subscribed == tt.wantErr {
+				t.Errorf("subscribed = %v, want %v", subscribed, !tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNotificationsRespectCategoryAccess(t *testing.T) {
+	s, svc := newTestServer(t)
+	createNote(t, svc, "ideas", "first idea")
+	secret := createNote(t, svc, "secret", "first secret")
+
+	restricted := connect(t, s, notes.WithCategoryAccess(context.Background(), []string{"ideas"}), "restricted")
+	full := connect(t, s, context.Background(), "full")
+	for _, sess := range []*testSession{restricted, full} {
+		for _, uri := range []string{categoriesURI, categoryURI("ideas")} {
+			req := rpcRequest{Method: methodResourcesSubscribe}
+			req.Params.URI = uri
+			s.subscriptionResponse(sess.id, req)
+		}
+	}
+	// The full session also watches the secret category and note
+	for _, uri := range []string{categoryURI("secret"), noteURI(secret.ID.Hex())} {
+		req := rpcRequest{Method: methodResourcesSubscribe}
+		req.Params.URI = uri
+		s.subscriptionResponse(full.id, req)
+	}
+
+	createNote(t, svc, "secret", "second secret")
+	if got, _ := restricted.received(); len(got) != 0 {
+		t.Errorf("restricted session was told about %v", got)
+	}
+	if got, _ := full.received(); len(got) != 2 {
+		t.Errorf("full session got updates %v, want secret and the category list", got)
+	}
+
+	// Moving a note out of sight is news to whoever could see it before
+	ideas := "ideas"
+	if _, err := svc.Update(context.Background(), secret.ID.Hex(), notes.UpdateNoteInput{Category: &ideas}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := restricted.received(); len(got) != 2 {
+		t.Errorf("restricted session got updates %v, want ideas and the category list", got)
+	}
+	if got, _ := full.received(); len(got) != 4 {
+		t.Errorf("full session got updates %v, want both categories, the note and the category list", got)
+	}
+}
+
+func TestHTTPHandlerRejectsLargeBodies(t *testing.T) {
+	s, _ := newTestServer(t)
+	body := `{"jsonrpc":"2.0","id":1,"method":"ping","params":{"pad":"` + strings.Repeat("x", maxRPCBodyBytes) + `"}}`
+	r := httptest.NewRequest(http.MethodPost, "/mcp", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	s.HTTPHandler().ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusRequestEntityTooLarge)
+	}
+}
@@ -0,0 +1,206 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+
+	"scratchpad/internal/auth"
+	"scratchpad/internal/notes"
+
+	"github.com/mark3labs/mcp-go/mcp"
+)
+
+// callTool runs a tools/call request through the server the way a client
+// would, returning the text of the result and whether it is an error
+func callTool(t *testing.T, s *Server, ctx context.Context, name string, args map[string]any) (string, bool) {
+	t.Helper()
+	msg, err := json.Marshal(map[string]any{
+		"jsonrpc": "2.0",
+		"id":      1,
+		"method":  "tools/call",
+		"params":  map[string]any{"name": name, "arguments": args},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, ok := s.HandleMessage(ctx, msg).(mcp.JSONRPCResponse)
+	if !ok {
+		t.Fatalf("%s: got %#v, want a response", name, resp)
+	}
+	result, ok := resp.Result.(mcp.CallToolResult)
+	if !ok || len(result.Content) != 1 {
+		t.Fatalf("%s: got result %#v", name, resp.Result)
+	}
+	text, ok := mcp.AsTextContent(result.Content[0])
+	if !ok {
+		t.Fatalf("%s: got content %#v, want text", name, result.Content[0])
+	}
+	return text.Text, result.IsError
+}
+
+// decodeTool calls a tool that must succeed and decodes its JSON result
+func decodeTool[T any](t *testing.T, s *Server, ctx context.Context, name string, args map[string]any) T {
+	t.Helper()
+	text, isErr := callTool(t, s, ctx, name, args)
+	if isErr {
+		t.Fatalf("%s: %s", name, text)
+	}
+	var v T
+	if err := json.Unmarshal([]byte(text), &v); err != nil {
+		t.Fatalf("%s: decode %q: %v", name, text, err)
+	}
+	return v
+}
+
+func TestWriteTools(t *testing.T) {
+	s, svc := newTestServer(t)
+	ctx := context.Background()
+
+	created := decodeTool[CreateResult](t, s, ctx, "create_note", map[string]any{
+		"category": "Content Ideas",
+		"content":  "Thread about MCP servers",
+		"tags":     []string{"#MCP", "threads"},
+	})
+	if created.Category != "content-ideas" || !slices.Equal(created.Tags, []string{"mcp", "threads"}) || created.Version != 1 {
+		t.Fatalf("created %+v, want a normalized version 1 note", created)
+	}
+
+	appended := decodeTool[NoteResult](t, s, ctx, "append_to_note", map[string]any{"id": created.ID, "content": "- with examples"})
+	if appended.Content != "Thread about MCP servers\n\n- with examples" || appended.Version != 2 {
+		t.Errorf("appended note = %q at version %d", appended.Content, appended.Version)
+	}
+
+	updated := decodeTool[NoteResult](t, s, ctx, "update_note", map[string]any{"id": created.ID, "category": "drafts", "tags": []string{}, "version": 2})
+	if updated.Category != "drafts" || len(updated.Tags) != 0 || updated.Version != 3 {
+		t.Errorf("updated note = %+v, want it in drafts without tags at version 3", updated)
+	}
+	if text, isErr := callTool(t, s, ctx, "update_note", map[string]any{"id": created.ID, "content": "stale", "version": 2}); !isErr {
+		t.Errorf("update at a stale version succeeded: %s", text)
+	}
+
+	if text, isErr := callTool(t, s, ctx, "delete_note", map[string]any{"id": created.ID}); isErr {
+		t.Fatalf("delete_note: %s", text)
+	}
+	if _, err := svc.GetByID(ctx, created.ID); err == nil {
+		t.Error("deleted note can still be read")
+	}
+}
+
+func TestReadTools(t *testing.T) {
+	s, svc := newTestServer(t)
+	ctx := context.Background()
+	rust := createNote(t, svc, "dev", "Rust async runtimes compared")
+	createNote(t, svc, "dev", "Go channels and async callbacks")
+	createNote(t, svc, "cooking", "Weeknight ragù")
+
+	t.Run("list_categories", func(t *testing.T) {
+		cats := decodeTool[[]CategoryResult](t, s, ctx, "list_categories", nil)
+		if len(cats) != 2 {
+			t.Errorf("got %d categories, want 2", len(cats))
+		}
+	})
+	t.Run("get_notes", func(t *testing.T) {
+		got := decodeTool[[]NoteResult](t, s, ctx, "get_notes", map[string]any{"category": "dev"})
+		if len(got) != 2 {
+			t.Errorf("got %d notes, want 2", len(got))
+		}
+	})
+	t.Run("search_notes", func(t *testing.T) {
+		page := decodeTool[SearchPageResult](t, s, ctx, "search_notes", map[string]any{"query": "async -callbacks"})
+		if page.Total != 1 || page.Hits[0].ID != rust.ID.Hex() || len(page.Hits[0].Snippets) == 0 {
+			t.Errorf("search = %+v, want the rust note with snippets", page)
+		}
+	})
+	t.Run("get_note", func(t *testing.T) {
+		got := decodeTool[NoteResult](t, s, ctx, "get_note", map[string]any{"id": rust.ID.Hex()})
+		if got.Content != rust.Content {
+			t.Errorf("get_note content = %q, want %q", got.Content, rust.Content)
+		}
+	})
+}
+
+func TestToolErrors(t *testing.T) {
+	s, _ := newTestServer(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		tool    string
+		args    map[string]any
+		wantErr string
+	}{
+		{"missing category", "create_note", map[string]any{"content": "x"}, "category is required"},
+		{"blank content", "create_note", map[string]any{"category": "ideas", "content": " "}, "content is required"},
+		{"bad note ID", "get_note", map[string]any{"id": "nope"}, "invalid note ID"},
+		{"missing note", "get_note", map[string]any{"id": "000000000000000000000000"}, "not found"},
+		{"bad query", "search_notes", map[string]any{"query": "rust OR"}, "position"},
+		{"bad since", "search_notes", map[string]any{"query": "rust", "since": "yesterday"}, "invalid 'since'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, isErr := callTool(t, s, ctx, tt.tool, tt.args)
+			if !isErr || !strings.Contains(text, tt.wantErr) {
+				t.Errorf("got %q (error %v), want an error mentioning %q", text, isErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestToolScopes(t *testing.T) {
+	s, svc := newTestServer(t)
+	note := createNote(t, svc, "ideas", "scoped")
+	keys := auth.NewService(auth.NewMemoryKeyRepo())
+	key := func(scopes ...auth.Scope) context.Context {
+		minted, err := keys.Mint(context.Background(), auth.MintKeyInput{Name: "test", Scopes: scopes})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return auth.WithKey(context.Background(), minted.APIKey)
+	}
+	reader, writer := key(auth.ScopeRead), key(auth.ScopeRead, auth.ScopeWrite)
+
+	tests := []struct {
+		name    string
+		ctx     context.Context
+		tool    string
+		args    map[string]any
+		wantErr bool
+	}{
+		{"reader reads", reader, "get_note", map[string]any{"id": note.ID.Hex()}, false},
+		{"reader writes", reader, "create_note", map[string]any{"category": "ideas", "content": "x"}, true},
+		{"writer writes", writer, "create_note", map[string]any{"category": "ideas", "content": "y"}, false},
+		{"writer deletes", writer, "delete_note", map[string]any{"id": note.ID.Hex()}, true},
+		{"stdio deletes", context.Background(), "delete_note", map[string]any{"id": note.ID.Hex()}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			text, isErr := callTool(t, s, tt.ctx, tt.tool, tt.args)
+			if isErr != tt.wantErr {
+				t.Errorf("got %q (error %v), want error %v", text, isErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+// contendedRepo is a store where every edit loses to a concurrent one
+type contendedRepo struct {
+	*notes.MemoryRepo
+}
+
+func (contendedRepo) Update(context.Context, *notes.Note, int64) error {
+	return notes.ErrVersionConflict
+}
+
+func TestAppendReportsContention(t *testing.T) {
+	svc := notes.NewService(contendedRepo{notes.NewMemoryRepo()})
+	s := NewServer(svc, Options{})
+	note := createNote(t, svc, "ideas", "busy note")
+
+	text, isErr := callTool(t, s, context.Background(), "append_to_note", map[string]any{"id": note.ID.Hex(), "content": "more"})
+	if !isErr || !strings.Contains(text, "try again") {
+		t.Errorf("got %q (error %v), want a retryable error", text, isErr)
+	}
+}
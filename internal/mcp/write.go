@@ -0,0 +1,248 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"scratchpad/internal/auth"
+	"scratchpad/internal/notes"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// toolScopes maps the tools that modify notes to the API key scope they
+// need; every other tool needs auth.ScopeRead
+var toolScopes = map[string]auth.Scope{
+	"create_note":    auth.ScopeWrite,
+	"update_note":    auth.ScopeWrite,
+	"append_to_note": auth.ScopeWrite,
+	"delete_note":    auth.ScopeDelete,
+}
+
+// requireToolScope rejects tool calls the request's API key isn't scoped
+// for, and anonymous calls beyond what the auth middleware lets anonymous
+// requests do. Stdio calls are let through.
+func requireToolScope(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		scope, ok := toolScopes[req.Params.Name]
+		if !ok {
+			scope = auth.ScopeRead
+		}
+		if key, ok := auth.FromContext(ctx); ok && !key.HasScope(scope) {
+			return mcp.NewToolResultError(fmt.Sprintf("API key %q lacks the %s scope needed for %s", key.Name, scope, req.Params.Name)), nil
+		}
+		if scopes, ok := auth.AnonymousScopes(ctx); ok && !slices.Contains(scopes, scope) {
+			return mcp.NewToolResultError(fmt.Sprintf("an API key with the %s scope is needed for %s", scope, req.Params.Name)), nil
+		}
+		return next(ctx, req)
+	}
+}
+
+// CreateResult is what create_note returns
+type CreateResult struct {
+	NoteResult
+	Merged bool `json:"merged,omitempty"` // an existing duplicate got the tags instead
+}
+
+// addWriteTools registers the tools that modify notes. They go through
+// notes.Service, so validation and category normalization match the REST API.
+func addWriteTools(s *server.MCPServer, svc *notes.Service) {
+	// Tool: create_note - Create a new note
+	s.AddTool(
+		mcp.NewTool("create_note",
+			mcp.WithDescription("Create a new note in a category. Categories are normalized (lowercased, spaces become hyphens) and created on first use. Returns the stored note including its ID."),
+			mcp.WithDestructiveHintAnnotation(false),
+			mcp.WithString("category",
+				mcp.Required(),
+				mcp.Description("Category name (e.g., 'twitter-analytics', 'content-ideas')"),
+			),
+			mcp.WithString("content",
+				mcp.Required(),
+				mcp.Description("Note content in markdown"),
+			),
+			mcp.WithArray("tags",
+				mcp.WithStringItems(),
+				mcp.Description("Optional: Tags for the note"),
+			),
+			mcp.WithString("author",
+				mcp.Description("Optional: Who is writing the note, recorded in revision history"),
+			),
+			mcp.WithString("on_duplicate",
+				mcp.Enum("allow", "reject", "merge"),
+				mcp.Description("Optional: What to do if the category already has a note with the same content, ignoring case, punctuation and whitespace: create it anyway, fail, or add the tags to the existing note (default: server setting)"),
+			),
+		),
+		handleCreateNote(svc),
+	)
+
+	// Tool: update_note - Replace content, category or tags
+	s.AddTool(
+		mcp.NewTool("update_note",
+			mcp.WithDescription("Update an existing note. Any of content, category and tags may be given; omitted fields are left unchanged and content replaces the old content entirely. The previous version is kept in revision history."),
+			mcp.WithString("id",
+				mcp.Required(),
+				mcp.Description("The note ID (24-character hex string)"),
+			),
+			mcp.WithString("content",
+				mcp.Description("Optional: New note content in markdown, replacing the current content"),
+			),
+			mcp.WithString("category",
+				mcp.Description("Optional: Move the note to this category"),
+			),
+			mcp.WithArray("tags",
+				mcp.WithStringItems(),
+				mcp.Description("Optional: Replace the note's tags (an empty list removes them)"),
+			),
+			mcp.WithNumber("version",
+				mcp.Description("Optional: Only update if the note is still at this version, as returned by get_note"),
+			),
+			mcp.WithString("author",
+				mcp.Description("Optional: Who is making the change, recorded in revision history"),
+			),
+		),
+		handleUpdateNote(svc),
+	)
+
+	// Tool: append_to_note - Add content to the end of a note
+	s.AddTool(
+		mcp.NewTool("append_to_note",
+			mcp.WithDescription("Append markdown to the end of an existing note as a new paragraph. Concurrent appends are retried rather than overwriting each other; if the note keeps changing, the call fails saying so and can be retried."),
+			mcp.WithDestructiveHintAnnotation(false),
+			mcp.WithString("id",
+				mcp.Required(),
+				mcp.Description("The note ID (24-character hex string)"),
+			),
+			mcp.WithString("content",
+				mcp.Required(),
+				mcp.Description("Markdown to append"),
+			),
+			mcp.WithString("author",
+				mcp.Description("Optional: Who is making the change, recorded in revision history"),
+			),
+		),
+		handleAppendToNote(svc),
+	)
+
+	// Tool: delete_note - Move a note to the trash
+	s.AddTool(
+		mcp.NewTool("delete_note",
+			mcp.WithDescription("Delete a note by moving it to the trash. It can be restored from the web UI or REST API until the trash retention period purges it."),
+			mcp.WithString("id",
+				mcp.Required(),
+				mcp.Description("The note ID (24-character hex string)"),
+			),
+		),
+		handleDeleteNote(svc),
+	)
+}
+
+func handleCreateNote(svc *notes.Service) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		category, err := req.RequireString("category")
+		if err != nil {
+			return mcp.NewToolResultError("category is required"), nil
+		}
+		content, err := req.RequireString("content")
+		if err != nil {
+			return mcp.NewToolResultError("content is required"), nil
+		}
+
+		result, err := svc.Create(ctx, notes.CreateNoteInput{
+			Category:    category,
+			Content:     content,
+			Tags:        req.GetStringSlice("tags", nil),
+			Author:      req.GetString("author", ""),
+			OnDuplicate: notes.DuplicatePolicy(req.GetString("on_duplicate", "")),
+		})
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to create note: %v", err)), nil
+		}
+
+		data, _ := json.MarshalIndent(CreateResult{
+			NoteResult: noteToResult(result.Note),
+			Merged:     result.Merged,
+		}, "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	}
+}
+
+func handleUpdateNote(svc *notes.Service) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, err := req.RequireString("id")
+		if err != nil {
+			return mcp.NewToolResultError("id is required"), nil
+		}
+
+		args := req.GetArguments()
+		input := notes.UpdateNoteInput{
+			Author:  req.GetString("author", ""),
+			Version: int64(req.GetInt("version", 0)),
+		}
+		if _, ok := args["content"]; ok {
+			content := req.GetString("content", "")
+			input.Content = &content
+		}
+		if _, ok := args["category"]; ok {
+			category := req.GetString("category", "")
+			input.Category = &category
+		}
+		if _, ok := args["tags"]; ok {
+			tags := req.GetStringSlice("tags", nil)
+			input.Tags = &tags
+		}
+
+		note, err := svc.Update(ctx, id, input)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to update note: %v", err)), nil
+		}
+
+		data, _ := json.MarshalIndent(noteToResult(note), "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	}
+}
+
+func handleAppendToNote(svc *notes.Service) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, err := req.RequireString("id")
+		if err != nil {
+			return mcp.NewToolResultError("id is required"), nil
+		}
+		content, err := req.RequireString("content")
+		if err != nil {
+			return mcp.NewToolResultError("content is required"), nil
+		}
+
+		note, err := svc.Update(ctx, id, notes.UpdateNoteInput{
+			Append: content,
+			Author: req.GetString("author", ""),
+		})
+		if errors.Is(err, notes.ErrVersionConflict) {
+			return mcp.NewToolResultError("the note kept changing while appending to it and nothing was appended; try again"), nil
+		}
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to append to note: %v", err)), nil
+		}
+
+		data, _ := json.MarshalIndent(noteToResult(note), "", "  ")
+		return mcp.NewToolResultText(string(data)), nil
+	}
+}
+
+func handleDeleteNote(svc *notes.Service) server.ToolHandlerFunc {
+	return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, err := req.RequireString("id")
+		if err != nil {
+			return mcp.NewToolResultError("id is required"), nil
+		}
+
+		if err := svc.Delete(ctx, id); err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("failed to delete note: %v", err)), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("Note %s moved to the trash", id)), nil
+	}
+}
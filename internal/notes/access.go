@@ -0,0 +1,57 @@
+package notes
+
+import (
+	"context"
+	"slices"
+)
+
+type categoryAccessKey struct{}
+
+// WithCategoryAccess limits everything the Service reads or writes on
+// behalf of ctx to the given categories. Notes elsewhere behave as if they
+// don't exist, and creating or moving notes into other categories fails
+// with ErrForbidden. An empty list leaves ctx unrestricted.
+func WithCategoryAccess(ctx context.Context, categories []string) context.Context {
+	if len(categories) == 0 {
+		return ctx
+	}
+	normalized := make([]string, 0, len(categories))
+	for _, c := range categories {
+		if c = normalizeCategory(c); c != "" {
+			normalized = append(normalized, c)
+		}
+	}
+	return context.WithValue(ctx, categoryAccessKey{}, normalized)
+}
+
+// categoryAccess returns the categories ctx is limited to, or nil when it
+// isn't restricted
+func categoryAccess(ctx context.Context) []string {
+	categories, _ := ctx.Value(categoryAccessKey{}).([]string)
+	return categories
+}
+
+// canAccess reports whether ctx may touch notes in category
+func canAccess(ctx context.Context, category string) bool {
+	allowed := categoryAccess(ctx)
+	return allowed == nil || slices.Contains(allowed, category)
+}
+
+// CanAccess reports whether ctx may touch notes in category, for packages
+// that pass on news of notes outside the Service
+func CanAccess(ctx context.Context, category string) bool {
+	return canAccess(ctx, category)
+}
+
+// scopeQuery narrows a category filter to what ctx may see. ok is false when
+// the requested category is off limits, in which case nothing matches.
+func scopeQuery(ctx context.Context, category string) (categories []string, ok bool) {
+	allowed := categoryAccess(ctx)
+	if allowed == nil {
+		return nil, true
+	}
+	if category != "" && !slices.Contains(allowed, category) {
+		return nil, false
+	}
+	return allowed, true
+}
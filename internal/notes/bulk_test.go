@@ -0,0 +1,158 @@
+package notes
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDecodeBulk(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		want      []string // content of each item, "!" for a parse error
+		wantErr   string
+		errOnLine string
+	}{
+		{name: "empty", body: "  \n"},
+		{name: "array", body: ` [{"category":"a","content":"one"},{"category":"b","content":"two"}]`, want: []string{"one", "two"}},
+		{name: "empty array", body: `[]`},
+		{name: "malformed array", body: `[{"category":"a","content":"one"},]`, wantErr: "invalid JSON array"},
+		{name: "ndjson", body: "{\"category\":\"a\",\"content\":\"one\"}\n\n{\"category\":\"b\",\"content\":\"two\"}", want: []string{"one", "two"}},
+		{
+			name:      "ndjson with a bad line",
+			body:      "{\"category\":\"a\",\"content\":\"one\"}\n\n{\"category\":\n{\"category\":\"b\",\"content\":\"two\"}\n",
+			want:      []string{"one", "!", "two"},
+			errOnLine: "line 3: invalid JSON",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			inputs, parseErrs, err := decodeBulk(bufio.NewReader(strings.NewReader(tt.body)))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(inputs) != len(tt.want) || len(parseErrs) != len(tt.want) {
+				t.Fatalf("got %d inputs and %d errors, want %d", len(inputs), len(parseErrs), len(tt.want))
+			}
+			for i, want := range tt.want {
+				if want == "!" {
+					if parseErrs[i] == nil || !strings.Contains(parseErrs[i].Error(), tt.errOnLine) {
+						t.Errorf("item %d: err = %v, want %q", i, parseErrs[i], tt.errOnLine)
+					}
+					continue
+				}
+				if parseErrs[i] != nil || inputs[i].Content != want {
+					t.Errorf("item %d = %q, %v; want %q", i, inputs[i].Content, parseErrs[i], want)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateMany(t *testing.T) {
+	for name, svc := range newStoreServices(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			mustCreate(t, svc, CreateNoteInput{Category: "log", Content: "already stored"})
+
+			// More than a batch, with an invalid item and a duplicate check
+			// in the middle of one
+			inputs := make([]CreateNoteInput, bulkBatchSize+50)
+			for i := range inputs {
+				inputs[i] = CreateNoteInput{Category: "log", Content: fmt.Sprintf("entry %d", i)}
+			}
+			inputs[10].Content = " "
+			inputs[20] = CreateNoteInput{Category: "log", Content: "already stored", OnDuplicate: DuplicatesReject}
+			inputs[30] = CreateNoteInput{Category: "log", Content: "already stored", Tags: []string{"again"}, OnDuplicate: DuplicatesMerge}
+
+			results := svc.CreateMany(ctx, inputs)
+			if len(results) != len(inputs) {
+				t.Fatalf("got %d results, want %d", len(results), len(inputs))
+			}
+			for i, res := range results {
+				want := BulkCreated
+				switch i {
+				case 10, 20:
+					want = BulkFailed
+				case 30:
+					want = BulkMerged
+				}
+				if res.Index != i || res.Status != want {
+					t.Errorf("result %d = %+v, want index %d %s", i, res, i, want)
+				}
+				if (res.ID == "") != (want == BulkFailed) || (res.Error != "") != (want == BulkFailed) {
+					t.Errorf("result %d = %+v, want an ID or an error", i, res)
+				}
+			}
+
+			count, err := svc.Count(ctx, "log")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := int64(len(inputs) - 3 + 1); count != want {
+				t.Errorf("stored %d notes, want %d", count, want)
+			}
+			created, err := svc.GetByID(ctx, results[len(results)-1].ID)
+			if err != nil || created.Content != fmt.Sprintf("entry %d", len(inputs)-1) {
+				t.Errorf("last note = %v, %v; want the last entry", created, err)
+			}
+		})
+	}
+}
+
+func TestCreateNotesBulk(t *testing.T) {
+	mux, _ := newTestMux(t)
+
+	tests := []struct {
+		name                    string
+		body                    string
+		want                    int
+		created, merged, failed int
+	}{
+		{name: "all created", body: `[{"category":"a","content":"one"},{"category":"a","content":"two"}]`, want: http.StatusCreated, created: 2},
+		{
+			name:    "some failed",
+			body:    "{\"category\":\"a\",\"content\":\"three\"}\nnot json\n{\"category\":\"\",\"content\":\"four\"}\n{\"category\":\"a\",\"content\":\"one\",\"onDuplicate\":\"merge\"}\n",
+			want:    http.StatusMultiStatus,
+			created: 1, merged: 1, failed: 2,
+		},
+		{name: "empty", body: "", want: http.StatusBadRequest},
+		{name: "malformed array", body: `[{"category":"a"`, want: http.StatusBadRequest},
+		{name: "too many", body: strings.Repeat("{\"category\":\"a\",\"content\":\"x\"}\n", maxBulkItems+1), want: http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(mux, http.MethodPost, "/api/notes/bulk", tt.body)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if tt.want == http.StatusBadRequest {
+				return
+			}
+			var resp BulkCreateResponse
+			if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Created != tt.created || resp.Merged != tt.merged || resp.Failed != tt.failed {
+				t.Errorf("got %d created, %d merged, %d failed; want %d, %d, %d",
+					resp.Created, resp.Merged, resp.Failed, tt.created, tt.merged, tt.failed)
+			}
+			for i, res := range resp.Results {
+				if res.Index != i {
+					t.Errorf("result %d has index %d", i, res.Index)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package notes
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultBusBacklog is how many recent events a Bus keeps for clients
+	// resuming with Last-Event-ID
+	DefaultBusBacklog = 1024
+	// subscriberBuffer is how far a subscriber may fall behind before it is
+	// dropped; it reconnects and catches up from the backlog
+	subscriberBuffer = 256
+)
+
+// BusEvent is an Event numbered by the Bus that carried it
+type BusEvent struct {
+	ID uint64
+	Event
+}
+
+// Bus fans note events out to live subscribers, such as the SSE feed, and
+// keeps a backlog so they can resume where they left off. Event IDs start
+// from the time the Bus was created, so IDs from before a restart read as
+// too old rather than colliding with new ones.
+type Bus struct {
+	mu      sync.Mutex
+	next    uint64
+	backlog []BusEvent // ring buffer, oldest at start once full
+	start   int
+	subs    map[*Subscription]struct{}
+	closed  bool
+}
+
+// Subscription receives events published after it was made. C is closed
+// when the subscriber falls too far behind or the Bus is closed.
+type Subscription struct {
+	C   <-chan BusEvent
+	c   chan BusEvent
+	bus *Bus
+}
+
+func NewBus(backlog int) *Bus {
+	return &Bus{
+		next:    uint64(time.Now().UnixMicro()),
+		backlog: make([]BusEvent, 0, max(backlog, 1)),
+		subs:    make(map[*Subscription]struct{}),
+	}
+}
+
+// Publish numbers an event and hands it to every subscriber. It has the
+// Listener signature, so a Bus can be fed with Service.OnChange.
+func (b *Bus) Publish(ctx context.Context, e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	be := BusEvent{ID: b.next, Event: e}
+	b.next++
+	if len(b.backlog) < cap(b.backlog) {
+		b.backlog = append(b.backlog, be)
+	} else {
+		b.backlog[b.start] = be
+		b.start = (b.start + 1) % len(b.backlog)
+	}
+
+	for sub := range b.subs {
+		select {
+		case sub.c <- be:
+		default:
+			b.drop(sub)
+		}
+	}
+}
+
+// Subscribe starts a subscription. With a lastID, the events published
+// since are returned as backlog; complete is false when some of them are no
+// longer kept, or lastID is unknown, and the client should reload instead.
+func (b *Bus) Subscribe(lastID uint64) (sub *Subscription, backlog []BusEvent, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := make(chan BusEvent, subscriberBuffer)
+	sub = &Subscription{C: c, c: c, bus: b}
+	if b.closed {
+		close(c)
+		return sub, nil, true
+	}
+	b.subs[sub] = struct{}{}
+
+	if lastID == 0 {
+		return sub, nil, true
+	}
+	oldest := b.next
+	if len(b.backlog) > 0 {
+		oldest = b.backlog[b.start].ID
+	}
+	complete = lastID+1 >= oldest && lastID < b.next
+	for i := range b.backlog {
+		be := b.backlog[(b.start+i)%len(b.backlog)]
+		if be.ID > lastID {
+			backlog = append(backlog, be)
+		}
+	}
+	return sub, backlog, complete
+}
+
+// LastID is the ID of the latest event, for pages that start following the
+// feed from where they were rendered
+func (b *Bus) LastID() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.next - 1
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.drop(s)
+}
+
+// drop removes a subscriber and closes its channel. Must be called with
+// mu held.
+func (b *Bus) drop(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.c)
+	}
+}
+
+// Close ends every subscription, letting long-lived streams finish so the
+// server can shut down
+func (b *Bus) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		b.drop(sub)
+	}
+}
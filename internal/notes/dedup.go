@@ -0,0 +1,249 @@
+package notes
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/bits"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// DefaultMinSimilarity is the similarity two notes need to count as near
+// duplicates. Unrelated notes hover around 0.5, as half the SimHash bits
+// agree by chance.
+const DefaultMinSimilarity = 0.8
+
+// fingerprint sets the content hashes used to spot duplicates. Content is
+// normalized first: case, punctuation and whitespace don't count.
+func (n *Note) fingerprint() {
+	tokens := contentTokens(n.Content)
+	sum := sha256.Sum256([]byte(strings.Join(tokens, " ")))
+	n.ContentHash = hex.EncodeToString(sum[:])
+	n.SimHash = simHash(tokens)
+}
+
+// contentTokens splits content into lowercase words
+func contentTokens(content string) []string {
+	return strings.FieldsFunc(strings.ToLower(content), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// simHash computes a 64-bit SimHash over words and word pairs. Notes that
+// share most of their wording end up a few bits apart.
+func simHash(tokens []string) int64 {
+	var weights [64]int
+	add := func(feature string) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		for bit := range weights {
+			if sum&(1<<bit) != 0 {
+				weights[bit]++
+			} else {
+				weights[bit]--
+			}
+		}
+	}
+	for i, tok := range tokens {
+		add(tok)
+		if i > 0 {
+			add(tokens[i-1] + " " + tok)
+		}
+	}
+
+	var out uint64
+	for bit, w := range weights {
+		if w > 0 {
+			out |= 1 << bit
+		}
+	}
+	return int64(out)
+}
+
+// similarity scores two fingerprints from 0 to 1; matching content hashes
+// always score 1
+func similarity(a, b *Fingerprint) float64 {
+	if a.ContentHash != "" && a.ContentHash == b.ContentHash {
+		return 1
+	}
+	return 1 - float64(bits.OnesCount64(uint64(a.SimHash^b.SimHash)))/64
+}
+
+// checkMinSimilarity validates a similarity threshold, defaulting 0 to
+// DefaultMinSimilarity
+func checkMinSimilarity(sim float64) (float64, error) {
+	switch {
+	case sim == 0:
+		return DefaultMinSimilarity, nil
+	case sim < 0 || sim > 1:
+		return 0, fmt.Errorf("%w: similarity must be between 0 and 1", ErrInvalidInput)
+	}
+	return sim, nil
+}
+
+// Similar returns the notes closest to a note, most similar first
+func (s *Service) Similar(ctx context.Context, id string, q SimilarQuery) ([]*SimilarNote, error) {
+	minSim, err := checkMinSimilarity(q.MinSimilarity)
+	if err != nil {
+		return nil, err
+	}
+	note, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	categories, ok := scopeQuery(ctx, normalizeCategory(q.Category))
+	if !ok {
+		return []*SimilarNote{}, nil
+	}
+	candidates, err := s.repo.ListFingerprints(ctx, normalizeCategory(q.Category), categories)
+	if err != nil {
+		return nil, err
+	}
+
+	self := &Fingerprint{ContentHash: note.ContentHash, SimHash: note.SimHash}
+	type match struct {
+		fp  *Fingerprint
+		sim float64
+	}
+	var matches []match
+	for _, fp := range candidates {
+		if fp.ID == note.ID {
+			continue
+		}
+		if sim := similarity(self, fp); sim >= minSim {
+			matches = append(matches, match{fp, sim})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].sim != matches[j].sim {
+			return matches[i].sim > matches[j].sim
+		}
+		return matches[i].fp.CreatedAt.After(matches[j].fp.CreatedAt)
+	})
+
+	limit := clampLimit(q.Limit, 10, 50)
+	similar := make([]*SimilarNote, 0, min(limit, len(matches)))
+	for _, m := range matches[:min(limit, len(matches))] {
+		n, err := s.repo.FindByID(ctx, m.fp.ID)
+		if errors.Is(err, ErrNoteNotFound) {
+			continue // deleted meanwhile
+		}
+		if err != nil {
+			return nil, err
+		}
+		similar = append(similar, &SimilarNote{Note: n, Similarity: m.sim})
+	}
+	return similar, nil
+}
+
+// FindDuplicates clusters near-duplicate notes. Notes are linked when their
+// similarity reaches the threshold and clusters are whatever stays
+// connected, so they never span categories. Largest clusters come first.
+func (s *Service) FindDuplicates(ctx context.Context, q DuplicatesQuery) ([]*DuplicateCluster, error) {
+	minSim, err := checkMinSimilarity(q.MinSimilarity)
+	if err != nil {
+		return nil, err
+	}
+	category := normalizeCategory(q.Category)
+	categories, ok := scopeQuery(ctx, category)
+	if !ok {
+		return []*DuplicateCluster{}, nil
+	}
+	fps, err := s.repo.ListFingerprints(ctx, category, categories)
+	if err != nil {
+		return nil, err
+	}
+
+	byCategory := make(map[string][]*Fingerprint)
+	for _, fp := range fps {
+		byCategory[fp.Category] = append(byCategory[fp.Category], fp)
+	}
+
+	var clusters []*DuplicateCluster
+	for cat, group := range byCategory {
+		clusters = append(clusters, clusterFingerprints(cat, group, minSim)...)
+	}
+	sort.SliceStable(clusters, func(i, j int) bool {
+		if len(clusters[i].Notes) != len(clusters[j].Notes) {
+			return len(clusters[i].Notes) > len(clusters[j].Notes)
+		}
+		return clusters[i].Notes[0].CreatedAt.After(clusters[j].Notes[0].CreatedAt)
+	})
+	clusters = clusters[:min(clampLimit(q.Limit, 20, 100), len(clusters))]
+
+	// Clusters hold bare IDs until now; load the notes for those returned
+	for _, c := range clusters {
+		loaded := c.Notes[:0]
+		for _, stub := range c.Notes {
+			n, err := s.repo.FindByID(ctx, stub.ID)
+			if errors.Is(err, ErrNoteNotFound) {
+				continue // deleted meanwhile
+			}
+			if err != nil {
+				return nil, err
+			}
+			loaded = append(loaded, n)
+		}
+		c.Notes = loaded
+	}
+	return clusters, nil
+}
+
+// clusterFingerprints links every pair of notes at least minSim alike with
+// union-find and returns the groups of two or more. Notes are stubs holding
+// only ID and CreatedAt.
+func clusterFingerprints(category string, fps []*Fingerprint, minSim float64) []*DuplicateCluster {
+	parent := make([]int, len(fps))
+	weakest := make([]float64, len(fps))
+	for i := range parent {
+		parent[i] = i
+		weakest[i] = 1
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range fps {
+		for j := i + 1; j < len(fps); j++ {
+			sim := similarity(fps[i], fps[j])
+			if sim < minSim {
+				continue
+			}
+			ri, rj := find(i), find(j)
+			if ri != rj {
+				parent[rj] = ri
+				weakest[ri] = min(weakest[ri], weakest[rj], sim)
+			}
+		}
+	}
+
+	groups := make(map[int][]int)
+	for i := range fps {
+		root := find(i)
+		groups[root] = append(groups[root], i)
+	}
+
+	var clusters []*DuplicateCluster
+	for root, members := range groups {
+		if len(members) < 2 {
+			continue
+		}
+		c := &DuplicateCluster{Category: category, MinSimilarity: weakest[root]}
+		for _, i := range members {
+			c.Notes = append(c.Notes, &Note{ID: fps[i].ID, CreatedAt: fps[i].CreatedAt})
+		}
+		sortNewestFirst(c.Notes)
+		clusters = append(clusters, c)
+	}
+	return clusters
+}
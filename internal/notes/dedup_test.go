@@ -0,0 +1,132 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// bitsSet returns a SimHash with the lowest n bits set, so it is n bits
+// away from zero
+func bitsSet(n int) int64 {
+	return int64(uint64(1)<<n - 1)
+}
+
+func TestSimilarity(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Fingerprint
+		want float64
+	}{
+		{"same hash", Fingerprint{ContentHash: "x", SimHash: 0}, Fingerprint{ContentHash: "x", SimHash: -1}, 1},
+		{"same simhash", Fingerprint{SimHash: 42}, Fingerprint{SimHash: 42}, 1},
+		{"12 bits apart", Fingerprint{}, Fingerprint{SimHash: bitsSet(12)}, 0.8125},
+		{"13 bits apart", Fingerprint{}, Fingerprint{SimHash: bitsSet(13)}, 0.796875},
+		{"half the bits", Fingerprint{}, Fingerprint{SimHash: bitsSet(32)}, 0.5},
+		{"opposite", Fingerprint{}, Fingerprint{SimHash: -1}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := similarity(&tt.a, &tt.b); got != tt.want {
+				t.Errorf("similarity = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckMinSimilarity(t *testing.T) {
+	tests := []struct {
+		in      float64
+		want    float64
+		wantErr bool
+	}{
+		{0, DefaultMinSimilarity, false},
+		{0.5, 0.5, false},
+		{1, 1, false},
+		{-0.1, 0, true},
+		{1.5, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := checkMinSimilarity(tt.in)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("checkMinSimilarity(%v) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidInput) {
+			t.Errorf("checkMinSimilarity(%v): err = %v, want %v", tt.in, err, ErrInvalidInput)
+		}
+	}
+}
+
+func TestClusterThreshold(t *testing.T) {
+	// a-b and b-c are 12 bits apart, a-c 24, and d is 13 bits from a and
+	// further from the rest
+	fps := []*Fingerprint{
+		{ID: primitive.NewObjectID(), SimHash: 0},
+		{ID: primitive.NewObjectID(), SimHash: bitsSet(12)},
+		{ID: primitive.NewObjectID(), SimHash: bitsSet(24)},
+		{ID: primitive.NewObjectID(), SimHash: bitsSet(13) << 40},
+	}
+
+	tests := []struct {
+		name        string
+		minSim      float64
+		wantSizes   []int
+		wantWeakest float64
+	}{
+		{"default", DefaultMinSimilarity, []int{3}, 0.8125},
+		{"exactly the link", 0.8125, []int{3}, 0.8125},
+		{"just above the link", 0.82, nil, 0},
+		{"loose enough for d", 0.79, []int{4}, 0.796875},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clusters := clusterFingerprints("ideas", fps, tt.minSim)
+			var sizes []int
+			for _, c := range clusters {
+				sizes = append(sizes, len(c.Notes))
+			}
+			if !slices.Equal(sizes, tt.wantSizes) {
+				t.Fatalf("cluster sizes = %v, want %v", sizes, tt.wantSizes)
+			}
+			if len(clusters) == 1 && clusters[0].MinSimilarity != tt.wantWeakest {
+				t.Errorf("weakest link = %v, want %v", clusters[0].MinSimilarity, tt.wantWeakest)
+			}
+		})
+	}
+}
+
+func TestSimilarNotes(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	orig := mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "Write a thread about running MCP servers locally with SQLite and a single binary"})
+	near := mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "Write a thread about running MCP servers locally with SQLite and one binary"})
+	exact := mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "write a thread about running MCP servers, locally, with SQLite and a single binary!"})
+	mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "Groceries: eggs, flour, butter and a bag of coffee beans"})
+	mustCreate(t, svc, CreateNoteInput{Category: "other", Content: orig.Content})
+
+	similar, err := svc.Similar(ctx, orig.ID.Hex(), SimilarQuery{Category: "ideas"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, n := range similar {
+		ids = append(ids, n.ID.Hex())
+	}
+	if want := []string{exact.ID.Hex(), near.ID.Hex()}; !slices.Equal(ids, want) {
+		t.Fatalf("similar = %v, want the exact then the near duplicate %v", ids, want)
+	}
+	if similar[0].Similarity != 1 || similar[1].Similarity < DefaultMinSimilarity || similar[1].Similarity == 1 {
+		t.Errorf("similarities = %v and %v, want 1 and at least %v", similar[0].Similarity, similar[1].Similarity, DefaultMinSimilarity)
+	}
+
+	strict, err := svc.Similar(ctx, orig.ID.Hex(), SimilarQuery{Category: "ideas", MinSimilarity: 1})
+	if err != nil || len(strict) != 1 || strict[0].ID != exact.ID {
+		t.Errorf("at similarity 1: got %d notes, %v; want only the exact duplicate", len(strict), err)
+	}
+	if _, err := svc.Similar(ctx, orig.ID.Hex(), SimilarQuery{MinSimilarity: 2}); !errors.Is(err, ErrInvalidInput) {
+		t.Errorf("similarity 2: err = %v, want %v", err, ErrInvalidInput)
+	}
+}
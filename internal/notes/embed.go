@@ -0,0 +1,258 @@
+package notes
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Embedder turns text into vectors that lie close together when the texts
+// are alike in meaning
+type Embedder interface {
+	// Model names what makes the vectors; vectors of different models are
+	// never compared
+	Model() string
+	// Embed returns a vector per text, in order
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+var (
+	_ Embedder = (*HashEmbedder)(nil)
+	_ Embedder = (*HTTPEmbedder)(nil)
+)
+
+// Vector is an embedding. It is stored as packed little-endian float32s,
+// a quarter of the size of an array of doubles.
+type Vector []float32
+
+func (v Vector) MarshalBSONValue() (bsontype.Type, []byte, error) {
+	return bson.MarshalValue(primitive.Binary{Data: v.bytes()})
+}
+
+func (v *Vector) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	var b primitive.Binary
+	if err := (bson.RawValue{Type: t, Value: data}).Unmarshal(&b); err != nil {
+		return fmt.Errorf("decode vector: %w", err)
+	}
+	vec, err := vectorFromBytes(b.Data)
+	if err != nil {
+		return err
+	}
+	*v = vec
+	return nil
+}
+
+func (v Vector) bytes() []byte {
+	buf := make([]byte, 4*len(v))
+	for i, x := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(x))
+	}
+	return buf
+}
+
+func vectorFromBytes(b []byte) (Vector, error) {
+	if len(b)%4 != 0 {
+		return nil, fmt.Errorf("decode vector: %d bytes is not a whole number of float32s", len(b))
+	}
+	v := make(Vector, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v, nil
+}
+
+// normalize scales v to unit length in place, so the dot product of two
+// vectors is their cosine similarity
+func normalize(v Vector) {
+	var sum float64
+	for _, x := range v {
+		sum += float64(x) * float64(x)
+	}
+	if sum == 0 {
+		return
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range v {
+		v[i] /= norm
+	}
+}
+
+// dot returns the dot product of two vectors, 0 when their sizes differ
+func dot(a, b Vector) float64 {
+	if len(a) != len(b) {
+		return 0
+	}
+	var sum float64
+	for i := range a {
+		sum += float64(a[i]) * float64(b[i])
+	}
+	return sum
+}
+
+// DefaultHashDims is the size of HashEmbedder vectors
+const DefaultHashDims = 512
+
+// trigramWeight is how much each character trigram of a word counts
+// relative to the whole word
+const trigramWeight = 0.3
+
+// HashEmbedder is the built-in Embedder, needing neither a model nor the
+// network. Words and their character trigrams are hashed into a fixed-size
+// vector, weighted by sublinear term frequency; common function words are
+// left out. Notes sharing words, or parts of words such as "follow" and
+// "followers", come out close. Synonyms don't, which is what an embedding
+// server is for.
+type HashEmbedder struct {
+	dims int
+}
+
+func NewHashEmbedder(dims int) *HashEmbedder {
+	return &HashEmbedder{dims: max(dims, 1)}
+}
+
+func (e *HashEmbedder) Model() string {
+	return fmt.Sprintf("hash-%d", e.dims)
+}
+
+// Embed hashes each text; it never fails
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+func (e *HashEmbedder) embed(text string) []float32 {
+	words := make(map[string]int)
+	grams := make(map[string]int)
+	for _, tok := range contentTokens(text) {
+		if stopWords[tok] {
+			continue
+		}
+		words[tok]++
+		padded := []rune("<" + tok + ">")
+		for i := 0; i+3 <= len(padded); i++ {
+			grams[string(padded[i:i+3])]++
+		}
+	}
+
+	v := make([]float32, e.dims)
+	add := func(feature string, count int, weight float64) {
+		h := fnv.New64a()
+		h.Write([]byte(feature))
+		sum := h.Sum64()
+		// The top bit picks a sign, so colliding features tend to cancel
+		// out rather than pile up
+		w := float32(weight * (1 + math.Log(float64(count))))
+		if sum>>63 == 1 {
+			w = -w
+		}
+		v[sum%uint64(e.dims)] += w
+	}
+	for word, n := range words {
+		add("w:"+word, n, 1)
+	}
+	for gram, n := range grams {
+		add("g:"+gram, n, trigramWeight)
+	}
+	return v
+}
+
+// stopWords are left out of HashEmbedder vectors, as they say little about
+// what a text is about
+var stopWords = func() map[string]bool {
+	words := strings.Fields(`a about after all also am an and any are as at be been
+		but by can could did do does for from had has have he her his how i if in
+		into is it its just me more most my no not of on or our out over she so
+		some than that the their them then there these they this to up us was we
+		were what when where which while who why will with would you your`)
+	set := make(map[string]bool, len(words))
+	for _, w := range words {
+		set[w] = true
+	}
+	return set
+}()
+
+// embedTimeout bounds a request to an embedding server
+const embedTimeout = 30 * time.Second
+
+// HTTPEmbedder gets embeddings from a server speaking the OpenAI embeddings
+// API, as local model servers such as Ollama, llama.cpp and LM Studio do
+type HTTPEmbedder struct {
+	url    string
+	model  string
+	client *http.Client
+}
+
+// NewHTTPEmbedder asks the server at baseURL (e.g. http://localhost:11434/v1)
+// for embeddings made by model
+func NewHTTPEmbedder(baseURL, model string) *HTTPEmbedder {
+	return &HTTPEmbedder{
+		url:    strings.TrimRight(baseURL, "/") + "/embeddings",
+		model:  model,
+		client: &http.Client{Timeout: embedTimeout},
+	}
+}
+
+func (e *HTTPEmbedder) Model() string {
+	return e.model
+}
+
+// Embed sends all texts in one request
+func (e *HTTPEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	body, err := json.Marshal(map[string]any{"model": e.model, "input": texts})
+	if err != nil {
+		return nil, fmt.Errorf("encode embedding request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("build embedding request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("request embeddings: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("request embeddings: server returned %s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("decode embeddings: %w", err)
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(texts) {
+			return nil, fmt.Errorf("decode embeddings: index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if len(v) == 0 {
+			return nil, fmt.Errorf("decode embeddings: no embedding for input %d", i)
+		}
+	}
+	return vectors, nil
+}
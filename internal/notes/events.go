@@ -0,0 +1,55 @@
+package notes
+
+import (
+	"context"
+	"time"
+)
+
+// EventType names a change made to a note through the Service
+type EventType string
+
+const (
+	EventNoteCreated  EventType = "note.created"
+	EventNoteUpdated  EventType = "note.updated"
+	EventNoteDeleted  EventType = "note.deleted"
+	EventNoteRestored EventType = "note.restored"
+)
+
+// Event describes a stored change to a note
+type Event struct {
+	Type EventType
+	// Note is the note after the change; for deletes, the note as it was
+	// when it went to the trash
+	Note *Note
+	// PrevCategory is set on updates that moved the note to another category
+	PrevCategory string
+	At           time.Time
+}
+
+// Listener is called synchronously after a change is stored, so it must
+// return quickly and hand any slow work off to a goroutine
+type Listener func(ctx context.Context, e Event)
+
+// OnChange registers a listener for note changes
+func (s *Service) OnChange(fn Listener) {
+	s.listenersMu.Lock()
+	defer s.listenersMu.Unlock()
+	s.listeners = append(s.listeners, fn)
+}
+
+func (s *Service) emit(ctx context.Context, typ EventType, note *Note, prevCategory string) {
+	s.listenersMu.RLock()
+	listeners := s.listeners
+	s.listenersMu.RUnlock()
+
+	if len(listeners) == 0 {
+		return
+	}
+	e := Event{Type: typ, Note: note, At: time.Now()}
+	if prevCategory != note.Category {
+		e.PrevCategory = prevCategory
+	}
+	for _, fn := range listeners {
+		fn(ctx, e)
+	}
+}
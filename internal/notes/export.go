@@ -0,0 +1,129 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Export calls fn with every note matching q that ctx may see, oldest first.
+// Notes come out as stored, IDs and timestamps included, so an export can be
+// imported elsewhere unchanged.
+func (s *Service) Export(ctx context.Context, q ExportQuery, fn func(*Note) error) error {
+	q.Category = normalizeCategory(q.Category)
+	categories, ok := scopeQuery(ctx, q.Category)
+	if !ok {
+		return nil
+	}
+	q.Categories = categories
+	if q.Since != nil && q.Until != nil && !q.Since.Before(*q.Until) {
+		return fmt.Errorf("%w: since must be before until", ErrInvalidInput)
+	}
+	return s.repo.Export(ctx, q, fn)
+}
+
+// checkImportMode validates an import mode, defaulting empty to ImportSkip
+func checkImportMode(mode ImportMode) (ImportMode, error) {
+	switch mode {
+	case "":
+		return ImportSkip, nil
+	case ImportSkip, ImportOverwrite, ImportNewID:
+		return mode, nil
+	}
+	return "", fmt.Errorf("%w: mode must be skip, overwrite or new-id", ErrInvalidInput)
+}
+
+// ImportNote stores a note from an export, keeping its ID, timestamps,
+// version and author. mode decides what happens when the ID is taken; a note
+// without an ID always gets a fresh one. The result is BulkCreated,
+// BulkUpdated or BulkSkipped.
+//
+// Overwriting replaces the stored note, bringing it back from the trash if
+// need be. Like an edit, it first saves the stored version as a revision,
+// and the import becomes a later version than the one it replaces. A live
+// note that already holds the same content, category, tags and author is
+// left alone and reported as skipped.
+func (s *Service) ImportNote(ctx context.Context, n *Note, mode ImportMode) (BulkStatus, error) {
+	mode, err := checkImportMode(mode)
+	if err != nil {
+		return BulkFailed, err
+	}
+
+	n.Category = normalizeCategory(n.Category)
+	if n.Category == "" {
+		return BulkFailed, fmt.Errorf("%w: category is required", ErrInvalidInput)
+	}
+	if strings.TrimSpace(n.Content) == "" {
+		return BulkFailed, fmt.Errorf("%w: content is required", ErrInvalidInput)
+	}
+	if !canAccess(ctx, n.Category) {
+		return BulkFailed, fmt.Errorf("%w: no access to category %q", ErrForbidden, n.Category)
+	}
+	n.Tags = normalizeTags(n.Tags)
+	n.Author = strings.TrimSpace(n.Author)
+	if n.CreatedAt.IsZero() {
+		n.CreatedAt = time.Now()
+	}
+	if n.UpdatedAt.Before(n.CreatedAt) {
+		n.UpdatedAt = n.CreatedAt
+	}
+	n.Version = max(n.Version, 1)
+	n.fingerprint()
+	if mode == ImportNewID || n.ID.IsZero() {
+		n.ID = primitive.NewObjectID()
+	}
+
+	prevCategory := n.Category
+	if mode == ImportOverwrite {
+		existing, err := s.findAny(ctx, n.ID)
+		switch {
+		case err == nil && !canAccess(ctx, existing.Category):
+			return BulkFailed, fmt.Errorf("%w: no access to category %q", ErrForbidden, existing.Category)
+		case err == nil && existing.DeletedAt == nil && n.DeletedAt == nil && sameContent(existing, n):
+			// Re-importing an unchanged note would only bump its version
+			return BulkSkipped, nil
+		case err == nil:
+			if err := s.repo.SaveRevision(ctx, revisionOf(existing)); err != nil {
+				return BulkFailed, err
+			}
+			prevCategory = existing.Category
+			n.Version = max(n.Version, existing.Version+1)
+		case !errors.Is(err, ErrNoteNotFound):
+			return BulkFailed, err
+		}
+	}
+
+	replaced, err := s.repo.ImportNote(ctx, n, mode == ImportOverwrite)
+	switch {
+	case errors.Is(err, ErrNoteExists) && mode == ImportSkip:
+		return BulkSkipped, nil
+	case err != nil:
+		return BulkFailed, err
+	case replaced:
+		s.emit(ctx, EventNoteUpdated, n, prevCategory)
+		return BulkUpdated, nil
+	}
+	s.emit(ctx, EventNoteCreated, n, n.Category)
+	return BulkCreated, nil
+}
+
+// sameContent reports whether two notes hold the same content, category,
+// tags and author
+func sameContent(a, b *Note) bool {
+	return a.Content == b.Content && a.Category == b.Category &&
+		slices.Equal(a.Tags, b.Tags) && a.Author == b.Author
+}
+
+// findAny returns a note in the workspace whether or not it is in the trash
+func (s *Service) findAny(ctx context.Context, id primitive.ObjectID) (*Note, error) {
+	note, err := s.repo.FindByID(ctx, id)
+	if errors.Is(err, ErrNoteNotFound) {
+		return s.repo.FindTrashed(ctx, id)
+	}
+	return note, err
+}
@@ -0,0 +1,38 @@
+package notes
+
+import (
+	"context"
+	"testing"
+)
+
+func TestImportOverwriteKeepsRevision(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(NewMemoryRepo())
+	created, err := svc.Create(ctx, CreateNoteInput{Category: "ideas", Content: "original"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig := created.Note
+
+	imported := *orig
+	imported.Content = "from the backup"
+	status, err := svc.ImportNote(ctx, &imported, ImportOverwrite)
+	if err != nil || status != BulkUpdated {
+		t.Fatalf("import = %v, %v; want %v", status, err, BulkUpdated)
+	}
+
+	note, err := svc.GetByID(ctx, orig.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note.Content != "from the backup" || note.Version <= orig.Version {
+		t.Errorf("note is %q at version %d, want the import at a version after %d", note.Content, note.Version, orig.Version)
+	}
+	rev, err := svc.GetRevision(ctx, orig.ID.Hex(), orig.Version)
+	if err != nil {
+		t.Fatalf("replaced version was not kept: %v", err)
+	}
+	if rev.Content != "original" {
+		t.Errorf("revision %d content = %q, want %q", orig.Version, rev.Content, "original")
+	}
+}
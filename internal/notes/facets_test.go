@@ -0,0 +1,109 @@
+package notes
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSearchFacets(t *testing.T) {
+	for name, svc := range newStoreServices(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for _, n := range []*Note{
+				{Category: "ideas", Content: "launch plan", Tags: []string{"go", "web"}, CreatedAt: time.Date(2024, 1, 5, 12, 0, 0, 0, time.UTC)},
+				{Category: "ideas", Content: "launch party", Tags: []string{"go"}, CreatedAt: time.Date(2024, 2, 10, 12, 0, 0, 0, time.UTC)},
+				{Category: "work", Content: "launch review", Tags: []string{"web"}, CreatedAt: time.Date(2024, 2, 20, 12, 0, 0, 0, time.UTC)},
+				{Category: "work", Content: "unrelated", Tags: []string{"go"}, CreatedAt: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+			} {
+				if _, err := svc.ImportNote(ctx, n, ImportNewID); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Facets count every match, not just the page
+			result, err := svc.Search(ctx, SearchQuery{Query: "launch", Limit: 1, Facets: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Total != 3 || len(result.Hits) != 1 || result.Facets == nil {
+				t.Fatalf("got %d of %d hits, facets %v", len(result.Hits), result.Total, result.Facets)
+			}
+			checks := []struct {
+				facet string
+				got   []FacetCount
+				want  []FacetCount
+			}{
+				{"categories", result.Facets.Categories, []FacetCount{{"ideas", 2}, {"work", 1}}},
+				{"tags", result.Facets.Tags, []FacetCount{{"go", 2}, {"web", 2}}},
+				{"months", result.Facets.Months, []FacetCount{{"2024-02", 2}, {"2024-01", 1}}},
+			}
+			for _, c := range checks {
+				if !slices.Equal(c.got, c.want) {
+					t.Errorf("%s = %v, want %v", c.facet, c.got, c.want)
+				}
+			}
+
+			// Each value refines the search to the notes it counted
+			refines := map[string][]FacetCount{"category": result.Facets.Categories, "tag": result.Facets.Tags, "created": result.Facets.Months}
+			for field, counts := range refines {
+				for _, c := range counts {
+					query := "launch " + FieldTerm(field, c.Value)
+					refined, err := svc.Search(ctx, SearchQuery{Query: query})
+					if err != nil {
+						t.Fatal(err)
+					}
+					if refined.Total != c.Count {
+						t.Errorf("%s found %d, facet counted %d", query, refined.Total, c.Count)
+					}
+				}
+			}
+
+			restricted := WithCategoryAccess(ctx, []string{"work"})
+			result, err = svc.Search(restricted, SearchQuery{Query: "launch", Facets: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := []FacetCount{{"work", 1}}; !slices.Equal(result.Facets.Categories, want) {
+				t.Errorf("restricted categories = %v, want %v", result.Facets.Categories, want)
+			}
+			if want := []FacetCount{{"web", 1}}; !slices.Equal(result.Facets.Tags, want) {
+				t.Errorf("restricted tags = %v, want %v", result.Facets.Tags, want)
+			}
+
+			result, err = svc.Search(restricted, SearchQuery{Query: "launch", Category: "ideas", Facets: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Facets == nil || len(result.Facets.Categories)+len(result.Facets.Tags)+len(result.Facets.Months) != 0 {
+				t.Errorf("facets for an unreadable category = %v, want empty", result.Facets)
+			}
+
+			if result, err := svc.Search(ctx, SearchQuery{Query: "launch"}); err != nil || result.Facets != nil {
+				t.Errorf("facets = %v, %v; want none unless asked for", result.Facets, err)
+			}
+		})
+	}
+}
+
+func TestSearchFacetsCapped(t *testing.T) {
+	for name, svc := range newStoreServices(t) {
+		t.Run(name, func(t *testing.T) {
+			for i := range MaxFacetValues + 5 {
+				mustCreate(t, svc, CreateNoteInput{Category: fmt.Sprintf("c%02d", i), Content: "capped"})
+			}
+			result, err := svc.Search(context.Background(), SearchQuery{Query: "capped", Facets: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n := len(result.Facets.Categories); n != MaxFacetValues {
+				t.Errorf("got %d category values, want %d", n, MaxFacetValues)
+			}
+			if first := result.Facets.Categories[0]; first.Value != "c00" {
+				t.Errorf("first value = %v, want ties in value order", first)
+			}
+		})
+	}
+}
@@ -0,0 +1,215 @@
+package notes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"scratchpad/internal/workspace"
+	"scratchpad/views/components"
+)
+
+const (
+	// sseRetry is how long browsers wait before reconnecting a dropped stream
+	sseRetry = 3 * time.Second
+	// sseHeartbeat keeps idle streams from being cut by proxies
+	sseHeartbeat = 25 * time.Second
+)
+
+// FeedEvent is the data of an event on GET /api/events
+type FeedEvent struct {
+	Type         EventType `json:"type"`
+	Note         *Note     `json:"note"`
+	PrevCategory string    `json:"prevCategory,omitempty"`
+	At           time.Time `json:"at"`
+}
+
+// Events handles GET /api/events, a Server-Sent Events stream of changes
+// to notes in the workspace. Each event is named after its type and carries
+// a FeedEvent. category and type (both repeatable or comma-separated)
+// narrow the stream; a note moved out of a category still counts for it.
+//
+// Clients resuming with Last-Event-ID (or lastEventId in the query) first
+// get what they missed. When that is no longer known, a "reset" event
+// comes first and the client should reload.
+func (h *Handler) Events(w http.ResponseWriter, r *http.Request) {
+	categories := queryList(r, "category")
+	for i, c := range categories {
+		categories[i] = normalizeCategory(c)
+	}
+	var types []EventType
+	for _, t := range queryList(r, "type") {
+		typ := EventType(t)
+		switch typ {
+		case EventNoteCreated, EventNoteUpdated, EventNoteDeleted, EventNoteRestored:
+			types = append(types, typ)
+		default:
+			h.jsonError(w, fmt.Sprintf("unknown event type %q", t), http.StatusBadRequest)
+			return
+		}
+	}
+
+	match := func(e Event) bool {
+		return (len(types) == 0 || slices.Contains(types, e.Type)) &&
+			(len(categories) == 0 || slices.Contains(categories, e.Note.Category) ||
+				(e.PrevCategory != "" && slices.Contains(categories, e.PrevCategory)))
+	}
+	h.stream(w, r, match, true, func(be BusEvent) (string, []byte, error) {
+		data, err := json.Marshal(FeedEvent{Type: be.Type, Note: be.Note, PrevCategory: be.PrevCategory, At: be.At})
+		return string(be.Type), data, err
+	})
+}
+
+// EventsFragment handles GET /fragments/events?category=, the stream behind
+// the live category page. New notes arrive as "note-added" events holding a
+// NoteCard to prepend; changes to a shown note arrive as "note-{id}" events
+// holding its new card, or a hidden placeholder once it has left.
+func (h *Handler) EventsFragment(w http.ResponseWriter, r *http.Request) {
+	category := normalizeCategory(r.URL.Query().Get("category"))
+	if category == "" {
+		http.Error(w, "category is required", http.StatusBadRequest)
+		return
+	}
+
+	match := func(e Event) bool {
+		return e.Note.Category == category || e.PrevCategory == category
+	}
+	h.stream(w, r, match, false, func(be BusEvent) (string, []byte, error) {
+		id := be.Note.ID.Hex()
+		event := "note-" + id
+		var html bytes.Buffer
+		switch {
+		case be.Type == EventNoteDeleted || be.Note.Category != category:
+			fmt.Fprintf(&html, `<div id="note-%s" hidden></div>`, id)
+		default:
+			if be.Type != EventNoteUpdated || be.PrevCategory != "" {
+				event = "note-added"
+			}
+			view := h.notesToViews([]*Note{be.Note})[0]
+			err := components.NoteCard(view, h.svc.RenderMarkdown(be.Note.Content)).Render(r.Context(), &html)
+			if err != nil {
+				return "", nil, err
+			}
+		}
+		return event, html.Bytes(), nil
+	})
+}
+
+// stream subscribes to the bus and writes matching events the caller may
+// see as SSE until the client goes away or the bus closes. render names
+// each event and produces its data. Unless reset is set, a client resuming
+// from an unknown point just carries on with new events.
+func (h *Handler) stream(w http.ResponseWriter, r *http.Request, match func(Event) bool, reset bool,
+	render func(BusEvent) (event string, data []byte, err error)) {
+	ctx := r.Context()
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+	var after uint64
+	if lastID != "" {
+		// An ID that doesn't parse is as unknown as one from before a restart
+		after, _ = strconv.ParseUint(lastID, 10, 64)
+		after = max(after, 1)
+	}
+
+	sub, backlog, complete := h.bus.Subscribe(after)
+	defer sub.Close()
+
+	// Streams outlive the server's write timeout
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", sseRetry.Milliseconds())
+	if reset && !complete {
+		writeSSE(w, 0, "reset", []byte("{}"))
+	}
+
+	send := func(be BusEvent) error {
+		if !visible(ctx, be.Event) || !match(be.Event) {
+			return nil
+		}
+		event, data, err := render(be)
+		if err != nil {
+			h.log.Error("failed to render event", "error", err, "event", be.Type)
+			return nil
+		}
+		return writeSSE(w, be.ID, event, data)
+	}
+	for _, be := range backlog {
+		if err := send(be); err != nil {
+			return
+		}
+	}
+	if rc.Flush() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case be, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if send(be) != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+		}
+		if rc.Flush() != nil {
+			return
+		}
+	}
+}
+
+// visible reports whether ctx may see an event: same workspace, and a
+// category it has access to
+func visible(ctx context.Context, e Event) bool {
+	return e.Note.Workspace == workspace.FromContext(ctx) && canAccess(ctx, e.Note.Category)
+}
+
+// writeSSE writes one event in the text/event-stream format, splitting
+// multi-line data over several data fields. A zero id is left out.
+func writeSSE(w http.ResponseWriter, id uint64, event string, data []byte) error {
+	var b bytes.Buffer
+	if id != 0 {
+		fmt.Fprintf(&b, "id: %d\n", id)
+	}
+	fmt.Fprintf(&b, "event: %s\n", event)
+	for _, line := range strings.Split(string(data), "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteByte('\n')
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// queryList reads a query parameter given several times, comma-separated,
+// or both
+func queryList(r *http.Request, name string) []string {
+	var values []string
+	for _, v := range r.URL.Query()[name] {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+	return values
+}
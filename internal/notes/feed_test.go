@@ -0,0 +1,157 @@
+package notes
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBusSubscribe(t *testing.T) {
+	bus := NewBus(3)
+	for i := 0; i < 5; i++ {
+		bus.Publish(context.Background(), Event{Type: EventNoteCreated, Note: &Note{}})
+	}
+	last := bus.LastID()
+	first := last - 4 // no longer in the backlog, which keeps the last 3
+
+	tests := []struct {
+		name         string
+		lastID       uint64
+		wantBacklog  []uint64
+		wantComplete bool
+	}{
+		{"fresh", 0, nil, true},
+		{"up to date", last, nil, true},
+		{"one behind", last - 1, []uint64{last}, true},
+		{"oldest kept", last - 3, []uint64{last - 2, last - 1, last}, true},
+		{"too far behind", first, []uint64{last - 2, last - 1, last}, false},
+		{"before the bus started", 1, []uint64{last - 2, last - 1, last}, false},
+		{"from the future", last + 10, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, backlog, complete := bus.Subscribe(tt.lastID)
+			defer sub.Close()
+			var ids []uint64
+			for _, be := range backlog {
+				ids = append(ids, be.ID)
+			}
+			if !slices.Equal(ids, tt.wantBacklog) || complete != tt.wantComplete {
+				t.Errorf("backlog %v, complete %v; want %v, %v", ids, complete, tt.wantBacklog, tt.wantComplete)
+			}
+		})
+	}
+}
+
+func TestBusDropsSlowSubscribers(t *testing.T) {
+	bus := NewBus(1)
+	sub, _, _ := bus.Subscribe(0)
+	for i := 0; i < subscriberBuffer+1; i++ {
+		bus.Publish(context.Background(), Event{Type: EventNoteCreated, Note: &Note{}})
+	}
+	n := 0
+	for range sub.C {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("got %d events before the channel closed, want %d", n, subscriberBuffer)
+	}
+}
+
+type sseEvent struct {
+	id    string
+	event string
+}
+
+// readEvents requests an event stream and returns the events it replays
+// before waiting for new ones
+func readEvents(t *testing.T, mux http.Handler, target, lastID string) []sseEvent {
+	t.Helper()
+	// A cancelled request writes the backlog and returns instead of waiting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)
+	if lastID != "" {
+		r.Header.Set("Last-Event-ID", lastID)
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d: %s", target, w.Code, w.Body)
+	}
+
+	var events []sseEvent
+	var cur sseEvent
+	sc := bufio.NewScanner(w.Body)
+	for sc.Scan() {
+		field, value, _ := strings.Cut(sc.Text(), ": ")
+		switch field {
+		case "id":
+			cur.id = value
+		case "event":
+			cur.event = value
+		case "":
+			if cur.event != "" {
+				events = append(events, cur)
+			}
+			cur = sseEvent{}
+		}
+	}
+	return events
+}
+
+func eventNames(events []sseEvent) []string {
+	names := make([]string, len(events))
+	for i, e := range events {
+		names[i] = e.event
+	}
+	return names
+}
+
+func TestEventsResume(t *testing.T) {
+	mux, svc := newTestMux(t)
+	note := mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "first"})
+	if _, err := svc.Update(context.Background(), note.ID.Hex(), UpdateNoteInput{Append: "more"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Delete(context.Background(), note.ID.Hex()); err != nil {
+		t.Fatal(err)
+	}
+
+	// An ID older than anything kept replays the whole backlog after a reset
+	all := readEvents(t, mux, "/api/events", "1")
+	if want := []string{"reset", "note.created", "note.updated", "note.deleted"}; !slices.Equal(eventNames(all), want) {
+		t.Fatalf("events = %v, want %v", eventNames(all), want)
+	}
+	created, updated, deleted := all[1].id, all[2].id, all[3].id
+	next, _ := strconv.ParseUint(deleted, 10, 64)
+
+	tests := []struct {
+		name   string
+		target string
+		lastID string
+		want   []string
+	}{
+		{"no last ID", "/api/events", "", nil},
+		{"resume", "/api/events", created, []string{"note.updated", "note.deleted"}},
+		{"resume from query", "/api/events?lastEventId=" + updated, "", []string{"note.deleted"}},
+		{"header wins over query", "/api/events?lastEventId=" + created, updated, []string{"note.deleted"}},
+		{"up to date", "/api/events", deleted, nil},
+		{"resume with filter", "/api/events?type=note.deleted", created, []string{"note.deleted"}},
+		{"unknown ID", "/api/events", strconv.FormatUint(next+100, 10), []string{"reset"}},
+		{"malformed ID", "/api/events", "abc", []string{"reset", "note.created", "note.updated", "note.deleted"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := eventNames(readEvents(t, mux, tt.target, tt.lastID))
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,13 +1,22 @@
 package notes
 
 import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"cmp"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
+	"scratchpad/internal/workspace"
 	"scratchpad/views/components"
 	"scratchpad/views/models"
 	"scratchpad/views/pages"
@@ -15,16 +24,20 @@ import (
 
 type Handler struct {
 	svc *Service
+	bus *Bus
 	log *slog.Logger
 }
 
-func NewHandler(svc *Service, log *slog.Logger) *Handler {
-	return &Handler{svc: svc, log: log}
+func NewHandler(svc *Service, bus *Bus, log *slog.Logger) *Handler {
+	return &Handler{svc: svc, bus: bus, log: log}
 }
 
 // --- REST API Handlers ---
 
-// CreateNote handles POST /api/notes
+// CreateNote handles POST /api/notes. With an Idempotency-Key header (or
+// idempotencyKey field) a retried request gets the original note and status
+// back instead of creating a duplicate. A note merged into an existing
+// duplicate (onDuplicate "merge") comes back with 200 rather than 201.
 func (h *Handler) CreateNote(w http.ResponseWriter, r *http.Request) {
 	var input CreateNoteInput
 	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
@@ -32,14 +45,181 @@ func (h *Handler) CreateNote(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	note, err := h.svc.Create(r.Context(), input)
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if key != "" && input.IdempotencyKey != "" && key != input.IdempotencyKey {
+		h.jsonError(w, "Idempotency-Key header and idempotencyKey field disagree", http.StatusBadRequest)
+		return
+	}
+	if key == "" {
+		key = input.IdempotencyKey
+	}
+
+	var result *CreateResult
+	var err error
+	if key == "" {
+		result, err = h.svc.Create(r.Context(), input)
+	} else {
+		result, err = h.svc.CreateIdempotent(r.Context(), key, input)
+	}
 	if err != nil {
-		h.log.Error("failed to create note", "error", err)
+		h.serviceError(w, err, "failed to create note")
+		return
+	}
+	if result.Replayed {
+		w.Header().Set(IdempotentReplayedHeader, "true")
+	}
+	h.jsonResponse(w, result.Note, result.Status)
+}
+
+const (
+	// IdempotencyKeyHeader names the key that makes POST /api/notes safe to retry
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks a response replayed for a known key
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+)
+
+const (
+	// maxBulkItems caps how many notes one bulk request may carry
+	maxBulkItems = 1000
+	// maxBulkBodyBytes caps the size of a bulk request body
+	maxBulkBodyBytes = 16 << 20
+)
+
+// BulkCreateResponse is the body returned by POST /api/notes/bulk
+type BulkCreateResponse struct {
+	Created int          `json:"created"`
+	Merged  int          `json:"merged,omitempty"`
+	Failed  int          `json:"failed"`
+	Results []BulkResult `json:"results"`
+}
+
+// CreateNotesBulk handles POST /api/notes/bulk. The body is either a JSON
+// array of notes or NDJSON, one note per line. Each item is validated and
+// reported on separately; the status is 201 when every item was created and
+// 207 when some failed.
+func (h *Handler) CreateNotesBulk(w http.ResponseWriter, r *http.Request) {
+	body := bufio.NewReader(http.MaxBytesReader(w, r.Body, maxBulkBodyBytes))
+
+	inputs, parseErrs, err := decodeBulk(body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.jsonError(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		h.jsonError(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if len(inputs) == 0 {
+		h.jsonError(w, "no notes in request", http.StatusBadRequest)
+		return
+	}
+	if len(inputs) > maxBulkItems {
+		h.jsonError(w, fmt.Sprintf("too many notes, the limit is %d per request", maxBulkItems), http.StatusBadRequest)
+		return
+	}
+
+	// Lines that failed to parse keep their slot so indexes match the request
+	var valid []CreateNoteInput
+	var validIdx []int
+	for i, input := range inputs {
+		if parseErrs[i] == nil {
+			valid = append(valid, input)
+			validIdx = append(validIdx, i)
+		}
+	}
+	created := h.svc.CreateMany(r.Context(), valid)
+
+	resp := BulkCreateResponse{Results: make([]BulkResult, len(inputs))}
+	for i, perr := range parseErrs {
+		if perr != nil {
+			resp.Results[i] = BulkResult{Index: i, Status: BulkFailed, Error: perr.Error()}
+		}
+	}
+	for j, res := range created {
+		res.Index = validIdx[j]
+		resp.Results[res.Index] = res
+	}
+	for _, res := range resp.Results {
+		switch res.Status {
+		case BulkCreated:
+			resp.Created++
+		case BulkMerged:
+			resp.Merged++
+		default:
+			resp.Failed++
+		}
+	}
+
+	status := http.StatusCreated
+	if resp.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	h.jsonResponse(w, resp, status)
+}
 
-	h.jsonResponse(w, note, http.StatusCreated)
+// decodeBulk reads a JSON array or NDJSON stream of notes. A malformed array
+// fails the request, while a malformed NDJSON line only fails that item:
+// its parse error is returned at the same index.
+func decodeBulk(r *bufio.Reader) ([]CreateNoteInput, []error, error) {
+	first, err := peekNonSpace(r)
+	if err == io.EOF {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if first == '[' {
+		var inputs []CreateNoteInput
+		if err := json.NewDecoder(r).Decode(&inputs); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return nil, nil, err
+			}
+			return nil, nil, fmt.Errorf("invalid JSON array: %v", err)
+		}
+		return inputs, make([]error, len(inputs)), nil
+	}
+
+	var inputs []CreateNoteInput
+	var parseErrs []error
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxBulkBodyBytes)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var input CreateNoteInput
+		err := json.Unmarshal(text, &input)
+		if err != nil {
+			err = fmt.Errorf("line %d: invalid JSON: %v", line, err)
+		}
+		inputs = append(inputs, input)
+		parseErrs = append(parseErrs, err)
+		if len(inputs) > maxBulkItems {
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return inputs, parseErrs, nil
+}
+
+// peekNonSpace skips leading whitespace and returns the next byte without
+// consuming it
+func peekNonSpace(r *bufio.Reader) (byte, error) {
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		if !unicode.IsSpace(rune(b)) {
+			return b, r.UnreadByte()
+		}
+	}
 }
 
 // GetNote handles GET /api/notes/{id}
@@ -51,16 +231,191 @@ func (h *Handler) GetNote(w http.ResponseWriter, r *http.Request) {
 	}
 
 	note, err := h.svc.GetByID(r.Context(), id)
-	if errors.Is(err, ErrNoteNotFound) {
-		h.jsonError(w, "note not found", http.StatusNotFound)
+	if err != nil {
+		h.serviceError(w, err, "failed to get note")
+		return
+	}
+
+	w.Header().Set("ETag", etag(note))
+	h.jsonResponse(w, note, http.StatusOK)
+}
+
+// ReplaceNote handles PUT /api/notes/{id}
+func (h *Handler) ReplaceNote(w http.ResponseWriter, r *http.Request) {
+	var input UpdateNoteInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, "invalid JSON body", http.StatusBadRequest)
 		return
 	}
+	if input.Content == nil {
+		h.jsonError(w, "content is required", http.StatusBadRequest)
+		return
+	}
+	if input.Append != "" || input.Prepend != "" {
+		h.jsonError(w, "append/prepend are only supported with PATCH", http.StatusBadRequest)
+		return
+	}
+
+	h.updateNote(w, r, input)
+}
+
+// PatchNote handles PATCH /api/notes/{id}
+func (h *Handler) PatchNote(w http.ResponseWriter, r *http.Request) {
+	var input UpdateNoteInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		h.jsonError(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+	if input.Content != nil {
+		h.jsonError(w, "use PUT to replace content", http.StatusBadRequest)
+		return
+	}
+
+	h.updateNote(w, r, input)
+}
+
+// updateNote applies an edit, honouring If-Match for optimistic concurrency
+func (h *Handler) updateNote(w http.ResponseWriter, r *http.Request, input UpdateNoteInput) {
+	id := r.PathValue("id")
+	if id == "" {
+		h.jsonError(w, "note ID required", http.StatusBadRequest)
+		return
+	}
+
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		version, err := parseETag(ifMatch)
+		if err != nil {
+			h.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		input.Version = version
+	}
+
+	note, err := h.svc.Update(r.Context(), id, input)
 	if err != nil {
-		h.log.Error("failed to get note", "error", err)
-		h.jsonError(w, "internal error", http.StatusInternalServerError)
+		h.serviceError(w, err, "failed to update note")
 		return
 	}
 
+	w.Header().Set("ETag", etag(note))
+	h.jsonResponse(w, note, http.StatusOK)
+}
+
+// SimilarNotes handles GET /api/notes/{id}/similar
+func (h *Handler) SimilarNotes(w http.ResponseWriter, r *http.Request) {
+	similar, err := h.svc.Similar(r.Context(), r.PathValue("id"), SimilarQuery{
+		Category:      r.URL.Query().Get("category"),
+		MinSimilarity: h.parseFloat(r.URL.Query().Get("min_similarity"), 0),
+		Limit:         h.parseInt(r.URL.Query().Get("limit"), 10),
+	})
+	if err != nil {
+		h.serviceError(w, err, "failed to find similar notes")
+		return
+	}
+
+	h.jsonResponse(w, similar, http.StatusOK)
+}
+
+// RelatedNotes handles GET /api/notes/{id}/related, finding notes that
+// share the note's distinctive terms
+func (h *Handler) RelatedNotes(w http.ResponseWriter, r *http.Request) {
+	q := RelatedQuery{
+		Category: r.URL.Query().Get("category"),
+		Limit:    h.parseInt(r.URL.Query().Get("limit"), 5),
+	}
+	if v := r.URL.Query().Get("omit_content"); v != "" {
+		var err error
+		if q.OmitContent, err = strconv.ParseBool(v); err != nil {
+			h.jsonError(w, "invalid omit_content, use true or false", http.StatusBadRequest)
+			return
+		}
+	}
+
+	related, err := h.svc.Related(r.Context(), r.PathValue("id"), q)
+	if err != nil {
+		h.serviceError(w, err, "failed to find related notes")
+		return
+	}
+
+	h.jsonResponse(w, related, http.StatusOK)
+}
+
+// FindDuplicates handles GET /api/duplicates, reporting clusters of
+// near-duplicate notes
+func (h *Handler) FindDuplicates(w http.ResponseWriter, r *http.Request) {
+	clusters, err := h.svc.FindDuplicates(r.Context(), DuplicatesQuery{
+		Category:      r.URL.Query().Get("category"),
+		MinSimilarity: h.parseFloat(r.URL.Query().Get("min_similarity"), 0),
+		Limit:         h.parseInt(r.URL.Query().Get("limit"), 20),
+	})
+	if err != nil {
+		h.serviceError(w, err, "failed to find duplicates")
+		return
+	}
+	if clusters == nil {
+		clusters = []*DuplicateCluster{}
+	}
+
+	h.jsonResponse(w, clusters, http.StatusOK)
+}
+
+// ListRevisions handles GET /api/notes/{id}/revisions
+func (h *Handler) ListRevisions(w http.ResponseWriter, r *http.Request) {
+	revisions, err := h.svc.ListRevisions(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.serviceError(w, err, "failed to list revisions")
+		return
+	}
+	if revisions == nil {
+		revisions = []*Revision{}
+	}
+
+	h.jsonResponse(w, revisions, http.StatusOK)
+}
+
+// GetRevision handles GET /api/notes/{id}/revisions/{rev}
+func (h *Handler) GetRevision(w http.ResponseWriter, r *http.Request) {
+	version, err := strconv.ParseInt(r.PathValue("rev"), 10, 64)
+	if err != nil {
+		h.jsonError(w, "revision must be a version number", http.StatusBadRequest)
+		return
+	}
+
+	rev, err := h.svc.GetRevision(r.Context(), r.PathValue("id"), version)
+	if err != nil {
+		h.serviceError(w, err, "failed to get revision")
+		return
+	}
+
+	h.jsonResponse(w, rev, http.StatusOK)
+}
+
+// RestoreRevision handles POST /api/notes/{id}/revisions/{rev}/restore
+func (h *Handler) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	version, err := strconv.ParseInt(r.PathValue("rev"), 10, 64)
+	if err != nil {
+		h.jsonError(w, "revision must be a version number", http.StatusBadRequest)
+		return
+	}
+
+	// Body is optional and only carries the author
+	var input struct {
+		Author string `json:"author"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+			h.jsonError(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	note, err := h.svc.RestoreRevision(r.Context(), r.PathValue("id"), version, input.Author)
+	if err != nil {
+		h.serviceError(w, err, "failed to restore revision")
+		return
+	}
+
+	w.Header().Set("ETag", etag(note))
 	h.jsonResponse(w, note, http.StatusOK)
 }
 
@@ -68,57 +423,86 @@ func (h *Handler) GetNote(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) ListNotes(w http.ResponseWriter, r *http.Request) {
 	q := ListQuery{
 		Category: r.URL.Query().Get("category"),
+		Tags:     parseTags(r),
+		TagMode:  TagMode(r.URL.Query().Get("tag_mode")),
 		Limit:    h.parseInt(r.URL.Query().Get("limit"), 50),
 		Offset:   h.parseInt(r.URL.Query().Get("offset"), 0),
 	}
 
 	notes, err := h.svc.List(r.Context(), q)
 	if err != nil {
-		h.log.Error("failed to list notes", "error", err)
-		h.jsonError(w, "internal error", http.StatusInternalServerError)
+		h.serviceError(w, err, "failed to list notes")
 		return
 	}
 
 	h.jsonResponse(w, notes, http.StatusOK)
 }
 
-// SearchNotes handles GET /api/notes/search
+// SearchNotes handles GET /api/notes/search. q is a search query (see
+// query.go); a query that doesn't parse is a 400 saying what is wrong. The
+// response is a SearchResult: a page of hits, each with a score and
+// snippets, the total and facets. omit_content=true leaves out the bodies.
 func (h *Handler) SearchNotes(w http.ResponseWriter, r *http.Request) {
 	q := SearchQuery{
 		Query:    r.URL.Query().Get("q"),
 		Category: r.URL.Query().Get("category"),
+		Tags:     parseTags(r),
+		TagMode:  TagMode(r.URL.Query().Get("tag_mode")),
 		Limit:    h.parseInt(r.URL.Query().Get("limit"), 50),
 		Offset:   h.parseInt(r.URL.Query().Get("offset"), 0),
+		Facets:   true,
 	}
-
-	// Parse date filters
-	if since := r.URL.Query().Get("since"); since != "" {
-		t, err := time.Parse(time.RFC3339, since)
-		if err != nil {
-			t, err = time.Parse("2006-01-02", since)
-		}
-		if err == nil {
-			q.Since = &t
+	var err error
+	if q.Since, q.Until, err = parseDateRange(r); err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if v := r.URL.Query().Get("omit_content"); v != "" {
+		if q.OmitContent, err = strconv.ParseBool(v); err != nil {
+			h.jsonError(w, "invalid omit_content, use true or false", http.StatusBadRequest)
+			return
 		}
 	}
-	if until := r.URL.Query().Get("until"); until != "" {
-		t, err := time.Parse(time.RFC3339, until)
-		if err != nil {
-			t, err = time.Parse("2006-01-02", until)
+
+	result, err := h.svc.Search(r.Context(), q)
+	if err != nil {
+		h.serviceError(w, err, "failed to search notes")
+		return
+	}
+
+	h.jsonResponse(w, result, http.StatusOK)
+}
+
+// SemanticSearch handles GET /api/notes/semantic, finding notes by meaning
+// rather than by their exact words
+func (h *Handler) SemanticSearch(w http.ResponseWriter, r *http.Request) {
+	q := SemanticQuery{
+		Query:      r.URL.Query().Get("q"),
+		Category:   r.URL.Query().Get("category"),
+		TextWeight: DefaultTextWeight,
+		Limit:      h.parseInt(r.URL.Query().Get("limit"), 10),
+	}
+	var err error
+	if v := r.URL.Query().Get("text_weight"); v != "" {
+		if q.TextWeight, err = strconv.ParseFloat(v, 64); err != nil {
+			h.jsonError(w, "invalid text_weight, use a number from 0 to 1", http.StatusBadRequest)
+			return
 		}
-		if err == nil {
-			q.Until = &t
+	}
+	if v := r.URL.Query().Get("omit_content"); v != "" {
+		if q.OmitContent, err = strconv.ParseBool(v); err != nil {
+			h.jsonError(w, "invalid omit_content, use true or false", http.StatusBadRequest)
+			return
 		}
 	}
 
-	notes, err := h.svc.Search(r.Context(), q)
+	hits, err := h.svc.SemanticSearch(r.Context(), q)
 	if err != nil {
-		h.log.Error("failed to search notes", "error", err)
-		h.jsonError(w, "internal error", http.StatusInternalServerError)
+		h.serviceError(w, err, "failed to search notes by meaning")
 		return
 	}
 
-	h.jsonResponse(w, notes, http.StatusOK)
+	h.jsonResponse(w, hits, http.StatusOK)
 }
 
 // ListCategories handles GET /api/categories
@@ -133,6 +517,21 @@ func (h *Handler) ListCategories(w http.ResponseWriter, r *http.Request) {
 	h.jsonResponse(w, categories, http.StatusOK)
 }
 
+// ListTags handles GET /api/tags
+func (h *Handler) ListTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := h.svc.ListTags(r.Context())
+	if err != nil {
+		h.log.Error("failed to list tags", "error", err)
+		h.jsonError(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	if tags == nil {
+		tags = []*Tag{}
+	}
+
+	h.jsonResponse(w, tags, http.StatusOK)
+}
+
 // DeleteNote handles DELETE /api/notes/{id}
 func (h *Handler) DeleteNote(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
@@ -142,17 +541,366 @@ func (h *Handler) DeleteNote(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err := h.svc.Delete(r.Context(), id)
+	if err != nil {
+		h.serviceError(w, err, "failed to delete note")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RestoreNote handles POST /api/notes/{id}/restore
+func (h *Handler) RestoreNote(w http.ResponseWriter, r *http.Request) {
+	note, err := h.svc.Restore(r.Context(), r.PathValue("id"))
 	if errors.Is(err, ErrNoteNotFound) {
-		h.jsonError(w, "note not found", http.StatusNotFound)
+		h.jsonError(w, "note not found in trash", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		h.serviceError(w, err, "failed to restore note")
 		return
 	}
+
+	h.jsonResponse(w, note, http.StatusOK)
+}
+
+// ListTrash handles GET /api/trash
+func (h *Handler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	q := ListQuery{
+		Category: r.URL.Query().Get("category"),
+		Limit:    h.parseInt(r.URL.Query().Get("limit"), 50),
+		Offset:   h.parseInt(r.URL.Query().Get("offset"), 0),
+	}
+
+	notes, err := h.svc.ListTrash(r.Context(), q)
 	if err != nil {
-		h.log.Error("failed to delete note", "error", err)
+		h.log.Error("failed to list trash", "error", err)
 		h.jsonError(w, "internal error", http.StatusInternalServerError)
 		return
 	}
+	if notes == nil {
+		notes = []*Note{}
+	}
 
-	w.WriteHeader(http.StatusNoContent)
+	h.jsonResponse(w, notes, http.StatusOK)
+}
+
+// Export handles GET /api/export, streaming notes oldest first as NDJSON
+// (or a JSON array with format=json), optionally filtered by category and a
+// since/until range on creation time
+func (h *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	q, err := parseExportQuery(r)
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	switch format {
+	case "", "ndjson":
+		format = "ndjson"
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+	default:
+		h.jsonError(w, "format must be ndjson or json", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="scratchpad-%s-%s.%s"`,
+		workspace.FromContext(r.Context()), time.Now().Format("20060102"), format))
+
+	// A large export takes longer than the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	count := 0
+	err = h.svc.Export(r.Context(), q, func(n *Note) error {
+		data, err := json.Marshal(n)
+		if err != nil {
+			return err
+		}
+		switch {
+		case format == "ndjson":
+		case count == 0:
+			io.WriteString(w, "[\n")
+		default:
+			io.WriteString(w, ",\n")
+		}
+		count++
+		data = append(data, '\n')
+		_, err = w.Write(data)
+		return err
+	})
+	if err != nil && count == 0 {
+		h.serviceError(w, err, "failed to export notes")
+		return
+	}
+	if err != nil {
+		// The status line is gone; cutting the stream short is all that's left
+		h.log.Error("export interrupted", "error", err, "exported", count)
+		return
+	}
+	if format == "json" {
+		if count == 0 {
+			io.WriteString(w, "[")
+		}
+		io.WriteString(w, "]\n")
+	}
+}
+
+// ExportVault handles GET /api/export/vault, returning the notes as a zip
+// of category/<date>-<slug>.md files with YAML front matter. It takes the
+// same filters as Export.
+func (h *Handler) ExportVault(w http.ResponseWriter, r *http.Request) {
+	q, err := parseExportQuery(r)
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="scratchpad-%s-%s.zip"`,
+		workspace.FromContext(r.Context()), time.Now().Format("20060102")))
+
+	// A large vault takes longer than the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	zw := zip.NewWriter(w)
+	count, err := h.svc.ExportVault(r.Context(), q, zw)
+	if err != nil && count == 0 {
+		h.serviceError(w, err, "failed to export vault")
+		return
+	}
+	if err != nil {
+		// Leave the archive without its directory so it reads as truncated
+		h.log.Error("vault export interrupted", "error", err, "exported", count)
+		return
+	}
+	if err := zw.Close(); err != nil {
+		h.log.Error("failed to finish vault export", "error", err)
+	}
+}
+
+// parseExportQuery reads the category and since/until filters shared by the
+// export endpoints
+func parseExportQuery(r *http.Request) (ExportQuery, error) {
+	q := ExportQuery{Category: r.URL.Query().Get("category")}
+	var err error
+	q.Since, q.Until, err = parseDateRange(r)
+	return q, err
+}
+
+// parseDateRange reads the since and until query parameters
+func parseDateRange(r *http.Request) (since, until *time.Time, err error) {
+	if v := r.URL.Query().Get("since"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return nil, nil, errors.New("invalid since date, use YYYY-MM-DD or RFC3339")
+		}
+		since = &t
+	}
+	if v := r.URL.Query().Get("until"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return nil, nil, errors.New("invalid until date, use YYYY-MM-DD or RFC3339")
+		}
+		until = &t
+	}
+	return since, until, nil
+}
+
+// maxImportBodyBytes caps the size of an import body
+const maxImportBodyBytes = 256 << 20
+
+// ImportCounts tallies the outcomes of an import
+type ImportCounts struct {
+	Created int `json:"created"`
+	Updated int `json:"updated"`
+	Skipped int `json:"skipped"`
+	Failed  int `json:"failed"`
+}
+
+// Add counts one outcome
+func (c *ImportCounts) Add(status BulkStatus) {
+	switch status {
+	case BulkCreated:
+		c.Created++
+	case BulkUpdated:
+		c.Updated++
+	case BulkSkipped:
+		c.Skipped++
+	default:
+		c.Failed++
+	}
+}
+
+// ImportResponse is the body returned by POST /api/import
+type ImportResponse struct {
+	ImportCounts
+	Results []BulkResult `json:"results"`
+}
+
+// VaultImportResponse is the body returned by POST /api/import/vault
+type VaultImportResponse struct {
+	ImportCounts
+	Results []VaultResult `json:"results"`
+}
+
+// Import handles POST /api/import. The body is an export, NDJSON or a JSON
+// array; mode (skip, overwrite or new-id) decides what happens to notes whose
+// ID is taken. Notes are stored one by one as they are read, so an import
+// cut short by a bad array keeps what came before it. The status is 200 when
+// nothing failed and 207 otherwise.
+func (h *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	mode, err := checkImportMode(ImportMode(r.URL.Query().Get("mode")))
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Uploads up to maxImportBodyBytes outlast the server's timeouts
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	resp := ImportResponse{Results: []BulkResult{}}
+	body := bufio.NewReader(http.MaxBytesReader(w, r.Body, maxImportBodyBytes))
+	err = decodeImport(body, func(n *Note, parseErr error) {
+		res := BulkResult{Index: len(resp.Results), Status: BulkFailed}
+		if parseErr != nil {
+			res.Error = parseErr.Error()
+		} else if status, err := h.svc.ImportNote(r.Context(), n, mode); err != nil {
+			res.Error = err.Error()
+		} else {
+			res.Status, res.ID = status, n.ID.Hex()
+		}
+
+		resp.Add(res.Status)
+		resp.Results = append(resp.Results, res)
+	})
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			err = fmt.Errorf("request body too large, the limit is %d MB", maxImportBodyBytes>>20)
+		}
+		if len(resp.Results) == 0 {
+			h.jsonError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp.Failed++
+		resp.Results = append(resp.Results, BulkResult{Index: len(resp.Results), Status: BulkFailed, Error: err.Error()})
+	}
+
+	status := http.StatusOK
+	if resp.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	h.jsonResponse(w, resp, status)
+}
+
+// ImportVault handles POST /api/import/vault. The body is a zip of markdown
+// files as written by ExportVault or kept by Obsidian; mode works as for
+// Import. The status is 200 when nothing failed and 207 otherwise.
+func (h *Handler) ImportVault(w http.ResponseWriter, r *http.Request) {
+	mode, err := checkImportMode(ImportMode(r.URL.Query().Get("mode")))
+	if err != nil {
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Uploads up to maxImportBodyBytes outlast the server's timeouts
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	// zip keeps its directory at the end, so the archive is read whole
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportBodyBytes))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.jsonError(w, fmt.Sprintf("request body too large, the limit is %d MB", maxImportBodyBytes>>20), http.StatusRequestEntityTooLarge)
+			return
+		}
+		h.jsonError(w, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		h.jsonError(w, "body is not a zip archive", http.StatusBadRequest)
+		return
+	}
+
+	resp := VaultImportResponse{Results: []VaultResult{}}
+	err = h.svc.ImportVault(r.Context(), archive, mode, func(res VaultResult) {
+		resp.Add(res.Status)
+		resp.Results = append(resp.Results, res)
+	})
+	if err != nil {
+		if len(resp.Results) == 0 {
+			h.serviceError(w, err, "failed to import vault")
+			return
+		}
+		resp.Failed++
+		resp.Results = append(resp.Results, VaultResult{Status: BulkFailed, Error: err.Error()})
+	}
+
+	status := http.StatusOK
+	if resp.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	h.jsonResponse(w, resp, status)
+}
+
+// decodeImport reads a JSON array or NDJSON stream of notes, calling fn as
+// each one is read. A malformed NDJSON line is passed to fn as a parse
+// error; a malformed array ends the stream with an error.
+func decodeImport(r *bufio.Reader, fn func(n *Note, parseErr error)) error {
+	first, err := peekNonSpace(r)
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if first == '[' {
+		dec := json.NewDecoder(r)
+		dec.Token() // the opening bracket
+		for i := 0; dec.More(); i++ {
+			var n Note
+			if err := dec.Decode(&n); err != nil {
+				return fmt.Errorf("invalid JSON array at item %d: %w", i, err)
+			}
+			fn(&n, nil)
+		}
+		if _, err := dec.Token(); err != nil {
+			return fmt.Errorf("invalid JSON array: %w", err)
+		}
+		return nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxBulkBodyBytes)
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var n Note
+		if err := json.Unmarshal(text, &n); err != nil {
+			fn(nil, fmt.Errorf("line %d: invalid JSON: %v", line, err))
+			continue
+		}
+		fn(&n, nil)
+	}
+	return scanner.Err()
+}
+
+// parseTimeParam parses a date query parameter, RFC3339 or YYYY-MM-DD
+func parseTimeParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
 }
 
 // --- Helper methods ---
@@ -169,6 +917,59 @@ func (h *Handler) jsonError(w http.ResponseWriter, message string, status int) {
 	json.NewEncoder(w).Encode(map[string]string{"error": message})
 }
 
+// serviceError maps Service errors onto JSON error responses
+func (h *Handler) serviceError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, ErrInvalidInput):
+		h.jsonError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrForbidden):
+		h.jsonError(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, ErrNoteNotFound):
+		h.jsonError(w, "note not found", http.StatusNotFound)
+	case errors.Is(err, ErrRevisionNotFound):
+		h.jsonError(w, "revision not found", http.StatusNotFound)
+	case errors.Is(err, ErrVersionConflict):
+		h.jsonError(w, "note has been modified, fetch it again and retry", http.StatusPreconditionFailed)
+	case errors.Is(err, ErrDuplicateNote):
+		h.jsonError(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, ErrIdempotencyKeyReused):
+		h.jsonError(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, ErrIdempotencyInFlight):
+		h.jsonError(w, err.Error(), http.StatusConflict)
+	default:
+		h.log.Error(msg, "error", err)
+		h.jsonError(w, "internal error", http.StatusInternalServerError)
+	}
+}
+
+// etag renders a note's version as a strong ETag
+func etag(n *Note) string {
+	return fmt.Sprintf(`"%d"`, n.Version)
+}
+
+// parseETag extracts the version from an If-Match header. "*" matches any
+// version and yields 0.
+func parseETag(header string) (int64, error) {
+	tag := strings.TrimSpace(header)
+	if tag == "*" {
+		return 0, nil
+	}
+	tag = strings.TrimPrefix(tag, "W/")
+	tag = strings.Trim(tag, `"`)
+
+	version, err := strconv.ParseInt(tag, 10, 64)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("invalid If-Match header %q", header)
+	}
+	return version, nil
+}
+
+// parseTags collects the tags query parameter, which may be repeated and/or
+// comma-separated
+func parseTags(r *http.Request) []string {
+	return queryList(r, "tags")
+}
+
 func (h *Handler) parseInt(s string, defaultVal int) int {
 	if s == "" {
 		return defaultVal
@@ -180,6 +981,17 @@ func (h *Handler) parseInt(s string, defaultVal int) int {
 	return v
 }
 
+func (h *Handler) parseFloat(s string, defaultVal float64) float64 {
+	if s == "" {
+		return defaultVal
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return defaultVal
+	}
+	return v
+}
+
 // --- View model converters ---
 
 func (h *Handler) categoriesToViews(categories []*Category) []models.CategoryView {
@@ -201,8 +1013,127 @@ func (h *Handler) notesToViews(notes []*Note) []models.NoteView {
 			ID:        note.ID.Hex(),
 			Category:  note.Category,
 			Content:   note.Content,
+			Tags:      note.Tags,
 			CreatedAt: note.CreatedAt,
 			UpdatedAt: note.UpdatedAt,
+			Version:   note.Version,
+			DeletedAt: note.DeletedAt,
+		}
+	}
+	return views
+}
+
+// resultToView converts a page of search results, turning each facet value
+// into the query term that filters by it
+func (h *Handler) resultToView(result *SearchResult) models.SearchResultView {
+	view := models.SearchResultView{
+		Hits:    h.hitsToViews(result.Hits),
+		Total:   result.Total,
+		Offset:  result.Offset,
+		Limit:   result.Limit,
+		HasMore: result.HasMore,
+	}
+	if result.Facets == nil {
+		return view
+	}
+
+	facets := []struct {
+		name, field string
+		counts      []FacetCount
+	}{
+		{"Categories", "category", result.Facets.Categories},
+		{"Tags", "tag", result.Facets.Tags},
+		{"Months", "created", result.Facets.Months},
+	}
+	for _, f := range facets {
+		fv := models.FacetView{Name: f.name}
+		for _, c := range f.counts {
+			label := c.Value
+			if f.field == "tag" {
+				label = "#" + label
+			}
+			fv.Values = append(fv.Values, models.FacetValueView{
+				Label:  label,
+				Count:  c.Count,
+				Refine: FieldTerm(f.field, c.Value),
+			})
+		}
+		view.Facets = append(view.Facets, fv)
+	}
+	return view
+}
+
+// hitsToViews converts search hits, splitting each snippet at its
+// highlights
+func (h *Handler) hitsToViews(hits []*SearchHit) []models.SearchHitView {
+	views := make([]models.SearchHitView, len(hits))
+	for i, hit := range hits {
+		views[i] = models.SearchHitView{
+			Note:     h.notesToViews([]*Note{hit.Note})[0],
+			Score:    hit.Score,
+			Snippets: snippetViews(hit.Snippets),
+		}
+	}
+	return views
+}
+
+func (h *Handler) relatedToViews(related []*RelatedNote) []models.RelatedNoteView {
+	views := make([]models.RelatedNoteView, len(related))
+	for i, rel := range related {
+		views[i] = models.RelatedNoteView{
+			Note:     h.notesToViews([]*Note{rel.Note})[0],
+			Terms:    rel.Terms,
+			Snippets: snippetViews(rel.Snippets),
+		}
+	}
+	return views
+}
+
+// snippetViews splits snippets into their plain and highlighted parts
+func snippetViews(snippets []Snippet) []models.SnippetView {
+	views := make([]models.SnippetView, len(snippets))
+	for i, snippet := range snippets {
+		text := []rune(snippet.Text)
+		pos := 0
+		for _, hl := range snippet.Highlights {
+			if hl[0] > pos {
+				views[i].Parts = append(views[i].Parts, models.SnippetPart{Text: string(text[pos:hl[0]])})
+			}
+			views[i].Parts = append(views[i].Parts, models.SnippetPart{Text: string(text[hl[0]:hl[1]]), Mark: true})
+			pos = hl[1]
+		}
+		if pos < len(text) {
+			views[i].Parts = append(views[i].Parts, models.SnippetPart{Text: string(text[pos:])})
+		}
+	}
+	return views
+}
+
+// revisionsToViews pairs each version with a diff against the one before.
+// revisions must be ordered newest first and include the current version.
+func (h *Handler) revisionsToViews(revisions []*Revision, current int64) []models.RevisionView {
+	views := make([]models.RevisionView, len(revisions))
+	for i, rev := range revisions {
+		var prevContent, prevCategory string
+		if i+1 < len(revisions) {
+			prevContent = revisions[i+1].Content
+			prevCategory = revisions[i+1].Category
+		}
+
+		diff := DiffLines(prevContent, rev.Content)
+		lines := make([]models.DiffLineView, len(diff))
+		for j, line := range diff {
+			lines[j] = models.DiffLineView{Op: string(line.Op), Text: line.Text}
+		}
+
+		views[i] = models.RevisionView{
+			Version:      rev.Version,
+			Category:     rev.Category,
+			PrevCategory: prevCategory,
+			Author:       rev.Author,
+			CreatedAt:    rev.CreatedAt,
+			Current:      rev.Version == current,
+			Diff:         lines,
 		}
 	}
 	return views
@@ -237,6 +1168,9 @@ func (h *Handler) CategoryPage(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Taken before listing, so a note added in between is replayed by the
+	// live feed rather than missed
+	lastEventID := h.bus.LastID()
 	noteList, err := h.svc.List(r.Context(), ListQuery{
 		Category: category,
 		Limit:    50,
@@ -256,7 +1190,7 @@ func (h *Handler) CategoryPage(w http.ResponseWriter, r *http.Request) {
 		renderedContent[note.ID] = h.svc.RenderMarkdown(note.Content)
 	}
 
-	pages.CategoryPage(category, noteViews, totalCount, renderedContent).Render(r.Context(), w)
+	pages.CategoryPage(category, noteViews, totalCount, renderedContent, lastEventID).Render(r.Context(), w)
 }
 
 // SearchPage handles GET /search
@@ -275,11 +1209,17 @@ func (h *Handler) SearchPage(w http.ResponseWriter, r *http.Request) {
 func (h *Handler) NotesFragment(w http.ResponseWriter, r *http.Request) {
 	q := ListQuery{
 		Category: r.URL.Query().Get("category"),
+		Tags:     parseTags(r),
+		TagMode:  TagMode(r.URL.Query().Get("tag_mode")),
 		Limit:    h.parseInt(r.URL.Query().Get("limit"), 50),
 		Offset:   h.parseInt(r.URL.Query().Get("offset"), 0),
 	}
 
 	noteList, err := h.svc.List(r.Context(), q)
+	if errors.Is(err, ErrInvalidInput) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		h.log.Error("failed to list notes", "error", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
@@ -301,24 +1241,23 @@ func (h *Handler) SearchFragment(w http.ResponseWriter, r *http.Request) {
 	q := SearchQuery{
 		Query:    r.URL.Query().Get("q"),
 		Category: r.URL.Query().Get("category"),
+		Tags:     parseTags(r),
+		TagMode:  TagMode(r.URL.Query().Get("tag_mode")),
 		Limit:    h.parseInt(r.URL.Query().Get("limit"), 50),
+		Offset:   h.parseInt(r.URL.Query().Get("offset"), 0),
+		Facets:   true,
 	}
-
-	// Parse date filters
-	if since := r.URL.Query().Get("since"); since != "" {
-		t, err := time.Parse("2006-01-02", since)
-		if err == nil {
-			q.Since = &t
-		}
-	}
-	if until := r.URL.Query().Get("until"); until != "" {
-		t, err := time.Parse("2006-01-02", until)
-		if err == nil {
-			q.Until = &t
-		}
+	var err error
+	if q.Since, q.Until, err = parseDateRange(r); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	noteList, err := h.svc.Search(r.Context(), q)
+	result, err := h.svc.Search(r.Context(), q)
+	if errors.Is(err, ErrInvalidInput) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		h.log.Error("failed to search notes", "error", err)
 		http.Error(w, "internal error", http.StatusInternalServerError)
@@ -326,11 +1265,80 @@ func (h *Handler) SearchFragment(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Convert to view models and render markdown
+	view := h.resultToView(result)
+	renderedContent := make(map[string]string)
+	for _, hit := range view.Hits {
+		renderedContent[hit.Note.ID] = h.svc.RenderMarkdown(hit.Note.Content)
+	}
+
+	pages.SearchResults(view, renderedContent, q.Query).Render(r.Context(), w)
+}
+
+// HistoryPage handles GET /note/{id}/history
+func (h *Handler) HistoryPage(w http.ResponseWriter, r *http.Request) {
+	note, err := h.svc.GetByID(r.Context(), r.PathValue("id"))
+	if errors.Is(err, ErrNoteNotFound) || errors.Is(err, ErrInvalidInput) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		h.log.Error("failed to get note", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	revisions, err := h.svc.ListRevisions(r.Context(), note.ID.Hex())
+	if err != nil {
+		h.log.Error("failed to list revisions", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+	revisions = append([]*Revision{revisionOf(note)}, revisions...)
+
+	noteView := h.notesToViews([]*Note{note})[0]
+	pages.HistoryPage(noteView, h.revisionsToViews(revisions, note.Version)).Render(r.Context(), w)
+}
+
+// NotePage handles GET /note/{id}, a single note with the notes related to
+// it underneath
+func (h *Handler) NotePage(w http.ResponseWriter, r *http.Request) {
+	note, err := h.svc.GetByID(r.Context(), r.PathValue("id"))
+	if errors.Is(err, ErrNoteNotFound) || errors.Is(err, ErrInvalidInput) {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		h.log.Error("failed to get note", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	related, err := h.svc.Related(r.Context(), note.ID.Hex(), RelatedQuery{Limit: 5})
+	if err != nil {
+		h.log.Error("failed to find related notes", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	noteView := h.notesToViews([]*Note{note})[0]
+	title := cmp.Or(h.svc.title(note.Content), "Note")
+	pages.NotePage(title, noteView, h.svc.RenderMarkdown(note.Content), h.relatedToViews(related)).Render(r.Context(), w)
+}
+
+// TrashPage handles GET /trash
+func (h *Handler) TrashPage(w http.ResponseWriter, r *http.Request) {
+	noteList, err := h.svc.ListTrash(r.Context(), ListQuery{Limit: 200})
+	if err != nil {
+		h.log.Error("failed to list trash", "error", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
 	noteViews := h.notesToViews(noteList)
 	renderedContent := make(map[string]string)
 	for _, note := range noteViews {
 		renderedContent[note.ID] = h.svc.RenderMarkdown(note.Content)
 	}
 
-	pages.SearchResults(noteViews, renderedContent, q.Query).Render(r.Context(), w)
+	pages.TrashPage(noteViews, renderedContent).Render(r.Context(), w)
 }
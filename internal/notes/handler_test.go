@@ -0,0 +1,100 @@
+package notes
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestMux serves the note API routes over a MemoryRepo
+func newTestMux(t *testing.T) (*http.ServeMux, *Service) {
+	t.Helper()
+	svc := newTestService(t)
+	bus := NewBus(16)
+	svc.OnChange(bus.Publish)
+	h := NewHandler(svc, bus, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/notes", h.ListNotes)
+	mux.HandleFunc("POST /api/notes", h.CreateNote)
+	mux.HandleFunc("POST /api/notes/bulk", h.CreateNotesBulk)
+	mux.HandleFunc("GET /api/notes/{id}", h.GetNote)
+	mux.HandleFunc("PUT /api/notes/{id}", h.ReplaceNote)
+	mux.HandleFunc("PATCH /api/notes/{id}", h.PatchNote)
+	mux.HandleFunc("DELETE /api/notes/{id}", h.DeleteNote)
+	mux.HandleFunc("POST /api/notes/{id}/restore", h.RestoreNote)
+	mux.HandleFunc("GET /api/trash", h.ListTrash)
+	mux.HandleFunc("GET /api/events", h.Events)
+	return mux, svc
+}
+
+// serve sends a request through mux; headers come in name, value pairs
+func serve(mux http.Handler, method, target, body string, headers ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func TestGetNoteETag(t *testing.T) {
+	mux, svc := newTestMux(t)
+	note := mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "v1"})
+
+	w := serve(mux, http.MethodGet, "/api/notes/"+note.ID.Hex(), "")
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != `"1"` {
+		t.Errorf("GET = %d with ETag %s, want 200 with \"1\"", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		body     string
+		ifMatch  string
+		want     int
+		wantETag string
+	}{
+		{"put, no precondition", http.MethodPut, `{"content":"v2"}`, "", http.StatusOK, `"2"`},
+		{"put, current version", http.MethodPut, `{"content":"v2"}`, `"1"`, http.StatusOK, `"2"`},
+		{"put, weak tag", http.MethodPut, `{"content":"v2"}`, `W/"1"`, http.StatusOK, `"2"`},
+		{"put, any version", http.MethodPut, `{"content":"v2"}`, "*", http.StatusOK, `"2"`},
+		{"put, stale version", http.MethodPut, `{"content":"v2"}`, `"7"`, http.StatusPreconditionFailed, ""},
+		{"patch, current version", http.MethodPatch, `{"append":"more"}`, `"1"`, http.StatusOK, `"2"`},
+		{"patch, stale version", http.MethodPatch, `{"append":"more"}`, `"2"`, http.StatusPreconditionFailed, ""},
+		{"malformed tag", http.MethodPut, `{"content":"v2"}`, `"one"`, http.StatusBadRequest, ""},
+		{"negative version", http.MethodPut, `{"content":"v2"}`, `"-1"`, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux, svc := newTestMux(t)
+			note := mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "v1"})
+
+			var headers []string
+			if tt.ifMatch != "" {
+				headers = []string{"If-Match", tt.ifMatch}
+			}
+			w := serve(mux, tt.method, "/api/notes/"+note.ID.Hex(), tt.body, headers...)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body)
+			}
+			if got := w.Header().Get("ETag"); got != tt.wantETag {
+				t.Errorf("ETag = %q, want %q", got, tt.wantETag)
+			}
+
+			stored, err := svc.GetByID(context.Background(), note.ID.Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed := stored.Version != note.Version; changed != (tt.want == http.StatusOK) {
+				t.Errorf("stored version = %d after a %d response", stored.Version, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,153 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"scratchpad/internal/workspace"
+)
+
+func TestCreateIdempotent(t *testing.T) {
+	for name, svc := range newStoreServices(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			input := CreateNoteInput{Category: "ideas", Content: "Retry me", Tags: []string{"a"}}
+
+			first, err := svc.CreateIdempotent(ctx, "key-1", input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if first.Replayed || first.Status != http.StatusCreated {
+				t.Fatalf("first create = %+v, want a fresh 201", first)
+			}
+
+			// A replay answers with the original note, even after it changed
+			edited := "Edited"
+			if _, err := svc.Update(ctx, first.Note.ID.Hex(), UpdateNoteInput{Content: &edited}); err != nil {
+				t.Fatal(err)
+			}
+			again, err := svc.CreateIdempotent(ctx, " key-1 ", CreateNoteInput{Category: "Ideas", Content: "Retry me", Tags: []string{"#A"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !again.Replayed || again.Status != http.StatusCreated || again.Note.ID != first.Note.ID || again.Note.Content != "Retry me" {
+				t.Errorf("replay = %+v, want the original response", again)
+			}
+			if count, _ := svc.Count(ctx, "ideas"); count != 1 {
+				t.Errorf("stored %d notes, want 1", count)
+			}
+
+			if _, err := svc.CreateIdempotent(ctx, "key-1", CreateNoteInput{Category: "ideas", Content: "Something else"}); !errors.Is(err, ErrIdempotencyKeyReused) {
+				t.Errorf("reuse for another note: err = %v, want ErrIdempotencyKeyReused", err)
+			}
+
+			// Keys belong to a workspace
+			other, err := svc.CreateIdempotent(workspace.With(ctx, "team"), "key-1", input)
+			if err != nil || other.Replayed || other.Note.ID == first.Note.ID {
+				t.Errorf("another workspace's create = %+v, %v; want a new note", other, err)
+			}
+
+			for _, key := range []string{"", "   ", string(make([]byte, maxIdempotencyKeyLen+1))} {
+				if _, err := svc.CreateIdempotent(ctx, key, input); !errors.Is(err, ErrInvalidInput) {
+					t.Errorf("key of length %d: err = %v, want ErrInvalidInput", len(key), err)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateIdempotentInFlight(t *testing.T) {
+	for name, svc := range newStoreServices(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			input := CreateNoteInput{Category: "ideas", Content: "Slow create"}
+			note, err := newNote(ctx, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Claim the key as a request that hasn't finished would
+			claimed := &IdempotencyRecord{Key: "slow", RequestHash: requestHash(note), ExpiresAt: time.Now().Add(time.Hour)}
+			if _, err := svc.repo.ClaimIdempotencyKey(ctx, claimed); err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := svc.CreateIdempotent(ctx, "slow", input); !errors.Is(err, ErrIdempotencyInFlight) {
+				t.Errorf("same request: err = %v, want ErrIdempotencyInFlight", err)
+			}
+			if _, err := svc.CreateIdempotent(ctx, "slow", CreateNoteInput{Category: "ideas", Content: "Other"}); !errors.Is(err, ErrIdempotencyKeyReused) {
+				t.Errorf("different request: err = %v, want ErrIdempotencyKeyReused", err)
+			}
+		})
+	}
+}
+
+func TestCreateIdempotentKeyLifetime(t *testing.T) {
+	for name, svc := range newStoreServices(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			// A failed create leaves the key free for the retry
+			existing := mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "Taken"})
+			input := CreateNoteInput{Category: "ideas", Content: "Taken", OnDuplicate: DuplicatesReject}
+			if _, err := svc.CreateIdempotent(ctx, "retry", input); !errors.Is(err, ErrDuplicateNote) {
+				t.Fatalf("err = %v, want ErrDuplicateNote", err)
+			}
+			if err := svc.Delete(ctx, existing.ID.Hex()); err != nil {
+				t.Fatal(err)
+			}
+			if res, err := svc.CreateIdempotent(ctx, "retry", input); err != nil || res.Replayed {
+				t.Errorf("retry after a failure = %+v, %v; want a fresh create", res, err)
+			}
+
+			// An expired key is forgotten
+			svc.SetIdempotencyTTL(time.Millisecond)
+			expiring := CreateNoteInput{Category: "ideas", Content: "Short-lived"}
+			first, err := svc.CreateIdempotent(ctx, "brief", expiring)
+			if err != nil {
+				t.Fatal(err)
+			}
+			time.Sleep(20 * time.Millisecond)
+			second, err := svc.CreateIdempotent(ctx, "brief", expiring)
+			if err != nil || second.Replayed || second.Note.ID == first.Note.ID {
+				t.Errorf("create after expiry = %+v, %v; want a new note", second, err)
+			}
+		})
+	}
+}
+
+func TestCreateNoteIdempotencyKey(t *testing.T) {
+	mux, svc := newTestMux(t)
+	body := `{"category":"ideas","content":"Once"}`
+
+	if w := serve(mux, http.MethodPost, "/api/notes", body, IdempotencyKeyHeader, "k1"); w.Code != http.StatusCreated || w.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Fatalf("first POST = %d, replayed %q", w.Code, w.Header().Get(IdempotentReplayedHeader))
+	}
+	tests := []struct {
+		name     string
+		body     string
+		key      string
+		want     int
+		replayed bool
+	}{
+		{"header replay", body, "k1", http.StatusCreated, true},
+		{"field replay", `{"category":"ideas","content":"Once","idempotencyKey":"k1"}`, "", http.StatusCreated, true},
+		{"header and field disagree", `{"category":"ideas","content":"Once","idempotencyKey":"k2"}`, "k1", http.StatusBadRequest, false},
+		{"reused for another note", `{"category":"ideas","content":"Twice"}`, "k1", http.StatusUnprocessableEntity, false},
+	}
+	for _, tt := range tests {
+		var headers []string
+		if tt.key != "" {
+			headers = []string{IdempotencyKeyHeader, tt.key}
+		}
+		w := serve(mux, http.MethodPost, "/api/notes", tt.body, headers...)
+		if w.Code != tt.want || (w.Header().Get(IdempotentReplayedHeader) == "true") != tt.replayed {
+			t.Errorf("%s: %d, replayed %q; want %d, replayed %v", tt.name, w.Code, w.Header().Get(IdempotentReplayedHeader), tt.want, tt.replayed)
+		}
+	}
+	if count, _ := svc.Count(context.Background(), "ideas"); count != 1 {
+		t.Errorf("stored %d notes, want 1", count)
+	}
+}
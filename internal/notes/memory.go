@@ -0,0 +1,639 @@
+package notes
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"scratchpad/internal/workspace"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryRepo is a NoteStore that keeps everything in process memory. It
+// mirrors Repo's semantics (ordering, limit clamping, search queries)
+// and is meant for tests and throwaway runs; nothing survives a restart.
+type MemoryRepo struct {
+	mu          sync.RWMutex
+	notes       map[primitive.ObjectID]*Note
+	revisions   map[primitive.ObjectID][]*Revision // oldest first
+	embeddings  map[primitive.ObjectID]*Embedding
+	idempotency map[idempotencyID]*IdempotencyRecord
+}
+
+// idempotencyID identifies an idempotency key within its workspace
+type idempotencyID struct{ workspace, key string }
+
+func NewMemoryRepo() *MemoryRepo {
+	return &MemoryRepo{
+		notes:       make(map[primitive.ObjectID]*Note),
+		revisions:   make(map[primitive.ObjectID][]*Revision),
+		embeddings:  make(map[primitive.ObjectID]*Embedding),
+		idempotency: make(map[idempotencyID]*IdempotencyRecord),
+	}
+}
+
+// EnsureIndexes is a no-op for the in-memory store
+func (r *MemoryRepo) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+// Insert creates a new note in the context's workspace
+func (r *MemoryRepo) Insert(ctx context.Context, n *Note) error {
+	n.ID = primitive.NewObjectID()
+	n.Workspace = workspace.FromContext(ctx)
+	n.CreatedAt = time.Now()
+	n.UpdatedAt = n.CreatedAt
+	n.Version = 1
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notes[n.ID] = cloneNote(n)
+	return nil
+}
+
+// InsertMany creates notes; it cannot fail
+func (r *MemoryRepo) InsertMany(ctx context.Context, notes []*Note) (int, error) {
+	for i, n := range notes {
+		if err := r.Insert(ctx, n); err != nil {
+			return i, err
+		}
+	}
+	return len(notes), nil
+}
+
+// FindByID retrieves a note by its ID
+func (r *MemoryRepo) FindByID(ctx context.Context, id primitive.ObjectID) (*Note, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	note, ok := r.notes[id]
+	if !ok || !isLive(ctx, note) {
+		return nil, ErrNoteNotFound
+	}
+	return cloneNote(note), nil
+}
+
+// Update writes n's category, content and tags if the stored version still equals
+// expectedVersion
+func (r *MemoryRepo) Update(ctx context.Context, n *Note, expectedVersion int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.notes[n.ID]
+	if !ok || !isLive(ctx, stored) {
+		return ErrNoteNotFound
+	}
+	if stored.Version != expectedVersion {
+		return ErrVersionConflict
+	}
+
+	n.UpdatedAt = time.Now()
+	n.Version = expectedVersion + 1
+	stored.Category = n.Category
+	stored.Content = n.Content
+	stored.Tags = append([]string(nil), n.Tags...)
+	stored.Author = n.Author
+	stored.ContentHash = n.ContentHash
+	stored.SimHash = n.SimHash
+	stored.UpdatedAt = n.UpdatedAt
+	stored.Version = n.Version
+	return nil
+}
+
+// List retrieves notes with optional category filter, sorted by created_at desc
+func (r *MemoryRepo) List(ctx context.Context, q ListQuery) ([]*Note, error) {
+	matches := r.filter(ctx, func(n *Note) bool {
+		return inCategory(n, q.Category, q.Categories) && hasTags(n, q.Tags, q.TagMode)
+	})
+	sortNewestFirst(matches)
+	return page(matches, q.Offset, clampLimit(q.Limit, 50, 200)), nil
+}
+
+// Search performs a search query with optional filters, ranking by the
+// number of occurrences of the words and phrases every match must contain
+func (r *MemoryRepo) Search(ctx context.Context, q SearchQuery) (*SearchResult, error) {
+	match := func(*Note) bool { return true }
+	var ranked []*regexp.Regexp
+	if q.expr != nil {
+		match = q.expr.matcher()
+		terms, _, _ := q.expr.rankedTerms()
+		for _, t := range terms {
+			ranked = append(ranked, regexp.MustCompile(t.foldedPattern()))
+		}
+	}
+	scores := make(map[primitive.ObjectID]float64)
+
+	matches := r.filter(ctx, func(n *Note) bool {
+		if !inCategory(n, q.Category, q.Categories) {
+			return false
+		}
+		if !hasTags(n, q.Tags, q.TagMode) {
+			return false
+		}
+		if q.Since != nil && n.CreatedAt.Before(*q.Since) {
+			return false
+		}
+		if q.Until != nil && n.CreatedAt.After(*q.Until) {
+			return false
+		}
+		if !match(n) {
+			return false
+		}
+		if len(ranked) > 0 {
+			content := foldAccents(n.Content)
+			for _, re := range ranked {
+				scores[n.ID] += float64(len(re.FindAllStringIndex(content, -1)))
+			}
+		}
+		return true
+	})
+
+	sortNewestFirst(matches)
+	if len(ranked) > 0 {
+		sort.SliceStable(matches, func(i, j int) bool {
+			return scores[matches[i].ID] > scores[matches[j].ID]
+		})
+	}
+
+	result := &SearchResult{Total: int64(len(matches))}
+	for _, n := range page(matches, q.Offset, clampLimit(q.Limit, 50, 200)) {
+		result.Hits = append(result.Hits, &SearchHit{Note: n, Score: scores[n.ID]})
+	}
+	if q.Facets {
+		result.Facets = facetsOf(matches)
+	}
+	return result, nil
+}
+
+// facetsOf counts notes by category, tag and month
+func facetsOf(notes []*Note) *SearchFacets {
+	categories := make(map[string]int64)
+	tags := make(map[string]int64)
+	months := make(map[string]int64)
+	for _, n := range notes {
+		categories[n.Category]++
+		for _, t := range n.Tags {
+			tags[t]++
+		}
+		months[n.CreatedAt.UTC().Format("2006-01")]++
+	}
+
+	byCount := func(counts map[string]int64) []FacetCount {
+		fcs := facetCounts(counts)
+		sort.SliceStable(fcs, func(i, j int) bool { return fcs[i].Count > fcs[j].Count })
+		return fcs[:min(len(fcs), MaxFacetValues)]
+	}
+	byMonth := facetCounts(months)
+	slices.Reverse(byMonth)
+	return &SearchFacets{
+		Categories: byCount(categories),
+		Tags:       byCount(tags),
+		Months:     byMonth[:min(len(byMonth), MaxFacetValues)],
+	}
+}
+
+// facetCounts lists counts sorted by value
+func facetCounts(counts map[string]int64) []FacetCount {
+	fcs := make([]FacetCount, 0, len(counts))
+	for v, c := range counts {
+		fcs = append(fcs, FacetCount{Value: v, Count: c})
+	}
+	sort.Slice(fcs, func(i, j int) bool { return fcs[i].Value < fcs[j].Value })
+	return fcs
+}
+
+// GetRecent retrieves most recent notes across all categories
+func (r *MemoryRepo) GetRecent(ctx context.Context, limit int, since *time.Time) ([]*Note, error) {
+	matches := r.filter(ctx, func(n *Note) bool {
+		return since == nil || !n.CreatedAt.Before(*since)
+	})
+	sortNewestFirst(matches)
+	return page(matches, 0, clampLimit(limit, 20, 100)), nil
+}
+
+// ListCategories returns all categories with counts and last note time
+func (r *MemoryRepo) ListCategories(ctx context.Context) ([]*Category, error) {
+	r.mu.RLock()
+	byName := make(map[string]*Category)
+	for _, n := range r.notes {
+		if !isLive(ctx, n) {
+			continue
+		}
+		cat, ok := byName[n.Category]
+		if !ok {
+			cat = &Category{Name: n.Category}
+			byName[n.Category] = cat
+		}
+		cat.Count++
+		if n.CreatedAt.After(cat.LastNote) {
+			cat.LastNote = n.CreatedAt
+		}
+	}
+	r.mu.RUnlock()
+
+	categories := make([]*Category, 0, len(byName))
+	for _, cat := range byName {
+		categories = append(categories, cat)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].LastNote.After(categories[j].LastNote)
+	})
+	return categories, nil
+}
+
+// ListTags returns all tags on live notes with usage counts, most used first
+func (r *MemoryRepo) ListTags(ctx context.Context, categories []string) ([]*Tag, error) {
+	r.mu.RLock()
+	counts := make(map[string]int64)
+	for _, n := range r.notes {
+		if !isLive(ctx, n) || !inCategory(n, "", categories) {
+			continue
+		}
+		for _, t := range n.Tags {
+			counts[t]++
+		}
+	}
+	r.mu.RUnlock()
+
+	tags := make([]*Tag, 0, len(counts))
+	for name, count := range counts {
+		tags = append(tags, &Tag{Name: name, Count: count})
+	}
+	sort.Slice(tags, func(i, j int) bool {
+		if tags[i].Count != tags[j].Count {
+			return tags[i].Count > tags[j].Count
+		}
+		return tags[i].Name < tags[j].Name
+	})
+	return tags, nil
+}
+
+// Delete moves a note to the trash
+func (r *MemoryRepo) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	note, ok := r.notes[id]
+	if !ok || !isLive(ctx, note) {
+		return ErrNoteNotFound
+	}
+	now := time.Now()
+	note.DeletedAt = &now
+	return nil
+}
+
+// Restore takes a note back out of the trash
+func (r *MemoryRepo) Restore(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	note, ok := r.notes[id]
+	if !ok || !isTrashed(ctx, note) {
+		return ErrNoteNotFound
+	}
+	note.DeletedAt = nil
+	return nil
+}
+
+// FindTrashed retrieves a note from the trash by its ID
+func (r *MemoryRepo) FindTrashed(ctx context.Context, id primitive.ObjectID) (*Note, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	note, ok := r.notes[id]
+	if !ok || !isTrashed(ctx, note) {
+		return nil, ErrNoteNotFound
+	}
+	return cloneNote(note), nil
+}
+
+// ListTrash retrieves trashed notes, most recently deleted first
+func (r *MemoryRepo) ListTrash(ctx context.Context, q ListQuery) ([]*Note, error) {
+	r.mu.RLock()
+	var matches []*Note
+	for _, n := range r.notes {
+		if isTrashed(ctx, n) && inCategory(n, q.Category, q.Categories) {
+			matches = append(matches, cloneNote(n))
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].DeletedAt.After(*matches[j].DeletedAt)
+	})
+	return page(matches, q.Offset, clampLimit(q.Limit, 50, 200)), nil
+}
+
+// Purge permanently removes notes trashed before the cutoff, along with
+// their revisions, in every workspace
+func (r *MemoryRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var purged int64
+	for id, n := range r.notes {
+		if n.DeletedAt != nil && n.DeletedAt.Before(before) {
+			delete(r.notes, id)
+			delete(r.revisions, id)
+			delete(r.embeddings, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// Count returns the total number of notes, optionally filtered by category
+func (r *MemoryRepo) Count(ctx context.Context, category string) (int64, error) {
+	matches := r.filter(ctx, func(n *Note) bool {
+		return category == "" || n.Category == category
+	})
+	return int64(len(matches)), nil
+}
+
+// Export streams live notes matching q to fn, oldest first
+func (r *MemoryRepo) Export(ctx context.Context, q ExportQuery, fn func(*Note) error) error {
+	matches := r.filter(ctx, func(n *Note) bool {
+		return inCategory(n, q.Category, q.Categories) &&
+			(q.Since == nil || !n.CreatedAt.Before(*q.Since)) &&
+			(q.Until == nil || n.CreatedAt.Before(*q.Until))
+	})
+	sort.Slice(matches, func(i, j int) bool {
+		if !matches[i].CreatedAt.Equal(matches[j].CreatedAt) {
+			return matches[i].CreatedAt.Before(matches[j].CreatedAt)
+		}
+		return matches[i].ID.Hex() < matches[j].ID.Hex()
+	})
+	for _, n := range matches {
+		if err := fn(n); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportNote inserts n as given, or replaces the note with its ID in the
+// context's workspace when overwrite is set
+func (r *MemoryRepo) ImportNote(ctx context.Context, n *Note, overwrite bool) (bool, error) {
+	n.Workspace = workspace.FromContext(ctx)
+	n.DeletedAt = nil
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, exists := r.notes[n.ID]
+	if exists && (!overwrite || stored.Workspace != n.Workspace) {
+		return false, ErrNoteExists
+	}
+	r.notes[n.ID] = cloneNote(n)
+	return exists, nil
+}
+
+// FindByContentHash returns the newest live note in category whose content
+// hashes to hash
+func (r *MemoryRepo) FindByContentHash(ctx context.Context, category, hash string) (*Note, error) {
+	matches := r.filter(ctx, func(n *Note) bool {
+		return n.Category == category && n.ContentHash == hash
+	})
+	if len(matches) == 0 {
+		return nil, ErrNoteNotFound
+	}
+	sortNewestFirst(matches)
+	return matches[0], nil
+}
+
+// ListFingerprints returns the fingerprints of live notes, optionally
+// filtered by category
+func (r *MemoryRepo) ListFingerprints(ctx context.Context, category string, categories []string) ([]*Fingerprint, error) {
+	matches := r.filter(ctx, func(n *Note) bool {
+		return inCategory(n, category, categories)
+	})
+	fps := make([]*Fingerprint, len(matches))
+	for i, n := range matches {
+		fps[i] = &Fingerprint{ID: n.ID, Category: n.Category, ContentHash: n.ContentHash, SimHash: n.SimHash, CreatedAt: n.CreatedAt}
+	}
+	return fps, nil
+}
+
+// ListEmbeddings returns the embedding state of live notes
+func (r *MemoryRepo) ListEmbeddings(ctx context.Context) ([]*NoteEmbedding, error) {
+	matches := r.filter(ctx, func(n *Note) bool { return true })
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	embeddings := make([]*NoteEmbedding, len(matches))
+	for i, n := range matches {
+		embeddings[i] = &NoteEmbedding{
+			ID:          n.ID,
+			Category:    n.Category,
+			ContentHash: n.ContentHash,
+			CreatedAt:   n.CreatedAt,
+			Embedding:   r.embeddings[n.ID],
+		}
+	}
+	return embeddings, nil
+}
+
+// SaveEmbedding stores a note's embedding
+func (r *MemoryRepo) SaveEmbedding(ctx context.Context, id primitive.ObjectID, e *Embedding) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	note, ok := r.notes[id]
+	if !ok || !isLive(ctx, note) {
+		return ErrNoteNotFound
+	}
+	saved := *e
+	r.embeddings[id] = &saved
+	return nil
+}
+
+// SaveRevision stores a snapshot of a note version, ignoring versions that
+// are already saved
+func (r *MemoryRepo) SaveRevision(ctx context.Context, rev *Revision) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.revisions[rev.NoteID] {
+		if existing.Version == rev.Version {
+			return nil
+		}
+	}
+	saved := *rev
+	r.revisions[rev.NoteID] = append(r.revisions[rev.NoteID], &saved)
+	return nil
+}
+
+// ListRevisions returns a note's stored revisions, newest first
+func (r *MemoryRepo) ListRevisions(ctx context.Context, noteID primitive.ObjectID) ([]*Revision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored := r.revisions[noteID]
+	revisions := make([]*Revision, 0, len(stored))
+	for _, rev := range stored {
+		c := *rev
+		revisions = append(revisions, &c)
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Version > revisions[j].Version
+	})
+	return revisions, nil
+}
+
+// FindRevision retrieves a single revision of a note
+func (r *MemoryRepo) FindRevision(ctx context.Context, noteID primitive.ObjectID, version int64) (*Revision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, rev := range r.revisions[noteID] {
+		if rev.Version == version {
+			c := *rev
+			return &c, nil
+		}
+	}
+	return nil, ErrRevisionNotFound
+}
+
+// ClaimIdempotencyKey stores rec unless the workspace already holds a live
+// record for its key, which is returned instead. Expired records, in any
+// workspace, are swept first.
+func (r *MemoryRepo) ClaimIdempotencyKey(ctx context.Context, rec *IdempotencyRecord) (*IdempotencyRecord, error) {
+	rec.Workspace = workspace.FromContext(ctx)
+	rec.CreatedAt = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, stored := range r.idempotency {
+		if !stored.ExpiresAt.After(rec.CreatedAt) {
+			delete(r.idempotency, id)
+		}
+	}
+
+	id := idempotencyID{rec.Workspace, rec.Key}
+	if stored, ok := r.idempotency[id]; ok {
+		c := *stored
+		return &c, nil
+	}
+	c := *rec
+	r.idempotency[id] = &c
+	return nil, nil
+}
+
+// CompleteIdempotencyKey records the response for a claimed key
+func (r *MemoryRepo) CompleteIdempotencyKey(ctx context.Context, key string, status int, response []byte) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, ok := r.idempotency[idempotencyID{workspace.FromContext(ctx), key}]; ok {
+		stored.Status = status
+		stored.Response = slices.Clone(response)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey drops a claimed key that has no response yet
+func (r *MemoryRepo) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id := idempotencyID{workspace.FromContext(ctx), key}
+	if stored, ok := r.idempotency[id]; ok && stored.Status == 0 {
+		delete(r.idempotency, id)
+	}
+	return nil
+}
+
+// --- Helpers ---
+
+// filter returns copies of the live notes in the context's workspace that
+// keep accepts
+func (r *MemoryRepo) filter(ctx context.Context, keep func(n *Note) bool) []*Note {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matches []*Note
+	for _, n := range r.notes {
+		if isLive(ctx, n) && keep(n) {
+			matches = append(matches, cloneNote(n))
+		}
+	}
+	return matches
+}
+
+// isLive reports whether n is an untrashed note in the context's workspace
+func isLive(ctx context.Context, n *Note) bool {
+	return n.DeletedAt == nil && n.Workspace == workspace.FromContext(ctx)
+}
+
+// isTrashed reports whether n is a trashed note in the context's workspace
+func isTrashed(ctx context.Context, n *Note) bool {
+	return n.DeletedAt != nil && n.Workspace == workspace.FromContext(ctx)
+}
+
+// inCategory reports whether n is in category, or in any of categories when
+// category is empty
+func inCategory(n *Note, category string, categories []string) bool {
+	if category != "" {
+		return n.Category == category
+	}
+	return len(categories) == 0 || slices.Contains(categories, n.Category)
+}
+
+// hasTags reports whether n carries any (or all, per mode) of tags
+func hasTags(n *Note, tags []string, mode TagMode) bool {
+	if len(tags) == 0 {
+		return true
+	}
+	for _, want := range tags {
+		found := false
+		for _, t := range n.Tags {
+			if t == want {
+				found = true
+				break
+			}
+		}
+		if found && mode != TagModeAll {
+			return true
+		}
+		if !found && mode == TagModeAll {
+			return false
+		}
+	}
+	return mode == TagModeAll
+}
+
+func sortNewestFirst(noteList []*Note) {
+	sort.Slice(noteList, func(i, j int) bool {
+		return noteList[i].CreatedAt.After(noteList[j].CreatedAt)
+	})
+}
+
+func page(noteList []*Note, offset, limit int) []*Note {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(noteList) {
+		return nil
+	}
+	end := offset + limit
+	if end > len(noteList) {
+		end = len(noteList)
+	}
+	return noteList[offset:end]
+}
+
+// cloneNote copies a note so callers can't mutate stored state
+func cloneNote(n *Note) *Note {
+	c := *n
+	c.Tags = append([]string(nil), n.Tags...)
+	if n.DeletedAt != nil {
+		deletedAt := *n.DeletedAt
+		c.DeletedAt = &deletedAt
+	}
+	return &c
+}
@@ -0,0 +1,551 @@
+package notes
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Search queries are written in a small language, parsed here and compiled
+// by each store:
+//
+//	rust async               notes containing both words
+//	"exact phrase"           the words in this order
+//	-draft                   notes without the word
+//	rust OR go               either side; binds looser than the implicit AND
+//	(rust OR go) -draft      parentheses group
+//	category:work tag:idea   field filters, which can be negated and OR-ed too
+//	created:>2026-01-01      created or updated dates with >, >=, <, <=, a..b,
+//	                         or a bare YYYY, YYYY-MM or YYYY-MM-DD period
+//
+// Words match case-insensitively at the start of a word, so "run" finds
+// "running"; phrases match anywhere.
+
+type queryKind int
+
+const (
+	queryAnd queryKind = iota
+	queryOr
+	queryNot
+	queryText // a word or phrase in the content
+	queryCategory
+	queryTag
+	queryDate // Field within [From, To)
+)
+
+// queryNode is one node of a parsed search query
+type queryNode struct {
+	Kind     queryKind
+	Children []*queryNode // and, or, not
+	Value    string       // text, category or tag
+	Phrase   bool         // text was quoted
+	Field    string       // date: created_at or updated_at
+	From, To *time.Time   // date bounds, either may be nil
+}
+
+// queryDateFields maps the date filters onto the fields they compare
+var queryDateFields = map[string]string{
+	"created": "created_at",
+	"updated": "updated_at",
+}
+
+type tokenKind int
+
+const (
+	tokenTerm tokenKind = iota
+	tokenField
+	tokenOr
+	tokenAnd
+	tokenOpen
+	tokenClose
+)
+
+type queryToken struct {
+	kind   tokenKind
+	pos    int // byte offset in the query
+	negate bool
+	field  string
+	value  string
+	quoted bool
+}
+
+// parseSearchQuery parses a search query. A blank query parses to nil,
+// which matches everything; errors wrap ErrInvalidInput and say where the
+// query went wrong.
+func parseSearchQuery(query string) (*queryNode, error) {
+	tokens, err := lexQuery(query)
+	if err != nil || len(tokens) == 0 {
+		return nil, err
+	}
+	p := &queryParser{query: query, tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok, ok := p.peek(); ok {
+		return nil, p.errorf(tok.pos, "unexpected %q", ")")
+	}
+	return node, nil
+}
+
+func lexQuery(query string) ([]queryToken, error) {
+	var tokens []queryToken
+	for i := 0; i < len(query); {
+		r, size := utf8.DecodeRuneInString(query[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+
+		tok := queryToken{pos: i}
+		if r == '-' {
+			next, _ := utf8.DecodeRuneInString(query[i+1:])
+			if i+1 == len(query) || unicode.IsSpace(next) || next == ')' {
+				return nil, queryError(query, i, "nothing to exclude after -")
+			}
+			tok.negate = true
+			i++
+		}
+
+		switch query[i] {
+		case '(':
+			tok.kind = tokenOpen
+			i++
+		case ')':
+			tok.kind = tokenClose
+			i++
+		case '"':
+			value, end, err := lexPhrase(query, i)
+			if err != nil {
+				return nil, err
+			}
+			tok.kind, tok.value, tok.quoted = tokenTerm, value, true
+			i = end
+		default:
+			end := i + strings.IndexFunc(query[i:], isWordEnd)
+			if end < i {
+				end = len(query)
+			}
+			word := query[i:end]
+			i = end
+
+			name, value, isField := strings.Cut(word, ":")
+			switch {
+			case word == "OR" && !tok.negate:
+				tok.kind = tokenOr
+			case word == "AND" && !tok.negate:
+				tok.kind = tokenAnd
+			case isField && isFieldName(name):
+				tok.kind, tok.field, tok.value = tokenField, strings.ToLower(name), value
+				if value == "" && i < len(query) && query[i] == '"' {
+					value, end, err := lexPhrase(query, i)
+					if err != nil {
+						return nil, err
+					}
+					tok.value, tok.quoted = value, true
+					i = end
+				}
+				if tok.value == "" {
+					return nil, queryError(query, tok.pos, "missing value after %s:", name)
+				}
+			default:
+				tok.kind, tok.value = tokenTerm, word
+			}
+		}
+		tokens = append(tokens, tok)
+	}
+	return tokens, nil
+}
+
+// lexPhrase reads the quoted phrase starting at query[start], returning it
+// and the offset just past the closing quote
+func lexPhrase(query string, start int) (string, int, error) {
+	end := strings.IndexByte(query[start+1:], '"')
+	if end < 0 {
+		return "", 0, queryError(query, start, "unterminated quote")
+	}
+	phrase := strings.TrimSpace(query[start+1 : start+1+end])
+	if phrase == "" {
+		return "", 0, queryError(query, start, "empty phrase")
+	}
+	return phrase, start + end + 2, nil
+}
+
+func isWordEnd(r rune) bool {
+	return unicode.IsSpace(r) || r == '(' || r == ')' || r == '"'
+}
+
+// isFieldName reports whether a word's text before a colon names a field.
+// Anything else, like "TODO:", "10:30" or "http://", stays a plain word.
+func isFieldName(name string) bool {
+	switch strings.ToLower(name) {
+	case "category", "tag", "created", "updated":
+		return true
+	}
+	return false
+}
+
+type queryParser struct {
+	query  string
+	tokens []queryToken
+	i      int
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.i == len(p.tokens) {
+		return queryToken{}, false
+	}
+	return p.tokens[p.i], true
+}
+
+func (p *queryParser) errorf(pos int, format string, args ...any) error {
+	return queryError(p.query, pos, format, args...)
+}
+
+func (p *queryParser) parseOr() (*queryNode, error) {
+	var alternatives []*queryNode
+	for {
+		node, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		alternatives = append(alternatives, node)
+
+		tok, ok := p.peek()
+		if !ok || tok.kind != tokenOr {
+			break
+		}
+		p.i++
+		if next, ok := p.peek(); !ok || next.kind == tokenClose {
+			return nil, p.errorf(tok.pos, "OR needs a term on both sides")
+		}
+	}
+	if len(alternatives) == 1 {
+		return alternatives[0], nil
+	}
+	return &queryNode{Kind: queryOr, Children: alternatives}, nil
+}
+
+func (p *queryParser) parseAnd() (*queryNode, error) {
+	var terms []*queryNode
+	and := -1 // position of an AND still waiting for its right side
+	for {
+		tok, ok := p.peek()
+		if !ok || tok.kind == tokenOr || tok.kind == tokenClose {
+			break
+		}
+		if tok.kind == tokenAnd {
+			if len(terms) == 0 || and >= 0 {
+				return nil, p.errorf(tok.pos, "AND needs a term on both sides")
+			}
+			and = tok.pos
+			p.i++
+			continue
+		}
+
+		node, err := p.parseUnit()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, node)
+		and = -1
+	}
+
+	if and >= 0 {
+		return nil, p.errorf(and, "AND needs a term on both sides")
+	}
+	if len(terms) == 0 {
+		tok, ok := p.peek()
+		switch {
+		case !ok:
+			return nil, p.errorf(len(p.query), "expected a search term")
+		case tok.kind == tokenOr:
+			return nil, p.errorf(tok.pos, "OR needs a term on both sides")
+		default:
+			return nil, p.errorf(tok.pos, "expected a search term")
+		}
+	}
+	if len(terms) == 1 {
+		return terms[0], nil
+	}
+	return &queryNode{Kind: queryAnd, Children: terms}, nil
+}
+
+func (p *queryParser) parseUnit() (*queryNode, error) {
+	tok := p.tokens[p.i]
+	p.i++
+
+	var node *queryNode
+	switch tok.kind {
+	case tokenOpen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if next, ok := p.peek(); !ok || next.kind != tokenClose {
+			return nil, p.errorf(tok.pos, "missing closing %q", ")")
+		}
+		p.i++
+		node = inner
+	case tokenTerm:
+		node = &queryNode{Kind: queryText, Value: tok.value, Phrase: tok.quoted}
+	case tokenField:
+		var err error
+		if node, err = p.fieldNode(tok); err != nil {
+			return nil, err
+		}
+	}
+
+	if tok.negate {
+		node = &queryNode{Kind: queryNot, Children: []*queryNode{node}}
+	}
+	return node, nil
+}
+
+func (p *queryParser) fieldNode(tok queryToken) (*queryNode, error) {
+	switch tok.field {
+	case "category":
+		if c := normalizeCategory(tok.value); c != "" {
+			return &queryNode{Kind: queryCategory, Value: c}, nil
+		}
+	case "tag":
+		if t := normalizeTags([]string{tok.value}); len(t) == 1 {
+			return &queryNode{Kind: queryTag, Value: t[0]}, nil
+		}
+	case "created", "updated":
+		from, to, err := parseDateFilter(tok.value)
+		if err != nil {
+			return nil, p.errorf(tok.pos, "%s:%s: %v", tok.field, tok.value, err)
+		}
+		return &queryNode{Kind: queryDate, Field: queryDateFields[tok.field], From: from, To: to}, nil
+	}
+	return nil, p.errorf(tok.pos, "missing value after %s:", tok.field)
+}
+
+// parseDateFilter reads the value of a created: or updated: filter into
+// bounds: an inclusive From and exclusive To
+func parseDateFilter(v string) (from, to *time.Time, err error) {
+	if lo, hi, ok := strings.Cut(v, ".."); ok {
+		if lo == "" && hi == "" {
+			return nil, nil, fmt.Errorf("range needs at least one end")
+		}
+		if lo != "" {
+			start, _, err := parsePeriod(lo)
+			if err != nil {
+				return nil, nil, err
+			}
+			from = &start
+		}
+		if hi != "" {
+			_, end, err := parsePeriod(hi)
+			if err != nil {
+				return nil, nil, err
+			}
+			to = &end
+		}
+		return from, to, nil
+	}
+
+	op := ""
+	for _, prefix := range []string{">=", "<=", ">", "<"} {
+		if strings.HasPrefix(v, prefix) {
+			op, v = prefix, v[len(prefix):]
+			break
+		}
+	}
+	start, end, err := parsePeriod(v)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch op {
+	case ">=":
+		return &start, nil, nil
+	case ">":
+		return &end, nil, nil
+	case "<":
+		return nil, &start, nil
+	case "<=":
+		return nil, &end, nil
+	default:
+		return &start, &end, nil
+	}
+}
+
+// parsePeriod reads a year, month, day or RFC 3339 instant as the span of
+// time it covers
+func parsePeriod(v string) (start, end time.Time, err error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, t.Add(time.Millisecond), nil
+	}
+	periods := []struct {
+		layout string
+		next   func(time.Time) time.Time
+	}{
+		{"2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+		{"2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+		{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+	}
+	for _, p := range periods {
+		if t, err := time.Parse(p.layout, v); err == nil {
+			return t, p.next(t), nil
+		}
+	}
+	return start, end, fmt.Errorf("invalid date %q, use YYYY-MM-DD, YYYY-MM, YYYY or RFC3339", v)
+}
+
+// FieldTerm writes a field filter in the query language, quoting the value
+// when it would not lex as one word
+func FieldTerm(field, value string) string {
+	value = strings.ReplaceAll(value, `"`, "")
+	if strings.IndexFunc(value, isWordEnd) >= 0 {
+		value = `"` + value + `"`
+	}
+	return field + ":" + value
+}
+
+// queryError reports a parse error at a byte offset, given to the user as
+// a 1-based character position
+func queryError(query string, pos int, format string, args ...any) error {
+	col := utf8.RuneCountInString(query[:pos]) + 1
+	return fmt.Errorf("%w: search query: %s at position %d", ErrInvalidInput, fmt.Sprintf(format, args...), col)
+}
+
+// rankedTerms splits off the terms stores rank results by from the rest of
+// the query: the words and phrases every match must contain or, for a query
+// of nothing but words joined by OR, those words, any of which will do
+// (any is set). rest is nil when nothing else is left.
+func (n *queryNode) rankedTerms() (terms []*queryNode, any bool, rest *queryNode) {
+	switch n.Kind {
+	case queryText:
+		return []*queryNode{n}, false, nil
+	case queryOr:
+		for _, c := range n.Children {
+			if c.Kind != queryText || c.Phrase {
+				return nil, false, n
+			}
+		}
+		return n.Children, true, nil
+	case queryAnd:
+		var others []*queryNode
+		for _, c := range n.Children {
+			if c.Kind == queryText {
+				terms = append(terms, c)
+			} else {
+				others = append(others, c)
+			}
+		}
+		switch len(others) {
+		case 0:
+			return terms, false, nil
+		case 1:
+			return terms, false, others[0]
+		default:
+			return terms, false, &queryNode{Kind: queryAnd, Children: others}
+		}
+	default:
+		return nil, false, n
+	}
+}
+
+// anyOf builds a query matching notes with any of the words, which stores
+// rank by how well they match. It is nil without words.
+func anyOf(words []string) *queryNode {
+	nodes := make([]*queryNode, len(words))
+	for i, w := range words {
+		nodes[i] = &queryNode{Kind: queryText, Value: w}
+	}
+	switch len(nodes) {
+	case 0:
+		return nil
+	case 1:
+		return nodes[0]
+	}
+	return &queryNode{Kind: queryOr, Children: nodes}
+}
+
+// textPattern is the regular expression a text node matches content with:
+// words at the start of a word, phrases anywhere, both ignoring case
+func (n *queryNode) textPattern() string {
+	pattern := regexp.QuoteMeta(n.Value)
+	if !n.Phrase && isWordChar(n.Value[0]) {
+		pattern = `\b` + pattern
+	}
+	return "(?i)" + pattern
+}
+
+// foldedPattern is textPattern for content passed through foldAccents
+func (n *queryNode) foldedPattern() string {
+	folded := *n
+	if v := foldAccents(n.Value); v != "" {
+		folded.Value = v
+	}
+	return folded.textPattern()
+}
+
+// foldAccents strips diacritics so that "café" matches "cafe", as it does
+// under the SQLite full-text tokenizer
+func foldAccents(s string) string {
+	ascii := true
+	for i := 0; i < len(s) && ascii; i++ {
+		ascii = s[i] < utf8.RuneSelf
+	}
+	if ascii {
+		return s
+	}
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// matcher compiles the query into a predicate for stores that filter notes
+// themselves
+func (n *queryNode) matcher() func(*Note) bool {
+	switch n.Kind {
+	case queryAnd, queryOr:
+		children := make([]func(*Note) bool, len(n.Children))
+		for i, c := range n.Children {
+			children[i] = c.matcher()
+		}
+		all := n.Kind == queryAnd
+		return func(note *Note) bool {
+			for _, match := range children {
+				if match(note) != all {
+					return !all
+				}
+			}
+			return all
+		}
+	case queryNot:
+		match := n.Children[0].matcher()
+		return func(note *Note) bool { return !match(note) }
+	case queryText:
+		re := regexp.MustCompile(n.foldedPattern())
+		return func(note *Note) bool { return re.MatchString(foldAccents(note.Content)) }
+	case queryCategory:
+		return func(note *Note) bool { return note.Category == n.Value }
+	case queryTag:
+		return func(note *Note) bool { return slices.Contains(note.Tags, n.Value) }
+	default:
+		return func(note *Note) bool {
+			t := note.CreatedAt
+			if n.Field == "updated_at" {
+				t = note.UpdatedAt
+			}
+			return (n.From == nil || !t.Before(*n.From)) && (n.To == nil || t.Before(*n.To))
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package notes
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// render writes a parsed query compactly, for comparing parse trees
+func render(n *queryNode) string {
+	if n == nil {
+		return "<nil>"
+	}
+	join := func(children []*queryNode) string {
+		parts := make([]string, len(children))
+		for i, c := range children {
+			parts[i] = render(c)
+		}
+		return strings.Join(parts, " ")
+	}
+	bound := func(t *time.Time) string {
+		if t == nil {
+			return "*"
+		}
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	switch n.Kind {
+	case queryAnd:
+		return "and(" + join(n.Children) + ")"
+	case queryOr:
+		return "or(" + join(n.Children) + ")"
+	case queryNot:
+		return "-" + render(n.Children[0])
+	case queryText:
+		if n.Phrase {
+			return `"` + n.Value + `"`
+		}
+		return n.Value
+	case queryCategory:
+		return "category:" + n.Value
+	case queryTag:
+		return "tag:" + n.Value
+	default:
+		return n.Field + "[" + bound(n.From) + "," + bound(n.To) + ")"
+	}
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"", "<nil>"},
+		{"   ", "<nil>"},
+		{"rust", "rust"},
+		{"Rust  async", "and(Rust async)"},
+		{`"exact phrase"`, `"exact phrase"`},
+		{`say "hello world" twice`, `and(say "hello world" twice)`},
+		{"rust OR go", "or(rust go)"},
+		{"a b OR c", "or(and(a b) c)"},
+		{"a AND b", "and(a b)"},
+		{"(rust OR go) -draft", "and(or(rust go) -draft)"},
+		{"-(a OR b)", "-or(a b)"},
+		{`-"bad idea"`, `-"bad idea"`},
+		{"or and", "and(or and)"},
+		{"10:30 meeting", "and(10:30 meeting)"},
+		{"TODO: fix", "and(TODO: fix)"},
+		{"Q3: revenue", "and(Q3: revenue)"},
+		{"see http://example.com", "and(see http://example.com)"},
+		{"foo:bar -note:", "and(foo:bar -note:)"},
+		{"category:work", "category:work"},
+		{"TAG:idea", "tag:idea"},
+		{`Category:"Work Log" tag:#Idea`, "and(category:work-log tag:idea)"},
+		{"-tag:draft", "-tag:draft"},
+		{"tag:a OR tag:b", "or(tag:a tag:b)"},
+		{"created:2026", "created_at[2026-01-01T00:00:00Z,2027-01-01T00:00:00Z)"},
+		{"created:2026-02", "created_at[2026-02-01T00:00:00Z,2026-03-01T00:00:00Z)"},
+		{"created:2026-02-28", "created_at[2026-02-28T00:00:00Z,2026-03-01T00:00:00Z)"},
+		{"created:>2026-01-01", "created_at[2026-01-02T00:00:00Z,*)"},
+		{"created:>=2026-01", "created_at[2026-01-01T00:00:00Z,*)"},
+		{"updated:<2026-03-15", "updated_at[*,2026-03-15T00:00:00Z)"},
+		{"updated:<=2026-03", "updated_at[*,2026-04-01T00:00:00Z)"},
+		{"created:2026-01-01..2026-02-01", "created_at[2026-01-01T00:00:00Z,2026-02-02T00:00:00Z)"},
+		{"created:2025..", "created_at[2025-01-01T00:00:00Z,*)"},
+		{"created:..2026", "created_at[*,2027-01-01T00:00:00Z)"},
+		{"created:2026-01-02T10:00:00Z", "created_at[2026-01-02T10:00:00Z,2026-01-02T10:00:00.001Z)"},
+		{"rust -created:2025", "and(rust -created_at[2025-01-01T00:00:00Z,2026-01-01T00:00:00Z))"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			n, err := parseSearchQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := render(n); got != tt.want {
+				t.Errorf("parsed as %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchQueryErrors(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"a OR", "OR needs a term on both sides at position 3"},
+		{"OR a", "OR needs a term on both sides at position 1"},
+		{"a OR OR b", "OR needs a term on both sides at position 6"},
+		{"(a OR) b", "OR needs a term on both sides at position 4"},
+		{"a AND", "AND needs a term on both sides at position 3"},
+		{"AND a", "AND needs a term on both sides at position 1"},
+		{"a AND AND b", "AND needs a term on both sides at position 7"},
+		{"café OR", "OR needs a term on both sides at position 6"},
+		{"rust -", "nothing to exclude after - at position 6"},
+		{"- rust", "nothing to exclude after - at position 1"},
+		{"(-)", "nothing to exclude after - at position 2"},
+		{`""`, "empty phrase at position 1"},
+		{`rust "  "`, "empty phrase at position 6"},
+		{`"rust`, "unterminated quote at position 1"},
+		{`category:"`, "unterminated quote at position 10"},
+		{"(rust", `missing closing ")" at position 1`},
+		{"rust)", `unexpected ")" at position 5`},
+		{"()", "expected a search term at position 2"},
+		{"tag:", "missing value after tag: at position 1"},
+		{`category:""`, "empty phrase at position 10"},
+		{"tag:#", "missing value after tag: at position 1"},
+		{"created:>", `created:>: invalid date "", use YYYY-MM-DD, YYYY-MM, YYYY or RFC3339 at position 1`},
+		{"created:2026-13", `created:2026-13: invalid date "2026-13", use YYYY-MM-DD, YYYY-MM, YYYY or RFC3339 at position 1`},
+		{"updated:..", "updated:..: range needs at least one end at position 1"},
+		{"a created:yesterday..2026", `invalid date "yesterday", use YYYY-MM-DD, YYYY-MM, YYYY or RFC3339 at position 3`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			n, err := parseSearchQuery(tt.query)
+			if err == nil {
+				t.Fatalf("parsed as %s, want an error", render(n))
+			}
+			if !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("err = %v, want it to wrap %v", err, ErrInvalidInput)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %q, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func mustParse(t *testing.T, query string) *queryNode {
+	t.Helper()
+	n, err := parseSearchQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestQueryFilter(t *testing.T) {
+	y2026 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	y2027 := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		query string
+		want  bson.M
+	}{
+		{"rust", bson.M{"content": primitive.Regex{Pattern: `(?i)\brust`}}},
+		{`"a.b (c)"`, bson.M{"content": primitive.Regex{Pattern: `(?i)a\.b \(c\)`}}},
+		{"#go", bson.M{"content": primitive.Regex{Pattern: `(?i)#go`}}},
+		{"a OR category:b", bson.M{"$or": bson.A{
+			bson.M{"content": primitive.Regex{Pattern: `(?i)\ba`}},
+			bson.M{"category": "b"},
+		}}},
+		{"tag:x -tag:y", bson.M{"$and": bson.A{
+			bson.M{"tags": "x"},
+			bson.M{"$nor": bson.A{bson.M{"tags": "y"}}},
+		}}},
+		{"created:2026", bson.M{"created_at": bson.M{"$gte": y2026, "$lt": y2027}}},
+		{"updated:<2026", bson.M{"updated_at": bson.M{"$lt": y2026}}},
+		{"created:>=2026", bson.M{"created_at": bson.M{"$gte": y2026}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			if got := queryFilter(mustParse(t, tt.query)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filter = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankedTerms(t *testing.T) {
+	tests := []struct {
+		query    string
+		wantText string // the $text search
+		wantRest string
+	}{
+		{"rust", `"rust"`, "<nil>"},
+		{`rust "exact phrase" tag:x`, `"rust" "exact phrase"`, "tag:x"},
+		{"a b -c tag:x", `"a" "b"`, "and(-c tag:x)"},
+		{"a OR b", "a b", "<nil>"},
+		{`a OR "b c"`, "", `or(a "b c")`},
+		{"tag:x", "", "tag:x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			terms, any, rest := mustParse(t, tt.query).rankedTerms()
+			if got := textSearch(terms, any); got != tt.wantText {
+				t.Errorf("text search = %q, want %q", got, tt.wantText)
+			}
+			if got := render(rest); got != tt.wantRest {
+				t.Errorf("rest = %s, want %s", got, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestFTSTerm(t *testing.T) {
+	tests := []struct {
+		node *queryNode
+		want string
+	}{
+		{&queryNode{Kind: queryText, Value: "rust"}, `"rust"*`},
+		{&queryNode{Kind: queryText, Value: "NEAR(a"}, `"NEAR(a"*`},
+		{&queryNode{Kind: queryText, Value: "exact phrase", Phrase: true}, `"exact phrase"`},
+		{&queryNode{Kind: queryText, Value: `say "hi"`, Phrase: true}, `"say ""hi"""`},
+	}
+	for _, tt := range tests {
+		if got := ftsTerm(tt.node); got != tt.want {
+			t.Errorf("ftsTerm(%s) = %s, want %s", render(tt.node), got, tt.want)
+		}
+	}
+}
+
+func TestSQLiteQuery(t *testing.T) {
+	const match = "n.seq IN (SELECT rowid FROM notes_fts WHERE notes_fts MATCH ?)"
+	y2026 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	y2027 := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+
+	tests := []struct {
+		query    string
+		wantCond string
+		wantArgs []any
+	}{
+		{"rust", match, []any{`"rust"*`}},
+		{`"exact phrase"`, match, []any{`"exact phrase"`}},
+		{"rust -tag:x", "(" + match + " AND NOT EXISTS (SELECT 1 FROM json_each(n.tags) WHERE value = ?))", []any{`"rust"*`, "x"}},
+		{"category:a OR category:b", "(n.category = ? OR n.category = ?)", []any{"a", "b"}},
+		{"created:2026", "(1 AND n.created_at >= ? AND n.created_at < ?)", []any{y2026, y2027}},
+		{"updated:<2026", "(1 AND n.updated_at < ?)", []any{y2026}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			cond, args := sqliteQuery(mustParse(t, tt.query))
+			if cond != tt.wantCond {
+				t.Errorf("condition = %s\nwant %s", cond, tt.wantCond)
+			}
+			if !reflect.DeepEqual(args, tt.wantArgs) {
+				t.Errorf("args = %v, want %v", args, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestMatcherFoldsAccents(t *testing.T) {
+	tests := []struct {
+		query   string
+		content string
+		want    bool
+	}{
+		{"cafe", "Meeting at the café", true},
+		{"café", "Meeting at the cafe", true},
+		{"Café", "CAFÉ opens at nine", true},
+		{`"creme brulee"`, "Dessert: crème brûlée", true},
+		{"naive", "a naïve approach", true},
+		{"cafe", "caffeine", false},
+		{"-cafe", "Meeting at the café", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query+"/"+tt.content, func(t *testing.T) {
+			expr, err := parseSearchQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := expr.matcher()(&Note{Content: tt.content}); got != tt.want {
+				t.Errorf("match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
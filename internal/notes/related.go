@@ -0,0 +1,115 @@
+package notes
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	// maxRelatedTerms is how many of a note's terms are looked for in others
+	maxRelatedTerms = 12
+	// relatedCandidates is how many notes matching any of the terms are
+	// considered
+	relatedCandidates = 50
+)
+
+// Related finds notes about the same things as a note, in any category the
+// caller can see, the way MoreLikeThis does: the note's most used
+// distinctive terms are searched for with the text index, whose ranking
+// scores the matches. Related notes share at least two of the terms, or the
+// only one, best matches first.
+func (s *Service) Related(ctx context.Context, id string, q RelatedQuery) ([]*RelatedNote, error) {
+	note, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	category := normalizeCategory(q.Category)
+	categories, ok := scopeQuery(ctx, category)
+	terms := distinctiveTerms(note.Content, maxRelatedTerms)
+	if !ok || len(terms) == 0 {
+		return []*RelatedNote{}, nil
+	}
+
+	expr := anyOf(terms)
+	result, err := s.repo.Search(ctx, SearchQuery{
+		Category:   category,
+		Categories: categories,
+		Limit:      relatedCandidates,
+		expr:       expr,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	patterns := make([]*regexp.Regexp, len(terms))
+	for i, t := range terms {
+		// Folded like the search matcher, so "café" and "cafe" are one term
+		patterns[i] = regexp.MustCompile((&queryNode{Kind: queryText, Value: t}).foldedPattern())
+	}
+	minShared := min(2, len(terms))
+	limit := clampLimit(q.Limit, 5, 50)
+	h := newHighlighter(expr)
+
+	related := make([]*RelatedNote, 0, limit)
+	for _, hit := range result.Hits {
+		if hit.ID == note.ID {
+			continue
+		}
+		var shared []string
+		content := foldAccents(hit.Content)
+		for i, re := range patterns {
+			if re.MatchString(content) {
+				shared = append(shared, terms[i])
+			}
+		}
+		if len(shared) < minShared {
+			continue
+		}
+
+		rel := &RelatedNote{Note: hit.Note, Score: hit.Score, Terms: shared, Snippets: h.snippets(hit.Content)}
+		if q.OmitContent {
+			rel.Content = ""
+		}
+		related = append(related, rel)
+		if len(related) == limit {
+			break
+		}
+	}
+	return related, nil
+}
+
+// distinctiveTerms returns up to n of the words content uses most, leaving
+// out function words, words under three letters and numbers. Ties go to
+// longer words, which tend to say more.
+func distinctiveTerms(content string, n int) []string {
+	counts := make(map[string]int)
+	for _, tok := range contentTokens(content) {
+		if len(tok) < 3 || stopWords[tok] || strings.IndexFunc(tok, isNotDigit) < 0 {
+			continue
+		}
+		counts[tok]++
+	}
+
+	terms := make([]string, 0, len(counts))
+	for t := range counts {
+		terms = append(terms, t)
+	}
+	sort.Slice(terms, func(i, j int) bool {
+		a, b := terms[i], terms[j]
+		if counts[a] != counts[b] {
+			return counts[a] > counts[b]
+		}
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return a < b
+	})
+	return terms[:min(n, len(terms))]
+}
+
+func isNotDigit(r rune) bool {
+	return !unicode.IsDigit(r)
+}
@@ -0,0 +1,138 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestRelatedFoldsAccents(t *testing.T) {
+	for name, svc := range newStoreServices(t) {
+		t.Run(name, func(t *testing.T) {
+			note := mustCreate(t, svc, CreateNoteInput{Category: "coffee", Content: "Café crema tasting notes from the café"})
+			plain := mustCreate(t, svc, CreateNoteInput{Category: "coffee", Content: "The cafe served crema twice"})
+
+			related, err := svc.Related(context.Background(), note.ID.Hex(), RelatedQuery{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(related) != 1 || related[0].ID != plain.ID {
+				t.Fatalf("related = %v, want the note spelling it cafe", related)
+			}
+			if !slices.Contains(related[0].Terms, "café") || !slices.Contains(related[0].Terms, "crema") {
+				t.Errorf("shared terms = %q, want café and crema", related[0].Terms)
+			}
+		})
+	}
+}
+
+func TestDistinctiveTerms(t *testing.T) {
+	tests := []struct {
+		content string
+		n       int
+		want    []string
+	}{
+		{"Kubernetes pods and kubernetes autoscaling for the pods of kubernetes", 3, []string{"kubernetes", "pods", "autoscaling"}},
+		{"It is on me to do it by 2024", 5, []string{}},
+		{"go vs rust, big vs small", 5, []string{"small", "rust", "big"}},
+		{"alpha beta gamma delta", 2, []string{"alpha", "delta"}},
+	}
+	for _, tt := range tests {
+		if got := distinctiveTerms(tt.content, tt.n); !slices.Equal(got, tt.want) {
+			t.Errorf("distinctiveTerms(%q, %d) = %q, want %q", tt.content, tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestRelated(t *testing.T) {
+	for name, svc := range newStoreServices(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			note := mustCreate(t, svc, CreateNoteInput{Category: "ops", Content: "Kubernetes autoscaling: tune kubernetes autoscaling with pod metrics"})
+			near := mustCreate(t, svc, CreateNoteInput{Category: "ops", Content: "Kubernetes autoscaling broke under load, check pod metrics"})
+			other := mustCreate(t, svc, CreateNoteInput{Category: "blog", Content: "Post idea: autoscaling on kubernetes"})
+			mustCreate(t, svc, CreateNoteInput{Category: "ops", Content: "Kubernetes meetup on Thursday"})
+			mustCreate(t, svc, CreateNoteInput{Category: "kitchen", Content: "Sourdough starter feeding"})
+			trashed := mustCreate(t, svc, CreateNoteInput{Category: "ops", Content: "Old kubernetes autoscaling runbook"})
+			if err := svc.Delete(ctx, trashed.ID.Hex()); err != nil {
+				t.Fatal(err)
+			}
+
+			related, err := svc.Related(ctx, note.ID.Hex(), RelatedQuery{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []string
+			for _, r := range related {
+				ids = append(ids, r.ID.Hex())
+			}
+			if want := []string{near.ID.Hex(), other.ID.Hex()}; !slices.Equal(ids, want) {
+				t.Fatalf("related = %v, want the notes sharing two or more terms, best first: %v", ids, want)
+			}
+			if terms := related[0].Terms; len(terms) < 4 || !slices.Contains(terms, "metrics") {
+				t.Errorf("shared terms = %q, want kubernetes, autoscaling, pod and metrics", terms)
+			}
+			if len(related[0].Snippets) == 0 || len(related[0].Snippets[0].Highlights) == 0 {
+				t.Errorf("snippets = %v, want the shared terms highlighted", related[0].Snippets)
+			}
+
+			queries := []struct {
+				name string
+				ctx  context.Context
+				q    RelatedQuery
+				want []string
+			}{
+				{"category", ctx, RelatedQuery{Category: "Blog"}, []string{other.ID.Hex()}},
+				{"limit", ctx, RelatedQuery{Limit: 1}, []string{near.ID.Hex()}},
+				{"restricted", WithCategoryAccess(ctx, []string{"ops"}), RelatedQuery{}, []string{near.ID.Hex()}},
+				{"unreadable category", WithCategoryAccess(ctx, []string{"ops"}), RelatedQuery{Category: "blog"}, nil},
+			}
+			for _, tt := range queries {
+				related, err := svc.Related(tt.ctx, note.ID.Hex(), tt.q)
+				if err != nil {
+					t.Fatalf("%s: %v", tt.name, err)
+				}
+				var ids []string
+				for _, r := range related {
+					ids = append(ids, r.ID.Hex())
+				}
+				if !slices.Equal(ids, tt.want) {
+					t.Errorf("%s: related = %v, want %v", tt.name, ids, tt.want)
+				}
+			}
+
+			if related, err := svc.Related(ctx, note.ID.Hex(), RelatedQuery{OmitContent: true}); err != nil || related[0].Content != "" {
+				t.Errorf("with content omitted, got %v, %v", related, err)
+			}
+			if _, err := svc.Related(ctx, "6650a1b2c3d4e5f601234567", RelatedQuery{}); !errors.Is(err, ErrNoteNotFound) {
+				t.Errorf("missing note: err = %v, want ErrNoteNotFound", err)
+			}
+			if _, err := svc.Related(WithCategoryAccess(ctx, []string{"blog"}), note.ID.Hex(), RelatedQuery{}); !errors.Is(err, ErrNoteNotFound) {
+				t.Errorf("unreadable note: err = %v, want ErrNoteNotFound", err)
+			}
+		})
+	}
+}
+
+func TestRelatedSingleTerm(t *testing.T) {
+	for name, svc := range newStoreServices(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			note := mustCreate(t, svc, CreateNoteInput{Category: "ops", Content: "Kubernetes!"})
+			match := mustCreate(t, svc, CreateNoteInput{Category: "ops", Content: "Notes on kubernetes upgrades"})
+			stop := mustCreate(t, svc, CreateNoteInput{Category: "ops", Content: "It is what it is"})
+
+			related, err := svc.Related(ctx, note.ID.Hex(), RelatedQuery{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(related) != 1 || related[0].ID != match.ID {
+				t.Errorf("related = %v, want the note sharing the only term", related)
+			}
+			if related, err := svc.Related(ctx, stop.ID.Hex(), RelatedQuery{}); err != nil || len(related) != 0 {
+				t.Errorf("related to a note of function words = %v, %v; want none", related, err)
+			}
+		})
+	}
+}
@@ -4,8 +4,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
+	"scratchpad/internal/workspace"
+
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -13,33 +16,77 @@ import (
 )
 
 var (
-	ErrNoteNotFound = errors.New("note not found")
+	ErrNoteNotFound     = errors.New("note not found")
+	ErrRevisionNotFound = errors.New("revision not found")
+	ErrVersionConflict  = errors.New("note was modified concurrently")
+	ErrInvalidInput     = errors.New("invalid input")
+	ErrForbidden        = errors.New("forbidden")
+	ErrDuplicateNote    = errors.New("duplicate note")
+	ErrNoteExists       = errors.New("a note with this ID already exists")
+
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+	ErrIdempotencyInFlight  = errors.New("a request with this idempotency key is still in progress")
 )
 
 type Repo struct {
-	coll *mongo.Collection
+	coll        *mongo.Collection
+	revisions   *mongo.Collection
+	idempotency *mongo.Collection
 }
 
 func NewRepo(db *mongo.Database) *Repo {
-	return &Repo{coll: db.Collection("notes")}
+	return &Repo{
+		coll:        db.Collection("notes"),
+		revisions:   db.Collection("note_revisions"),
+		idempotency: db.Collection("idempotency_keys"),
+	}
 }
 
 // EnsureIndexes creates necessary indexes for the notes collection
 func (r *Repo) EnsureIndexes(ctx context.Context) error {
+	if err := r.migrateWorkspaces(ctx); err != nil {
+		return err
+	}
+	if err := r.backfillFingerprints(ctx); err != nil {
+		return err
+	}
+
+	// Every query is scoped to a workspace, so indexes lead with it
 	indexes := []mongo.IndexModel{
 		{
-			Keys: bson.D{{Key: "content", Value: "text"}},
+			Keys: bson.D{
+				{Key: "workspace", Value: 1},
+				{Key: "content", Value: "text"},
+			},
 		},
 		{
-			Keys: bson.D{{Key: "category", Value: 1}},
+			Keys: bson.D{
+				{Key: "workspace", Value: 1},
+				{Key: "created_at", Value: -1},
+			},
 		},
 		{
-			Keys: bson.D{{Key: "created_at", Value: -1}},
+			Keys: bson.D{
+				{Key: "workspace", Value: 1},
+				{Key: "category", Value: 1},
+				{Key: "created_at", Value: -1},
+			},
+		},
+		{
+			Keys:    bson.D{{Key: "deleted_at", Value: 1}},
+			Options: options.Index().SetSparse(true),
+		},
+		{
+			Keys: bson.D{
+				{Key: "workspace", Value: 1},
+				{Key: "tags", Value: 1}, // multikey
+			},
 		},
 		{
 			Keys: bson.D{
+				{Key: "workspace", Value: 1},
 				{Key: "category", Value: 1},
-				{Key: "created_at", Value: -1},
+				{Key: "content_hash", Value: 1},
 			},
 		},
 	}
@@ -48,14 +95,102 @@ func (r *Repo) EnsureIndexes(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("create indexes: %w", err)
 	}
+
+	_, err = r.revisions.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{
+			{Key: "note_id", Value: 1},
+			{Key: "version", Value: -1},
+		},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("create revision indexes: %w", err)
+	}
+
+	// MongoDB's TTL monitor removes records once expires_at passes
+	_, err = r.idempotency.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "workspace", Value: 1},
+				{Key: "key", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("create idempotency key indexes: %w", err)
+	}
 	return nil
 }
 
-// Insert creates a new note
+// legacyIndexes were created before workspaces; the text index in
+// particular has to go, as a collection can only have one
+var legacyIndexes = []string{"content_text", "category_1", "created_at_-1", "category_1_created_at_-1", "tags_1"}
+
+// migrateWorkspaces moves notes written before workspaces existed into the
+// default workspace and drops the indexes that didn't lead with it
+func (r *Repo) migrateWorkspaces(ctx context.Context) error {
+	_, err := r.coll.UpdateMany(ctx,
+		bson.M{"workspace": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"workspace": workspace.Default}},
+	)
+	if err != nil {
+		return fmt.Errorf("assign default workspace: %w", err)
+	}
+
+	for _, name := range legacyIndexes {
+		_, err := r.coll.Indexes().DropOne(ctx, name)
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && (cmdErr.Code == 26 || cmdErr.Code == 27) {
+			continue // NamespaceNotFound, IndexNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("drop index %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// backfillFingerprints hashes the content of notes written before content
+// hashes existed, in every workspace and including the trash
+func (r *Repo) backfillFingerprints(ctx context.Context) error {
+	cursor, err := r.coll.Find(ctx,
+		bson.M{"content_hash": bson.M{"$exists": false}},
+		options.Find().SetProjection(bson.M{"content": 1}),
+	)
+	if err != nil {
+		return fmt.Errorf("find notes to fingerprint: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var n Note
+		if err := cursor.Decode(&n); err != nil {
+			return fmt.Errorf("decode note to fingerprint: %w", err)
+		}
+		n.fingerprint()
+		_, err := r.coll.UpdateByID(ctx, n.ID, bson.M{"$set": bson.M{
+			"content_hash": n.ContentHash,
+			"simhash":      n.SimHash,
+		}})
+		if err != nil {
+			return fmt.Errorf("fingerprint note %s: %w", n.ID.Hex(), err)
+		}
+	}
+	return cursor.Err()
+}
+
+// Insert creates a new note in the context's workspace
 func (r *Repo) Insert(ctx context.Context, n *Note) error {
 	n.ID = primitive.NewObjectID()
+	n.Workspace = workspace.FromContext(ctx)
 	n.CreatedAt = time.Now()
 	n.UpdatedAt = n.CreatedAt
+	n.Version = 1
 
 	_, err := r.coll.InsertOne(ctx, n)
 	if err != nil {
@@ -64,10 +199,41 @@ func (r *Repo) Insert(ctx context.Context, n *Note) error {
 	return nil
 }
 
+// InsertMany creates notes with a single ordered insert
+func (r *Repo) InsertMany(ctx context.Context, notes []*Note) (int, error) {
+	if len(notes) == 0 {
+		return 0, nil
+	}
+	docs := make([]any, len(notes))
+	now := time.Now()
+	for i, n := range notes {
+		n.ID = primitive.NewObjectID()
+		n.Workspace = workspace.FromContext(ctx)
+		n.CreatedAt = now
+		n.UpdatedAt = now
+		n.Version = 1
+		docs[i] = n
+	}
+
+	_, err := r.coll.InsertMany(ctx, docs, options.InsertMany().SetOrdered(true))
+	if err != nil {
+		// An ordered insert stops at the first failed document
+		inserted := 0
+		var bwe mongo.BulkWriteException
+		if errors.As(err, &bwe) && len(bwe.WriteErrors) > 0 {
+			inserted = bwe.WriteErrors[0].Index
+		}
+		return inserted, fmt.Errorf("insert notes: %w", err)
+	}
+	return len(notes), nil
+}
+
 // FindByID retrieves a note by its ID
 func (r *Repo) FindByID(ctx context.Context, id primitive.ObjectID) (*Note, error) {
 	var note Note
-	err := r.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&note)
+	filter := liveFilter(ctx)
+	filter["_id"] = id
+	err := r.coll.FindOne(ctx, filter).Decode(&note)
 	if errors.Is(err, mongo.ErrNoDocuments) {
 		return nil, ErrNoteNotFound
 	}
@@ -77,12 +243,55 @@ func (r *Repo) FindByID(ctx context.Context, id primitive.ObjectID) (*Note, erro
 	return &note, nil
 }
 
+// Update writes n's category, content and tags if the stored version still equals
+// expectedVersion, bumping version and updated_at
+func (r *Repo) Update(ctx context.Context, n *Note, expectedVersion int64) error {
+	filter := liveFilter(ctx)
+	filter["_id"] = n.ID
+	filter["version"] = expectedVersion
+	if expectedVersion == 0 {
+		// Notes written before versioning have no version field
+		filter["version"] = bson.M{"$in": bson.A{0, nil}}
+	}
+
+	now := time.Now()
+	update := bson.M{"$set": bson.M{
+		"category":     n.Category,
+		"content":      n.Content,
+		"tags":         n.Tags,
+		"author":       n.Author,
+		"content_hash": n.ContentHash,
+		"simhash":      n.SimHash,
+		"updated_at":   now,
+		"version":      expectedVersion + 1,
+	}}
+
+	result, err := r.coll.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return fmt.Errorf("update note: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		delete(filter, "version")
+		exists, err := r.coll.CountDocuments(ctx, filter)
+		if err != nil {
+			return fmt.Errorf("update note: %w", err)
+		}
+		if exists == 0 {
+			return ErrNoteNotFound
+		}
+		return ErrVersionConflict
+	}
+
+	n.UpdatedAt = now
+	n.Version = expectedVersion + 1
+	return nil
+}
+
 // List retrieves notes with optional category filter, sorted by created_at desc
 func (r *Repo) List(ctx context.Context, q ListQuery) ([]*Note, error) {
-	filter := bson.M{}
-	if q.Category != "" {
-		filter["category"] = q.Category
-	}
+	filter := liveFilter(ctx)
+	addCategoryFilter(filter, q.Category, q.Categories)
+	addTagFilter(filter, q.Tags, q.TagMode)
 
 	if q.Limit <= 0 {
 		q.Limit = 50
@@ -109,19 +318,31 @@ func (r *Repo) List(ctx context.Context, q ListQuery) ([]*Note, error) {
 	return notes, nil
 }
 
-// Search performs full-text search with optional filters
-func (r *Repo) Search(ctx context.Context, q SearchQuery) ([]*Note, error) {
-	filter := bson.M{}
-
-	// Full-text search
-	if q.Query != "" {
-		filter["$text"] = bson.M{"$search": q.Query}
+// Search performs a search query with optional filters. The query's ranked
+// terms (see rankedTerms) go to $text, which ranks the results; the rest
+// of the query becomes plain conditions, as $text can be neither negated
+// nor OR-ed.
+func (r *Repo) Search(ctx context.Context, q SearchQuery) (*SearchResult, error) {
+	filter := liveFilter(ctx)
+
+	// Search query
+	ranked := false
+	if q.expr != nil {
+		terms, any, rest := q.expr.rankedTerms()
+		if len(terms) > 0 {
+			filter["$text"] = bson.M{"$search": textSearch(terms, any)}
+			ranked = true
+		}
+		if rest != nil {
+			filter["$and"] = bson.A{queryFilter(rest)}
+		}
 	}
 
 	// Category filter
-	if q.Category != "" {
-		filter["category"] = q.Category
-	}
+	addCategoryFilter(filter, q.Category, q.Categories)
+
+	// Tag filter
+	addTagFilter(filter, q.Tags, q.TagMode)
 
 	// Date range filter
 	if q.Since != nil || q.Until != nil {
@@ -135,43 +356,72 @@ func (r *Repo) Search(ctx context.Context, q SearchQuery) ([]*Note, error) {
 		filter["created_at"] = dateFilter
 	}
 
-	if q.Limit <= 0 {
-		q.Limit = 50
-	}
-	if q.Limit > 200 {
-		q.Limit = 200
-	}
-
-	opts := options.Find().
-		SetLimit(int64(q.Limit)).
-		SetSkip(int64(q.Offset)).
-		SetSort(bson.D{{Key: "created_at", Value: -1}})
+	limit := clampLimit(q.Limit, 50, 200)
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: filter}}}
+	sort := bson.D{{Key: "created_at", Value: -1}}
 
 	// Add text score for relevance sorting when doing text search
-	if q.Query != "" {
-		opts.SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}})
-		opts.SetSort(bson.D{
-			{Key: "score", Value: bson.M{"$meta": "textScore"}},
-			{Key: "created_at", Value: -1},
-		})
+	if ranked {
+		pipeline = append(pipeline, bson.D{{Key: "$addFields", Value: bson.M{"score": bson.M{"$meta": "textScore"}}}})
+		sort = bson.D{{Key: "score", Value: -1}, {Key: "created_at", Value: -1}}
 	}
 
-	cursor, err := r.coll.Find(ctx, filter, opts)
+	// One pass yields the page, the total and the facets
+	facets := bson.M{
+		"hits":  bson.A{bson.M{"$sort": sort}, bson.M{"$skip": q.Offset}, bson.M{"$limit": limit}},
+		"total": bson.A{bson.M{"$count": "n"}},
+	}
+	if q.Facets {
+		facets["categories"] = bson.A{bson.M{"$sortByCount": "$category"}, bson.M{"$limit": MaxFacetValues}}
+		facets["tags"] = bson.A{bson.M{"$unwind": "$tags"}, bson.M{"$sortByCount": "$tags"}, bson.M{"$limit": MaxFacetValues}}
+		facets["months"] = bson.A{
+			bson.M{"$group": bson.M{
+				"_id":   bson.M{"$dateToString": bson.M{"format": "%Y-%m", "date": "$created_at"}},
+				"count": bson.M{"$sum": 1},
+			}},
+			bson.M{"$sort": bson.M{"_id": -1}},
+			bson.M{"$limit": MaxFacetValues},
+		}
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$facet", Value: facets}})
+
+	cursor, err := r.coll.Aggregate(ctx, pipeline)
 	if err != nil {
 		return nil, fmt.Errorf("search notes: %w", err)
 	}
 	defer cursor.Close(ctx)
 
-	var notes []*Note
-	if err := cursor.All(ctx, &notes); err != nil {
+	var docs []struct {
+		Hits []struct {
+			Note  `bson:",inline"`
+			Score float64 `bson:"score"`
+		} `bson:"hits"`
+		Total []struct {
+			N int64 `bson:"n"`
+		} `bson:"total"`
+		SearchFacets `bson:",inline"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
 		return nil, fmt.Errorf("decode search results: %w", err)
 	}
-	return notes, nil
+
+	doc := docs[0]
+	result := &SearchResult{Hits: make([]*SearchHit, len(doc.Hits))}
+	for i := range doc.Hits {
+		result.Hits[i] = &SearchHit{Note: &doc.Hits[i].Note, Score: doc.Hits[i].Score}
+	}
+	if len(doc.Total) > 0 {
+		result.Total = doc.Total[0].N
+	}
+	if q.Facets {
+		result.Facets = &doc.SearchFacets
+	}
+	return result, nil
 }
 
 // GetRecent retrieves most recent notes across all categories
 func (r *Repo) GetRecent(ctx context.Context, limit int, since *time.Time) ([]*Note, error) {
-	filter := bson.M{}
+	filter := liveFilter(ctx)
 	if since != nil {
 		filter["created_at"] = bson.M{"$gte": *since}
 	}
@@ -203,6 +453,9 @@ func (r *Repo) GetRecent(ctx context.Context, limit int, since *time.Time) ([]*N
 // ListCategories returns all categories with counts and last note time
 func (r *Repo) ListCategories(ctx context.Context) ([]*Category, error) {
 	pipeline := []bson.M{
+		{
+			"$match": liveFilter(ctx),
+		},
 		{
 			"$group": bson.M{
 				"_id":       "$category",
@@ -228,21 +481,141 @@ func (r *Repo) ListCategories(ctx context.Context) ([]*Category, error) {
 	return categories, nil
 }
 
-// Delete removes a note by ID
+// ListTags returns all tags on live notes with usage counts, most used first
+func (r *Repo) ListTags(ctx context.Context, categories []string) ([]*Tag, error) {
+	match := liveFilter(ctx)
+	addCategoryFilter(match, "", categories)
+
+	pipeline := []bson.M{
+		{"$match": match},
+		{"$unwind": "$tags"},
+		{"$group": bson.M{"_id": "$tags", "count": bson.M{"$sum": 1}}},
+		{"$sort": bson.D{{Key: "count", Value: -1}, {Key: "_id", Value: 1}}},
+	}
+
+	cursor, err := r.coll.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("aggregate tags: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var tags []*Tag
+	if err := cursor.All(ctx, &tags); err != nil {
+		return nil, fmt.Errorf("decode tags: %w", err)
+	}
+	return tags, nil
+}
+
+// Delete moves a note to the trash by setting deleted_at
 func (r *Repo) Delete(ctx context.Context, id primitive.ObjectID) error {
-	result, err := r.coll.DeleteOne(ctx, bson.M{"_id": id})
+	filter := liveFilter(ctx)
+	filter["_id"] = id
+	result, err := r.coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"deleted_at": time.Now()}})
 	if err != nil {
 		return fmt.Errorf("delete note: %w", err)
 	}
-	if result.DeletedCount == 0 {
+	if result.MatchedCount == 0 {
 		return ErrNoteNotFound
 	}
 	return nil
 }
 
+// Restore takes a note back out of the trash
+func (r *Repo) Restore(ctx context.Context, id primitive.ObjectID) error {
+	filter := trashFilter(ctx)
+	filter["_id"] = id
+	result, err := r.coll.UpdateOne(ctx, filter, bson.M{"$unset": bson.M{"deleted_at": ""}})
+	if err != nil {
+		return fmt.Errorf("restore note: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNoteNotFound
+	}
+	return nil
+}
+
+// FindTrashed retrieves a note from the trash by its ID
+func (r *Repo) FindTrashed(ctx context.Context, id primitive.ObjectID) (*Note, error) {
+	var note Note
+	filter := trashFilter(ctx)
+	filter["_id"] = id
+	err := r.coll.FindOne(ctx, filter).Decode(&note)
+	if err == mongo.ErrNoDocuments {
+		return nil, ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find trashed note: %w", err)
+	}
+	return &note, nil
+}
+
+// ListTrash retrieves trashed notes, most recently deleted first
+func (r *Repo) ListTrash(ctx context.Context, q ListQuery) ([]*Note, error) {
+	filter := trashFilter(ctx)
+	addCategoryFilter(filter, q.Category, q.Categories)
+
+	if q.Limit <= 0 {
+		q.Limit = 50
+	}
+	if q.Limit > 200 {
+		q.Limit = 200
+	}
+
+	opts := options.Find().
+		SetLimit(int64(q.Limit)).
+		SetSkip(int64(q.Offset)).
+		SetSort(bson.D{{Key: "deleted_at", Value: -1}})
+
+	cursor, err := r.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list trash: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var notes []*Note
+	if err := cursor.All(ctx, &notes); err != nil {
+		return nil, fmt.Errorf("decode trash: %w", err)
+	}
+	return notes, nil
+}
+
+// Purge permanently removes notes trashed before the cutoff, along with
+// their revisions, in every workspace
+func (r *Repo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	filter := bson.M{"deleted_at": bson.M{"$lt": before}}
+
+	cursor, err := r.coll.Find(ctx, filter, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, fmt.Errorf("find purgeable notes: %w", err)
+	}
+	var docs []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(ctx, &docs); err != nil {
+		return 0, fmt.Errorf("decode purgeable notes: %w", err)
+	}
+	if len(docs) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]primitive.ObjectID, len(docs))
+	for i, d := range docs {
+		ids[i] = d.ID
+	}
+
+	result, err := r.coll.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, fmt.Errorf("purge notes: %w", err)
+	}
+	if _, err := r.revisions.DeleteMany(ctx, bson.M{"note_id": bson.M{"$in": ids}}); err != nil {
+		return 0, fmt.Errorf("purge note revisions: %w", err)
+	}
+	return result.DeletedCount, nil
+}
+
 // Count returns the total number of notes, optionally filtered by category
 func (r *Repo) Count(ctx context.Context, category string) (int64, error) {
-	filter := bson.M{}
+	filter := liveFilter(ctx)
 	if category != "" {
 		filter["category"] = category
 	}
@@ -252,3 +625,323 @@ func (r *Repo) Count(ctx context.Context, category string) (int64, error) {
 	}
 	return count, nil
 }
+
+// Export streams live notes matching q to fn, oldest first
+func (r *Repo) Export(ctx context.Context, q ExportQuery, fn func(*Note) error) error {
+	filter := liveFilter(ctx)
+	addCategoryFilter(filter, q.Category, q.Categories)
+	if q.Since != nil || q.Until != nil {
+		created := bson.M{}
+		if q.Since != nil {
+			created["$gte"] = *q.Since
+		}
+		if q.Until != nil {
+			created["$lt"] = *q.Until
+		}
+		filter["created_at"] = created
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+
+	cursor, err := r.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return fmt.Errorf("export notes: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var note Note
+		if err := cursor.Decode(&note); err != nil {
+			return fmt.Errorf("decode exported note: %w", err)
+		}
+		if err := fn(&note); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("export notes: %w", err)
+	}
+	return nil
+}
+
+// ImportNote inserts n as given, or replaces the note with its ID in the
+// context's workspace when overwrite is set
+func (r *Repo) ImportNote(ctx context.Context, n *Note, overwrite bool) (bool, error) {
+	n.Workspace = workspace.FromContext(ctx)
+	n.DeletedAt = nil
+
+	if overwrite {
+		res, err := r.coll.ReplaceOne(ctx, bson.M{"_id": n.ID, "workspace": n.Workspace}, n)
+		if err != nil {
+			return false, fmt.Errorf("import note %s: %w", n.ID.Hex(), err)
+		}
+		if res.MatchedCount == 1 {
+			return true, nil
+		}
+	}
+
+	_, err := r.coll.InsertOne(ctx, n)
+	if mongo.IsDuplicateKeyError(err) {
+		return false, ErrNoteExists
+	}
+	if err != nil {
+		return false, fmt.Errorf("import note %s: %w", n.ID.Hex(), err)
+	}
+	return false, nil
+}
+
+// FindByContentHash returns the newest live note in category whose content
+// hashes to hash
+func (r *Repo) FindByContentHash(ctx context.Context, category, hash string) (*Note, error) {
+	filter := liveFilter(ctx)
+	filter["category"] = category
+	filter["content_hash"] = hash
+	opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+
+	var note Note
+	err := r.coll.FindOne(ctx, filter, opts).Decode(&note)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find note by content hash: %w", err)
+	}
+	return &note, nil
+}
+
+// ListFingerprints returns the fingerprints of live notes, optionally
+// filtered by category
+func (r *Repo) ListFingerprints(ctx context.Context, category string, categories []string) ([]*Fingerprint, error) {
+	filter := liveFilter(ctx)
+	addCategoryFilter(filter, category, categories)
+	opts := options.Find().SetProjection(bson.M{
+		"category": 1, "content_hash": 1, "simhash": 1, "created_at": 1,
+	})
+
+	cursor, err := r.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list fingerprints: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var fps []*Fingerprint
+	if err := cursor.All(ctx, &fps); err != nil {
+		return nil, fmt.Errorf("decode fingerprints: %w", err)
+	}
+	return fps, nil
+}
+
+// ListEmbeddings returns the embedding state of live notes
+func (r *Repo) ListEmbeddings(ctx context.Context) ([]*NoteEmbedding, error) {
+	opts := options.Find().SetProjection(bson.M{
+		"category": 1, "content_hash": 1, "created_at": 1, "embedding": 1,
+	})
+
+	cursor, err := r.coll.Find(ctx, liveFilter(ctx), opts)
+	if err != nil {
+		return nil, fmt.Errorf("list embeddings: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var embeddings []*NoteEmbedding
+	if err := cursor.All(ctx, &embeddings); err != nil {
+		return nil, fmt.Errorf("decode embeddings: %w", err)
+	}
+	return embeddings, nil
+}
+
+// SaveEmbedding stores a note's embedding alongside it
+func (r *Repo) SaveEmbedding(ctx context.Context, id primitive.ObjectID, e *Embedding) error {
+	filter := liveFilter(ctx)
+	filter["_id"] = id
+	result, err := r.coll.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"embedding": e}})
+	if err != nil {
+		return fmt.Errorf("save embedding: %w", err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNoteNotFound
+	}
+	return nil
+}
+
+// addCategoryFilter matches category, or any of categories when category is
+// empty
+func addCategoryFilter(filter bson.M, category string, categories []string) {
+	if category != "" {
+		filter["category"] = category
+	} else if len(categories) > 0 {
+		filter["category"] = bson.M{"$in": categories}
+	}
+}
+
+// addTagFilter restricts filter to notes carrying any or all of tags
+func addTagFilter(filter bson.M, tags []string, mode TagMode) {
+	if len(tags) == 0 {
+		return
+	}
+	if mode == TagModeAll {
+		filter["tags"] = bson.M{"$all": tags}
+	} else {
+		filter["tags"] = bson.M{"$in": tags}
+	}
+}
+
+// textSearch builds a $text search string requiring every term, or any of
+// them. Required terms are quoted, since $text ORs bare words but ANDs
+// phrases.
+func textSearch(terms []*queryNode, any bool) string {
+	quoted := make([]string, len(terms))
+	for i, t := range terms {
+		quoted[i] = t.Value
+		if !any {
+			quoted[i] = `"` + t.Value + `"`
+		}
+	}
+	return strings.Join(quoted, " ")
+}
+
+// queryFilter compiles a parsed search query into a filter
+func queryFilter(n *queryNode) bson.M {
+	switch n.Kind {
+	case queryAnd, queryOr:
+		children := make(bson.A, len(n.Children))
+		for i, c := range n.Children {
+			children[i] = queryFilter(c)
+		}
+		if n.Kind == queryAnd {
+			return bson.M{"$and": children}
+		}
+		return bson.M{"$or": children}
+	case queryNot:
+		return bson.M{"$nor": bson.A{queryFilter(n.Children[0])}}
+	case queryText:
+		return bson.M{"content": primitive.Regex{Pattern: n.textPattern()}}
+	case queryCategory:
+		return bson.M{"category": n.Value}
+	case queryTag:
+		return bson.M{"tags": n.Value}
+	default:
+		bounds := bson.M{}
+		if n.From != nil {
+			bounds["$gte"] = *n.From
+		}
+		if n.To != nil {
+			bounds["$lt"] = *n.To
+		}
+		return bson.M{n.Field: bounds}
+	}
+}
+
+// liveFilter matches notes in the context's workspace that are not in the
+// trash. Notes written before soft delete existed have no deleted_at field,
+// which $eq null also matches.
+func liveFilter(ctx context.Context) bson.M {
+	return bson.M{"workspace": workspace.FromContext(ctx), "deleted_at": nil}
+}
+
+// trashFilter matches trashed notes in the context's workspace
+func trashFilter(ctx context.Context) bson.M {
+	return bson.M{"workspace": workspace.FromContext(ctx), "deleted_at": bson.M{"$ne": nil}}
+}
+
+// SaveRevision stores a snapshot of a note version, ignoring versions that
+// are already saved
+func (r *Repo) SaveRevision(ctx context.Context, rev *Revision) error {
+	filter := bson.M{"note_id": rev.NoteID, "version": rev.Version}
+	update := bson.M{"$setOnInsert": rev}
+
+	_, err := r.revisions.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("save revision: %w", err)
+	}
+	return nil
+}
+
+// ListRevisions returns a note's stored revisions, newest first
+func (r *Repo) ListRevisions(ctx context.Context, noteID primitive.ObjectID) ([]*Revision, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}})
+
+	cursor, err := r.revisions.Find(ctx, bson.M{"note_id": noteID}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list revisions: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var revisions []*Revision
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, fmt.Errorf("decode revisions: %w", err)
+	}
+	return revisions, nil
+}
+
+// FindRevision retrieves a single revision of a note
+func (r *Repo) FindRevision(ctx context.Context, noteID primitive.ObjectID, version int64) (*Revision, error) {
+	var rev Revision
+	err := r.revisions.FindOne(ctx, bson.M{"note_id": noteID, "version": version}).Decode(&rev)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find revision %s@%d: %w", noteID.Hex(), version, err)
+	}
+	return &rev, nil
+}
+
+// ClaimIdempotencyKey stores rec unless the workspace already holds a live
+// record for its key, which is returned instead
+func (r *Repo) ClaimIdempotencyKey(ctx context.Context, rec *IdempotencyRecord) (*IdempotencyRecord, error) {
+	rec.Workspace = workspace.FromContext(ctx)
+	rec.CreatedAt = time.Now()
+	filter := bson.M{"workspace": rec.Workspace, "key": rec.Key}
+
+	// The TTL monitor only runs once a minute; a lapsed record must not
+	// block the key in the meantime
+	_, err := r.idempotency.DeleteOne(ctx, bson.M{
+		"workspace":  rec.Workspace,
+		"key":        rec.Key,
+		"expires_at": bson.M{"$lte": rec.CreatedAt},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("expire idempotency key: %w", err)
+	}
+
+	_, err = r.idempotency.InsertOne(ctx, rec)
+	if err == nil {
+		return nil, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return nil, fmt.Errorf("claim idempotency key: %w", err)
+	}
+
+	var existing IdempotencyRecord
+	err = r.idempotency.FindOne(ctx, filter).Decode(&existing)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		// Released by a failed request between our insert and this read
+		return nil, ErrIdempotencyInFlight
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find idempotency key: %w", err)
+	}
+	return &existing, nil
+}
+
+// CompleteIdempotencyKey records the response for a claimed key
+func (r *Repo) CompleteIdempotencyKey(ctx context.Context, key string, status int, response []byte) error {
+	_, err := r.idempotency.UpdateOne(ctx,
+		bson.M{"workspace": workspace.FromContext(ctx), "key": key},
+		bson.M{"$set": bson.M{"status": status, "response": response}},
+	)
+	if err != nil {
+		return fmt.Errorf("complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey drops a claimed key that has no response yet
+func (r *Repo) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	_, err := r.idempotency.DeleteOne(ctx,
+		bson.M{"workspace": workspace.FromContext(ctx), "key": key, "status": 0})
+	if err != nil {
+		return fmt.Errorf("release idempotency key: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"scratchpad/internal/workspace"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// DefaultTextWeight is how much keyword relevance counts in semantic
+	// search unless the query says otherwise
+	DefaultTextWeight = 0.3
+	// vectorRefresh is how long the index trusts a workspace's vectors before
+	// reloading them, to pick up changes made through other instances
+	vectorRefresh = time.Minute
+	// embedBatchSize is how many notes go to the Embedder at a time
+	embedBatchSize = 32
+	// keywordCandidates is how many keyword matches hybrid ranking considers
+	keywordCandidates = 200
+)
+
+// vectorIndex keeps the embeddings of each workspace's notes in memory and
+// searches them by brute force, which stays quick into the tens of
+// thousands of notes. A workspace is loaded from the store on its first
+// search and then kept current from the Service's events. Notes are
+// embedded, and their vectors saved, when a search finds them missing or
+// stale, so writes never wait on the Embedder.
+type vectorIndex struct {
+	mu         sync.Mutex
+	workspaces map[string]*vectorSet
+}
+
+// vectorSet is the index of one workspace
+type vectorSet struct {
+	mu       sync.Mutex // held while searching or refreshing
+	notes    map[primitive.ObjectID]*NoteEmbedding
+	loadedAt time.Time
+
+	changed map[primitive.ObjectID]Event // since the last search, guarded by vectorIndex.mu
+}
+
+func newVectorIndex() *vectorIndex {
+	return &vectorIndex{workspaces: make(map[string]*vectorSet)}
+}
+
+// set returns the index of a workspace, creating it empty
+func (x *vectorIndex) set(ws string) *vectorSet {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	set, ok := x.workspaces[ws]
+	if !ok {
+		set = &vectorSet{changed: make(map[primitive.ObjectID]Event)}
+		x.workspaces[ws] = set
+	}
+	return set
+}
+
+// noteChanged is a Listener recording changes for the next search to apply.
+// Workspaces not searched yet read everything from the store instead.
+func (x *vectorIndex) noteChanged(ctx context.Context, e Event) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if set, ok := x.workspaces[e.Note.Workspace]; ok {
+		set.changed[e.Note.ID] = e
+	}
+}
+
+// takeChanges returns and forgets the changes recorded for a set
+func (x *vectorIndex) takeChanges(set *vectorSet) map[primitive.ObjectID]Event {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	changed := set.changed
+	set.changed = make(map[primitive.ObjectID]Event)
+	return changed
+}
+
+// SetEmbedder sets what semantic search embeds notes and queries with; the
+// default is a HashEmbedder. Notes embedded by another model are embedded
+// again as searches come across them.
+func (s *Service) SetEmbedder(e Embedder) {
+	s.embedder = e
+}
+
+// SemanticSearch finds the notes closest in meaning to a query. With a
+// TextWeight above 0 the ranking is hybrid: the relevance the store's text
+// search gives the query's words is blended in, so notes that say it in
+// the same words as the query get a lift.
+func (s *Service) SemanticSearch(ctx context.Context, q SemanticQuery) ([]*SemanticHit, error) {
+	if strings.TrimSpace(q.Query) == "" {
+		return nil, fmt.Errorf("%w: query is required", ErrInvalidInput)
+	}
+	if q.TextWeight < 0 || q.TextWeight > 1 {
+		return nil, fmt.Errorf("%w: text weight must be between 0 and 1", ErrInvalidInput)
+	}
+	q.Category = normalizeCategory(q.Category)
+	categories, ok := scopeQuery(ctx, q.Category)
+	if !ok {
+		return []*SemanticHit{}, nil
+	}
+	q.Categories = categories
+
+	embedded, err := s.embedder.Embed(ctx, []string{q.Query})
+	if err == nil && len(embedded) != 1 {
+		err = fmt.Errorf("got %d vectors for one text", len(embedded))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("embed query: %w", err)
+	}
+	query := Vector(embedded[0])
+	normalize(query)
+
+	type candidate struct {
+		id        primitive.ObjectID
+		createdAt time.Time
+		note      *Note
+		sim, text float64
+	}
+	candidates := make(map[primitive.ObjectID]*candidate)
+	vectors, err := s.vectors(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, ne := range vectors {
+		if inScope(ne.Category, q.Category, q.Categories) {
+			candidates[ne.ID] = &candidate{id: ne.ID, createdAt: ne.CreatedAt, sim: dot(query, ne.Embedding.Vector)}
+		}
+	}
+
+	keywords := keywordQuery(q.Query)
+	if q.TextWeight > 0 && keywords != nil {
+		result, err := s.repo.Search(ctx, SearchQuery{
+			Category:   q.Category,
+			Categories: q.Categories,
+			Limit:      keywordCandidates,
+			expr:       keywords,
+		})
+		if err != nil {
+			return nil, err
+		}
+		var best float64
+		for _, hit := range result.Hits {
+			best = max(best, hit.Score)
+		}
+		for _, hit := range result.Hits {
+			c, ok := candidates[hit.ID]
+			if !ok {
+				c = &candidate{id: hit.ID, createdAt: hit.CreatedAt}
+				candidates[hit.ID] = c
+			}
+			c.note = hit.Note
+			if best > 0 {
+				c.text = hit.Score / best
+			}
+		}
+	}
+
+	score := func(c *candidate) float64 {
+		return (1-q.TextWeight)*c.sim + q.TextWeight*c.text
+	}
+	ranked := make([]*candidate, 0, len(candidates))
+	for _, c := range candidates {
+		if score(c) > 0 {
+			ranked = append(ranked, c)
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if si, sj := score(ranked[i]), score(ranked[j]); si != sj {
+			return si > sj
+		}
+		return ranked[i].createdAt.After(ranked[j].createdAt)
+	})
+
+	limit := clampLimit(q.Limit, 10, 50)
+	h := newHighlighter(keywords)
+	hits := make([]*SemanticHit, 0, min(limit, len(ranked)))
+	for _, c := range ranked {
+		if len(hits) == limit {
+			break
+		}
+		if c.note == nil {
+			if c.note, err = s.repo.FindByID(ctx, c.id); errors.Is(err, ErrNoteNotFound) {
+				continue // deleted meanwhile
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		hit := &SemanticHit{Note: c.note, Score: score(c), Similarity: c.sim, TextScore: c.text}
+		hit.Snippets = h.snippets(hit.Content)
+		if q.OmitContent {
+			hit.Content = ""
+		}
+		hits = append(hits, hit)
+	}
+	return hits, nil
+}
+
+// inScope reports whether a note's category passes a category filter
+func inScope(noteCategory, category string, categories []string) bool {
+	switch {
+	case category != "":
+		return noteCategory == category
+	case len(categories) > 0:
+		return slices.Contains(categories, noteCategory)
+	}
+	return true
+}
+
+// keywordQuery turns plain language into a query matching any of its words,
+// leaving out function words. It is nil when no word is left.
+func keywordQuery(text string) *queryNode {
+	var words []string
+	seen := make(map[string]bool)
+	for _, tok := range contentTokens(text) {
+		if stopWords[tok] || seen[tok] {
+			continue
+		}
+		seen[tok] = true
+		words = append(words, tok)
+	}
+	return anyOf(words)
+}
+
+// vectors returns the embedded notes of the workspace in ctx, first
+// bringing the index up to date: reloading it when due, applying recorded
+// changes and embedding the notes whose vectors are missing or stale
+func (s *Service) vectors(ctx context.Context) ([]*NoteEmbedding, error) {
+	set := s.index.set(workspace.FromContext(ctx))
+	set.mu.Lock()
+	defer set.mu.Unlock()
+
+	if set.notes == nil || time.Since(set.loadedAt) > vectorRefresh {
+		// Changes recorded so far are in what the store returns; later ones
+		// are applied below even if it has them too
+		s.index.takeChanges(set)
+		list, err := s.repo.ListEmbeddings(ctx)
+		if err != nil {
+			return nil, err
+		}
+		set.notes = make(map[primitive.ObjectID]*NoteEmbedding, len(list))
+		for _, ne := range list {
+			set.notes[ne.ID] = ne
+		}
+		set.loadedAt = time.Now()
+	}
+
+	changed := make(map[primitive.ObjectID]*Note)
+	for id, e := range s.index.takeChanges(set) {
+		if e.Type == EventNoteDeleted {
+			delete(set.notes, id)
+			continue
+		}
+		ne := noteEmbedding(e.Note, nil)
+		if prev, ok := set.notes[id]; ok {
+			ne.Embedding = prev.Embedding
+		}
+		set.notes[id] = ne
+		changed[id] = e.Note
+	}
+
+	model := s.embedder.Model()
+	var stale []primitive.ObjectID
+	for id, ne := range set.notes {
+		if e := ne.Embedding; e == nil || e.Model != model || e.ContentHash != ne.ContentHash {
+			stale = append(stale, id)
+		}
+	}
+	if err := s.embedNotes(ctx, set, stale, changed); err != nil {
+		return nil, err
+	}
+
+	vectors := make([]*NoteEmbedding, 0, len(set.notes))
+	for _, ne := range set.notes {
+		if ne.Embedding != nil && ne.Embedding.Model == model {
+			vectors = append(vectors, ne)
+		}
+	}
+	return vectors, nil
+}
+
+// embedNotes embeds notes of a set and saves their vectors. Their content
+// comes from notes when a recorded change left it there, and from the store
+// otherwise; notes gone from the store leave the set.
+func (s *Service) embedNotes(ctx context.Context, set *vectorSet, ids []primitive.ObjectID, notes map[primitive.ObjectID]*Note) error {
+	want := make(map[primitive.ObjectID]bool)
+	for _, id := range ids {
+		if _, ok := notes[id]; !ok {
+			want[id] = true
+		}
+	}
+	if len(want) > 0 {
+		err := s.repo.Export(ctx, ExportQuery{}, func(n *Note) error {
+			if want[n.ID] {
+				notes[n.ID] = n
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	model := s.embedder.Model()
+	for start := 0; start < len(ids); start += embedBatchSize {
+		var batch []*Note
+		var texts []string
+		for _, id := range ids[start:min(start+embedBatchSize, len(ids))] {
+			n, ok := notes[id]
+			if !ok {
+				delete(set.notes, id)
+				continue
+			}
+			batch = append(batch, n)
+			texts = append(texts, n.Content)
+		}
+		if len(batch) == 0 {
+			continue
+		}
+
+		vectors, err := s.embedder.Embed(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("embed notes: %w", err)
+		}
+		if len(vectors) != len(batch) {
+			return fmt.Errorf("embed notes: got %d vectors for %d notes", len(vectors), len(batch))
+		}
+		for i, n := range batch {
+			v := Vector(vectors[i])
+			normalize(v)
+			e := &Embedding{Model: model, ContentHash: n.ContentHash, Vector: v}
+			err := s.repo.SaveEmbedding(ctx, n.ID, e)
+			if errors.Is(err, ErrNoteNotFound) {
+				delete(set.notes, n.ID)
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			set.notes[n.ID] = noteEmbedding(n, e)
+		}
+	}
+	return nil
+}
+
+// noteEmbedding describes a note and its embedding for the index
+func noteEmbedding(n *Note, e *Embedding) *NoteEmbedding {
+	return &NoteEmbedding{
+		ID:          n.ID,
+		Category:    n.Category,
+		ContentHash: n.ContentHash,
+		CreatedAt:   n.CreatedAt,
+		Embedding:   e,
+	}
+}
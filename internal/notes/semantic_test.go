@@ -0,0 +1,232 @@
+package notes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// countingEmbedder is a HashEmbedder under another model name that counts
+// the texts it embeds
+type countingEmbedder struct {
+	*HashEmbedder
+	model string
+
+	mu       sync.Mutex
+	embedded int
+}
+
+func (e *countingEmbedder) Model() string { return e.model }
+
+func (e *countingEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	e.mu.Lock()
+	e.embedded += len(texts)
+	e.mu.Unlock()
+	return e.HashEmbedder.Embed(ctx, texts)
+}
+
+// took returns how many texts were embedded since the last call
+func (e *countingEmbedder) took() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	n := e.embedded
+	e.embedded = 0
+	return n
+}
+
+func semanticIDs(t *testing.T, svc *Service, ctx context.Context, q SemanticQuery) []string {
+	t.Helper()
+	hits, err := svc.SemanticSearch(ctx, q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(hits))
+	for i, h := range hits {
+		ids[i] = h.ID.Hex()
+	}
+	return ids
+}
+
+func TestSemanticSearch(t *testing.T) {
+	for name, svc := range newStoreServices(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			threads := mustCreate(t, svc, CreateNoteInput{Category: "growth", Content: "Growing twitter followers with threads"})
+			followers := mustCreate(t, svc, CreateNoteInput{Category: "growth", Content: "Follower growth on twitter this quarter"})
+			bread := mustCreate(t, svc, CreateNoteInput{Category: "kitchen", Content: "Sourdough bread recipe with a long rise"})
+
+			got := semanticIDs(t, svc, ctx, SemanticQuery{Query: "how to grow my twitter following"})
+			if len(got) < 2 || !slices.Contains(got[:2], threads.ID.Hex()) || !slices.Contains(got[:2], followers.ID.Hex()) {
+				t.Errorf("hits = %v, want the twitter notes first", got)
+			}
+			if i := slices.Index(got, bread.ID.Hex()); i >= 0 && i < len(got)-1 {
+				t.Errorf("hits = %v, want the bread note last if at all", got)
+			}
+			if got := semanticIDs(t, svc, ctx, SemanticQuery{Query: "twitter", Category: "Kitchen"}); len(got) != 0 {
+				t.Errorf("kitchen hits = %v, want none", got)
+			}
+			restricted := WithCategoryAccess(ctx, []string{"kitchen"})
+			if got := semanticIDs(t, svc, restricted, SemanticQuery{Query: "twitter followers bread"}); !slices.Equal(got, []string{bread.ID.Hex()}) {
+				t.Errorf("restricted hits = %v, want only the kitchen note", got)
+			}
+
+			hits, err := svc.SemanticSearch(ctx, SemanticQuery{Query: "sourdough", TextWeight: DefaultTextWeight, OmitContent: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(hits) == 0 || hits[0].ID != bread.ID || hits[0].TextScore != 1 || hits[0].Content != "" {
+				t.Fatalf("hybrid hits = %+v, want the bread note with the best text score and no content", hits)
+			}
+			if s := hits[0].Snippets; len(s) != 1 || len(s[0].Highlights) != 1 {
+				t.Errorf("snippets = %v, want the keyword highlighted", s)
+			}
+
+			for _, q := range []SemanticQuery{{Query: "  "}, {Query: "x", TextWeight: -0.1}, {Query: "x", TextWeight: 1.5}} {
+				if _, err := svc.SemanticSearch(ctx, q); !errors.Is(err, ErrInvalidInput) {
+					t.Errorf("%+v: err = %v, want ErrInvalidInput", q, err)
+				}
+			}
+		})
+	}
+}
+
+func TestSemanticSearchFollowsChanges(t *testing.T) {
+	for name, svc := range newStoreServices(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			embedder := &countingEmbedder{HashEmbedder: NewHashEmbedder(DefaultHashDims), model: "counting"}
+			svc.SetEmbedder(embedder)
+			note := mustCreate(t, svc, CreateNoteInput{Category: "kitchen", Content: "Sourdough bread recipe"})
+			mustCreate(t, svc, CreateNoteInput{Category: "growth", Content: "Twitter follower growth"})
+
+			search := func(query string) []string {
+				return semanticIDs(t, svc, ctx, SemanticQuery{Query: query})
+			}
+			search("bread")
+			if n := embedder.took(); n != 3 {
+				t.Errorf("first search embedded %d texts, want both notes and the query", n)
+			}
+			search("bread")
+			if n := embedder.took(); n != 1 {
+				t.Errorf("second search embedded %d texts, want only the query", n)
+			}
+
+			content := "Pizza dough with a cold ferment"
+			if _, err := svc.Update(ctx, note.ID.Hex(), UpdateNoteInput{Content: &content}); err != nil {
+				t.Fatal(err)
+			}
+			if got := search("pizza dough"); len(got) != 1 || got[0] != note.ID.Hex() {
+				t.Errorf("hits after an edit = %v, want the edited note", got)
+			}
+			if n := embedder.took(); n != 2 {
+				t.Errorf("search after an edit embedded %d texts, want the edited note and the query", n)
+			}
+
+			if err := svc.Delete(ctx, note.ID.Hex()); err != nil {
+				t.Fatal(err)
+			}
+			if got := search("pizza dough"); len(got) != 0 {
+				t.Errorf("hits after deleting = %v, want none", got)
+			}
+
+			// Another model means embedding everything again
+			svc.SetEmbedder(&countingEmbedder{HashEmbedder: NewHashEmbedder(64), model: "other"})
+			if got := search("twitter"); len(got) != 1 {
+				t.Errorf("hits with a new model = %v, want the twitter note", got)
+			}
+		})
+	}
+}
+
+// embeddingServer answers OpenAI-style embedding requests with HashEmbedder
+// vectors, listing them in reverse order
+func embeddingServer(t *testing.T, model string) *httptest.Server {
+	t.Helper()
+	hash := NewHashEmbedder(DefaultHashDims)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model string   `json:"model"`
+			Input []string `json:"input"`
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/v1/embeddings" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Model != model {
+			http.Error(w, "unknown model", http.StatusNotFound)
+			return
+		}
+		vectors, _ := hash.Embed(r.Context(), req.Input)
+		type datum struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		}
+		var data []datum
+		for i := len(vectors) - 1; i >= 0; i-- {
+			data = append(data, datum{i, vectors[i]})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": data})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestHTTPEmbedder(t *testing.T) {
+	srv := embeddingServer(t, "nomic-embed-text")
+	ctx := context.Background()
+
+	e := NewHTTPEmbedder(srv.URL+"/v1/", "nomic-embed-text")
+	texts := []string{"twitter growth", "sourdough bread"}
+	got, err := e.Embed(ctx, texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, _ := NewHashEmbedder(DefaultHashDims).Embed(ctx, texts)
+	for i := range texts {
+		if !slices.Equal(got[i], want[i]) {
+			t.Errorf("vector %d does not belong to %q", i, texts[i])
+		}
+	}
+
+	if _, err := NewHTTPEmbedder(srv.URL+"/v1", "missing").Embed(ctx, texts); err == nil || !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "unknown model") {
+		t.Errorf("unknown model: err = %v, want the server's status and message", err)
+	}
+
+	// Searching with it goes through the server
+	svc := newTestService(t)
+	svc.SetEmbedder(e)
+	note := mustCreate(t, svc, CreateNoteInput{Category: "kitchen", Content: "Sourdough bread recipe"})
+	mustCreate(t, svc, CreateNoteInput{Category: "growth", Content: "Twitter follower growth"})
+	if got := semanticIDs(t, svc, ctx, SemanticQuery{Query: "bread"}); len(got) != 1 || got[0] != note.ID.Hex() {
+		t.Errorf("hits = %v, want the bread note", got)
+	}
+}
+
+func TestHTTPEmbedderBadResponses(t *testing.T) {
+	tests := []struct {
+		name, body string
+		want       string
+	}{
+		{"not JSON", `nope`, "decode embeddings"},
+		{"index out of range", `{"data":[{"index":0,"embedding":[1]},{"index":2,"embedding":[1]}]}`, "index 2 out of range"},
+		{"missing input", `{"data":[{"index":1,"embedding":[1]}]}`, "no embedding for input 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			_, err := NewHTTPEmbedder(srv.URL, "m").Embed(context.Background(), []string{"a", "b"})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
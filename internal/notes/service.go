@@ -2,9 +2,19 @@ package notes
 
 import (
 	"bytes"
+	"cmp"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"net/http"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/extension"
@@ -14,11 +24,20 @@ import (
 )
 
 type Service struct {
-	repo *Repo
+	repo NoteStore
 	md   goldmark.Markdown
+
+	idempotencyTTL time.Duration
+	duplicates     DuplicatePolicy
+
+	embedder Embedder
+	index    *vectorIndex
+
+	listenersMu sync.RWMutex
+	listeners   []Listener
 }
 
-func NewService(repo *Repo) *Service {
+func NewService(repo NoteStore) *Service {
 	// Create goldmark with GFM extensions for better markdown support
 	md := goldmark.New(
 		goldmark.WithExtensions(
@@ -34,73 +53,632 @@ func NewService(repo *Repo) *Service {
 		),
 	)
 
-	return &Service{
-		repo: repo,
-		md:   md,
+	s := &Service{
+		repo:           repo,
+		md:             md,
+		idempotencyTTL: DefaultIdempotencyTTL,
+		duplicates:     DuplicatesAllow,
+		embedder:       NewHashEmbedder(DefaultHashDims),
+		index:          newVectorIndex(),
+	}
+	s.OnChange(s.index.noteChanged)
+	return s
+}
+
+// DefaultIdempotencyTTL is how long idempotency keys are remembered unless
+// SetIdempotencyTTL says otherwise
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// SetIdempotencyTTL sets how long CreateIdempotent remembers a key
+func (s *Service) SetIdempotencyTTL(ttl time.Duration) {
+	s.idempotencyTTL = ttl
+}
+
+// SetDuplicatePolicy sets what creating an exact duplicate does when the
+// input doesn't say; the default is DuplicatesAllow
+func (s *Service) SetDuplicatePolicy(policy DuplicatePolicy) error {
+	if err := checkDuplicatePolicy(policy); err != nil {
+		return err
+	}
+	s.duplicates = policy
+	return nil
+}
+
+func checkDuplicatePolicy(policy DuplicatePolicy) error {
+	switch policy {
+	case DuplicatesAllow, DuplicatesReject, DuplicatesMerge:
+		return nil
+	}
+	return fmt.Errorf("%w: onDuplicate must be allow, reject or merge", ErrInvalidInput)
+}
+
+// maxUpdateAttempts bounds the read-modify-write retries for edits that
+// don't pin a version (e.g. two agents appending to the same note)
+const maxUpdateAttempts = 5
+
+// Create creates a new note. When the category already holds a note with
+// the same normalized content, input.OnDuplicate (or the server default)
+// decides whether to create it anyway, fail with ErrDuplicateNote or merge
+// its tags into the existing note.
+func (s *Service) Create(ctx context.Context, input CreateNoteInput) (*CreateResult, error) {
+	note, err := newNote(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	return s.insert(ctx, note, input.OnDuplicate)
+}
+
+// insert stores a validated note, applying the duplicate policy. The check
+// and the insert aren't atomic, so racing creates can still both land.
+func (s *Service) insert(ctx context.Context, note *Note, policy DuplicatePolicy) (*CreateResult, error) {
+	if policy == "" {
+		policy = s.duplicates
+	}
+	if policy != DuplicatesAllow {
+		existing, err := s.repo.FindByContentHash(ctx, note.Category, note.ContentHash)
+		switch {
+		case err == nil && policy == DuplicatesReject:
+			return nil, fmt.Errorf("%w: same content as note %s", ErrDuplicateNote, existing.ID.Hex())
+		case err == nil:
+			return s.mergeDuplicate(ctx, existing, note)
+		case !errors.Is(err, ErrNoteNotFound):
+			return nil, err
+		}
+	}
+
+	if err := s.repo.Insert(ctx, note); err != nil {
+		return nil, err
+	}
+	s.emit(ctx, EventNoteCreated, note, note.Category)
+	return &CreateResult{Note: note, Status: http.StatusCreated}, nil
+}
+
+// mergeDuplicate adds the tags of dup, which was not stored, to existing
+func (s *Service) mergeDuplicate(ctx context.Context, existing, dup *Note) (*CreateResult, error) {
+	tags := normalizeTags(append(slices.Clone(existing.Tags), dup.Tags...))
+	if len(tags) == len(existing.Tags) {
+		return &CreateResult{Note: existing, Status: http.StatusOK, Merged: true}, nil
+	}
+	merged, err := s.Update(ctx, existing.ID.Hex(), UpdateNoteInput{Tags: &tags, Author: dup.Author})
+	if err != nil {
+		return nil, err
+	}
+	return &CreateResult{Note: merged, Status: http.StatusOK, Merged: true}, nil
+}
+
+// maxIdempotencyKeyLen bounds the length of an idempotency key
+const maxIdempotencyKeyLen = 255
+
+// CreateIdempotent creates a note at most once per idempotency key. Replaying
+// a key returns the note from the first response, even if it has changed or
+// been deleted since. Reusing a key for a different note fails with
+// ErrIdempotencyKeyReused, and while the first request is still running with
+// ErrIdempotencyInFlight. Failed creates don't use up the key.
+func (s *Service) CreateIdempotent(ctx context.Context, key string, input CreateNoteInput) (*CreateResult, error) {
+	key = strings.TrimSpace(key)
+	if key == "" || len(key) > maxIdempotencyKeyLen {
+		return nil, fmt.Errorf("%w: idempotency key must be 1 to %d characters", ErrInvalidInput, maxIdempotencyKeyLen)
+	}
+
+	note, err := newNote(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+
+	rec := &IdempotencyRecord{
+		Key:         key,
+		RequestHash: requestHash(note),
+		ExpiresAt:   time.Now().Add(s.idempotencyTTL),
+	}
+	existing, err := s.repo.ClaimIdempotencyKey(ctx, rec)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return replay(existing, rec.RequestHash)
+	}
+
+	result, err := s.insert(ctx, note, input.OnDuplicate)
+	if err != nil {
+		if relErr := s.repo.ReleaseIdempotencyKey(context.WithoutCancel(ctx), key); relErr != nil {
+			err = errors.Join(err, relErr)
+		}
+		return nil, err
+	}
+
+	response, err := json.Marshal(result.Note)
+	if err != nil {
+		return nil, fmt.Errorf("encode idempotent response: %w", err)
+	}
+	// The note exists now, so a failure here must not be reported as a
+	// failed create; retries just see the key in flight until it expires
+	_ = s.repo.CompleteIdempotencyKey(context.WithoutCancel(ctx), key, result.Status, response)
+	return result, nil
+}
+
+// replay turns a stored idempotency record back into a result
+func replay(rec *IdempotencyRecord, hash string) (*CreateResult, error) {
+	if rec.RequestHash != hash {
+		return nil, ErrIdempotencyKeyReused
+	}
+	if rec.Status == 0 {
+		return nil, ErrIdempotencyInFlight
+	}
+	var note Note
+	if err := json.Unmarshal(rec.Response, &note); err != nil {
+		return nil, fmt.Errorf("decode idempotent response: %w", err)
+	}
+	return &CreateResult{Note: &note, Status: rec.Status, Replayed: true}, nil
+}
+
+// requestHash fingerprints the validated note a create would insert
+func requestHash(n *Note) string {
+	h := sha256.New()
+	json.NewEncoder(h).Encode([]any{n.Category, n.Content, n.Tags, n.Author})
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// bulkBatchSize is how many notes CreateMany hands the store at a time
+const bulkBatchSize = 100
+
+// CreateMany creates notes from inputs, validating each like Create. Invalid
+// items are skipped rather than failing the whole call; the result at index
+// i reports what happened to inputs[i].
+func (s *Service) CreateMany(ctx context.Context, inputs []CreateNoteInput) []BulkResult {
+	results := make([]BulkResult, len(inputs))
+	var batch []*Note
+	var batchIdx []int
+
+	flush := func() {
+		inserted, err := s.repo.InsertMany(ctx, batch)
+		for j, note := range batch {
+			i := batchIdx[j]
+			if j < inserted {
+				results[i].ID = note.ID.Hex()
+				results[i].Status = BulkCreated
+				s.emit(ctx, EventNoteCreated, note, note.Category)
+			} else {
+				results[i].Status = BulkFailed
+				results[i].Error = err.Error()
+			}
+		}
+		batch, batchIdx = batch[:0], batchIdx[:0]
+	}
+
+	for i, input := range inputs {
+		results[i].Index = i
+		note, err := newNote(ctx, input)
+		if err != nil {
+			results[i].Status = BulkFailed
+			results[i].Error = err.Error()
+			continue
+		}
+		// Duplicate checks need earlier items stored first, so these go
+		// one at a time
+		if policy := cmp.Or(input.OnDuplicate, s.duplicates); policy != DuplicatesAllow {
+			if len(batch) > 0 {
+				flush()
+			}
+			res, err := s.insert(ctx, note, policy)
+			switch {
+			case err != nil:
+				results[i].Status = BulkFailed
+				results[i].Error = err.Error()
+			case res.Merged:
+				results[i].Status = BulkMerged
+				results[i].ID = res.Note.ID.Hex()
+			default:
+				results[i].Status = BulkCreated
+				results[i].ID = res.Note.ID.Hex()
+			}
+			continue
+		}
+		batch = append(batch, note)
+		batchIdx = append(batchIdx, i)
+		if len(batch) == bulkBatchSize {
+			flush()
+		}
+	}
+	if len(batch) > 0 {
+		flush()
 	}
+	return results
 }
 
-// Create creates a new note
-func (s *Service) Create(ctx context.Context, input CreateNoteInput) (*Note, error) {
-	// Normalize category: lowercase, replace spaces with hyphens
-	category := strings.ToLower(strings.TrimSpace(input.Category))
-	category = strings.ReplaceAll(category, " ", "-")
+// newNote validates input and builds the note to insert
+func newNote(ctx context.Context, input CreateNoteInput) (*Note, error) {
+	category := normalizeCategory(input.Category)
 
 	if category == "" {
-		return nil, fmt.Errorf("category is required")
+		return nil, fmt.Errorf("%w: category is required", ErrInvalidInput)
 	}
 	if strings.TrimSpace(input.Content) == "" {
-		return nil, fmt.Errorf("content is required")
+		return nil, fmt.Errorf("%w: content is required", ErrInvalidInput)
+	}
+	if input.OnDuplicate != "" {
+		if err := checkDuplicatePolicy(input.OnDuplicate); err != nil {
+			return nil, err
+		}
+	}
+	if !canAccess(ctx, category) {
+		return nil, fmt.Errorf("%w: no access to category %q", ErrForbidden, category)
 	}
 
 	note := &Note{
 		Category: category,
 		Content:  input.Content,
+		Tags:     normalizeTags(input.Tags),
+		Author:   strings.TrimSpace(input.Author),
+	}
+	note.fingerprint()
+	return note, nil
+}
+
+// GetByID retrieves a note by ID
+func (s *Service) GetByID(ctx context.Context, id string) (*Note, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid note ID", ErrInvalidInput)
 	}
+	return s.findByID(ctx, oid)
+}
 
-	if err := s.repo.Insert(ctx, note); err != nil {
+// findByID hides notes outside the caller's category access
+func (s *Service) findByID(ctx context.Context, oid primitive.ObjectID) (*Note, error) {
+	note, err := s.repo.FindByID(ctx, oid)
+	if err != nil {
 		return nil, err
 	}
-
+	if !canAccess(ctx, note.Category) {
+		return nil, ErrNoteNotFound
+	}
 	return note, nil
 }
 
-// GetByID retrieves a note by ID
-func (s *Service) GetByID(ctx context.Context, id string) (*Note, error) {
+// Update edits a note, keeping the previous version as a revision. When
+// input.Version is set the edit only applies if the note is still at that
+// version (ErrVersionConflict otherwise); without it, concurrent edits are
+// retried so appends never overwrite each other.
+func (s *Service) Update(ctx context.Context, id string, input UpdateNoteInput) (*Note, error) {
 	oid, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return nil, fmt.Errorf("invalid note ID: %w", err)
+		return nil, fmt.Errorf("%w: invalid note ID", ErrInvalidInput)
+	}
+
+	if input.Content == nil && input.Append == "" && input.Prepend == "" && input.Category == nil && input.Tags == nil {
+		return nil, fmt.Errorf("%w: nothing to update", ErrInvalidInput)
+	}
+
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		note, err := s.findByID(ctx, oid)
+		if err != nil {
+			return nil, err
+		}
+		if input.Version != 0 && note.Version != input.Version {
+			return nil, ErrVersionConflict
+		}
+
+		// Snapshot before writing; saving is idempotent per version, so a
+		// retried or conflicting attempt can't record a bogus revision
+		if err := s.repo.SaveRevision(ctx, revisionOf(note)); err != nil {
+			return nil, err
+		}
+
+		prevCategory := note.Category
+		if err := applyUpdate(note, input); err != nil {
+			return nil, err
+		}
+		if !canAccess(ctx, note.Category) {
+			return nil, fmt.Errorf("%w: no access to category %q", ErrForbidden, note.Category)
+		}
+
+		err = s.repo.Update(ctx, note, note.Version)
+		if errors.Is(err, ErrVersionConflict) && input.Version == 0 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.emit(ctx, EventNoteUpdated, note, prevCategory)
+		return note, nil
+	}
+
+	return nil, ErrVersionConflict
+}
+
+// ListRevisions returns the previous versions of a note, newest first
+func (s *Service) ListRevisions(ctx context.Context, id string) ([]*Revision, error) {
+	note, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListRevisions(ctx, note.ID)
+}
+
+// GetRevision returns a note as it was at the given version. The current
+// version is served from the note itself.
+func (s *Service) GetRevision(ctx context.Context, id string, version int64) (*Revision, error) {
+	note, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if version == note.Version {
+		return revisionOf(note), nil
+	}
+	return s.repo.FindRevision(ctx, note.ID, version)
+}
+
+// RestoreRevision makes an old version current again. The restore is itself
+// a new version, so nothing is lost.
+func (s *Service) RestoreRevision(ctx context.Context, id string, version int64, author string) (*Note, error) {
+	rev, err := s.GetRevision(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+	return s.Update(ctx, id, UpdateNoteInput{
+		Content:  &rev.Content,
+		Category: &rev.Category,
+		Author:   author,
+	})
+}
+
+// revisionOf snapshots a note's current version
+func revisionOf(note *Note) *Revision {
+	return &Revision{
+		NoteID:    note.ID,
+		Version:   note.Version,
+		Category:  note.Category,
+		Content:   note.Content,
+		Author:    note.Author,
+		CreatedAt: note.UpdatedAt,
+	}
+}
+
+// applyUpdate applies input to note in place, validating the result
+func applyUpdate(note *Note, input UpdateNoteInput) error {
+	note.Author = strings.TrimSpace(input.Author)
+
+	if input.Category != nil {
+		category := normalizeCategory(*input.Category)
+		if category == "" {
+			return fmt.Errorf("%w: category must not be empty", ErrInvalidInput)
+		}
+		note.Category = category
+	}
+	if input.Tags != nil {
+		note.Tags = normalizeTags(*input.Tags)
+	}
+
+	if input.Content != nil {
+		note.Content = *input.Content
+	}
+	if strings.TrimSpace(input.Prepend) != "" {
+		note.Content = joinParagraphs(input.Prepend, note.Content)
+	}
+	if strings.TrimSpace(input.Append) != "" {
+		note.Content = joinParagraphs(note.Content, input.Append)
+	}
+
+	if strings.TrimSpace(note.Content) == "" {
+		return fmt.Errorf("%w: content is required", ErrInvalidInput)
+	}
+	note.fingerprint()
+	return nil
+}
+
+// joinParagraphs concatenates two markdown blocks with a blank line between
+func joinParagraphs(first, second string) string {
+	first = strings.TrimRight(first, "\n")
+	second = strings.TrimLeft(second, "\n")
+	if first == "" {
+		return second
+	}
+	if second == "" {
+		return first
+	}
+	return first + "\n\n" + second
+}
+
+// NormalizeCategory returns a category in the form notes are stored under,
+// for packages that filter notes by category
+func NormalizeCategory(category string) string {
+	return normalizeCategory(category)
+}
+
+// normalizeCategory lowercases a category and replaces spaces with hyphens
+func normalizeCategory(category string) string {
+	category = strings.ToLower(strings.TrimSpace(category))
+	return strings.ReplaceAll(category, " ", "-")
+}
+
+// normalizeTags applies category normalization to each tag, strips a
+// leading '#', and drops empties and duplicates. Tags come back sorted.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	var normalized []string
+	for _, t := range tags {
+		t = normalizeCategory(strings.TrimPrefix(strings.TrimSpace(t), "#"))
+		if t == "" || seen[t] {
+			continue
+		}
+		seen[t] = true
+		normalized = append(normalized, t)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// checkTagMode validates a tag mode, defaulting empty to TagModeAny
+func checkTagMode(mode TagMode) (TagMode, error) {
+	switch mode {
+	case "":
+		return TagModeAny, nil
+	case TagModeAny, TagModeAll:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("%w: tag mode must be %q or %q", ErrInvalidInput, TagModeAny, TagModeAll)
 	}
-	return s.repo.FindByID(ctx, oid)
 }
 
 // List retrieves notes with optional filters
 func (s *Service) List(ctx context.Context, q ListQuery) ([]*Note, error) {
+	mode, err := checkTagMode(q.TagMode)
+	if err != nil {
+		return nil, err
+	}
+	q.Tags, q.TagMode = normalizeTags(q.Tags), mode
+
+	categories, ok := scopeQuery(ctx, q.Category)
+	if !ok {
+		return nil, nil
+	}
+	q.Categories = categories
 	return s.repo.List(ctx, q)
 }
 
-// Search performs full-text search
-func (s *Service) Search(ctx context.Context, q SearchQuery) ([]*Note, error) {
-	return s.repo.Search(ctx, q)
+// Search finds notes matching a search query and filters: a page of hits
+// with snippets of where each matched, and the total number of matches
+func (s *Service) Search(ctx context.Context, q SearchQuery) (*SearchResult, error) {
+	mode, err := checkTagMode(q.TagMode)
+	if err != nil {
+		return nil, err
+	}
+	q.Tags, q.TagMode = normalizeTags(q.Tags), mode
+	if q.expr, err = parseSearchQuery(q.Query); err != nil {
+		return nil, err
+	}
+	q.Limit, q.Offset = clampLimit(q.Limit, 50, 200), max(q.Offset, 0)
+
+	result := &SearchResult{}
+	if categories, ok := scopeQuery(ctx, q.Category); ok {
+		q.Categories = categories
+		if result, err = s.repo.Search(ctx, q); err != nil {
+			return nil, err
+		}
+	} else if q.Facets {
+		result.Facets = &SearchFacets{}
+	}
+
+	h := newHighlighter(q.expr)
+	for _, hit := range result.Hits {
+		hit.Snippets = h.snippets(hit.Content)
+		if q.OmitContent {
+			hit.Content = ""
+		}
+	}
+	result.Limit, result.Offset = q.Limit, q.Offset
+	result.HasMore = int64(q.Offset+len(result.Hits)) < result.Total
+
+	// Empty lists rather than nulls for JSON clients
+	if result.Hits == nil {
+		result.Hits = []*SearchHit{}
+	}
+	if f := result.Facets; f != nil {
+		for _, counts := range []*[]FacetCount{&f.Categories, &f.Tags, &f.Months} {
+			if *counts == nil {
+				*counts = []FacetCount{}
+			}
+		}
+	}
+	return result, nil
 }
 
 // GetRecent retrieves most recent notes
 func (s *Service) GetRecent(ctx context.Context, q SearchQuery) ([]*Note, error) {
+	if allowed := categoryAccess(ctx); allowed != nil {
+		result, err := s.repo.Search(ctx, SearchQuery{
+			Categories: allowed,
+			Since:      q.Since,
+			Limit:      clampLimit(q.Limit, 20, 100),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return result.Notes(), nil
+	}
 	return s.repo.GetRecent(ctx, q.Limit, q.Since)
 }
 
 // ListCategories returns all categories with stats
 func (s *Service) ListCategories(ctx context.Context) ([]*Category, error) {
-	return s.repo.ListCategories(ctx)
+	categories, err := s.repo.ListCategories(ctx)
+	if err != nil || categoryAccess(ctx) == nil {
+		return categories, err
+	}
+
+	visible := categories[:0]
+	for _, cat := range categories {
+		if canAccess(ctx, cat.Name) {
+			visible = append(visible, cat)
+		}
+	}
+	return visible, nil
+}
+
+// ListTags returns all tags with usage counts
+func (s *Service) ListTags(ctx context.Context) ([]*Tag, error) {
+	return s.repo.ListTags(ctx, categoryAccess(ctx))
 }
 
-// Delete removes a note by ID
+// Delete moves a note to the trash
 func (s *Service) Delete(ctx context.Context, id string) error {
 	oid, err := primitive.ObjectIDFromHex(id)
 	if err != nil {
-		return fmt.Errorf("invalid note ID: %w", err)
+		return fmt.Errorf("%w: invalid note ID", ErrInvalidInput)
+	}
+
+	note, err := s.findByID(ctx, oid)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, oid); err != nil {
+		return err
+	}
+
+	s.emit(ctx, EventNoteDeleted, note, note.Category)
+	return nil
+}
+
+// Restore takes a note back out of the trash
+func (s *Service) Restore(ctx context.Context, id string) (*Note, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid note ID", ErrInvalidInput)
+	}
+
+	trashed, err := s.repo.FindTrashed(ctx, oid)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccess(ctx, trashed.Category) {
+		return nil, ErrNoteNotFound
+	}
+	if err := s.repo.Restore(ctx, oid); err != nil {
+		return nil, err
+	}
+
+	note, err := s.repo.FindByID(ctx, oid)
+	if err != nil {
+		return nil, err
+	}
+	s.emit(ctx, EventNoteRestored, note, note.Category)
+	return note, nil
+}
+
+// ListTrash returns trashed notes, most recently deleted first
+func (s *Service) ListTrash(ctx context.Context, q ListQuery) ([]*Note, error) {
+	categories, ok := scopeQuery(ctx, q.Category)
+	if !ok {
+		return nil, nil
 	}
-	return s.repo.Delete(ctx, oid)
+	q.Categories = categories
+	return s.repo.ListTrash(ctx, q)
+}
+
+// PurgeTrash permanently removes notes that have been in the trash longer
+// than retention
+func (s *Service) PurgeTrash(ctx context.Context, retention time.Duration) (int64, error) {
+	return s.repo.Purge(ctx, time.Now().Add(-retention))
 }
 
 // RenderMarkdown converts markdown content to HTML
@@ -114,5 +692,21 @@ func (s *Service) RenderMarkdown(content string) string {
 
 // Count returns total note count
 func (s *Service) Count(ctx context.Context, category string) (int64, error) {
-	return s.repo.Count(ctx, category)
+	if categoryAccess(ctx) == nil || category != "" {
+		if !canAccess(ctx, category) {
+			return 0, nil
+		}
+		return s.repo.Count(ctx, category)
+	}
+
+	// Restricted callers only count the categories they can see
+	categories, err := s.ListCategories(ctx)
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, cat := range categories {
+		total += cat.Count
+	}
+	return total, nil
 }
@@ -0,0 +1,321 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	return NewService(NewMemoryRepo())
+}
+
+func mustCreate(t *testing.T, svc *Service, input CreateNoteInput) *Note {
+	t.Helper()
+	res, err := svc.Create(context.Background(), input)
+	if err != nil {
+		t.Fatalf("create %q: %v", input.Content, err)
+	}
+	return res.Note
+}
+
+func noteIDs(notes []*Note) []string {
+	ids := make([]string, len(notes))
+	for i, n := range notes {
+		ids[i] = n.ID.Hex()
+	}
+	return ids
+}
+
+func TestCreate(t *testing.T) {
+	restricted := WithCategoryAccess(context.Background(), []string{"work"})
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		input        CreateNoteInput
+		wantErr      error
+		wantCategory string
+		wantTags     []string
+	}{
+		{
+			name:         "normalizes category and tags",
+			input:        CreateNoteInput{Category: " Twitter Analytics ", Content: "hi", Tags: []string{"#Go", "go", " Data Science", ""}},
+			wantCategory: "twitter-analytics",
+			wantTags:     []string{"data-science", "go"},
+		},
+		{name: "missing category", input: CreateNoteInput{Category: "  ", Content: "hi"}, wantErr: ErrInvalidInput},
+		{name: "blank content", input: CreateNoteInput{Category: "ideas", Content: " \n "}, wantErr: ErrInvalidInput},
+		{name: "bad duplicate policy", input: CreateNoteInput{Category: "ideas", Content: "hi", OnDuplicate: "ignore"}, wantErr: ErrInvalidInput},
+		{name: "allowed category", ctx: restricted, input: CreateNoteInput{Category: "Work", Content: "hi"}, wantCategory: "work"},
+		{name: "forbidden category", ctx: restricted, input: CreateNoteInput{Category: "ideas", Content: "hi"}, wantErr: ErrForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			res, err := newTestService(t).Create(ctx, tt.input)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			n := res.Note
+			if n.ID.IsZero() || n.Version != 1 || n.CreatedAt.IsZero() {
+				t.Errorf("note not stored: id %s, version %d, created %v", n.ID.Hex(), n.Version, n.CreatedAt)
+			}
+			if n.Category != tt.wantCategory {
+				t.Errorf("category = %q, want %q", n.Category, tt.wantCategory)
+			}
+			if !slices.Equal(n.Tags, tt.wantTags) {
+				t.Errorf("tags = %q, want %q", n.Tags, tt.wantTags)
+			}
+		})
+	}
+}
+
+func TestCreateDuplicates(t *testing.T) {
+	tests := []struct {
+		policy     DuplicatePolicy
+		wantErr    error
+		wantMerged bool
+		wantCount  int64
+	}{
+		{DuplicatesAllow, nil, false, 2},
+		{DuplicatesReject, ErrDuplicateNote, false, 1},
+		{DuplicatesMerge, nil, true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			ctx := context.Background()
+			svc := newTestService(t)
+			first := mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "Same  idea", Tags: []string{"a"}})
+
+			res, err := svc.Create(ctx, CreateNoteInput{Category: "ideas", Content: "same idea", Tags: []string{"b"}, OnDuplicate: tt.policy})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && res.Merged != tt.wantMerged {
+				t.Errorf("merged = %v, want %v", res.Merged, tt.wantMerged)
+			}
+			if tt.wantMerged {
+				if res.Note.ID != first.ID || !slices.Equal(res.Note.Tags, []string{"a", "b"}) {
+					t.Errorf("merged into %s with tags %q, want %s with a and b", res.Note.ID.Hex(), res.Note.Tags, first.ID.Hex())
+				}
+			}
+			if count, _ := svc.Count(ctx, "ideas"); count != tt.wantCount {
+				t.Errorf("count = %d, want %d", count, tt.wantCount)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	strPtr := func(s string) *string { return &s }
+
+	tests := []struct {
+		name         string
+		input        UpdateNoteInput
+		wantErr      error
+		wantContent  string
+		wantCategory string
+	}{
+		{name: "replace content", input: UpdateNoteInput{Content: strPtr("new")}, wantContent: "new", wantCategory: "ideas"},
+		{name: "append", input: UpdateNoteInput{Append: "more\n"}, wantContent: "first\n\nmore\n", wantCategory: "ideas"},
+		{name: "prepend", input: UpdateNoteInput{Prepend: "# Title"}, wantContent: "# Title\n\nfirst\n", wantCategory: "ideas"},
+		{name: "move", input: UpdateNoteInput{Category: strPtr("Work Log")}, wantContent: "first\n", wantCategory: "work-log"},
+		{name: "current version", input: UpdateNoteInput{Append: "more", Version: 1}, wantContent: "first\n\nmore", wantCategory: "ideas"},
+		{name: "stale version", input: UpdateNoteInput{Append: "more", Version: 3}, wantErr: ErrVersionConflict},
+		{name: "nothing to do", input: UpdateNoteInput{Author: "bot"}, wantErr: ErrInvalidInput},
+		{name: "empty category", input: UpdateNoteInput{Category: strPtr(" ")}, wantErr: ErrInvalidInput},
+		{name: "empty content", input: UpdateNoteInput{Content: strPtr("")}, wantErr: ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc := newTestService(t)
+			orig := mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "first\n"})
+
+			note, err := svc.Update(ctx, orig.ID.Hex(), tt.input)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if note.Content != tt.wantContent || note.Category != tt.wantCategory {
+				t.Errorf("note = %q in %q, want %q in %q", note.Content, note.Category, tt.wantContent, tt.wantCategory)
+			}
+			if note.Version != 2 {
+				t.Errorf("version = %d, want 2", note.Version)
+			}
+			rev, err := svc.GetRevision(ctx, orig.ID.Hex(), 1)
+			if err != nil || rev.Content != "first\n" || rev.Category != "ideas" {
+				t.Errorf("revision 1 = %+v, %v; want the original note", rev, err)
+			}
+		})
+	}
+}
+
+func TestRestoreRevision(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	orig := mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "v1"})
+	v2 := "v2"
+	if _, err := svc.Update(ctx, orig.ID.Hex(), UpdateNoteInput{Content: &v2}); err != nil {
+		t.Fatal(err)
+	}
+
+	note, err := svc.RestoreRevision(ctx, orig.ID.Hex(), 1, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if note.Content != "v1" || note.Version != 3 {
+		t.Errorf("restored note = %q at version %d, want %q at version 3", note.Content, note.Version, "v1")
+	}
+	revs, err := svc.ListRevisions(ctx, orig.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revs) != 2 || revs[0].Version != 2 || revs[1].Version != 1 {
+		t.Errorf("got %d revisions, want versions 2 and 1, newest first", len(revs))
+	}
+}
+
+func TestCategoryAccess(t *testing.T) {
+	svc := newTestService(t)
+	work := mustCreate(t, svc, CreateNoteInput{Category: "work", Content: "quarterly plan", Tags: []string{"plan"}})
+	private := mustCreate(t, svc, CreateNoteInput{Category: "private", Content: "holiday plan", Tags: []string{"plan", "holiday"}})
+	ctx := WithCategoryAccess(context.Background(), []string{"work"})
+
+	t.Run("get", func(t *testing.T) {
+		if _, err := svc.GetByID(ctx, work.ID.Hex()); err != nil {
+			t.Errorf("allowed note: %v", err)
+		}
+		if _, err := svc.GetByID(ctx, private.ID.Hex()); !errors.Is(err, ErrNoteNotFound) {
+			t.Errorf("hidden note: err = %v, want %v", err, ErrNoteNotFound)
+		}
+	})
+	t.Run("update and delete", func(t *testing.T) {
+		if _, err := svc.Update(ctx, private.ID.Hex(), UpdateNoteInput{Append: "x"}); !errors.Is(err, ErrNoteNotFound) {
+			t.Errorf("update hidden note: err = %v, want %v", err, ErrNoteNotFound)
+		}
+		moveTo := "private"
+		if _, err := svc.Update(ctx, work.ID.Hex(), UpdateNoteInput{Category: &moveTo}); !errors.Is(err, ErrForbidden) {
+			t.Errorf("move out of reach: err = %v, want %v", err, ErrForbidden)
+		}
+		if err := svc.Delete(ctx, private.ID.Hex()); !errors.Is(err, ErrNoteNotFound) {
+			t.Errorf("delete hidden note: err = %v, want %v", err, ErrNoteNotFound)
+		}
+	})
+	t.Run("list and search", func(t *testing.T) {
+		listed, err := svc.List(ctx, ListQuery{})
+		if err != nil || !slices.Equal(noteIDs(listed), []string{work.ID.Hex()}) {
+			t.Errorf("list = %v, %v; want only the work note", noteIDs(listed), err)
+		}
+		if listed, _ := svc.List(ctx, ListQuery{Category: "private"}); len(listed) != 0 {
+			t.Errorf("list of a hidden category returned %d notes", len(listed))
+		}
+		result, err := svc.Search(ctx, SearchQuery{Query: "plan"})
+		if err != nil || result.Total != 1 || result.Hits[0].ID != work.ID {
+			t.Errorf("search = %+v, %v; want only the work note", result, err)
+		}
+		recent, err := svc.GetRecent(ctx, SearchQuery{})
+		if err != nil || !slices.Equal(noteIDs(recent), []string{work.ID.Hex()}) {
+			t.Errorf("recent = %v, %v; want only the work note", noteIDs(recent), err)
+		}
+	})
+	t.Run("categories, tags and counts", func(t *testing.T) {
+		cats, err := svc.ListCategories(ctx)
+		if err != nil || len(cats) != 1 || cats[0].Name != "work" {
+			t.Errorf("categories = %v, %v; want only work", cats, err)
+		}
+		tags, err := svc.ListTags(ctx)
+		if err != nil || len(tags) != 1 || tags[0].Name != "plan" {
+			t.Errorf("tags = %v, %v; want only plan", tags, err)
+		}
+		if n, _ := svc.Count(ctx, ""); n != 1 {
+			t.Errorf("count = %d, want 1", n)
+		}
+		if n, _ := svc.Count(ctx, "private"); n != 0 {
+			t.Errorf("count of a hidden category = %d, want 0", n)
+		}
+	})
+}
+
+func TestSearch(t *testing.T) {
+	svc := newTestService(t)
+	rust := mustCreate(t, svc, CreateNoteInput{Category: "dev", Content: "Rust async runtimes: tokio and async-std", Tags: []string{"rust"}})
+	golang := mustCreate(t, svc, CreateNoteInput{Category: "dev", Content: "Go channels beat async callbacks", Tags: []string{"go"}})
+	recipe := mustCreate(t, svc, CreateNoteInput{Category: "cooking", Content: "Slow-cooked ragù", Tags: []string{"recipe", "rust"}})
+
+	tests := []struct {
+		name    string
+		q       SearchQuery
+		want    []*Note
+		wantErr error
+	}{
+		{name: "word", q: SearchQuery{Query: "async"}, want: []*Note{rust, golang}},
+		{name: "prefix", q: SearchQuery{Query: "runtime"}, want: []*Note{rust}},
+		{name: "phrase", q: SearchQuery{Query: `"async callbacks"`}, want: []*Note{golang}},
+		{name: "negation", q: SearchQuery{Query: "async -tokio"}, want: []*Note{golang}},
+		{name: "or", q: SearchQuery{Query: "tokio OR ragù"}, want: []*Note{recipe, rust}},
+		{name: "category filter", q: SearchQuery{Query: "category:cooking"}, want: []*Note{recipe}},
+		{name: "category param", q: SearchQuery{Category: "dev", Tags: []string{"rust"}}, want: []*Note{rust}},
+		{name: "any tag", q: SearchQuery{Tags: []string{"go", "recipe"}}, want: []*Note{recipe, golang}},
+		{name: "all tags", q: SearchQuery{Tags: []string{"rust", "recipe"}, TagMode: TagModeAll}, want: []*Note{recipe}},
+		{name: "no match", q: SearchQuery{Query: "python"}},
+		{name: "bad tag mode", q: SearchQuery{TagMode: "some"}, wantErr: ErrInvalidInput},
+		{name: "bad query", q: SearchQuery{Query: "rust OR"}, wantErr: ErrInvalidInput},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := svc.Search(context.Background(), tt.q)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, want := noteIDs(result.Notes()), noteIDs(tt.want); !slices.Equal(got, want) {
+				t.Errorf("hits = %v, want %v", got, want)
+			}
+			if result.Total != int64(len(tt.want)) || result.Hits == nil {
+				t.Errorf("total = %d with hits %v, want %d", result.Total, result.Hits, len(tt.want))
+			}
+		})
+	}
+}
+
+func TestSearchPaging(t *testing.T) {
+	svc := newTestService(t)
+	for i := 0; i < 5; i++ {
+		mustCreate(t, svc, CreateNoteInput{Category: "log", Content: "entry"})
+	}
+
+	result, err := svc.Search(context.Background(), SearchQuery{Query: "entry", Limit: 2, Offset: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Hits) != 2 || result.Total != 5 || !result.HasMore {
+		t.Errorf("got %d hits of %d, has more %v; want 2 of 5 with more", len(result.Hits), result.Total, result.HasMore)
+	}
+	result, _ = svc.Search(context.Background(), SearchQuery{Query: "entry", Limit: 2, Offset: 4})
+	if len(result.Hits) != 1 || result.HasMore {
+		t.Errorf("last page: got %d hits, has more %v; want 1 without more", len(result.Hits), result.HasMore)
+	}
+}
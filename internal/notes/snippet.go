@@ -0,0 +1,155 @@
+package notes
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// maxSnippets caps the excerpts returned per hit
+	maxSnippets = 3
+	// snippetContext is how many characters of content surround a match
+	snippetContext = 60
+	// snippetLead is the length of the opening excerpt given when no term
+	// matched, e.g. for queries made only of filters
+	snippetLead = 160
+)
+
+// highlighter finds the terms a search matched in note content
+type highlighter struct {
+	patterns []*regexp.Regexp
+	words    []bool // whether each pattern is a word, highlighted to its end
+}
+
+// newHighlighter prepares the words and phrases of a query, leaving out
+// those under a negation. expr may be nil.
+func newHighlighter(expr *queryNode) *highlighter {
+	h := &highlighter{}
+	var walk func(n *queryNode)
+	walk = func(n *queryNode) {
+		switch n.Kind {
+		case queryAnd, queryOr:
+			for _, c := range n.Children {
+				walk(c)
+			}
+		case queryText:
+			h.patterns = append(h.patterns, regexp.MustCompile(n.textPattern()))
+			h.words = append(h.words, !n.Phrase)
+		}
+	}
+	if expr != nil {
+		walk(expr)
+	}
+	return h
+}
+
+// snippets excerpts content around its matches, with whitespace collapsed.
+// Matches close together share an excerpt.
+func (h *highlighter) snippets(content string) []Snippet {
+	text := strings.Join(strings.Fields(content), " ")
+	matches := h.matches(text)
+	runes := []rune(text)
+	if len(matches) == 0 {
+		return []Snippet{excerpt(runes, 0, min(len(runes), snippetLead), nil)}
+	}
+
+	var snippets []Snippet
+	for len(matches) > 0 && len(snippets) < maxSnippets {
+		start := max(0, matches[0][0]-snippetContext)
+		end := min(len(runes), matches[0][1]+snippetContext)
+		n := 1
+		for n < len(matches) && matches[n][0] < end {
+			end = min(len(runes), max(end, matches[n][1]+snippetContext/2))
+			n++
+		}
+		start, end = snapToWords(runes, start, end, matches[0][0], matches[n-1][1])
+		snippets = append(snippets, excerpt(runes, start, end, matches[:n]))
+		matches = matches[n:]
+	}
+	return snippets
+}
+
+// matches returns the sorted, non-overlapping character ranges of text
+// matched by any pattern
+func (h *highlighter) matches(text string) [][2]int {
+	var ranges [][2]int
+	for i, re := range h.patterns {
+		for _, m := range re.FindAllStringIndex(text, -1) {
+			end := m[1]
+			if h.words[i] {
+				if n := strings.IndexFunc(text[end:], isNotWordRune); n >= 0 {
+					end += n
+				} else {
+					end = len(text)
+				}
+			}
+			if end > m[0] {
+				ranges = append(ranges, [2]int{
+					utf8.RuneCountInString(text[:m[0]]),
+					utf8.RuneCountInString(text[:end]),
+				})
+			}
+		}
+	}
+	slices.SortFunc(ranges, func(a, b [2]int) int { return a[0] - b[0] })
+
+	merged := ranges[:0]
+	for _, r := range ranges {
+		if last := len(merged) - 1; last >= 0 && r[0] <= merged[last][1] {
+			merged[last][1] = max(merged[last][1], r[1])
+		} else {
+			merged = append(merged, r)
+		}
+	}
+	return merged
+}
+
+func isNotWordRune(r rune) bool {
+	return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_'
+}
+
+// snapToWords moves the edges of an excerpt to the nearest spaces inward,
+// so it doesn't start or end mid-word, without cutting into [from, to)
+func snapToWords(runes []rune, start, end, from, to int) (int, int) {
+	if start > 0 {
+		for i := start; i < from; i++ {
+			if runes[i] == ' ' {
+				start = i + 1
+				break
+			}
+		}
+	}
+	if end < len(runes) {
+		for i := end; i > to; i-- {
+			if runes[i] == ' ' {
+				end = i
+				break
+			}
+		}
+	}
+	return start, end
+}
+
+// excerpt builds a snippet from runes[start:end], marking where it was cut
+// and shifting the matches within it to its own offsets
+func excerpt(runes []rune, start, end int, matches [][2]int) Snippet {
+	var b strings.Builder
+	offset := -start
+	if start > 0 {
+		b.WriteString("…")
+		offset++
+	}
+	b.WriteString(string(runes[start:end]))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+
+	highlights := make([][2]int, len(matches))
+	for i, m := range matches {
+		highlights[i] = [2]int{m[0] + offset, m[1] + offset}
+	}
+	return Snippet{Text: b.String(), Highlights: highlights}
+}
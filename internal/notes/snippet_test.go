@@ -0,0 +1,87 @@
+package notes
+
+import (
+	"strings"
+	"testing"
+)
+
+// marked renders a snippet with its highlights in brackets, reading the
+// offsets as characters the way clients do
+func marked(s Snippet) string {
+	runes := []rune(s.Text)
+	var b strings.Builder
+	last := 0
+	for _, h := range s.Highlights {
+		b.WriteString(string(runes[last:h[0]]))
+		b.WriteString("[" + string(runes[h[0]:h[1]]) + "]")
+		last = h[1]
+	}
+	b.WriteString(string(runes[last:]))
+	return b.String()
+}
+
+func TestSnippets(t *testing.T) {
+	long := strings.Repeat("filler ", 30)
+	tests := []struct {
+		name    string
+		query   string
+		content string
+		want    []string
+	}{
+		{"word prefix", "run", "We run,\n\nthen keep   running.", []string{"We [run], then keep [running]."}},
+		{"phrase", `"red fox"`, "A red foxes den by the red fox.", []string{"A [red fox]es den by the [red fox]."}},
+		{"negated term", "go -rust", "go beats rust", []string{"[go] beats rust"}},
+		{"overlapping terms", "note notes", "notes on notes", []string{"[notes] on [notes]"}},
+		{"accented", "café", "Le café crème, café noir", []string{"Le [café] crème, [café] noir"}},
+		{"multibyte word", "テキスト", "日本語 テキストです 以上", []string{"日本語 [テキストです] 以上"}},
+		{"multibyte phrase", `"テキスト"`, "日本語のテキストです", []string{"日本語の[テキスト]です"}},
+		{"emoji before a match", "ship", "🚀🚀 ship it", []string{"🚀🚀 [ship] it"}},
+		{"cut on both sides", "needle", long + "ünïcode needle here " + long, []string{
+			"…filler filler filler filler filler filler filler ünïcode [needle] here filler filler filler filler filler filler filler…",
+		}},
+		{"far apart", "alpha omega", "alpha " + long + "omega", []string{
+			"[alpha] filler filler filler filler filler filler filler filler…",
+			"…filler filler filler filler filler filler filler filler [omega]",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expr, err := parseSearchQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			snippets := newHighlighter(expr).snippets(tt.content)
+			if len(snippets) != len(tt.want) {
+				t.Fatalf("got %d snippets %q, want %d", len(snippets), snippets, len(tt.want))
+			}
+			for i, s := range snippets {
+				if got := marked(s); got != tt.want[i] {
+					t.Errorf("snippet %d = %q, want %q", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSnippetsWithoutMatches(t *testing.T) {
+	content := strings.Repeat("é", snippetLead+10)
+	snippets := newHighlighter(nil).snippets(content)
+	if len(snippets) != 1 || len(snippets[0].Highlights) != 0 {
+		t.Fatalf("got %v, want one snippet without highlights", snippets)
+	}
+	if want := strings.Repeat("é", snippetLead) + "…"; snippets[0].Text != want {
+		t.Errorf("lead = %q, want the first %d characters", snippets[0].Text, snippetLead)
+	}
+}
+
+func TestSnippetsCapped(t *testing.T) {
+	expr, err := parseSearchQuery("x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	gap := " " + strings.Repeat("filler ", 40)
+	content := strings.Repeat("x"+gap, maxSnippets+2)
+	if n := len(newHighlighter(expr).snippets(content)); n != maxSnippets {
+		t.Errorf("got %d snippets, want %d", n, maxSnippets)
+	}
+}
@@ -0,0 +1,1099 @@
+package notes
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"scratchpad/internal/workspace"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// sqliteMigrations are applied in order; PRAGMA user_version records how
+// many have run. Append new entries, never edit existing ones.
+var sqliteMigrations = []string{
+	`CREATE TABLE notes (
+		seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+		id         TEXT    NOT NULL UNIQUE,
+		category   TEXT    NOT NULL,
+		content    TEXT    NOT NULL,
+		created_at INTEGER NOT NULL,
+		updated_at INTEGER NOT NULL
+	);
+	CREATE INDEX idx_notes_created_at ON notes(created_at DESC);
+	CREATE INDEX idx_notes_category_created_at ON notes(category, created_at DESC);
+
+	CREATE VIRTUAL TABLE notes_fts USING fts5(
+		content,
+		content='notes',
+		content_rowid='seq',
+		tokenize='porter unicode61'
+	);
+	CREATE TRIGGER notes_fts_ai AFTER INSERT ON notes BEGIN
+		INSERT INTO notes_fts(rowid, content) VALUES (new.seq, new.content);
+	END;
+	CREATE TRIGGER notes_fts_ad AFTER DELETE ON notes BEGIN
+		INSERT INTO notes_fts(notes_fts, rowid, content) VALUES ('delete', old.seq, old.content);
+	END;
+	CREATE TRIGGER notes_fts_au AFTER UPDATE OF content ON notes BEGIN
+		INSERT INTO notes_fts(notes_fts, rowid, content) VALUES ('delete', old.seq, old.content);
+		INSERT INTO notes_fts(rowid, content) VALUES (new.seq, new.content);
+	END;`,
+
+	`ALTER TABLE notes ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+
+	`ALTER TABLE notes ADD COLUMN author TEXT NOT NULL DEFAULT '';
+	CREATE TABLE note_revisions (
+		note_id    TEXT    NOT NULL REFERENCES notes(id) ON DELETE CASCADE,
+		version    INTEGER NOT NULL,
+		category   TEXT    NOT NULL,
+		content    TEXT    NOT NULL,
+		author     TEXT    NOT NULL DEFAULT '',
+		created_at INTEGER NOT NULL,
+		PRIMARY KEY (note_id, version)
+	);`,
+
+	`ALTER TABLE notes ADD COLUMN deleted_at INTEGER;
+	CREATE INDEX idx_notes_deleted_at ON notes(deleted_at) WHERE deleted_at IS NOT NULL;`,
+
+	// Tags are a JSON array, queried through json_each
+	`ALTER TABLE notes ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';`,
+
+	// Every query is scoped to a workspace, so indexes lead with it
+	`ALTER TABLE notes ADD COLUMN workspace TEXT NOT NULL DEFAULT 'default';
+	DROP INDEX idx_notes_created_at;
+	DROP INDEX idx_notes_category_created_at;
+	CREATE INDEX idx_notes_workspace_created_at ON notes(workspace, created_at DESC);
+	CREATE INDEX idx_notes_workspace_category_created_at ON notes(workspace, category, created_at DESC);`,
+
+	`CREATE TABLE idempotency_keys (
+		workspace    TEXT    NOT NULL,
+		key          TEXT    NOT NULL,
+		request_hash TEXT    NOT NULL,
+		status       INTEGER NOT NULL DEFAULT 0,
+		response     BLOB,
+		created_at   INTEGER NOT NULL,
+		expires_at   INTEGER NOT NULL,
+		PRIMARY KEY (workspace, key)
+	);
+	CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);`,
+
+	// Existing rows are hashed by backfillFingerprints
+	`ALTER TABLE notes ADD COLUMN content_hash TEXT NOT NULL DEFAULT '';
+	ALTER TABLE notes ADD COLUMN simhash INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX idx_notes_workspace_category_content_hash ON notes(workspace, category, content_hash);`,
+
+	// Filled in by the Service as semantic search needs them
+	`ALTER TABLE notes ADD COLUMN embedding_model TEXT NOT NULL DEFAULT '';
+	ALTER TABLE notes ADD COLUMN embedding_hash TEXT NOT NULL DEFAULT '';
+	ALTER TABLE notes ADD COLUMN embedding BLOB;`,
+}
+
+const sqliteNoteColumns = "n.id, n.workspace, n.category, n.content, n.tags, n.created_at, n.updated_at, n.version, n.author, n.deleted_at, n.content_hash, n.simhash"
+
+// SQLiteRepo is a NoteStore backed by an embedded SQLite database, using
+// FTS5 for full-text search.
+type SQLiteRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteRepo(db *sql.DB) *SQLiteRepo {
+	return &SQLiteRepo{db: db}
+}
+
+// EnsureIndexes creates tables, indexes and the FTS5 index by running any
+// pending schema migrations
+func (r *SQLiteRepo) EnsureIndexes(ctx context.Context) error {
+	var version int
+	if err := r.db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("read schema version: %w", err)
+	}
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := r.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin migration %d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("apply migration %d: %w", i+1, err)
+		}
+		// PRAGMA does not accept bound parameters
+		if _, err := tx.ExecContext(ctx, fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("set schema version %d: %w", i+1, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %d: %w", i+1, err)
+		}
+	}
+	return r.backfillFingerprints(ctx)
+}
+
+// backfillFingerprints hashes the content of notes written before content
+// hashes existed, in every workspace and including the trash
+func (r *SQLiteRepo) backfillFingerprints(ctx context.Context) error {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, content FROM notes WHERE content_hash = ''")
+	if err != nil {
+		return fmt.Errorf("find notes to fingerprint: %w", err)
+	}
+	type pendingNote struct {
+		id string
+		n  Note
+	}
+	var pending []*pendingNote
+	for rows.Next() {
+		p := &pendingNote{}
+		if err := rows.Scan(&p.id, &p.n.Content); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan note to fingerprint: %w", err)
+		}
+		p.n.fingerprint()
+		pending = append(pending, p)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("find notes to fingerprint: %w", err)
+	}
+
+	// Written after the scan so updates never run under an open read
+	for _, p := range pending {
+		_, err := r.db.ExecContext(ctx,
+			"UPDATE notes SET content_hash = ?, simhash = ? WHERE id = ?", p.n.ContentHash, p.n.SimHash, p.id)
+		if err != nil {
+			return fmt.Errorf("fingerprint note %s: %w", p.id, err)
+		}
+	}
+	return nil
+}
+
+// Insert creates a new note in the context's workspace
+func (r *SQLiteRepo) Insert(ctx context.Context, n *Note) error {
+	n.ID = primitive.NewObjectID()
+	n.Workspace = workspace.FromContext(ctx)
+	n.CreatedAt = time.Now()
+	n.UpdatedAt = n.CreatedAt
+	n.Version = 1
+
+	_, err := r.db.ExecContext(ctx,
+		`INSERT INTO notes (id, workspace, category, content, tags, created_at, updated_at, version, author, content_hash, simhash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		n.ID.Hex(), n.Workspace, n.Category, n.Content, encodeTags(n.Tags),
+		n.CreatedAt.UnixMilli(), n.UpdatedAt.UnixMilli(), n.Version, n.Author, n.ContentHash, n.SimHash,
+	)
+	if err != nil {
+		return fmt.Errorf("insert note: %w", err)
+	}
+	return nil
+}
+
+// InsertMany creates notes in one transaction, so either all are stored or
+// none are
+func (r *SQLiteRepo) InsertMany(ctx context.Context, notes []*Note) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("insert notes: %w", err)
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx,
+		`INSERT INTO notes (id, workspace, category, content, tags, created_at, updated_at, version, author, content_hash, simhash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return 0, fmt.Errorf("insert notes: %w", err)
+	}
+	defer stmt.Close()
+
+	now := time.Now()
+	for _, n := range notes {
+		n.ID = primitive.NewObjectID()
+		n.Workspace = workspace.FromContext(ctx)
+		n.CreatedAt = now
+		n.UpdatedAt = now
+		n.Version = 1
+
+		_, err := stmt.ExecContext(ctx,
+			n.ID.Hex(), n.Workspace, n.Category, n.Content, encodeTags(n.Tags),
+			n.CreatedAt.UnixMilli(), n.UpdatedAt.UnixMilli(), n.Version, n.Author, n.ContentHash, n.SimHash,
+		)
+		if err != nil {
+			return 0, fmt.Errorf("insert notes: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("insert notes: %w", err)
+	}
+	return len(notes), nil
+}
+
+// FindByID retrieves a note by its ID
+func (r *SQLiteRepo) FindByID(ctx context.Context, id primitive.ObjectID) (*Note, error) {
+	w := liveWhere(ctx)
+	w.add("n.id = ?", id.Hex())
+	row := r.db.QueryRowContext(ctx, "SELECT "+sqliteNoteColumns+" FROM notes n"+w.clause(), w.args...)
+
+	note, err := scanSQLiteNote(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find note %s: %w", id.Hex(), err)
+	}
+	return note, nil
+}
+
+// Update writes n's category, content and tags if the stored version still equals
+// expectedVersion, bumping version and updated_at
+func (r *SQLiteRepo) Update(ctx context.Context, n *Note, expectedVersion int64) error {
+	now := time.Now()
+	w := liveWhere(ctx)
+	w.add("n.id = ?", n.ID.Hex())
+	args := []any{n.Category, n.Content, encodeTags(n.Tags), n.Author, n.ContentHash, n.SimHash, now.UnixMilli(), expectedVersion + 1}
+	args = append(args, w.args...)
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE notes AS n SET category = ?, content = ?, tags = ?, author = ?, content_hash = ?, simhash = ?, updated_at = ?, version = ?"+
+			w.clause()+" AND n.version = ?",
+		append(args, expectedVersion)...,
+	)
+	if err != nil {
+		return fmt.Errorf("update note: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update note: %w", err)
+	}
+	if affected == 0 {
+		var exists int
+		err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM notes n"+w.clause(), w.args...).Scan(&exists)
+		if err != nil {
+			return fmt.Errorf("update note: %w", err)
+		}
+		if exists == 0 {
+			return ErrNoteNotFound
+		}
+		return ErrVersionConflict
+	}
+
+	n.UpdatedAt = now
+	n.Version = expectedVersion + 1
+	return nil
+}
+
+// List retrieves notes with optional category filter, sorted by created_at desc
+func (r *SQLiteRepo) List(ctx context.Context, q ListQuery) ([]*Note, error) {
+	w := liveWhere(ctx)
+	w.addCategory(q.Category, q.Categories)
+	w.addTags(q.Tags, q.TagMode)
+
+	query := "SELECT " + sqliteNoteColumns + " FROM notes n" + w.clause() +
+		" ORDER BY n.created_at DESC LIMIT ? OFFSET ?"
+	args := append(w.args, clampLimit(q.Limit, 50, 200), q.Offset)
+
+	notes, err := r.queryNotes(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list notes: %w", err)
+	}
+	return notes, nil
+}
+
+// Search performs a search query with optional filters. Results are
+// ranked by bm25 over the query's ranked terms (see rankedTerms), and
+// newest first without any.
+func (r *SQLiteRepo) Search(ctx context.Context, q SearchQuery) (*SearchResult, error) {
+	w := liveWhere(ctx)
+	score := ", 0"
+	from := " FROM notes n"
+	order := " ORDER BY n.created_at DESC"
+
+	// Search query
+	if q.expr != nil {
+		terms, any, rest := q.expr.rankedTerms()
+		if len(terms) > 0 {
+			match := make([]string, len(terms))
+			for i, t := range terms {
+				match[i] = ftsTerm(t)
+			}
+			op := " AND "
+			if any {
+				op = " OR "
+			}
+			// bm25 is lower for better matches
+			score = ", -bm25(notes_fts)"
+			from += " JOIN notes_fts ON notes_fts.rowid = n.seq"
+			w.add("notes_fts MATCH ?", strings.Join(match, op))
+			order = " ORDER BY bm25(notes_fts), n.created_at DESC"
+		}
+		if rest != nil {
+			cond, args := sqliteQuery(rest)
+			w.add(cond, args...)
+		}
+	}
+
+	// Category filter
+	w.addCategory(q.Category, q.Categories)
+
+	// Tag filter
+	w.addTags(q.Tags, q.TagMode)
+
+	// Date range filter
+	if q.Since != nil {
+		w.add("n.created_at >= ?", q.Since.UnixMilli())
+	}
+	if q.Until != nil {
+		w.add("n.created_at <= ?", q.Until.UnixMilli())
+	}
+
+	query := "SELECT " + sqliteNoteColumns + score + from + w.clause() + order + " LIMIT ? OFFSET ?"
+	args := append(w.args, clampLimit(q.Limit, 50, 200), q.Offset)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("search notes: %w", err)
+	}
+	defer rows.Close()
+
+	result := &SearchResult{}
+	for rows.Next() {
+		hit := &SearchHit{}
+		if hit.Note, err = scanSQLiteNote(scoreScanner{rows, &hit.Score}); err != nil {
+			return nil, fmt.Errorf("search notes: %w", err)
+		}
+		result.Hits = append(result.Hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("search notes: %w", err)
+	}
+
+	err = r.db.QueryRowContext(ctx, "SELECT COUNT(*)"+from+w.clause(), w.args...).Scan(&result.Total)
+	if err != nil {
+		return nil, fmt.Errorf("count search results: %w", err)
+	}
+	if !q.Facets {
+		return result, nil
+	}
+
+	facets := &SearchFacets{}
+	queries := []struct {
+		counts *[]FacetCount
+		query  string
+	}{
+		{&facets.Categories, "SELECT n.category AS value, COUNT(*) AS count" + from + w.clause() +
+			" GROUP BY value ORDER BY count DESC, value LIMIT ?"},
+		{&facets.Tags, "SELECT t.value AS value, COUNT(*) AS count" + from + ", json_each(n.tags) t" + w.clause() +
+			" GROUP BY value ORDER BY count DESC, value LIMIT ?"},
+		{&facets.Months, "SELECT strftime('%Y-%m', n.created_at / 1000, 'unixepoch') AS value, COUNT(*) AS count" + from + w.clause() +
+			" GROUP BY value ORDER BY value DESC LIMIT ?"},
+	}
+	for _, fq := range queries {
+		if *fq.counts, err = r.queryFacet(ctx, fq.query, append(w.args, MaxFacetValues)...); err != nil {
+			return nil, fmt.Errorf("count search facets: %w", err)
+		}
+	}
+	result.Facets = facets
+	return result, nil
+}
+
+// queryFacet reads value, count rows
+func (r *SQLiteRepo) queryFacet(ctx context.Context, query string, args ...any) ([]FacetCount, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []FacetCount
+	for rows.Next() {
+		var fc FacetCount
+		if err := rows.Scan(&fc.Value, &fc.Count); err != nil {
+			return nil, err
+		}
+		counts = append(counts, fc)
+	}
+	return counts, rows.Err()
+}
+
+// GetRecent retrieves most recent notes across all categories
+func (r *SQLiteRepo) GetRecent(ctx context.Context, limit int, since *time.Time) ([]*Note, error) {
+	w := liveWhere(ctx)
+	if since != nil {
+		w.add("n.created_at >= ?", since.UnixMilli())
+	}
+
+	query := "SELECT " + sqliteNoteColumns + " FROM notes n" + w.clause() +
+		" ORDER BY n.created_at DESC LIMIT ?"
+	args := append(w.args, clampLimit(limit, 20, 100))
+
+	notes, err := r.queryNotes(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("get recent notes: %w", err)
+	}
+	return notes, nil
+}
+
+// ListCategories returns all categories with counts and last note time
+func (r *SQLiteRepo) ListCategories(ctx context.Context) ([]*Category, error) {
+	w := liveWhere(ctx)
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT n.category, COUNT(*), MAX(n.created_at) AS last_note FROM notes n"+w.clause()+
+			" GROUP BY n.category ORDER BY last_note DESC", w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("aggregate categories: %w", err)
+	}
+	defer rows.Close()
+
+	var categories []*Category
+	for rows.Next() {
+		var cat Category
+		var lastNote int64
+		if err := rows.Scan(&cat.Name, &cat.Count, &lastNote); err != nil {
+			return nil, fmt.Errorf("decode categories: %w", err)
+		}
+		cat.LastNote = time.UnixMilli(lastNote).UTC()
+		categories = append(categories, &cat)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("decode categories: %w", err)
+	}
+	return categories, nil
+}
+
+// ListTags returns all tags on live notes with usage counts, most used first
+func (r *SQLiteRepo) ListTags(ctx context.Context, categories []string) ([]*Tag, error) {
+	w := liveWhere(ctx)
+	w.addCategory("", categories)
+
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT t.value, COUNT(*) AS count FROM notes n, json_each(n.tags) t"+w.clause()+
+			" GROUP BY t.value ORDER BY count DESC, t.value", w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("aggregate tags: %w", err)
+	}
+	defer rows.Close()
+
+	var tags []*Tag
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, fmt.Errorf("decode tags: %w", err)
+		}
+		tags = append(tags, &tag)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("decode tags: %w", err)
+	}
+	return tags, nil
+}
+
+// Delete moves a note to the trash by setting deleted_at
+func (r *SQLiteRepo) Delete(ctx context.Context, id primitive.ObjectID) error {
+	w := liveWhere(ctx)
+	w.add("n.id = ?", id.Hex())
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE notes AS n SET deleted_at = ?"+w.clause(), append([]any{time.Now().UnixMilli()}, w.args...)...)
+	if err != nil {
+		return fmt.Errorf("delete note: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("delete note: %w", err)
+	}
+	if affected == 0 {
+		return ErrNoteNotFound
+	}
+	return nil
+}
+
+// Restore takes a note back out of the trash
+func (r *SQLiteRepo) Restore(ctx context.Context, id primitive.ObjectID) error {
+	w := trashWhere(ctx)
+	w.add("n.id = ?", id.Hex())
+	result, err := r.db.ExecContext(ctx, "UPDATE notes AS n SET deleted_at = NULL"+w.clause(), w.args...)
+	if err != nil {
+		return fmt.Errorf("restore note: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("restore note: %w", err)
+	}
+	if affected == 0 {
+		return ErrNoteNotFound
+	}
+	return nil
+}
+
+// FindTrashed retrieves a note from the trash by its ID
+func (r *SQLiteRepo) FindTrashed(ctx context.Context, id primitive.ObjectID) (*Note, error) {
+	w := trashWhere(ctx)
+	w.add("n.id = ?", id.Hex())
+	row := r.db.QueryRowContext(ctx, "SELECT "+sqliteNoteColumns+" FROM notes n"+w.clause(), w.args...)
+	note, err := scanSQLiteNote(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find trashed note: %w", err)
+	}
+	return note, nil
+}
+
+// ListTrash retrieves trashed notes, most recently deleted first
+func (r *SQLiteRepo) ListTrash(ctx context.Context, q ListQuery) ([]*Note, error) {
+	w := trashWhere(ctx)
+	w.addCategory(q.Category, q.Categories)
+
+	query := "SELECT " + sqliteNoteColumns + " FROM notes n" + w.clause() +
+		" ORDER BY n.deleted_at DESC LIMIT ? OFFSET ?"
+	args := append(w.args, clampLimit(q.Limit, 50, 200), q.Offset)
+
+	notes, err := r.queryNotes(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list trash: %w", err)
+	}
+	return notes, nil
+}
+
+// Purge permanently removes notes trashed before the cutoff in every
+// workspace; their revisions go with them via ON DELETE CASCADE
+func (r *SQLiteRepo) Purge(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx,
+		"DELETE FROM notes WHERE deleted_at IS NOT NULL AND deleted_at < ?", before.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("purge notes: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("purge notes: %w", err)
+	}
+	return affected, nil
+}
+
+// Count returns the total number of notes, optionally filtered by category
+func (r *SQLiteRepo) Count(ctx context.Context, category string) (int64, error) {
+	w := liveWhere(ctx)
+	if category != "" {
+		w.add("n.category = ?", category)
+	}
+
+	var count int64
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM notes n"+w.clause(), w.args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count notes: %w", err)
+	}
+	return count, nil
+}
+
+// exportPageSize is how many notes Export reads per query, so a slow
+// consumer never holds a read open for long
+const exportPageSize = 500
+
+// Export streams live notes matching q to fn, oldest first, paging by
+// (created_at, id)
+func (r *SQLiteRepo) Export(ctx context.Context, q ExportQuery, fn func(*Note) error) error {
+	w := liveWhere(ctx)
+	w.addCategory(q.Category, q.Categories)
+	if q.Since != nil {
+		w.add("n.created_at >= ?", q.Since.UnixMilli())
+	}
+	if q.Until != nil {
+		w.add("n.created_at < ?", q.Until.UnixMilli())
+	}
+
+	clause, args := w.clause(), w.args
+	for {
+		query := "SELECT " + sqliteNoteColumns + " FROM notes n" + clause + " ORDER BY n.created_at, n.id LIMIT ?"
+		notes, err := r.queryNotes(ctx, query, append(slices.Clip(args), exportPageSize)...)
+		if err != nil {
+			return fmt.Errorf("export notes: %w", err)
+		}
+
+		for _, n := range notes {
+			if err := fn(n); err != nil {
+				return err
+			}
+		}
+		if len(notes) < exportPageSize {
+			return nil
+		}
+		last := notes[len(notes)-1]
+		clause = w.clause() + " AND (n.created_at, n.id) > (?, ?)"
+		args = append(slices.Clip(w.args), last.CreatedAt.UnixMilli(), last.ID.Hex())
+	}
+}
+
+// ImportNote inserts n as given, or replaces the note with its ID in the
+// context's workspace when overwrite is set
+func (r *SQLiteRepo) ImportNote(ctx context.Context, n *Note, overwrite bool) (bool, error) {
+	n.Workspace = workspace.FromContext(ctx)
+	n.DeletedAt = nil
+
+	if overwrite {
+		result, err := r.db.ExecContext(ctx,
+			`UPDATE notes SET category = ?, content = ?, tags = ?, created_at = ?, updated_at = ?, version = ?,
+			author = ?, content_hash = ?, simhash = ?, deleted_at = NULL WHERE id = ? AND workspace = ?`,
+			n.Category, n.Content, encodeTags(n.Tags), n.CreatedAt.UnixMilli(), n.UpdatedAt.UnixMilli(), n.Version,
+			n.Author, n.ContentHash, n.SimHash, n.ID.Hex(), n.Workspace,
+		)
+		if err != nil {
+			return false, fmt.Errorf("import note %s: %w", n.ID.Hex(), err)
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return false, fmt.Errorf("import note %s: %w", n.ID.Hex(), err)
+		} else if affected == 1 {
+			return true, nil
+		}
+	}
+
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO notes (id, workspace, category, content, tags, created_at, updated_at, version, author, content_hash, simhash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (id) DO NOTHING`,
+		n.ID.Hex(), n.Workspace, n.Category, n.Content, encodeTags(n.Tags),
+		n.CreatedAt.UnixMilli(), n.UpdatedAt.UnixMilli(), n.Version, n.Author, n.ContentHash, n.SimHash,
+	)
+	if err != nil {
+		return false, fmt.Errorf("import note %s: %w", n.ID.Hex(), err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("import note %s: %w", n.ID.Hex(), err)
+	}
+	if affected == 0 {
+		return false, ErrNoteExists
+	}
+	return false, nil
+}
+
+// FindByContentHash returns the newest live note in category whose content
+// hashes to hash
+func (r *SQLiteRepo) FindByContentHash(ctx context.Context, category, hash string) (*Note, error) {
+	w := liveWhere(ctx)
+	w.add("n.category = ?", category)
+	w.add("n.content_hash = ?", hash)
+
+	row := r.db.QueryRowContext(ctx,
+		"SELECT "+sqliteNoteColumns+" FROM notes n"+w.clause()+" ORDER BY n.created_at DESC LIMIT 1", w.args...)
+	note, err := scanSQLiteNote(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find note by content hash: %w", err)
+	}
+	return note, nil
+}
+
+// ListFingerprints returns the fingerprints of live notes, optionally
+// filtered by category
+func (r *SQLiteRepo) ListFingerprints(ctx context.Context, category string, categories []string) ([]*Fingerprint, error) {
+	w := liveWhere(ctx)
+	w.addCategory(category, categories)
+
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT n.id, n.category, n.content_hash, n.simhash, n.created_at FROM notes n"+w.clause(), w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("list fingerprints: %w", err)
+	}
+	defer rows.Close()
+
+	var fps []*Fingerprint
+	for rows.Next() {
+		var fp Fingerprint
+		var id string
+		var createdAt int64
+		if err := rows.Scan(&id, &fp.Category, &fp.ContentHash, &fp.SimHash, &createdAt); err != nil {
+			return nil, fmt.Errorf("scan fingerprint: %w", err)
+		}
+		if fp.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+			return nil, fmt.Errorf("decode note id %q: %w", id, err)
+		}
+		fp.CreatedAt = time.UnixMilli(createdAt).UTC()
+		fps = append(fps, &fp)
+	}
+	return fps, rows.Err()
+}
+
+// ListEmbeddings returns the embedding state of live notes
+func (r *SQLiteRepo) ListEmbeddings(ctx context.Context) ([]*NoteEmbedding, error) {
+	w := liveWhere(ctx)
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT n.id, n.category, n.content_hash, n.created_at, n.embedding_model, n.embedding_hash, n.embedding
+		FROM notes n`+w.clause(), w.args...)
+	if err != nil {
+		return nil, fmt.Errorf("list embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	var embeddings []*NoteEmbedding
+	for rows.Next() {
+		var ne NoteEmbedding
+		var id, model, hash string
+		var createdAt int64
+		var vector []byte
+		if err := rows.Scan(&id, &ne.Category, &ne.ContentHash, &createdAt, &model, &hash, &vector); err != nil {
+			return nil, fmt.Errorf("scan embedding: %w", err)
+		}
+		if ne.ID, err = primitive.ObjectIDFromHex(id); err != nil {
+			return nil, fmt.Errorf("decode note id %q: %w", id, err)
+		}
+		ne.CreatedAt = time.UnixMilli(createdAt).UTC()
+		if model != "" {
+			v, err := vectorFromBytes(vector)
+			if err != nil {
+				return nil, err
+			}
+			ne.Embedding = &Embedding{Model: model, ContentHash: hash, Vector: v}
+		}
+		embeddings = append(embeddings, &ne)
+	}
+	return embeddings, rows.Err()
+}
+
+// SaveEmbedding stores a note's embedding alongside it
+func (r *SQLiteRepo) SaveEmbedding(ctx context.Context, id primitive.ObjectID, e *Embedding) error {
+	w := liveWhere(ctx)
+	w.add("n.id = ?", id.Hex())
+	result, err := r.db.ExecContext(ctx,
+		"UPDATE notes AS n SET embedding_model = ?, embedding_hash = ?, embedding = ?"+w.clause(),
+		append([]any{e.Model, e.ContentHash, e.Vector.bytes()}, w.args...)...)
+	if err != nil {
+		return fmt.Errorf("save embedding: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("save embedding: %w", err)
+	}
+	if affected == 0 {
+		return ErrNoteNotFound
+	}
+	return nil
+}
+
+// SaveRevision stores a snapshot of a note version, ignoring versions that
+// are already saved
+func (r *SQLiteRepo) SaveRevision(ctx context.Context, rev *Revision) error {
+	_, err := r.db.ExecContext(ctx,
+		`INSERT OR IGNORE INTO note_revisions (note_id, version, category, content, author, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		rev.NoteID.Hex(), rev.Version, rev.Category, rev.Content, rev.Author, rev.CreatedAt.UnixMilli(),
+	)
+	if err != nil {
+		return fmt.Errorf("save revision: %w", err)
+	}
+	return nil
+}
+
+// ListRevisions returns a note's stored revisions, newest first
+func (r *SQLiteRepo) ListRevisions(ctx context.Context, noteID primitive.ObjectID) ([]*Revision, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`SELECT version, category, content, author, created_at FROM note_revisions
+		WHERE note_id = ? ORDER BY version DESC`, noteID.Hex())
+	if err != nil {
+		return nil, fmt.Errorf("list revisions: %w", err)
+	}
+	defer rows.Close()
+
+	var revisions []*Revision
+	for rows.Next() {
+		rev, err := scanSQLiteRevision(rows, noteID)
+		if err != nil {
+			return nil, fmt.Errorf("decode revisions: %w", err)
+		}
+		revisions = append(revisions, rev)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("decode revisions: %w", err)
+	}
+	return revisions, nil
+}
+
+// FindRevision retrieves a single revision of a note
+func (r *SQLiteRepo) FindRevision(ctx context.Context, noteID primitive.ObjectID, version int64) (*Revision, error) {
+	row := r.db.QueryRowContext(ctx,
+		`SELECT version, category, content, author, created_at FROM note_revisions
+		WHERE note_id = ? AND version = ?`, noteID.Hex(), version)
+
+	rev, err := scanSQLiteRevision(row, noteID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find revision %s@%d: %w", noteID.Hex(), version, err)
+	}
+	return rev, nil
+}
+
+// ClaimIdempotencyKey stores rec unless the workspace already holds a live
+// record for its key, which is returned instead. Expired records, in any
+// workspace, are swept first.
+func (r *SQLiteRepo) ClaimIdempotencyKey(ctx context.Context, rec *IdempotencyRecord) (*IdempotencyRecord, error) {
+	rec.Workspace = workspace.FromContext(ctx)
+	rec.CreatedAt = time.Now()
+
+	if _, err := r.db.ExecContext(ctx,
+		"DELETE FROM idempotency_keys WHERE expires_at <= ?", rec.CreatedAt.UnixMilli()); err != nil {
+		return nil, fmt.Errorf("expire idempotency keys: %w", err)
+	}
+
+	result, err := r.db.ExecContext(ctx,
+		`INSERT INTO idempotency_keys (workspace, key, request_hash, created_at, expires_at)
+		VALUES (?, ?, ?, ?, ?) ON CONFLICT DO NOTHING`,
+		rec.Workspace, rec.Key, rec.RequestHash, rec.CreatedAt.UnixMilli(), rec.ExpiresAt.UnixMilli(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("claim idempotency key: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return nil, fmt.Errorf("claim idempotency key: %w", err)
+	} else if affected == 1 {
+		return nil, nil
+	}
+
+	existing := IdempotencyRecord{Workspace: rec.Workspace, Key: rec.Key}
+	var createdAt, expiresAt int64
+	err = r.db.QueryRowContext(ctx,
+		`SELECT request_hash, status, response, created_at, expires_at FROM idempotency_keys
+		WHERE workspace = ? AND key = ?`, rec.Workspace, rec.Key,
+	).Scan(&existing.RequestHash, &existing.Status, &existing.Response, &createdAt, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Released by a failed request between our insert and this read
+		return nil, ErrIdempotencyInFlight
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find idempotency key: %w", err)
+	}
+	existing.CreatedAt = time.UnixMilli(createdAt)
+	existing.ExpiresAt = time.UnixMilli(expiresAt)
+	return &existing, nil
+}
+
+// CompleteIdempotencyKey records the response for a claimed key
+func (r *SQLiteRepo) CompleteIdempotencyKey(ctx context.Context, key string, status int, response []byte) error {
+	_, err := r.db.ExecContext(ctx,
+		"UPDATE idempotency_keys SET status = ?, response = ? WHERE workspace = ? AND key = ?",
+		status, response, workspace.FromContext(ctx), key,
+	)
+	if err != nil {
+		return fmt.Errorf("complete idempotency key: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey drops a claimed key that has no response yet
+func (r *SQLiteRepo) ReleaseIdempotencyKey(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx,
+		"DELETE FROM idempotency_keys WHERE workspace = ? AND key = ? AND status = 0",
+		workspace.FromContext(ctx), key,
+	)
+	if err != nil {
+		return fmt.Errorf("release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// --- Helpers ---
+
+func (r *SQLiteRepo) queryNotes(ctx context.Context, query string, args ...any) ([]*Note, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notes []*Note
+	for rows.Next() {
+		note, err := scanSQLiteNote(rows)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, note)
+	}
+	return notes, rows.Err()
+}
+
+type sqliteScanner interface {
+	Scan(dest ...any) error
+}
+
+// scoreScanner reads a score column following the note columns
+type scoreScanner struct {
+	sqliteScanner
+	score *float64
+}
+
+func (s scoreScanner) Scan(dest ...any) error {
+	return s.sqliteScanner.Scan(append(dest, s.score)...)
+}
+
+func scanSQLiteNote(s sqliteScanner) (*Note, error) {
+	var note Note
+	var id, tags string
+	var createdAt, updatedAt int64
+	var deletedAt sql.NullInt64
+	if err := s.Scan(&id, &note.Workspace, &note.Category, &note.Content, &tags, &createdAt, &updatedAt, &note.Version, &note.Author, &deletedAt, &note.ContentHash, &note.SimHash); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(tags), &note.Tags); err != nil {
+		return nil, fmt.Errorf("decode note tags: %w", err)
+	}
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("decode note id %q: %w", id, err)
+	}
+	note.ID = oid
+	note.CreatedAt = time.UnixMilli(createdAt).UTC()
+	note.UpdatedAt = time.UnixMilli(updatedAt).UTC()
+	if deletedAt.Valid {
+		t := time.UnixMilli(deletedAt.Int64).UTC()
+		note.DeletedAt = &t
+	}
+	return &note, nil
+}
+
+func scanSQLiteRevision(s sqliteScanner, noteID primitive.ObjectID) (*Revision, error) {
+	rev := Revision{NoteID: noteID}
+	var createdAt int64
+	if err := s.Scan(&rev.Version, &rev.Category, &rev.Content, &rev.Author, &createdAt); err != nil {
+		return nil, err
+	}
+	rev.CreatedAt = time.UnixMilli(createdAt).UTC()
+	return &rev, nil
+}
+
+// encodeTags stores tags as a JSON array, never null
+func encodeTags(tags []string) string {
+	if len(tags) == 0 {
+		return "[]"
+	}
+	data, _ := json.Marshal(tags)
+	return string(data)
+}
+
+// sqliteWhere accumulates AND-ed conditions and their bound arguments
+type sqliteWhere struct {
+	conds []string
+	args  []any
+}
+
+// liveWhere starts a condition list matching the context's workspace and
+// excluding trashed notes
+func liveWhere(ctx context.Context) sqliteWhere {
+	var w sqliteWhere
+	w.add("n.workspace = ?", workspace.FromContext(ctx))
+	w.add("n.deleted_at IS NULL")
+	return w
+}
+
+// trashWhere starts a condition list matching trashed notes in the
+// context's workspace
+func trashWhere(ctx context.Context) sqliteWhere {
+	var w sqliteWhere
+	w.add("n.workspace = ?", workspace.FromContext(ctx))
+	w.add("n.deleted_at IS NOT NULL")
+	return w
+}
+
+func (w *sqliteWhere) add(cond string, args ...any) {
+	w.conds = append(w.conds, cond)
+	w.args = append(w.args, args...)
+}
+
+// addCategory matches category, or any of categories when category is empty
+func (w *sqliteWhere) addCategory(category string, categories []string) {
+	if category != "" {
+		w.add("n.category = ?", category)
+		return
+	}
+	if len(categories) == 0 {
+		return
+	}
+	args := make([]any, len(categories))
+	for i, c := range categories {
+		args[i] = c
+	}
+	w.add("n.category IN ("+strings.TrimSuffix(strings.Repeat("?, ", len(categories)), ", ")+")", args...)
+}
+
+// addTags restricts to notes carrying any or all of tags
+func (w *sqliteWhere) addTags(tags []string, mode TagMode) {
+	if len(tags) == 0 {
+		return
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(tags)), ", ")
+	args := make([]any, len(tags))
+	for i, t := range tags {
+		args[i] = t
+	}
+
+	if mode == TagModeAll {
+		w.add(fmt.Sprintf("(SELECT COUNT(DISTINCT value) FROM json_each(n.tags) WHERE value IN (%s)) = %d",
+			placeholders, len(tags)), args...)
+	} else {
+		w.add(fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(n.tags) WHERE value IN (%s))", placeholders), args...)
+	}
+}
+
+func (w *sqliteWhere) clause() string {
+	if len(w.conds) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(w.conds, " AND ")
+}
+
+// ftsTerm quotes a word or phrase for an FTS5 MATCH expression so user
+// input can't inject FTS5 syntax. Words match as prefixes, like the start of
+// a word in the other stores.
+func ftsTerm(n *queryNode) string {
+	term := `"` + strings.ReplaceAll(n.Value, `"`, `""`) + `"`
+	if !n.Phrase {
+		term += "*"
+	}
+	return term
+}
+
+// sqliteQuery compiles a parsed search query into a condition and its
+// arguments
+func sqliteQuery(n *queryNode) (string, []any) {
+	switch n.Kind {
+	case queryAnd, queryOr:
+		conds := make([]string, len(n.Children))
+		var args []any
+		for i, c := range n.Children {
+			cond, cargs := sqliteQuery(c)
+			conds[i] = cond
+			args = append(args, cargs...)
+		}
+		op := " AND "
+		if n.Kind == queryOr {
+			op = " OR "
+		}
+		return "(" + strings.Join(conds, op) + ")", args
+	case queryNot:
+		cond, args := sqliteQuery(n.Children[0])
+		return "NOT " + cond, args
+	case queryText:
+		return "n.seq IN (SELECT rowid FROM notes_fts WHERE notes_fts MATCH ?)", []any{ftsTerm(n)}
+	case queryCategory:
+		return "n.category = ?", []any{n.Value}
+	case queryTag:
+		return "EXISTS (SELECT 1 FROM json_each(n.tags) WHERE value = ?)", []any{n.Value}
+	default:
+		conds := []string{"1"}
+		var args []any
+		if n.From != nil {
+			conds = append(conds, "n."+n.Field+" >= ?")
+			args = append(args, n.From.UnixMilli())
+		}
+		if n.To != nil {
+			conds = append(conds, "n."+n.Field+" < ?")
+			args = append(args, n.To.UnixMilli())
+		}
+		return "(" + strings.Join(conds, " AND ") + ")", args
+	}
+}
@@ -0,0 +1,119 @@
+package notes
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// NoteStore is the persistence layer behind Service. Repo (MongoDB),
+// SQLiteRepo and MemoryRepo implement it; handlers and MCP tools never talk
+// to a store directly.
+//
+// Every method works within the workspace in ctx (see workspace.FromContext):
+// Insert stamps it on the note and reads never see other workspaces. Only
+// Purge, a maintenance job, spans all workspaces; revisions are reached
+// through their note.
+//
+// Deleting a note only moves it to the trash. Every read except ListTrash
+// ignores trashed notes; Purge removes them for good.
+type NoteStore interface {
+	// EnsureIndexes prepares the underlying storage (indexes, tables)
+	EnsureIndexes(ctx context.Context) error
+	// Insert assigns ID and timestamps and stores a new note
+	Insert(ctx context.Context, n *Note) error
+	// InsertMany stores notes in order as Insert would, returning how many
+	// leading notes were stored; on error notes[inserted:] were not
+	InsertMany(ctx context.Context, notes []*Note) (inserted int, err error)
+	// FindByID returns ErrNoteNotFound when no live note has the given ID
+	FindByID(ctx context.Context, id primitive.ObjectID) (*Note, error)
+	// Update stores n's category, content and tags if the stored version equals
+	// expectedVersion, then bumps n.Version and n.UpdatedAt. Returns
+	// ErrNoteNotFound or ErrVersionConflict.
+	Update(ctx context.Context, n *Note, expectedVersion int64) error
+	// List returns notes sorted by created_at desc, limit clamped to 200
+	List(ctx context.Context, q ListQuery) ([]*Note, error)
+	// Search performs a search query with optional filters, returning a
+	// page of hits, best matches first, with the total and, if q.Facets is
+	// set, facet counts; limit clamped to 200
+	Search(ctx context.Context, q SearchQuery) (*SearchResult, error)
+	// GetRecent returns the newest notes across all categories, limit clamped to 100
+	GetRecent(ctx context.Context, limit int, since *time.Time) ([]*Note, error)
+	// ListCategories returns categories sorted by last note desc
+	ListCategories(ctx context.Context) ([]*Category, error)
+	// ListTags returns tags with usage counts, most used first, counting
+	// only notes in categories when it is non-empty
+	ListTags(ctx context.Context, categories []string) ([]*Tag, error)
+	// Delete moves a note to the trash, returning ErrNoteNotFound when no
+	// live note has the given ID
+	Delete(ctx context.Context, id primitive.ObjectID) error
+	// Restore takes a note out of the trash, returning ErrNoteNotFound when
+	// no trashed note has the given ID
+	Restore(ctx context.Context, id primitive.ObjectID) error
+	// FindTrashed returns ErrNoteNotFound when no trashed note has the given ID
+	FindTrashed(ctx context.Context, id primitive.ObjectID) (*Note, error)
+	// ListTrash returns trashed notes, most recently deleted first
+	ListTrash(ctx context.Context, q ListQuery) ([]*Note, error)
+	// Purge permanently removes notes (and their revisions) trashed before
+	// the cutoff, returning how many were removed
+	Purge(ctx context.Context, before time.Time) (int64, error)
+	// Count returns the number of notes, optionally filtered by category
+	Count(ctx context.Context, category string) (int64, error)
+	// Export calls fn with every live note matching q, oldest first,
+	// stopping at the first error fn returns
+	Export(ctx context.Context, q ExportQuery, fn func(*Note) error) error
+	// ImportNote stores n as given, keeping its ID, timestamps, version and
+	// author, and stamps the workspace. If n.ID is taken in the workspace,
+	// live or trashed, the note is replaced when overwrite is set (leaving it
+	// live) and ErrNoteExists is returned otherwise. An ID taken in another
+	// workspace always fails with ErrNoteExists.
+	ImportNote(ctx context.Context, n *Note, overwrite bool) (replaced bool, err error)
+	// FindByContentHash returns the newest live note in category with the
+	// given content hash, or ErrNoteNotFound
+	FindByContentHash(ctx context.Context, category, hash string) (*Note, error)
+	// ListFingerprints returns the fingerprints of all live notes in
+	// category, or in categories when category is empty (all when both are)
+	ListFingerprints(ctx context.Context, category string, categories []string) ([]*Fingerprint, error)
+	// ListEmbeddings returns what semantic search needs to know about every
+	// live note, embedded or not
+	ListEmbeddings(ctx context.Context) ([]*NoteEmbedding, error)
+	// SaveEmbedding stores the embedding of a note's content, returning
+	// ErrNoteNotFound when no live note has the given ID
+	SaveEmbedding(ctx context.Context, id primitive.ObjectID, e *Embedding) error
+
+	// SaveRevision stores a snapshot of a note version; saving the same
+	// note version twice is a no-op
+	SaveRevision(ctx context.Context, rev *Revision) error
+	// ListRevisions returns a note's stored revisions, newest first
+	ListRevisions(ctx context.Context, noteID primitive.ObjectID) ([]*Revision, error)
+	// FindRevision returns ErrRevisionNotFound when the version isn't stored
+	FindRevision(ctx context.Context, noteID primitive.ObjectID, version int64) (*Revision, error)
+
+	// ClaimIdempotencyKey stamps the workspace and CreatedAt on rec and stores
+	// it, unless a record for the same key has not expired yet; that record
+	// is returned instead and rec is not stored
+	ClaimIdempotencyKey(ctx context.Context, rec *IdempotencyRecord) (existing *IdempotencyRecord, err error)
+	// CompleteIdempotencyKey records the response for a claimed key
+	CompleteIdempotencyKey(ctx context.Context, key string, status int, response []byte) error
+	// ReleaseIdempotencyKey forgets a claimed key that has no response yet,
+	// so a failed request can be retried with it
+	ReleaseIdempotencyKey(ctx context.Context, key string) error
+}
+
+var (
+	_ NoteStore = (*Repo)(nil)
+	_ NoteStore = (*SQLiteRepo)(nil)
+	_ NoteStore = (*MemoryRepo)(nil)
+)
+
+// clampLimit applies the default and maximum page size shared by all stores
+func clampLimit(limit, defaultVal, max int) int {
+	if limit <= 0 {
+		return defaultVal
+	}
+	if limit > max {
+		return max
+	}
+	return limit
+}
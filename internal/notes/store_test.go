@@ -0,0 +1,196 @@
+package notes
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"scratchpad/internal/db"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// storeFixture is the set of notes both stores are loaded with. Times are
+// fixed and distinct so that newest-first orders are well defined.
+func storeFixture() []*Note {
+	base := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	notes := []*Note{
+		{Category: "dev", Content: "Rust async runtimes: tokio and async-std", Tags: []string{"rust"}},
+		{Category: "dev", Content: "Go channels beat async callbacks", Tags: []string{"go"}},
+		{Category: "dev", Content: "Draft: comparing Go and Rust error handling", Tags: []string{"go", "rust", "draft"}},
+		{Category: "cooking", Content: "Slow-cooked ragù with red wine", Tags: []string{"recipe"}},
+		{Category: "cooking", Content: "Espresso at the café on the corner", Tags: []string{"coffee"}},
+		{Category: "journal", Content: "Met Ana for coffee at the cafe", Tags: []string{"coffee", "people"}},
+		{Category: "journal", Content: "Quiet day, read about Rust lifetimes"},
+	}
+	for i, n := range notes {
+		n.ID = primitive.NewObjectIDFromTimestamp(base.Add(time.Duration(i) * time.Hour))
+		n.CreatedAt = base.Add(time.Duration(i) * 24 * time.Hour)
+		n.UpdatedAt = n.CreatedAt.Add(time.Hour)
+	}
+	return notes
+}
+
+// newSQLiteService returns a service over a fresh SQLite file
+func newSQLiteService(t *testing.T) *Service {
+	t.Helper()
+	ctx := context.Background()
+	database, err := db.OpenSQLite(ctx, filepath.Join(t.TempDir(), "notes.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	repo := NewSQLiteRepo(database)
+	if err := repo.EnsureIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return NewService(repo)
+}
+
+// newStoreServices returns one empty service per store that runs without
+// an external server
+func newStoreServices(t *testing.T) map[string]*Service {
+	t.Helper()
+	return map[string]*Service{
+		"memory": newTestService(t),
+		"sqlite": newSQLiteService(t),
+	}
+}
+
+// newParityServices returns services over a MemoryRepo and a SQLiteRepo
+// holding the same notes
+func newParityServices(t *testing.T) map[string]*Service {
+	t.Helper()
+	ctx := context.Background()
+	services := newStoreServices(t)
+	fixture := storeFixture()
+	for name, svc := range services {
+		for _, n := range fixture {
+			n := *n
+			if _, err := svc.ImportNote(ctx, &n, ImportSkip); err != nil {
+				t.Fatalf("%s: load %q: %v", name, n.Content, err)
+			}
+		}
+	}
+	return services
+}
+
+func TestStoresAgreeOnList(t *testing.T) {
+	services := newParityServices(t)
+	tests := []struct {
+		name string
+		q    ListQuery
+	}{
+		{"all", ListQuery{}},
+		{"category", ListQuery{Category: "dev"}},
+		{"categories", ListQuery{Categories: []string{"cooking", "journal"}}},
+		{"any tag", ListQuery{Tags: []string{"go", "coffee"}}},
+		{"all tags", ListQuery{Tags: []string{"go", "rust"}, TagMode: TagModeAll}},
+		{"page", ListQuery{Limit: 2, Offset: 3}},
+		{"empty category", ListQuery{Category: "nope"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string][]string)
+			for name, svc := range services {
+				notes, err := svc.List(context.Background(), tt.q)
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				got[name] = noteIDs(notes)
+			}
+			if !slices.Equal(got["memory"], got["sqlite"]) {
+				t.Errorf("memory listed %v, sqlite %v", got["memory"], got["sqlite"])
+			}
+		})
+	}
+}
+
+func TestStoresAgreeOnSearch(t *testing.T) {
+	services := newParityServices(t)
+	since := time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		q    SearchQuery
+		want int
+	}{
+		{"word", SearchQuery{Query: "async"}, 2},
+		{"prefix", SearchQuery{Query: "runtime"}, 1},
+		{"case", SearchQuery{Query: "RUST"}, 3},
+		{"phrase", SearchQuery{Query: `"error handling"`}, 1},
+		{"and", SearchQuery{Query: "go rust"}, 1},
+		{"or", SearchQuery{Query: "tokio OR lifetimes"}, 2},
+		{"not", SearchQuery{Query: "rust -draft"}, 2},
+		{"accent in note", SearchQuery{Query: "cafe"}, 2},
+		{"accent in query", SearchQuery{Query: "café"}, 2},
+		{"word with a colon", SearchQuery{Query: "runtimes: tokio"}, 1},
+		{"category field", SearchQuery{Query: "coffee category:journal"}, 1},
+		{"tag field", SearchQuery{Query: "tag:coffee"}, 2},
+		{"date field", SearchQuery{Query: "created:>=2024-05-04"}, 4},
+		{"category param", SearchQuery{Query: "rust", Category: "journal"}, 1},
+		{"tags param", SearchQuery{Tags: []string{"rust", "draft"}, TagMode: TagModeAll}, 1},
+		{"since", SearchQuery{Since: &since}, 5},
+		{"no query", SearchQuery{}, 7},
+		{"no match", SearchQuery{Query: "python"}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := make(map[string][]string)
+			for name, svc := range services {
+				result, err := svc.Search(context.Background(), tt.q)
+				if err != nil {
+					t.Fatalf("%s: %v", name, err)
+				}
+				if result.Total != int64(tt.want) {
+					t.Errorf("%s: total = %d, want %d", name, result.Total, tt.want)
+				}
+				// Stores rank matches differently, so only compare what matched
+				ids := noteIDs(result.Notes())
+				slices.Sort(ids)
+				got[name] = ids
+			}
+			if !slices.Equal(got["memory"], got["sqlite"]) {
+				t.Errorf("memory found %v, sqlite %v", got["memory"], got["sqlite"])
+			}
+		})
+	}
+}
+
+func TestStoresAgreeOnCounts(t *testing.T) {
+	services := newParityServices(t)
+	for _, category := range []string{"", "dev", "cooking", "nope"} {
+		counts := make(map[string]int64)
+		for name, svc := range services {
+			n, err := svc.Count(context.Background(), category)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			counts[name] = n
+		}
+		if counts["memory"] != counts["sqlite"] {
+			t.Errorf("count(%q): memory %d, sqlite %d", category, counts["memory"], counts["sqlite"])
+		}
+	}
+}
+
+func TestStoresAgreeOnCategories(t *testing.T) {
+	services := newParityServices(t)
+	got := make(map[string][]Category)
+	for name, svc := range services {
+		cats, err := svc.ListCategories(context.Background())
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, c := range cats {
+			got[name] = append(got[name], Category{Name: c.Name, Count: c.Count, LastNote: c.LastNote.UTC()})
+		}
+	}
+	if !slices.Equal(got["memory"], got["sqlite"]) {
+		t.Errorf("memory categories %v, sqlite %v", got["memory"], got["sqlite"])
+	}
+	if len(got["memory"]) != 3 {
+		t.Errorf("got %d categories, want 3", len(got["memory"]))
+	}
+}
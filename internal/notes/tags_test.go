@@ -0,0 +1,126 @@
+package notes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"testing"
+)
+
+// sortedIDs returns the IDs of notes in sorted order, for comparing results
+// whose order within the same timestamp isn't defined
+func sortedIDs(notes []*Note) []string {
+	ids := noteIDs(notes)
+	slices.Sort(ids)
+	return ids
+}
+
+func TestTagFilters(t *testing.T) {
+	for name, svc := range newStoreServices(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			both := mustCreate(t, svc, CreateNoteInput{Category: "dev", Content: "go and rust", Tags: []string{"go", "rust"}})
+			goOnly := mustCreate(t, svc, CreateNoteInput{Category: "dev", Content: "go", Tags: []string{"go"}})
+			draft := mustCreate(t, svc, CreateNoteInput{Category: "dev", Content: "rust draft", Tags: []string{"rust", "draft"}})
+			mustCreate(t, svc, CreateNoteInput{Category: "dev", Content: "untagged"})
+
+			tests := []struct {
+				name    string
+				q       ListQuery
+				want    []*Note
+				wantErr error
+			}{
+				{name: "any", q: ListQuery{Tags: []string{"go", "draft"}}, want: []*Note{draft, goOnly, both}},
+				{name: "all", q: ListQuery{Tags: []string{"go", "rust"}, TagMode: TagModeAll}, want: []*Note{both}},
+				{name: "normalized", q: ListQuery{Tags: []string{"#Go", " RUST "}, TagMode: TagModeAll}, want: []*Note{both}},
+				{name: "one tag", q: ListQuery{Tags: []string{"draft"}, TagMode: TagModeAll}, want: []*Note{draft}},
+				{name: "unused tag", q: ListQuery{Tags: []string{"python"}}},
+				{name: "bad mode", q: ListQuery{Tags: []string{"go"}, TagMode: "most"}, wantErr: ErrInvalidInput},
+			}
+			for _, tt := range tests {
+				listed, err := svc.List(ctx, tt.q)
+				if tt.wantErr != nil {
+					if !errors.Is(err, tt.wantErr) {
+						t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+					}
+					continue
+				}
+				if err != nil {
+					t.Fatalf("%s: %v", tt.name, err)
+				}
+				if got, want := sortedIDs(listed), sortedIDs(tt.want); !slices.Equal(got, want) {
+					t.Errorf("%s: listed %v, want %v", tt.name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestListTags(t *testing.T) {
+	for name, svc := range newStoreServices(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			mustCreate(t, svc, CreateNoteInput{Category: "dev", Content: "a", Tags: []string{"rust", "go"}})
+			mustCreate(t, svc, CreateNoteInput{Category: "dev", Content: "b", Tags: []string{"go"}})
+			draft := mustCreate(t, svc, CreateNoteInput{Category: "dev", Content: "c", Tags: []string{"rust", "draft"}})
+
+			tagCounts := func() []Tag {
+				tags, err := svc.ListTags(ctx)
+				if err != nil {
+					t.Fatal(err)
+				}
+				counts := make([]Tag, len(tags))
+				for i, tag := range tags {
+					counts[i] = *tag
+				}
+				return counts
+			}
+			if got, want := tagCounts(), []Tag{{"go", 2}, {"rust", 2}, {"draft", 1}}; !slices.Equal(got, want) {
+				t.Errorf("tags = %v, want %v, most used first", got, want)
+			}
+
+			if err := svc.Delete(ctx, draft.ID.Hex()); err != nil {
+				t.Fatal(err)
+			}
+			if got, want := tagCounts(), []Tag{{"go", 2}, {"rust", 1}}; !slices.Equal(got, want) {
+				t.Errorf("with a note trashed, tags = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestListNotesTagParams(t *testing.T) {
+	mux, svc := newTestMux(t)
+	both := mustCreate(t, svc, CreateNoteInput{Category: "dev", Content: "go and rust", Tags: []string{"go", "rust"}})
+	goOnly := mustCreate(t, svc, CreateNoteInput{Category: "dev", Content: "go", Tags: []string{"go"}})
+
+	tests := []struct {
+		target string
+		want   []*Note
+		status int
+	}{
+		{"/api/notes?tags=go,rust", []*Note{goOnly, both}, http.StatusOK},
+		{"/api/notes?tags=go,rust&tag_mode=all", []*Note{both}, http.StatusOK},
+		{"/api/notes?tags=go&tags=rust&tag_mode=all", []*Note{both}, http.StatusOK},
+		{"/api/notes?tags=go&tag_mode=some", nil, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		w := serve(mux, http.MethodGet, tt.target, "")
+		if w.Code != tt.status {
+			t.Errorf("%s = %d, want %d", tt.target, w.Code, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var listed []*Note
+		if err := json.NewDecoder(w.Body).Decode(&listed); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := sortedIDs(listed), sortedIDs(tt.want); !slices.Equal(got, want) {
+			t.Errorf("%s listed %v, want %v", tt.target, got, want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package notes
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestTrashAndRestore(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	kept := mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "keep this plan"})
+	trashed := mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "drop this plan"})
+	id := trashed.ID.Hex()
+
+	if err := svc.Delete(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Delete(ctx, id); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("second delete: err = %v, want %v", err, ErrNoteNotFound)
+	}
+	if _, err := svc.GetByID(ctx, id); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("get trashed note: err = %v, want %v", err, ErrNoteNotFound)
+	}
+	if _, err := svc.Update(ctx, id, UpdateNoteInput{Append: "x"}); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("edit trashed note: err = %v, want %v", err, ErrNoteNotFound)
+	}
+	listed, _ := svc.List(ctx, ListQuery{})
+	result, _ := svc.Search(ctx, SearchQuery{Query: "plan"})
+	count, _ := svc.Count(ctx, "ideas")
+	if len(listed) != 1 || result.Total != 1 || count != 1 {
+		t.Errorf("with one note trashed: listed %d, found %d, counted %d; want 1 each", len(listed), result.Total, count)
+	}
+	inTrash, err := svc.ListTrash(ctx, ListQuery{})
+	if err != nil || len(inTrash) != 1 || inTrash[0].ID != trashed.ID || inTrash[0].DeletedAt == nil {
+		t.Fatalf("trash = %v, %v; want the deleted note with its deletion time", noteIDs(inTrash), err)
+	}
+
+	if _, err := svc.Restore(ctx, kept.ID.Hex()); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("restore a live note: err = %v, want %v", err, ErrNoteNotFound)
+	}
+	restored, err := svc.Restore(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.DeletedAt != nil || restored.Content != trashed.Content || restored.Version != trashed.Version {
+		t.Errorf("restored note = %+v, want it as it was before deletion", restored)
+	}
+	if inTrash, _ := svc.ListTrash(ctx, ListQuery{}); len(inTrash) != 0 {
+		t.Errorf("trash still holds %d notes after restore", len(inTrash))
+	}
+}
+
+func TestTrashCategoryAccess(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(t)
+	secret := mustCreate(t, svc, CreateNoteInput{Category: "secret", Content: "hidden"})
+	work := mustCreate(t, svc, CreateNoteInput{Category: "work", Content: "visible"})
+	for _, n := range []*Note{secret, work} {
+		if err := svc.Delete(ctx, n.ID.Hex()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	restricted := WithCategoryAccess(ctx, []string{"work"})
+	inTrash, err := svc.ListTrash(restricted, ListQuery{})
+	if err != nil || len(inTrash) != 1 || inTrash[0].ID != work.ID {
+		t.Errorf("trash = %v, %v; want only the work note", noteIDs(inTrash), err)
+	}
+	if _, err := svc.Restore(restricted, secret.ID.Hex()); !errors.Is(err, ErrNoteNotFound) {
+		t.Errorf("restore hidden note: err = %v, want %v", err, ErrNoteNotFound)
+	}
+	if _, err := svc.Restore(restricted, work.ID.Hex()); err != nil {
+		t.Errorf("restore allowed note: %v", err)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	tests := []struct {
+		name       string
+		retention  time.Duration
+		wantPurged int64
+	}{
+		{"within retention", time.Hour, 0},
+		{"past retention", -time.Second, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			svc := newTestService(t)
+			live := mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "live"})
+			old := mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "v1"})
+			v2 := "v2"
+			if _, err := svc.Update(ctx, old.ID.Hex(), UpdateNoteInput{Content: &v2}); err != nil {
+				t.Fatal(err)
+			}
+			if err := svc.Delete(ctx, old.ID.Hex()); err != nil {
+				t.Fatal(err)
+			}
+
+			purged, err := svc.PurgeTrash(ctx, tt.retention)
+			if err != nil || purged != tt.wantPurged {
+				t.Fatalf("purged %d, %v; want %d", purged, err, tt.wantPurged)
+			}
+			_, restoreErr := svc.Restore(ctx, old.ID.Hex())
+			if gone := errors.Is(restoreErr, ErrNoteNotFound); gone != (tt.wantPurged == 1) {
+				t.Errorf("restore after purge: err = %v", restoreErr)
+			}
+			if tt.wantPurged == 1 {
+				if _, err := svc.repo.FindRevision(ctx, old.ID, 1); !errors.Is(err, ErrRevisionNotFound) {
+					t.Errorf("revision of purged note: err = %v, want %v", err, ErrRevisionNotFound)
+				}
+			}
+			if _, err := svc.GetByID(ctx, live.ID.Hex()); err != nil {
+				t.Errorf("live note was purged: %v", err)
+			}
+		})
+	}
+}
+
+func TestTrashAPI(t *testing.T) {
+	mux, svc := newTestMux(t)
+	note := mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "short-lived"})
+	path := "/api/notes/" + note.ID.Hex()
+
+	steps := []struct {
+		method, target string
+		want           int
+	}{
+		{http.MethodDelete, path, http.StatusNoContent},
+		{http.MethodGet, path, http.StatusNotFound},
+		{http.MethodDelete, path, http.StatusNotFound},
+		{http.MethodGet, "/api/trash", http.StatusOK},
+		{http.MethodPost, path + "/restore", http.StatusOK},
+		{http.MethodPost, path + "/restore", http.StatusNotFound},
+		{http.MethodGet, path, http.StatusOK},
+		{http.MethodDelete, "/api/notes/nope", http.StatusBadRequest},
+	}
+	for _, step := range steps {
+		w := serve(mux, step.method, step.target, "")
+		if w.Code != step.want {
+			t.Fatalf("%s %s = %d, want %d: %s", step.method, step.target, w.Code, step.want, w.Body)
+		}
+		if step.target == "/api/trash" {
+			var trash []*Note
+			if err := json.NewDecoder(w.Body).Decode(&trash); err != nil || len(trash) != 1 || trash[0].ID != note.ID {
+				t.Errorf("trash = %s, want the deleted note", w.Body)
+			}
+		}
+	}
+}
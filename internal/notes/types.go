@@ -9,10 +9,28 @@ import (
 // Note represents a scratchpad note with category
 type Note struct {
 	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Workspace string             `bson:"workspace" json:"workspace"`
 	Category  string             `bson:"category" json:"category"`
-	Content   string             `bson:"content" json:"content"` // markdown
+	Content   string             `bson:"content" json:"content,omitempty"` // markdown, left out of search hits on request
+	Tags      []string           `bson:"tags,omitempty" json:"tags,omitempty"`
 	CreatedAt time.Time          `bson:"created_at" json:"createdAt"`
 	UpdatedAt time.Time          `bson:"updated_at" json:"updatedAt"`
+	Version   int64              `bson:"version" json:"version"`                          // bumped on every update, exposed as ETag
+	Author    string             `bson:"author,omitempty" json:"author,omitempty"`        // who wrote the current version
+	DeletedAt *time.Time         `bson:"deleted_at,omitempty" json:"deletedAt,omitempty"` // set while the note is in the trash
+
+	ContentHash string `bson:"content_hash,omitempty" json:"contentHash,omitempty"` // hash of the normalized content, equal for exact duplicates
+	SimHash     int64  `bson:"simhash,omitempty" json:"-"`                          // locality-sensitive hash of the content, close for near duplicates
+}
+
+// Revision is a snapshot of a note as it was at a previous version
+type Revision struct {
+	NoteID    primitive.ObjectID `bson:"note_id" json:"noteId"`
+	Version   int64              `bson:"version" json:"version"`
+	Category  string             `bson:"category" json:"category"`
+	Content   string             `bson:"content" json:"content"`
+	Author    string             `bson:"author,omitempty" json:"author,omitempty"`
+	CreatedAt time.Time          `bson:"created_at" json:"createdAt"` // when this version was written
 }
 
 // Category represents aggregated category info
@@ -22,25 +40,287 @@ type Category struct {
 	LastNote time.Time `bson:"last_note" json:"lastNote"`
 }
 
+// Tag represents aggregated tag info
+type Tag struct {
+	Name  string `bson:"_id" json:"name"`
+	Count int64  `bson:"count" json:"count"`
+}
+
+// TagMode controls how multiple tag filters combine
+type TagMode string
+
+const (
+	TagModeAny TagMode = "any" // note has at least one of the tags
+	TagModeAll TagMode = "all" // note has every tag
+)
+
 // CreateNoteInput is the input for creating a note
 type CreateNoteInput struct {
-	Category string `json:"category"`
-	Content  string `json:"content"`
+	Category string   `json:"category"`
+	Content  string   `json:"content"`
+	Tags     []string `json:"tags,omitempty"`
+	Author   string   `json:"author,omitempty"`
+	// IdempotencyKey makes retries safe; the Idempotency-Key header sets it too
+	IdempotencyKey string `json:"idempotencyKey,omitempty"`
+	// OnDuplicate says what to do when the category already holds a note
+	// with the same normalized content; empty uses the server default
+	OnDuplicate DuplicatePolicy `json:"onDuplicate,omitempty"`
+}
+
+// DuplicatePolicy decides what creating an exact duplicate of a note does
+type DuplicatePolicy string
+
+const (
+	DuplicatesAllow  DuplicatePolicy = "allow"  // store it anyway
+	DuplicatesReject DuplicatePolicy = "reject" // fail with ErrDuplicateNote
+	DuplicatesMerge  DuplicatePolicy = "merge"  // add its tags to the existing note instead
+)
+
+// Fingerprint is what duplicate detection needs to know about a note
+type Fingerprint struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Category    string             `bson:"category"`
+	ContentHash string             `bson:"content_hash"`
+	SimHash     int64              `bson:"simhash"`
+	CreatedAt   time.Time          `bson:"created_at"`
+}
+
+// SimilarNote is a note together with how close it is to another one
+type SimilarNote struct {
+	*Note
+	Similarity float64 `json:"similarity"` // 1 is identical normalized content
+}
+
+// DuplicateCluster is a group of near-duplicate notes within a category
+type DuplicateCluster struct {
+	Category      string  `json:"category"`
+	MinSimilarity float64 `json:"minSimilarity"` // weakest link that joined the cluster
+	Notes         []*Note `json:"notes"`         // newest first
+}
+
+// Embedding is a vector an Embedder made from a note's content
+type Embedding struct {
+	Model       string `bson:"model"`
+	ContentHash string `bson:"content_hash"` // of the content it was made from
+	Vector      Vector `bson:"vector"`
+}
+
+// NoteEmbedding is what semantic search needs to know about a note. The
+// embedding is stale when it was made from other content or by another
+// model.
+type NoteEmbedding struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Category    string             `bson:"category"`
+	ContentHash string             `bson:"content_hash"` // of the current content
+	CreatedAt   time.Time          `bson:"created_at"`
+	Embedding   *Embedding         `bson:"embedding,omitempty"` // nil until first embedded
+}
+
+// IdempotencyRecord remembers the outcome of a create made with an
+// idempotency key, so a retry gets the original answer instead of a duplicate
+type IdempotencyRecord struct {
+	Workspace   string    `bson:"workspace"`
+	Key         string    `bson:"key"`
+	RequestHash string    `bson:"request_hash"` // fingerprint of the request, to catch reuse
+	Status      int       `bson:"status"`       // 0 while the original request is in flight
+	Response    []byte    `bson:"response,omitempty"`
+	CreatedAt   time.Time `bson:"created_at"`
+	ExpiresAt   time.Time `bson:"expires_at"`
+}
+
+// CreateResult is the outcome of CreateIdempotent
+type CreateResult struct {
+	Note     *Note
+	Status   int  // HTTP status of the original response
+	Replayed bool // the key was seen before and Note is the original response
+	Merged   bool // Note is an existing duplicate the new tags were merged into
+}
+
+// BulkStatus is the outcome of one item in a bulk create
+type BulkStatus string
+
+const (
+	BulkCreated BulkStatus = "created"
+	BulkMerged  BulkStatus = "merged"  // an exact duplicate existed, see DuplicatesMerge
+	BulkUpdated BulkStatus = "updated" // an import replaced the note with the same ID
+	BulkSkipped BulkStatus = "skipped" // an import left the note with the same ID alone
+	BulkFailed  BulkStatus = "failed"
+)
+
+// ImportMode decides what importing a note whose ID is taken does
+type ImportMode string
+
+const (
+	ImportSkip      ImportMode = "skip"      // keep the stored note
+	ImportOverwrite ImportMode = "overwrite" // replace the stored note
+	ImportNewID     ImportMode = "new-id"    // store the import under a fresh ID
+)
+
+// BulkResult reports what happened to one item of a bulk create
+type BulkResult struct {
+	Index  int        `json:"index"` // position of the item in the request
+	Status BulkStatus `json:"status"`
+	ID     string     `json:"id,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// UpdateNoteInput is the input for editing a note. Content replaces the
+// body outright; Append/Prepend add a paragraph to the existing body.
+// A nil Category keeps the current one.
+type UpdateNoteInput struct {
+	Content  *string   `json:"content,omitempty"`
+	Append   string    `json:"append,omitempty"`
+	Prepend  string    `json:"prepend,omitempty"`
+	Category *string   `json:"category,omitempty"`
+	Tags     *[]string `json:"tags,omitempty"` // replaces all tags when set
+	Author   string    `json:"author,omitempty"`
+	Version  int64     `json:"version,omitempty"` // expected current version, 0 skips the check
 }
 
 // SearchQuery represents search parameters
 type SearchQuery struct {
-	Query    string     // full-text search query
-	Category string     // filter by category
-	Since    *time.Time // notes after this date
-	Until    *time.Time // notes before this date
-	Limit    int
-	Offset   int
+	Query      string     // search query, see query.go for the syntax
+	Category   string     // filter by category
+	Categories []string   // when Category is empty, limit to these categories
+	Tags       []string   // filter by tags, combined per TagMode
+	TagMode    TagMode    // defaults to TagModeAny
+	Since      *time.Time // notes after this date
+	Until      *time.Time // notes before this date
+	Limit      int
+	Offset     int
+	// OmitContent leaves note bodies out of the hits, for callers that only
+	// need the snippets
+	OmitContent bool
+	// Facets counts the matches by category, tag and month
+	Facets bool
+
+	expr *queryNode // Query as parsed by the Service
+}
+
+// SearchHit is a note found by a search. Score is the relevance of the
+// match, higher is better, and 0 for queries without words or phrases to
+// rank by. Snippets are excerpts of the content around the matched terms.
+type SearchHit struct {
+	*Note
+	Score    float64   `json:"score"`
+	Snippets []Snippet `json:"snippets"`
+}
+
+// SearchResult is a page of search hits with the total number of matches
+// and, when asked for, how they break down
+type SearchResult struct {
+	Hits    []*SearchHit  `json:"hits"`
+	Total   int64         `json:"total"`
+	Limit   int           `json:"limit"`
+	Offset  int           `json:"offset"`
+	HasMore bool          `json:"hasMore"`
+	Facets  *SearchFacets `json:"facets,omitempty"`
+}
+
+// Notes returns the notes of the hits, in rank order
+func (r *SearchResult) Notes() []*Note {
+	notes := make([]*Note, len(r.Hits))
+	for i, hit := range r.Hits {
+		notes[i] = hit.Note
+	}
+	return notes
+}
+
+// SearchFacets counts all matches of a search by category, tag and month
+// of creation (YYYY-MM, UTC). Categories and tags come most common first,
+// months newest first, each capped at MaxFacetValues.
+type SearchFacets struct {
+	Categories []FacetCount `bson:"categories" json:"categories"`
+	Tags       []FacetCount `bson:"tags" json:"tags"`
+	Months     []FacetCount `bson:"months" json:"months"`
+}
+
+// FacetCount is the number of matches sharing a value
+type FacetCount struct {
+	Value string `bson:"_id" json:"value"`
+	Count int64  `bson:"count" json:"count"`
+}
+
+// MaxFacetValues caps the values listed per facet
+const MaxFacetValues = 20
+
+// Snippet is an excerpt of a note's content. Highlights are the matched
+// terms within Text, as [start, end) character offsets.
+type Snippet struct {
+	Text       string   `json:"text"`
+	Highlights [][2]int `json:"highlights"`
+}
+
+// SemanticQuery represents semantic search parameters
+type SemanticQuery struct {
+	Query      string   // plain language, matched by meaning
+	Category   string   // filter by category
+	Categories []string // when Category is empty, limit to these categories
+	// TextWeight is how much keyword relevance counts towards the score,
+	// from 0 (meaning only) to 1 (keywords only); see DefaultTextWeight
+	TextWeight float64
+	Limit      int
+	// OmitContent leaves note bodies out of the hits
+	OmitContent bool
+}
+
+// SemanticHit is a note found by meaning. Similarity is the cosine
+// similarity of its embedding to the query's and TextScore its keyword
+// relevance, scaled so the best keyword match scores 1. Hits are ranked by
+// Score, which blends the two per SemanticQuery.TextWeight.
+type SemanticHit struct {
+	*Note
+	Score      float64   `json:"score"`
+	Similarity float64   `json:"similarity"`
+	TextScore  float64   `json:"textScore"`
+	Snippets   []Snippet `json:"snippets"`
 }
 
 // ListQuery represents list parameters
 type ListQuery struct {
-	Category string
+	Category   string
+	Categories []string // when Category is empty, limit to these categories
+	Tags       []string
+	TagMode    TagMode
+	Limit      int
+	Offset     int
+}
+
+// SimilarQuery represents parameters for finding similar notes
+type SimilarQuery struct {
+	Category      string  // only compare against this category
+	MinSimilarity float64 // 0 to 1, defaults to DefaultMinSimilarity
+	Limit         int
+}
+
+// RelatedQuery represents parameters for finding related notes
+type RelatedQuery struct {
+	Category string // only look in this category
 	Limit    int
-	Offset   int
+	// OmitContent leaves note bodies out, keeping the snippets
+	OmitContent bool
+}
+
+// RelatedNote is a note sharing distinctive terms with another one.
+// Snippets show where it uses them.
+type RelatedNote struct {
+	*Note
+	Score    float64   `json:"score"` // text relevance of the shared terms, higher is better
+	Terms    []string  `json:"terms"` // the other note's terms this one contains
+	Snippets []Snippet `json:"snippets"`
+}
+
+// DuplicatesQuery represents parameters for the near-duplicate report
+type DuplicatesQuery struct {
+	Category      string  // only report this category
+	MinSimilarity float64 // 0 to 1, defaults to DefaultMinSimilarity
+	Limit         int     // maximum number of clusters
+}
+
+// ExportQuery represents export parameters
+type ExportQuery struct {
+	Category   string
+	Categories []string   // when Category is empty, limit to these categories
+	Since      *time.Time // notes created at or after this time
+	Until      *time.Time // notes created before this time
 }
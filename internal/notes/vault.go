@@ -0,0 +1,340 @@
+package notes
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/text"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"gopkg.in/yaml.v3"
+)
+
+// A vault is a tree of markdown files, one per note, laid out as
+// category/<date>-<slug>.md with the note's metadata in YAML front matter.
+// Obsidian and most markdown editors can open it as is.
+
+const (
+	// maxSlugLen bounds the title part of a vault file name
+	maxSlugLen = 60
+	// maxVaultFileBytes bounds a single markdown file read from a vault
+	maxVaultFileBytes = 4 << 20
+)
+
+// VaultWriter receives the files of a vault export; *zip.Writer is one.
+// Each header carries the file's name and the note's last update time.
+type VaultWriter interface {
+	CreateHeader(fh *zip.FileHeader) (io.Writer, error)
+}
+
+// frontMatter is the YAML header of a vault file
+type frontMatter struct {
+	ID       string    `yaml:"id,omitempty"`
+	Category string    `yaml:"category,omitempty"`
+	Tags     yamlTags  `yaml:"tags,omitempty"`
+	Author   string    `yaml:"author,omitempty"`
+	Created  time.Time `yaml:"created,omitempty"`
+	Updated  time.Time `yaml:"updated,omitempty"`
+}
+
+// yamlTags accepts tags written as a list or as one comma-separated string,
+// both of which Obsidian understands
+type yamlTags []string
+
+func (t *yamlTags) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*t = strings.FieldsFunc(node.Value, func(r rune) bool { return r == ',' || unicode.IsSpace(r) })
+		return nil
+	}
+	var list []string
+	if err := node.Decode(&list); err != nil {
+		return err
+	}
+	*t = list
+	return nil
+}
+
+// VaultResult reports what happened to one file of a vault import
+type VaultResult struct {
+	Path   string     `json:"path"`
+	Status BulkStatus `json:"status"`
+	ID     string     `json:"id,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// ExportVault writes every note matching q as a markdown file, returning
+// how many were written. File names come from the creation date and the
+// note's title; clashes get a numeric suffix.
+func (s *Service) ExportVault(ctx context.Context, q ExportQuery, vw VaultWriter) (int, error) {
+	used := make(map[string]bool)
+	count := 0
+	err := s.Export(ctx, q, func(n *Note) error {
+		base := n.CreatedAt.UTC().Format("2006-01-02") + "-" + slugify(s.title(n.Content))
+		name := path.Join(n.Category, base+".md")
+		for i := 2; used[name]; i++ {
+			name = path.Join(n.Category, base+"-"+strconv.Itoa(i)+".md")
+		}
+		used[name] = true
+
+		fm, err := yaml.Marshal(frontMatter{
+			ID:       n.ID.Hex(),
+			Category: n.Category,
+			Tags:     n.Tags,
+			Author:   n.Author,
+			Created:  n.CreatedAt.UTC(),
+			Updated:  n.UpdatedAt.UTC(),
+		})
+		if err != nil {
+			return fmt.Errorf("encode front matter: %w", err)
+		}
+
+		f, err := vw.CreateHeader(&zip.FileHeader{
+			Name:     name,
+			Method:   zip.Deflate,
+			Modified: n.UpdatedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("create %s: %w", name, err)
+		}
+		if _, err := fmt.Fprintf(f, "---\n%s---\n%s", fm, n.Content); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// ImportVault reads every markdown file in fsys back in as a note, passing
+// each file's outcome to report. Front matter maps onto the note; without
+// it the directory names the category and the date the file name starts
+// with, or else its modification time, stands in for the creation date. When a file's name carries a title its
+// content lacks (as when a note was created in Obsidian), the title is
+// added as a heading. Hidden files and directories such as .obsidian are
+// skipped. mode works as for ImportNote.
+func (s *Service) ImportVault(ctx context.Context, fsys fs.FS, mode ImportMode, report func(VaultResult)) error {
+	if _, err := checkImportMode(mode); err != nil {
+		return err
+	}
+
+	return fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != "." && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !strings.EqualFold(path.Ext(p), ".md") {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		res := VaultResult{Path: p, Status: BulkFailed}
+		note, err := s.readVaultFile(fsys, p, d)
+		if err == nil {
+			res.Status, err = s.ImportNote(ctx, note, mode)
+		}
+		if err != nil {
+			res.Error = err.Error()
+		} else {
+			res.ID = note.ID.Hex()
+		}
+		report(res)
+		return nil
+	})
+}
+
+// readVaultFile turns one markdown file into the note it describes
+func (s *Service) readVaultFile(fsys fs.FS, p string, d fs.DirEntry) (*Note, error) {
+	f, err := fsys.Open(p)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	data, err := io.ReadAll(io.LimitReader(f, maxVaultFileBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxVaultFileBytes {
+		return nil, fmt.Errorf("%w: file is larger than %d MB", ErrInvalidInput, maxVaultFileBytes>>20)
+	}
+
+	fm, content, err := splitFrontMatter(data)
+	if err != nil {
+		return nil, err
+	}
+
+	note := &Note{
+		Category:  fm.Category,
+		Content:   content,
+		Tags:      fm.Tags,
+		Author:    fm.Author,
+		CreatedAt: fm.Created,
+		UpdatedAt: fm.Updated,
+	}
+	if fm.ID != "" {
+		id, err := primitive.ObjectIDFromHex(fm.ID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: front matter id %q is not a note ID", ErrInvalidInput, fm.ID)
+		}
+		note.ID = id
+	}
+	if note.Category == "" {
+		note.Category = strings.ReplaceAll(path.Dir(p), "/", "-")
+		if note.Category == "." {
+			return nil, fmt.Errorf("%w: no category in front matter and the file is not in a directory", ErrInvalidInput)
+		}
+	}
+	title, date := fileTitle(p)
+	if note.CreatedAt.IsZero() {
+		note.CreatedAt = date
+	}
+	if note.CreatedAt.IsZero() {
+		if info, err := d.Info(); err == nil {
+			note.CreatedAt = info.ModTime()
+		}
+	}
+	if !titleMatches(slugify(title), slugify(s.title(content))) {
+		note.Content = "# " + title + "\n\n" + content
+	}
+	return note, nil
+}
+
+// fileTitle splits a vault file's name into its title and the date it is
+// prefixed with, if any
+func fileTitle(p string) (string, time.Time) {
+	title := strings.TrimSuffix(path.Base(p), path.Ext(p))
+	if len(title) > len("2006-01-02-") && title[10] == '-' {
+		if date, err := time.Parse("2006-01-02", title[:10]); err == nil {
+			return title[11:], date
+		}
+	}
+	return title, time.Time{}
+}
+
+// titleMatches reports whether a file name slug could have come from a
+// title slug, allowing for truncation and the suffix export adds on clashes
+func titleMatches(fileSlug, titleSlug string) bool {
+	if strings.HasPrefix(titleSlug, fileSlug) {
+		return true
+	}
+	base, n, found := cutLast(fileSlug, "-")
+	if _, err := strconv.Atoi(n); found && err == nil {
+		return strings.HasPrefix(titleSlug, base)
+	}
+	return false
+}
+
+// cutLast is strings.Cut around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// splitFrontMatter separates a leading YAML block fenced by --- lines from
+// the markdown after it
+func splitFrontMatter(data []byte) (frontMatter, string, error) {
+	var fm frontMatter
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	if !bytes.HasPrefix(data, []byte("---\n")) {
+		return fm, string(data), nil
+	}
+
+	rest := data[len("---\n"):]
+	var header, body []byte
+	switch {
+	case bytes.HasPrefix(rest, []byte("---\n")):
+		body = rest[len("---\n"):] // empty front matter
+	case bytes.Contains(rest, []byte("\n---\n")):
+		header, body, _ = bytes.Cut(rest, []byte("\n---\n"))
+	case bytes.HasSuffix(rest, []byte("\n---")):
+		header = bytes.TrimSuffix(rest, []byte("\n---"))
+	default:
+		return fm, string(data), nil
+	}
+	if err := yaml.Unmarshal(header, &fm); err != nil {
+		return fm, "", fmt.Errorf("%w: invalid front matter: %v", ErrInvalidInput, err)
+	}
+	return fm, string(body), nil
+}
+
+// title returns the text of a note's first heading, or of its first block
+// when it has none
+func (s *Service) title(content string) string {
+	src := []byte(content)
+	doc := s.md.Parser().Parse(text.NewReader(src))
+
+	var first, heading ast.Node
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering || n == doc {
+			return ast.WalkContinue, nil
+		}
+		if n.Kind() == ast.KindHeading {
+			heading = n
+			return ast.WalkStop, nil
+		}
+		if first == nil && n.Type() == ast.TypeBlock && n.HasChildren() && n.FirstChild().Type() == ast.TypeInline {
+			first = n
+		}
+		return ast.WalkSkipChildren, nil
+	})
+	if heading == nil {
+		heading = first
+	}
+	if heading == nil {
+		return ""
+	}
+	return strings.TrimSpace(string(heading.Text(src)))
+}
+
+// slugify turns a title into a file name fragment: lowercase letters and
+// digits joined by hyphens, cut at a word boundary near maxSlugLen
+func slugify(title string) string {
+	var b strings.Builder
+	hyphen := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			hyphen = false
+		} else {
+			hyphen = true
+		}
+	}
+
+	slug := b.String()
+	if len(slug) > maxSlugLen {
+		// Cut between runes so the name stays valid UTF-8
+		cut := maxSlugLen
+		for !utf8.RuneStart(slug[cut]) {
+			cut--
+		}
+		slug = slug[:cut]
+		if i := strings.LastIndexByte(slug, '-'); i > 0 {
+			slug = slug[:i]
+		}
+	}
+	if slug == "" {
+		return "note"
+	}
+	return slug
+}
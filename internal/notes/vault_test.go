@@ -0,0 +1,120 @@
+package notes
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestExportVaultSetsModifiedTimes(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(NewMemoryRepo())
+	updated := time.Date(2024, 3, 9, 14, 30, 0, 0, time.UTC)
+	note := &Note{
+		Category:  "ideas",
+		Content:   "# Old idea",
+		CreatedAt: updated.Add(-48 * time.Hour),
+		UpdatedAt: updated,
+	}
+	if _, err := svc.ImportNote(ctx, note, ImportNewID); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if n, err := svc.ExportVault(ctx, ExportQuery{}, zw); err != nil || n != 1 {
+		t.Fatalf("export = %d, %v; want 1 note", n, err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != 1 {
+		t.Fatalf("got %d entries, want 1", len(zr.File))
+	}
+	if got := zr.File[0].Modified; !got.Equal(updated) {
+		t.Errorf("entry modified at %v, want %v", got, updated)
+	}
+}
+
+func TestReimportUnchangedVault(t *testing.T) {
+	ctx := context.Background()
+	svc := NewService(NewMemoryRepo())
+	events := 0
+	svc.OnChange(func(context.Context, Event) { events++ })
+	kept := mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "# Kept\n\nas it was", Tags: []string{"a"}})
+	edited := mustCreate(t, svc, CreateNoteInput{Category: "ideas", Content: "# Edited\n\nbefore"})
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	if _, err := svc.ExportVault(ctx, ExportQuery{}, zw); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	after := "# Edited\n\nafter"
+	if _, err := svc.Update(ctx, edited.ID.Hex(), UpdateNoteInput{Content: &after}); err != nil {
+		t.Fatal(err)
+	}
+	events = 0
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := make(map[string]BulkStatus)
+	err = svc.ImportVault(ctx, zr, ImportOverwrite, func(res VaultResult) {
+		if res.Error != "" {
+			t.Errorf("%s: %s", res.Path, res.Error)
+		}
+		statuses[res.ID] = res.Status
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statuses[kept.ID.Hex()] != BulkSkipped || statuses[edited.ID.Hex()] != BulkUpdated {
+		t.Errorf("statuses = %v, want the unchanged note skipped and the edited one updated", statuses)
+	}
+	if n, _ := svc.GetByID(ctx, kept.ID.Hex()); n.Version != 1 {
+		t.Errorf("unchanged note is at version %d, want 1", n.Version)
+	}
+	if n, _ := svc.GetByID(ctx, edited.ID.Hex()); n.Content != edited.Content || n.Version != 3 {
+		t.Errorf("edited note = %q at version %d, want the exported content at version 3", n.Content, n.Version)
+	}
+	if events != 1 {
+		t.Errorf("got %d events, want 1 for the edited note", events)
+	}
+}
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title, want string
+	}{
+		{"Weekly Review: Q3 Plans!", "weekly-review-q3-plans"},
+		{"  --  ", "note"},
+		{"Café au lait", "café-au-lait"},
+		{strings.Repeat("word ", 20), strings.TrimSuffix(strings.Repeat("word-", 12), "-")},
+		{strings.Repeat("x", 70), strings.Repeat("x", maxSlugLen)},
+		// 3-byte runes with no hyphen to back off to, the limit mid-rune
+		{"a" + strings.Repeat("日本語", 10), "a" + strings.Repeat("日本語", 6) + "日"},
+	}
+	for _, tt := range tests {
+		got := slugify(tt.title)
+		if got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+		if !utf8.ValidString(got) || len(got) > maxSlugLen {
+			t.Errorf("slugify(%q) = %q, want valid UTF-8 of at most %d bytes", tt.title, got, maxSlugLen)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package notes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// watchRetry is how long WatchEvents waits before reopening a failed stream
+const watchRetry = 5 * time.Second
+
+// changeEvent is the part of a change stream document WatchEvents reads
+type changeEvent struct {
+	OperationType     string              `bson:"operationType"`
+	FullDocument      *Note               `bson:"fullDocument"`
+	ClusterTime       primitive.Timestamp `bson:"clusterTime"`
+	UpdateDescription struct {
+		UpdatedFields bson.M   `bson:"updatedFields"`
+		RemovedFields []string `bson:"removedFields"`
+	} `bson:"updateDescription"`
+}
+
+// WatchEvents follows the notes collection with a change stream and turns
+// what it sees into Events, so every instance of a multi-instance deploy
+// hears about changes made through any of them. It needs a replica set and
+// blocks until ctx is done, reopening the stream from where it left off
+// after errors, which go to onError.
+//
+// A change stream carries no earlier version of the note, so PrevCategory
+// is never set.
+func (r *Repo) WatchEvents(ctx context.Context, fn Listener, onError func(error)) {
+	pipeline := mongo.Pipeline{{{Key: "$match", Value: bson.M{
+		"operationType": bson.M{"$in": bson.A{"insert", "update", "replace"}},
+	}}}}
+	var resumeToken bson.Raw
+
+	for ctx.Err() == nil {
+		opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+		if resumeToken != nil {
+			opts.SetResumeAfter(resumeToken)
+		}
+		err := r.watch(ctx, pipeline, opts, fn, &resumeToken)
+		if ctx.Err() != nil {
+			return
+		}
+		onError(err)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(watchRetry):
+		}
+	}
+}
+
+func (r *Repo) watch(ctx context.Context, pipeline mongo.Pipeline, opts *options.ChangeStreamOptions,
+	fn Listener, resumeToken *bson.Raw) error {
+	stream, err := r.coll.Watch(ctx, pipeline, opts)
+	if err != nil {
+		return fmt.Errorf("watch notes: %w", err)
+	}
+	defer stream.Close(context.Background())
+
+	for stream.Next(ctx) {
+		*resumeToken = stream.ResumeToken()
+
+		var change changeEvent
+		if err := stream.Decode(&change); err != nil {
+			return fmt.Errorf("decode change: %w", err)
+		}
+		// The note may already be gone by the time an update is looked up
+		if change.FullDocument == nil {
+			continue
+		}
+		if typ, ok := change.eventType(); ok {
+			fn(ctx, Event{
+				Type: typ,
+				Note: change.FullDocument,
+				At:   time.Unix(int64(change.ClusterTime.T), 0),
+			})
+		}
+	}
+	return stream.Err()
+}
+
+// eventType maps a change onto the Service event it stands for. Updates to
+// notes in the trash, other than restoring them, are not reported.
+func (c *changeEvent) eventType() (EventType, bool) {
+	switch c.OperationType {
+	case "insert":
+		return EventNoteCreated, true
+	case "replace":
+		return EventNoteUpdated, c.FullDocument.DeletedAt == nil
+	}
+
+	if _, ok := c.UpdateDescription.UpdatedFields["deleted_at"]; ok && c.FullDocument.DeletedAt != nil {
+		return EventNoteDeleted, true
+	}
+	for _, f := range c.UpdateDescription.RemovedFields {
+		if f == "deleted_at" {
+			return EventNoteRestored, true
+		}
+	}
+	return EventNoteUpdated, c.FullDocument.DeletedAt == nil
+}
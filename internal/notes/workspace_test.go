@@ -0,0 +1,79 @@
+package notes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"scratchpad/internal/workspace"
+)
+
+func TestWorkspaceIsolation(t *testing.T) {
+	for name, svc := range newStoreServices(t) {
+		t.Run(name, func(t *testing.T) {
+			team := workspace.With(context.Background(), "team")
+			other := workspace.With(context.Background(), "other")
+
+			teamRes, err := svc.Create(team, CreateNoteInput{Category: "ideas", Content: "team roadmap", Tags: []string{"plan"}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			note := teamRes.Note
+			id := note.ID.Hex()
+			if _, err := svc.Create(context.Background(), CreateNoteInput{Category: "journal", Content: "my own roadmap"}); err != nil {
+				t.Fatal(err)
+			}
+
+			// Nothing of team's is visible or writable from another workspace
+			for _, ctx := range []context.Context{other, context.Background()} {
+				ws := workspace.FromContext(ctx)
+				if _, err := svc.GetByID(ctx, id); !errors.Is(err, ErrNoteNotFound) {
+					t.Errorf("%s: get: err = %v, want %v", ws, err, ErrNoteNotFound)
+				}
+				if _, err := svc.Update(ctx, id, UpdateNoteInput{Append: "x"}); !errors.Is(err, ErrNoteNotFound) {
+					t.Errorf("%s: update: err = %v, want %v", ws, err, ErrNoteNotFound)
+				}
+				if err := svc.Delete(ctx, id); !errors.Is(err, ErrNoteNotFound) {
+					t.Errorf("%s: delete: err = %v, want %v", ws, err, ErrNoteNotFound)
+				}
+				cats, _ := svc.ListCategories(ctx)
+				for _, c := range cats {
+					if c.Name == "ideas" {
+						t.Errorf("%s: lists team's category", ws)
+					}
+				}
+				tags, _ := svc.ListTags(ctx)
+				if len(tags) != 0 {
+					t.Errorf("%s: lists tags %v", ws, tags)
+				}
+				if n, _ := svc.Count(ctx, "ideas"); n != 0 {
+					t.Errorf("%s: counts %d notes in team's category", ws, n)
+				}
+			}
+
+			if result, _ := svc.Search(other, SearchQuery{Query: "roadmap"}); result.Total != 0 {
+				t.Errorf("other: search found %v", noteIDs(result.Notes()))
+			}
+			if result, _ := svc.Search(context.Background(), SearchQuery{Query: "roadmap"}); result.Total != 1 || result.Hits[0].Category != "journal" {
+				t.Errorf("default: search found %v, want only its own note", noteIDs(result.Notes()))
+			}
+			if listed, _ := svc.List(team, ListQuery{}); len(listed) != 1 || listed[0].ID != note.ID {
+				t.Errorf("team: listed %v, want only its own note", noteIDs(listed))
+			}
+
+			// Nor can the trash be reached across workspaces
+			if err := svc.Delete(team, id); err != nil {
+				t.Fatal(err)
+			}
+			if trash, _ := svc.ListTrash(other, ListQuery{}); len(trash) != 0 {
+				t.Errorf("other: trash holds %v", noteIDs(trash))
+			}
+			if _, err := svc.Restore(other, id); !errors.Is(err, ErrNoteNotFound) {
+				t.Errorf("other: restore: err = %v, want %v", err, ErrNoteNotFound)
+			}
+			if _, err := svc.Restore(team, id); err != nil {
+				t.Errorf("team: restore: %v", err)
+			}
+		})
+	}
+}
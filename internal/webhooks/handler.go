@@ -0,0 +1,231 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"scratchpad/internal/auth"
+	"scratchpad/internal/notes"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Handler struct {
+	svc *Service
+	log *slog.Logger
+}
+
+func NewHandler(svc *Service, log *slog.Logger) *Handler {
+	return &Handler{svc: svc, log: log}
+}
+
+// ListWebhooks handles GET /api/webhooks
+func (h *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := h.svc.List(r.Context())
+	if err != nil {
+		h.serviceError(w, err, "failed to list webhooks")
+		return
+	}
+	visible := []*Webhook{}
+	for _, hook := range hooks {
+		if canManage(r.Context(), hook) {
+			visible = append(visible, hook)
+		}
+	}
+	jsonResponse(w, visible, http.StatusOK)
+}
+
+// CreateWebhook handles POST /api/webhooks. The response is the only place
+// a generated secret is ever shown.
+func (h *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var input CreateWebhookInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		jsonError(w, "invalid JSON body", http.StatusBadRequest)
+		return
+	}
+
+	// A key limited to some categories can't watch notes outside them
+	if caller, ok := auth.FromContext(r.Context()); ok && len(caller.Categories) > 0 {
+		if len(input.Categories) == 0 || slices.ContainsFunc(input.Categories, func(c string) bool {
+			return !withinKey(caller, c)
+		}) {
+			jsonError(w, "webhook categories must be within your own", http.StatusForbidden)
+			return
+		}
+	}
+
+	created, err := h.svc.Create(r.Context(), input)
+	if err != nil {
+		h.serviceError(w, err, "failed to create webhook")
+		return
+	}
+	jsonResponse(w, created, http.StatusCreated)
+}
+
+// GetWebhook handles GET /api/webhooks/{id}
+func (h *Handler) GetWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.webhook(w, r)
+	if !ok {
+		return
+	}
+	jsonResponse(w, hook, http.StatusOK)
+}
+
+// DeleteWebhook handles DELETE /api/webhooks/{id}
+func (h *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.webhook(w, r)
+	if !ok {
+		return
+	}
+	if err := h.svc.Delete(r.Context(), hook.ID.Hex()); err != nil {
+		h.serviceError(w, err, "failed to delete webhook")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PingWebhook handles POST /api/webhooks/{id}/ping, queueing a
+// webhook.ping delivery and returning it
+func (h *Handler) PingWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.webhook(w, r)
+	if !ok {
+		return
+	}
+	d, err := h.svc.Ping(r.Context(), hook.ID.Hex())
+	if err != nil {
+		h.serviceError(w, err, "failed to ping webhook")
+		return
+	}
+	jsonResponse(w, d, http.StatusAccepted)
+}
+
+// ListDeliveries handles GET /api/webhooks/{id}/deliveries, the delivery
+// log of one webhook, newest first (query: status, limit)
+func (h *Handler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	hook, ok := h.webhook(w, r)
+	if !ok {
+		return
+	}
+	h.deliveries(w, r, hook.ID, DeliveryStatus(r.URL.Query().Get("status")))
+}
+
+// DeadLetters handles GET /api/webhooks/dead-letters, the deliveries in
+// the workspace that ran out of attempts, newest first (query: limit)
+func (h *Handler) DeadLetters(w http.ResponseWriter, r *http.Request) {
+	h.deliveries(w, r, primitive.NilObjectID, DeliveryDead)
+}
+
+func (h *Handler) deliveries(w http.ResponseWriter, r *http.Request, webhookID primitive.ObjectID, status DeliveryStatus) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	ds, err := h.svc.Deliveries(r.Context(), webhookID, status, limit)
+	if err != nil {
+		h.serviceError(w, err, "failed to list webhook deliveries")
+		return
+	}
+
+	// Category-limited callers only see deliveries of webhooks they manage
+	visible := []*Delivery{}
+	managed := make(map[primitive.ObjectID]bool)
+	for _, d := range ds {
+		ok, seen := managed[d.WebhookID]
+		if !seen {
+			hook, err := h.svc.Get(r.Context(), d.WebhookID.Hex())
+			ok = err == nil && canManage(r.Context(), hook)
+			managed[d.WebhookID] = ok
+		}
+		if ok {
+			visible = append(visible, d)
+		}
+	}
+	jsonResponse(w, visible, http.StatusOK)
+}
+
+// RetryDelivery handles POST /api/webhooks/deliveries/{id}/retry, sending
+// a delivery again with a fresh set of attempts
+func (h *Handler) RetryDelivery(w http.ResponseWriter, r *http.Request) {
+	d, err := h.svc.GetDelivery(r.Context(), r.PathValue("id"))
+	if err != nil {
+		h.serviceError(w, err, "failed to find webhook delivery")
+		return
+	}
+	if hook, err := h.svc.Get(r.Context(), d.WebhookID.Hex()); err != nil || !canManage(r.Context(), hook) {
+		jsonError(w, ErrDeliveryNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	d, err = h.svc.Redeliver(r.Context(), d.ID.Hex())
+	if err != nil {
+		h.serviceError(w, err, "failed to retry webhook delivery")
+		return
+	}
+	jsonResponse(w, d, http.StatusAccepted)
+}
+
+// webhook loads the webhook named in the path, answering 404 itself when
+// it doesn't exist or the caller may not manage it
+func (h *Handler) webhook(w http.ResponseWriter, r *http.Request) (*Webhook, bool) {
+	hook, err := h.svc.Get(r.Context(), r.PathValue("id"))
+	if err == nil && !canManage(r.Context(), hook) {
+		err = ErrWebhookNotFound
+	}
+	if err != nil {
+		h.serviceError(w, err, "failed to find webhook")
+		return nil, false
+	}
+	return hook, true
+}
+
+// canManage reports whether the caller may see and change a webhook: keys
+// limited to some categories only manage webhooks watching nothing else
+func canManage(ctx context.Context, hook *Webhook) bool {
+	caller, ok := auth.FromContext(ctx)
+	if !ok || len(caller.Categories) == 0 {
+		return true
+	}
+	if len(hook.Categories) == 0 {
+		return false
+	}
+	for _, c := range hook.Categories {
+		if !withinKey(caller, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// withinKey reports whether a key limited to some categories covers c
+func withinKey(key *auth.APIKey, c string) bool {
+	return slices.ContainsFunc(key.Categories, func(k string) bool {
+		return notes.NormalizeCategory(k) == notes.NormalizeCategory(c)
+	})
+}
+
+// serviceError maps Service errors onto JSON error responses
+func (h *Handler) serviceError(w http.ResponseWriter, err error, logMsg string) {
+	switch {
+	case errors.Is(err, ErrInvalidInput):
+		jsonError(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrWebhookNotFound), errors.Is(err, ErrDeliveryNotFound):
+		jsonError(w, err.Error(), http.StatusNotFound)
+	default:
+		h.log.Error(logMsg, "error", err)
+		jsonError(w, "internal error", http.StatusInternalServerError)
+	}
+}
+
+func jsonResponse(w http.ResponseWriter, data any, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
+
+func jsonError(w http.ResponseWriter, message string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
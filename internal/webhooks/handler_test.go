@@ -0,0 +1,157 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"scratchpad/internal/auth"
+	"scratchpad/internal/workspace"
+)
+
+// newTestMux serves the webhook API routes over a MemoryRepo; requests get
+// the workspace and key set on them by serve
+func newTestMux() (*http.ServeMux, *Service) {
+	svc := newTestService(NewMemoryRepo())
+	h := NewHandler(svc, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/webhooks", h.ListWebhooks)
+	mux.HandleFunc("POST /api/webhooks", h.CreateWebhook)
+	mux.HandleFunc("GET /api/webhooks/dead-letters", h.DeadLetters)
+	mux.HandleFunc("GET /api/webhooks/{id}", h.GetWebhook)
+	mux.HandleFunc("DELETE /api/webhooks/{id}", h.DeleteWebhook)
+	mux.HandleFunc("POST /api/webhooks/{id}/ping", h.PingWebhook)
+	mux.HandleFunc("GET /api/webhooks/{id}/deliveries", h.ListDeliveries)
+	mux.HandleFunc("POST /api/webhooks/deliveries/{id}/retry", h.RetryDelivery)
+	return mux, svc
+}
+
+// serve sends a request through mux as ctx's workspace and key
+func serve(mux http.Handler, ctx context.Context, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	return w
+}
+
+func mustCreateHook(t *testing.T, svc *Service, ctx context.Context, input CreateWebhookInput) *CreatedWebhook {
+	t.Helper()
+	hook, err := svc.Create(ctx, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hook
+}
+
+func decodeHooks(t *testing.T, w *httptest.ResponseRecorder) []string {
+	t.Helper()
+	var hooks []*Webhook
+	if err := json.NewDecoder(w.Body).Decode(&hooks); err != nil {
+		t.Fatal(err)
+	}
+	urls := make([]string, len(hooks))
+	for i, h := range hooks {
+		urls[i] = h.URL
+	}
+	return urls
+}
+
+func TestWebhookWorkspaces(t *testing.T) {
+	mux, svc := newTestMux()
+	ctx := context.Background()
+	team := workspace.With(ctx, "team")
+	hook := mustCreateHook(t, svc, team, CreateWebhookInput{URL: "http://example.com/team"})
+	path := "/api/webhooks/" + hook.ID.Hex()
+
+	if urls := decodeHooks(t, serve(mux, ctx, http.MethodGet, "/api/webhooks", "")); len(urls) != 0 {
+		t.Errorf("default workspace lists %v", urls)
+	}
+	for _, step := range []struct {
+		method, target string
+	}{
+		{http.MethodGet, path},
+		{http.MethodPost, path + "/ping"},
+		{http.MethodGet, path + "/deliveries"},
+		{http.MethodDelete, path},
+	} {
+		if w := serve(mux, ctx, step.method, step.target, ""); w.Code != http.StatusNotFound {
+			t.Errorf("default workspace: %s %s = %d, want 404", step.method, step.target, w.Code)
+		}
+	}
+
+	w := serve(mux, team, http.MethodPost, path+"/ping", "")
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("team ping = %d: %s", w.Code, w.Body)
+	}
+	var d Delivery
+	json.NewDecoder(w.Body).Decode(&d)
+	if w := serve(mux, ctx, http.MethodPost, "/api/webhooks/deliveries/"+d.ID.Hex()+"/retry", ""); w.Code != http.StatusNotFound {
+		t.Errorf("default workspace retries team's delivery: %d, want 404", w.Code)
+	}
+	if urls := decodeHooks(t, serve(mux, team, http.MethodGet, "/api/webhooks", "")); len(urls) != 1 {
+		t.Errorf("team lists %v, want its webhook", urls)
+	}
+	if w := serve(mux, team, http.MethodDelete, path, ""); w.Code != http.StatusNoContent {
+		t.Errorf("team delete = %d, want 204", w.Code)
+	}
+}
+
+func TestWebhookCategories(t *testing.T) {
+	mux, svc := newTestMux()
+	ctx := context.Background()
+	everything := mustCreateHook(t, svc, ctx, CreateWebhookInput{URL: "http://example.com/all"})
+	work := mustCreateHook(t, svc, ctx, CreateWebhookInput{URL: "http://example.com/work", Categories: []string{"work"}})
+	mustCreateHook(t, svc, ctx, CreateWebhookInput{URL: "http://example.com/wider", Categories: []string{"work", "private"}})
+	restricted := auth.WithKey(ctx, &auth.APIKey{Name: "work-admin", Scopes: []auth.Scope{auth.ScopeAdmin}, Categories: []string{"Work"}})
+
+	if urls := decodeHooks(t, serve(mux, restricted, http.MethodGet, "/api/webhooks", "")); len(urls) != 1 || urls[0] != work.URL {
+		t.Errorf("restricted key lists %v, want only the work webhook", urls)
+	}
+	if w := serve(mux, restricted, http.MethodGet, "/api/webhooks/"+everything.ID.Hex(), ""); w.Code != http.StatusNotFound {
+		t.Errorf("restricted key gets the unfiltered webhook: %d, want 404", w.Code)
+	}
+
+	creates := []struct {
+		body string
+		want int
+	}{
+		{`{"url":"http://example.com/x"}`, http.StatusForbidden},
+		{`{"url":"http://example.com/x","categories":["work","private"]}`, http.StatusForbidden},
+		{`{"url":"http://example.com/x","categories":["Work"]}`, http.StatusCreated},
+	}
+	for _, tt := range creates {
+		if w := serve(mux, restricted, http.MethodPost, "/api/webhooks", tt.body); w.Code != tt.want {
+			t.Errorf("create %s = %d, want %d", tt.body, w.Code, tt.want)
+		}
+	}
+
+	// Dead letters and retries only reach webhooks the key manages
+	var dead []*Delivery
+	for _, hook := range []*CreatedWebhook{everything, work} {
+		d, err := svc.Ping(ctx, hook.ID.Hex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		d.Status = DeliveryDead
+		if err := svc.store.SaveDelivery(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+		dead = append(dead, d)
+	}
+	w := serve(mux, restricted, http.MethodGet, "/api/webhooks/dead-letters", "")
+	var letters []*Delivery
+	if err := json.NewDecoder(w.Body).Decode(&letters); err != nil || len(letters) != 1 || letters[0].WebhookID != work.ID {
+		t.Errorf("dead letters = %v, %v; want only the work webhook's", letters, err)
+	}
+	if w := serve(mux, restricted, http.MethodPost, "/api/webhooks/deliveries/"+dead[0].ID.Hex()+"/retry", ""); w.Code != http.StatusNotFound {
+		t.Errorf("retry another webhook's delivery = %d, want 404", w.Code)
+	}
+	if w := serve(mux, restricted, http.MethodPost, "/api/webhooks/deliveries/"+dead[1].ID.Hex()+"/retry", ""); w.Code != http.StatusAccepted {
+		t.Errorf("retry own delivery = %d, want 202", w.Code)
+	}
+}
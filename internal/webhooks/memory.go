@@ -0,0 +1,214 @@
+package webhooks
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"scratchpad/internal/workspace"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryRepo is a Store kept in process memory, paired with
+// notes.MemoryRepo; webhooks and their deliveries do not survive a restart.
+type MemoryRepo struct {
+	mu         sync.RWMutex
+	hooks      map[primitive.ObjectID]*Webhook
+	deliveries map[primitive.ObjectID]*Delivery
+}
+
+func NewMemoryRepo() *MemoryRepo {
+	return &MemoryRepo{
+		hooks:      make(map[primitive.ObjectID]*Webhook),
+		deliveries: make(map[primitive.ObjectID]*Delivery),
+	}
+}
+
+// EnsureIndexes is a no-op for the in-memory store
+func (r *MemoryRepo) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+// Insert stores a new webhook
+func (r *MemoryRepo) Insert(ctx context.Context, w *Webhook) error {
+	w.ID = primitive.NewObjectID()
+	w.Workspace = workspace.FromContext(ctx)
+	w.CreatedAt = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks[w.ID] = cloneWebhook(w)
+	return nil
+}
+
+// Find retrieves a webhook by ID
+func (r *MemoryRepo) Find(ctx context.Context, id primitive.ObjectID) (*Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	w, ok := r.hooks[id]
+	if !ok || w.Workspace != workspace.FromContext(ctx) {
+		return nil, ErrWebhookNotFound
+	}
+	return cloneWebhook(w), nil
+}
+
+// List returns the workspace's webhooks, oldest first
+func (r *MemoryRepo) List(ctx context.Context) ([]*Webhook, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ws := workspace.FromContext(ctx)
+	var hooks []*Webhook
+	for _, w := range r.hooks {
+		if w.Workspace == ws {
+			hooks = append(hooks, cloneWebhook(w))
+		}
+	}
+	sort.Slice(hooks, func(i, j int) bool {
+		return hooks[i].CreatedAt.Before(hooks[j].CreatedAt)
+	})
+	return hooks, nil
+}
+
+// Delete removes a webhook and its deliveries
+func (r *MemoryRepo) Delete(ctx context.Context, id primitive.ObjectID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w, ok := r.hooks[id]
+	if !ok || w.Workspace != workspace.FromContext(ctx) {
+		return ErrWebhookNotFound
+	}
+	delete(r.hooks, id)
+	for did, d := range r.deliveries {
+		if d.WebhookID == id {
+			delete(r.deliveries, did)
+		}
+	}
+	return nil
+}
+
+// InsertDeliveries stores new deliveries
+func (r *MemoryRepo) InsertDeliveries(ctx context.Context, ds []*Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, d := range ds {
+		d.ID = primitive.NewObjectID()
+		r.deliveries[d.ID] = cloneDelivery(d)
+	}
+	return nil
+}
+
+// FindDelivery retrieves a delivery by ID
+func (r *MemoryRepo) FindDelivery(ctx context.Context, id primitive.ObjectID) (*Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	d, ok := r.deliveries[id]
+	if !ok || d.Workspace != workspace.FromContext(ctx) {
+		return nil, ErrDeliveryNotFound
+	}
+	return cloneDelivery(d), nil
+}
+
+// ListDeliveries returns deliveries matching q, newest first
+func (r *MemoryRepo) ListDeliveries(ctx context.Context, q DeliveryQuery) ([]*Delivery, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ws := workspace.FromContext(ctx)
+	var ds []*Delivery
+	for _, d := range r.deliveries {
+		if d.Workspace != ws ||
+			(!q.WebhookID.IsZero() && d.WebhookID != q.WebhookID) ||
+			(q.Status != "" && d.Status != q.Status) {
+			continue
+		}
+		ds = append(ds, cloneDelivery(d))
+	}
+	sort.Slice(ds, func(i, j int) bool {
+		if !ds[i].CreatedAt.Equal(ds[j].CreatedAt) {
+			return ds[i].CreatedAt.After(ds[j].CreatedAt)
+		}
+		return ds[i].ID.Hex() > ds[j].ID.Hex()
+	})
+	if len(ds) > q.Limit {
+		ds = ds[:q.Limit]
+	}
+	return ds, nil
+}
+
+// ClaimDue leases due deliveries, earliest due first
+func (r *MemoryRepo) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var due []*Delivery
+	for _, d := range r.deliveries {
+		if d.Status == DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+
+	claimed := make([]*Delivery, 0, min(limit, len(due)))
+	for _, d := range due[:min(limit, len(due))] {
+		d.NextAttemptAt = now.Add(lease)
+		claimed = append(claimed, cloneDelivery(d))
+	}
+	return claimed, nil
+}
+
+// SaveDelivery records the outcome of an attempt
+func (r *MemoryRepo) SaveDelivery(ctx context.Context, d *Delivery) error {
+	d.UpdatedAt = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.deliveries[d.ID]
+	if !ok {
+		return nil // its webhook was deleted meanwhile
+	}
+	stored.Status = d.Status
+	stored.Attempts = d.Attempts
+	stored.LastStatus = d.LastStatus
+	stored.LastError = d.LastError
+	stored.NextAttemptAt = d.NextAttemptAt
+	stored.UpdatedAt = d.UpdatedAt
+	return nil
+}
+
+// PruneDeliveries removes old succeeded deliveries
+func (r *MemoryRepo) PruneDeliveries(ctx context.Context, cutoff time.Time) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var pruned int64
+	for id, d := range r.deliveries {
+		if d.Status == DeliverySucceeded && d.UpdatedAt.Before(cutoff) {
+			delete(r.deliveries, id)
+			pruned++
+		}
+	}
+	return pruned, nil
+}
+
+func cloneWebhook(w *Webhook) *Webhook {
+	c := *w
+	c.Events = slices.Clone(w.Events)
+	c.Categories = slices.Clone(w.Categories)
+	return &c
+}
+
+func cloneDelivery(d *Delivery) *Delivery {
+	c := *d
+	c.Payload = slices.Clone(d.Payload)
+	return &c
+}
@@ -0,0 +1,214 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"scratchpad/internal/workspace"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Repo is a Store backed by the webhooks and webhook_deliveries collections
+type Repo struct {
+	hooks      *mongo.Collection
+	deliveries *mongo.Collection
+}
+
+func NewRepo(db *mongo.Database) *Repo {
+	return &Repo{
+		hooks:      db.Collection("webhooks"),
+		deliveries: db.Collection("webhook_deliveries"),
+	}
+}
+
+// EnsureIndexes creates the indexes for listing webhooks by workspace,
+// browsing the delivery log and finding due deliveries
+func (r *Repo) EnsureIndexes(ctx context.Context) error {
+	_, err := r.hooks.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "workspace", Value: 1}, {Key: "created_at", Value: 1}},
+	})
+	if err != nil {
+		return fmt.Errorf("create webhook indexes: %w", err)
+	}
+	_, err = r.deliveries.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "workspace", Value: 1}, {Key: "webhook_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "workspace", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}},
+	})
+	if err != nil {
+		return fmt.Errorf("create webhook delivery indexes: %w", err)
+	}
+	return nil
+}
+
+// Insert stores a new webhook
+func (r *Repo) Insert(ctx context.Context, w *Webhook) error {
+	w.ID = primitive.NewObjectID()
+	w.Workspace = workspace.FromContext(ctx)
+	w.CreatedAt = time.Now()
+
+	if _, err := r.hooks.InsertOne(ctx, w); err != nil {
+		return fmt.Errorf("insert webhook: %w", err)
+	}
+	return nil
+}
+
+// Find retrieves a webhook by ID
+func (r *Repo) Find(ctx context.Context, id primitive.ObjectID) (*Webhook, error) {
+	var w Webhook
+	err := r.hooks.FindOne(ctx, bson.M{"_id": id, "workspace": workspace.FromContext(ctx)}).Decode(&w)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find webhook: %w", err)
+	}
+	return &w, nil
+}
+
+// List returns the workspace's webhooks, oldest first
+func (r *Repo) List(ctx context.Context) ([]*Webhook, error) {
+	cursor, err := r.hooks.Find(ctx,
+		bson.M{"workspace": workspace.FromContext(ctx)},
+		options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("list webhooks: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var hooks []*Webhook
+	if err := cursor.All(ctx, &hooks); err != nil {
+		return nil, fmt.Errorf("decode webhooks: %w", err)
+	}
+	return hooks, nil
+}
+
+// Delete removes a webhook and its deliveries
+func (r *Repo) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := r.hooks.DeleteOne(ctx, bson.M{"_id": id, "workspace": workspace.FromContext(ctx)})
+	if err != nil {
+		return fmt.Errorf("delete webhook %s: %w", id.Hex(), err)
+	}
+	if res.DeletedCount == 0 {
+		return ErrWebhookNotFound
+	}
+	if _, err := r.deliveries.DeleteMany(ctx, bson.M{"webhook_id": id}); err != nil {
+		return fmt.Errorf("delete deliveries of webhook %s: %w", id.Hex(), err)
+	}
+	return nil
+}
+
+// InsertDeliveries stores new deliveries
+func (r *Repo) InsertDeliveries(ctx context.Context, ds []*Delivery) error {
+	if len(ds) == 0 {
+		return nil
+	}
+	docs := make([]any, len(ds))
+	for i, d := range ds {
+		d.ID = primitive.NewObjectID()
+		docs[i] = d
+	}
+	if _, err := r.deliveries.InsertMany(ctx, docs); err != nil {
+		return fmt.Errorf("insert webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// FindDelivery retrieves a delivery by ID
+func (r *Repo) FindDelivery(ctx context.Context, id primitive.ObjectID) (*Delivery, error) {
+	var d Delivery
+	err := r.deliveries.FindOne(ctx, bson.M{"_id": id, "workspace": workspace.FromContext(ctx)}).Decode(&d)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find webhook delivery: %w", err)
+	}
+	return &d, nil
+}
+
+// ListDeliveries returns deliveries matching q, newest first
+func (r *Repo) ListDeliveries(ctx context.Context, q DeliveryQuery) ([]*Delivery, error) {
+	filter := bson.M{"workspace": workspace.FromContext(ctx)}
+	if !q.WebhookID.IsZero() {
+		filter["webhook_id"] = q.WebhookID
+	}
+	if q.Status != "" {
+		filter["status"] = q.Status
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(int64(q.Limit))
+	cursor, err := r.deliveries.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("list webhook deliveries: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var ds []*Delivery
+	if err := cursor.All(ctx, &ds); err != nil {
+		return nil, fmt.Errorf("decode webhook deliveries: %w", err)
+	}
+	return ds, nil
+}
+
+// ClaimDue leases due deliveries one at a time, so concurrent workers
+// never claim the same one
+func (r *Repo) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*Delivery, error) {
+	var claimed []*Delivery
+	for len(claimed) < limit {
+		var d Delivery
+		err := r.deliveries.FindOneAndUpdate(ctx,
+			bson.M{"status": DeliveryPending, "next_attempt_at": bson.M{"$lte": now}},
+			bson.M{"$set": bson.M{"next_attempt_at": now.Add(lease)}},
+			options.FindOneAndUpdate().
+				SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).
+				SetReturnDocument(options.After),
+		).Decode(&d)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return claimed, fmt.Errorf("claim webhook delivery: %w", err)
+		}
+		claimed = append(claimed, &d)
+	}
+	return claimed, nil
+}
+
+// SaveDelivery records the outcome of an attempt
+func (r *Repo) SaveDelivery(ctx context.Context, d *Delivery) error {
+	d.UpdatedAt = time.Now()
+	_, err := r.deliveries.UpdateByID(ctx, d.ID, bson.M{"$set": bson.M{
+		"status":          d.Status,
+		"attempts":        d.Attempts,
+		"last_status":     d.LastStatus,
+		"last_error":      d.LastError,
+		"next_attempt_at": d.NextAttemptAt,
+		"updated_at":      d.UpdatedAt,
+	}})
+	if err != nil {
+		return fmt.Errorf("save webhook delivery %s: %w", d.ID.Hex(), err)
+	}
+	return nil
+}
+
+// PruneDeliveries removes old succeeded deliveries
+func (r *Repo) PruneDeliveries(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := r.deliveries.DeleteMany(ctx, bson.M{
+		"status":     DeliverySucceeded,
+		"updated_at": bson.M{"$lt": cutoff},
+	})
+	if err != nil {
+		return 0, fmt.Errorf("prune webhook deliveries: %w", err)
+	}
+	return res.DeletedCount, nil
+}
@@ -0,0 +1,463 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"scratchpad/internal/notes"
+	"scratchpad/internal/workspace"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Scratchpad-Event"
+	HeaderDelivery  = "X-Scratchpad-Delivery"
+	HeaderTimestamp = "X-Scratchpad-Timestamp"
+	// HeaderSignature is "sha256=" followed by the hex HMAC-SHA256, keyed
+	// with the webhook's secret, of the timestamp, a '.', and the body
+	HeaderSignature = "X-Scratchpad-Signature"
+)
+
+const (
+	secretPrefix   = "whsec_"
+	secretBytes    = 24
+	minSecretLen   = 16
+	requestTimeout = 10 * time.Second
+	// claimLease keeps a claimed delivery from being picked up again while
+	// its attempt is in flight; it must outlast requestTimeout
+	claimLease   = time.Minute
+	pollInterval = 5 * time.Second
+	batchSize    = 20
+	workers      = 4
+	maxBackoff   = 6 * time.Hour
+	// deliveryRetention is how long succeeded deliveries stay in the log;
+	// dead ones stay until retried or their webhook is deleted
+	deliveryRetention = 7 * 24 * time.Hour
+	// maxErrorBody bounds how much of a failed response is kept in the log
+	maxErrorBody = 512
+)
+
+// Default retry policy: with 8 attempts 30s apart at first, a delivery is
+// given up on after about an hour of failures
+const (
+	DefaultMaxAttempts = 8
+	DefaultRetryBase   = 30 * time.Second
+)
+
+// Service manages webhook subscriptions and delivers note events to them.
+// Events are queued in the store and sent by Run in the background, so
+// deliveries survive restarts and a slow endpoint never holds up a request.
+type Service struct {
+	store  Store
+	log    *slog.Logger
+	client *http.Client
+	wake   chan struct{}
+
+	maxAttempts int
+	retryBase   time.Duration
+}
+
+func NewService(store Store, log *slog.Logger) *Service {
+	return &Service{
+		store: store,
+		log:   log,
+		client: &http.Client{
+			Timeout: requestTimeout,
+			// A redirect is an answer, not a delivery; following it would
+			// also turn the POST into a GET
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		wake:        make(chan struct{}, 1),
+		maxAttempts: DefaultMaxAttempts,
+		retryBase:   DefaultRetryBase,
+	}
+}
+
+// SetRetryPolicy sets how many attempts a delivery gets before it goes to
+// the dead letters, and the wait after the first failure, which doubles
+// with each further one
+func (s *Service) SetRetryPolicy(maxAttempts int, base time.Duration) error {
+	if maxAttempts < 1 {
+		return fmt.Errorf("%w: at least one attempt is required", ErrInvalidInput)
+	}
+	if base <= 0 {
+		return fmt.Errorf("%w: retry delay must be positive", ErrInvalidInput)
+	}
+	s.maxAttempts, s.retryBase = maxAttempts, base
+	return nil
+}
+
+// Create validates and stores a webhook in ctx's workspace, returning it
+// with its secret
+func (s *Service) Create(ctx context.Context, input CreateWebhookInput) (*CreatedWebhook, error) {
+	u, err := url.Parse(strings.TrimSpace(input.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidInput)
+	}
+
+	var events []notes.EventType
+	for _, e := range input.Events {
+		if !slices.Contains(AllEvents, e) {
+			return nil, fmt.Errorf("%w: unknown event %q", ErrInvalidInput, e)
+		}
+		if !slices.Contains(events, e) {
+			events = append(events, e)
+		}
+	}
+	var categories []string
+	for _, c := range input.Categories {
+		if c = notes.NormalizeCategory(c); c != "" && !slices.Contains(categories, c) {
+			categories = append(categories, c)
+		}
+	}
+
+	secret := input.Secret
+	switch {
+	case secret == "":
+		raw := make([]byte, secretBytes)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("generate webhook secret: %w", err)
+		}
+		secret = secretPrefix + hex.EncodeToString(raw)
+	case len(secret) < minSecretLen:
+		return nil, fmt.Errorf("%w: secret must be at least %d characters", ErrInvalidInput, minSecretLen)
+	}
+
+	hook := &Webhook{URL: u.String(), Events: events, Categories: categories, Secret: secret}
+	if err := s.store.Insert(ctx, hook); err != nil {
+		return nil, err
+	}
+	return &CreatedWebhook{Webhook: hook, Secret: secret}, nil
+}
+
+// Get returns a webhook in ctx's workspace
+func (s *Service) Get(ctx context.Context, id string) (*Webhook, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrWebhookNotFound
+	}
+	return s.store.Find(ctx, oid)
+}
+
+// List returns the webhooks in ctx's workspace, oldest first
+func (s *Service) List(ctx context.Context) ([]*Webhook, error) {
+	return s.store.List(ctx)
+}
+
+// Delete removes a webhook together with its delivery log; deliveries
+// still queued are dropped
+func (s *Service) Delete(ctx context.Context, id string) error {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return ErrWebhookNotFound
+	}
+	return s.store.Delete(ctx, oid)
+}
+
+// Deliveries returns a page of the delivery log, newest first. A zero
+// webhookID covers every webhook in the workspace.
+func (s *Service) Deliveries(ctx context.Context, webhookID primitive.ObjectID, status DeliveryStatus, limit int) ([]*Delivery, error) {
+	switch status {
+	case "", DeliveryPending, DeliverySucceeded, DeliveryDead:
+	default:
+		return nil, fmt.Errorf("%w: status must be pending, succeeded or dead", ErrInvalidInput)
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+	return s.store.ListDeliveries(ctx, DeliveryQuery{WebhookID: webhookID, Status: status, Limit: min(limit, 200)})
+}
+
+// GetDelivery returns a delivery in ctx's workspace
+func (s *Service) GetDelivery(ctx context.Context, id string) (*Delivery, error) {
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, ErrDeliveryNotFound
+	}
+	return s.store.FindDelivery(ctx, oid)
+}
+
+// Redeliver queues a delivery to be sent again straight away with a fresh
+// set of attempts, typically to replay a dead letter once the endpoint is
+// fixed. The body is the one originally queued.
+func (s *Service) Redeliver(ctx context.Context, id string) (*Delivery, error) {
+	d, err := s.GetDelivery(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	d.Status = DeliveryPending
+	d.Attempts = 0
+	d.NextAttemptAt = time.Now()
+	if err := s.store.SaveDelivery(ctx, d); err != nil {
+		return nil, err
+	}
+	s.notify()
+	return d, nil
+}
+
+// Ping queues a webhook.ping event for one webhook, to check the endpoint
+// and its signature verification end to end
+func (s *Service) Ping(ctx context.Context, id string) (*Delivery, error) {
+	hook, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	payload := Payload{
+		ID:        primitive.NewObjectID().Hex(),
+		Type:      EventPing,
+		Workspace: hook.Workspace,
+		CreatedAt: time.Now().UTC(),
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encode webhook payload: %w", err)
+	}
+	d := newDelivery(hook, payload, body)
+	if err := s.store.InsertDeliveries(ctx, []*Delivery{d}); err != nil {
+		return nil, err
+	}
+	s.notify()
+	return d, nil
+}
+
+// NoteChanged is a notes.Listener that queues a delivery for every webhook
+// in the note's workspace whose filters match the event. The payload is
+// fixed here; matching and queueing happen in the background. Deliveries
+// are not ordered: consumers should use the note's version and updatedAt.
+func (s *Service) NoteChanged(ctx context.Context, e notes.Event) {
+	payload := Payload{
+		ID:           primitive.NewObjectID().Hex(),
+		Type:         e.Type,
+		Workspace:    e.Note.Workspace,
+		CreatedAt:    e.At.UTC(),
+		Note:         e.Note,
+		PrevCategory: e.PrevCategory,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		s.log.Error("failed to encode webhook payload", "error", err, "event", e.Type)
+		return
+	}
+
+	go func() {
+		ctx := workspace.With(context.Background(), payload.Workspace)
+		if err := s.enqueue(ctx, e, payload, body); err != nil {
+			s.log.Error("failed to queue webhook deliveries", "error", err, "event", e.Type, "note", e.Note.ID.Hex())
+		}
+	}()
+}
+
+func (s *Service) enqueue(ctx context.Context, e notes.Event, payload Payload, body []byte) error {
+	hooks, err := s.store.List(ctx)
+	if err != nil {
+		return err
+	}
+	var ds []*Delivery
+	for _, hook := range hooks {
+		if hook.wants(e) {
+			ds = append(ds, newDelivery(hook, payload, body))
+		}
+	}
+	if len(ds) == 0 {
+		return nil
+	}
+	if err := s.store.InsertDeliveries(ctx, ds); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+// wants reports whether an event passes the webhook's filters. A note
+// moved out of a watched category still counts.
+func (w *Webhook) wants(e notes.Event) bool {
+	if len(w.Events) > 0 && !slices.Contains(w.Events, e.Type) {
+		return false
+	}
+	return len(w.Categories) == 0 ||
+		slices.Contains(w.Categories, e.Note.Category) ||
+		(e.PrevCategory != "" && slices.Contains(w.Categories, e.PrevCategory))
+}
+
+func newDelivery(hook *Webhook, payload Payload, body []byte) *Delivery {
+	now := time.Now()
+	return &Delivery{
+		WebhookID:     hook.ID,
+		Workspace:     hook.Workspace,
+		EventID:       payload.ID,
+		Event:         payload.Type,
+		URL:           hook.URL,
+		Payload:       body,
+		Status:        DeliveryPending,
+		NextAttemptAt: now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
+
+// notify wakes Run early when something was queued
+func (s *Service) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends due deliveries until ctx is cancelled, checking the queue
+// whenever something is added and every few seconds for retries. It also
+// prunes succeeded deliveries past their retention now and then.
+func (s *Service) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	var lastPrune time.Time
+
+	for {
+		// A full batch suggests more are due
+		for s.sendDue(ctx) == batchSize && ctx.Err() == nil {
+		}
+
+		if time.Since(lastPrune) > time.Hour {
+			lastPrune = time.Now()
+			if pruned, err := s.store.PruneDeliveries(ctx, lastPrune.Add(-deliveryRetention)); err != nil {
+				s.log.Error("failed to prune webhook deliveries", "error", err)
+			} else if pruned > 0 {
+				s.log.Info("pruned webhook deliveries", "count", pruned)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendDue claims a batch of due deliveries and attempts them, a few at a
+// time, returning how many it claimed
+func (s *Service) sendDue(ctx context.Context) int {
+	ds, err := s.store.ClaimDue(ctx, time.Now(), claimLease, batchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			s.log.Error("failed to claim webhook deliveries", "error", err)
+		}
+		return 0
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	for _, d := range ds {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			s.attempt(ctx, d)
+		}()
+	}
+	wg.Wait()
+	return len(ds)
+}
+
+// attempt sends one delivery and records the outcome, scheduling a retry or
+// giving up when it fails
+func (s *Service) attempt(ctx context.Context, d *Delivery) {
+	hook, err := s.store.Find(workspace.With(ctx, d.Workspace), d.WebhookID)
+	if errors.Is(err, ErrWebhookNotFound) {
+		return // deleted along with its deliveries
+	}
+	if err != nil {
+		s.log.Error("failed to load webhook", "error", err, "webhook", d.WebhookID.Hex())
+		return // the lease runs out and it is tried again
+	}
+
+	d.Attempts++
+	d.LastStatus, err = s.send(ctx, hook, d)
+	if err != nil && ctx.Err() != nil {
+		return // shutting down; the lease runs out and it is tried again
+	}
+	switch {
+	case err == nil:
+		d.Status, d.LastError = DeliverySucceeded, ""
+	case d.Attempts >= s.maxAttempts:
+		d.Status, d.LastError = DeliveryDead, err.Error()
+		s.log.Warn("webhook delivery failed for good", "delivery", d.ID.Hex(), "url", d.URL, "attempts", d.Attempts, "error", err)
+	default:
+		d.LastError = err.Error()
+		d.NextAttemptAt = time.Now().Add(s.backoff(d.Attempts))
+	}
+	if err := s.store.SaveDelivery(ctx, d); err != nil {
+		s.log.Error("failed to save webhook delivery", "error", err, "delivery", d.ID.Hex())
+	}
+}
+
+// backoff returns the wait after the given number of failed attempts
+func (s *Service) backoff(attempts int) time.Duration {
+	wait := s.retryBase
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxBackoff)
+}
+
+// send POSTs a delivery's payload, signed with the webhook's secret. Any
+// answer other than 2xx is an error.
+func (s *Service) send(ctx context.Context, hook *Webhook, d *Delivery) (status int, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "scratchpad-webhooks")
+	req.Header.Set(HeaderEvent, string(d.Event))
+	req.Header.Set(HeaderDelivery, d.ID.Hex())
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg := "HTTP " + strconv.Itoa(resp.StatusCode)
+		if text := strings.TrimSpace(string(snippet)); text != "" {
+			msg += ": " + text
+		}
+		return resp.StatusCode, errors.New(msg)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the X-Scratchpad-Signature value for a body sent at
+// timestamp (Unix seconds). Receivers recompute it to check a delivery is
+// genuine, and reject old timestamps to stop replays.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,391 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"scratchpad/internal/db"
+	"scratchpad/internal/notes"
+	"scratchpad/internal/workspace"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newTestStores returns one empty store per backend that runs without an
+// external server
+func newTestStores(t *testing.T) map[string]Store {
+	t.Helper()
+	ctx := context.Background()
+	database, err := db.OpenSQLite(ctx, filepath.Join(t.TempDir(), "webhooks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Close() })
+	sqlite := NewSQLiteRepo(database)
+	if err := sqlite.EnsureIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return map[string]Store{"memory": NewMemoryRepo(), "sqlite": sqlite}
+}
+
+func newTestService(store Store) *Service {
+	return NewService(store, slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+// receiver is a webhook endpoint that records what it is sent and answers
+// with a status that can be changed between attempts
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	status   int
+	requests []receivedRequest
+}
+
+type receivedRequest struct {
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T) *receiver {
+	rc := &receiver{status: http.StatusNoContent}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rc.mu.Lock()
+		defer rc.mu.Unlock()
+		rc.requests = append(rc.requests, receivedRequest{header: r.Header.Clone(), body: body})
+		w.WriteHeader(rc.status)
+		if rc.status >= 300 {
+			io.WriteString(w, "boom")
+		}
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *receiver) answer(status int) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.status = status
+}
+
+func (rc *receiver) received() []receivedRequest {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return slices.Clone(rc.requests)
+}
+
+func TestSign(t *testing.T) {
+	// Computed independently: HMAC-SHA256 of `1700000000.{"id":"1"}`
+	want := "sha256=30ecd150dcb098bf84ad6f763b78db9a8e3807ab9fcafbb2f28d4665f3e2a825"
+	if got := Sign("whsec_test_secret_0123", "1700000000", []byte(`{"id":"1"}`)); got != want {
+		t.Errorf("Sign = %s, want %s", got, want)
+	}
+	if Sign("another_secret_0123", "1700000000", []byte(`{"id":"1"}`)) == want {
+		t.Error("signature does not depend on the secret")
+	}
+	if Sign("whsec_test_secret_0123", "1700000001", []byte(`{"id":"1"}`)) == want {
+		t.Error("signature does not depend on the timestamp")
+	}
+}
+
+func TestDeliverySigned(t *testing.T) {
+	ctx := context.Background()
+	rc := newReceiver(t)
+	svc := newTestService(NewMemoryRepo())
+	hook, err := svc.Create(ctx, CreateWebhookInput{URL: rc.URL, Secret: "a-secret-of-16-chars"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := svc.Ping(ctx, hook.ID.Hex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := svc.sendDue(ctx); n != 1 {
+		t.Fatalf("sent %d deliveries, want 1", n)
+	}
+
+	reqs := rc.received()
+	if len(reqs) != 1 {
+		t.Fatalf("endpoint got %d requests, want 1", len(reqs))
+	}
+	h := reqs[0].header
+	if h.Get(HeaderEvent) != string(EventPing) || h.Get(HeaderDelivery) != d.ID.Hex() || h.Get("Content-Type") != "application/json" {
+		t.Errorf("headers = %v", h)
+	}
+	ts, err := strconv.ParseInt(h.Get(HeaderTimestamp), 10, 64)
+	if err != nil || time.Since(time.Unix(ts, 0)) > time.Minute {
+		t.Errorf("timestamp = %q, want the current Unix time", h.Get(HeaderTimestamp))
+	}
+	if want := Sign("a-secret-of-16-chars", h.Get(HeaderTimestamp), reqs[0].body); h.Get(HeaderSignature) != want {
+		t.Errorf("signature = %s, want %s", h.Get(HeaderSignature), want)
+	}
+	var p Payload
+	if err := json.Unmarshal(reqs[0].body, &p); err != nil || p.Type != EventPing || p.Workspace != workspace.Default {
+		t.Errorf("payload = %s, %v", reqs[0].body, err)
+	}
+
+	got, err := svc.GetDelivery(ctx, d.ID.Hex())
+	if err != nil || got.Status != DeliverySucceeded || got.Attempts != 1 || got.LastStatus != http.StatusNoContent {
+		t.Errorf("delivery = %+v, %v; want succeeded on the first attempt", got, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	svc := newTestService(NewMemoryRepo())
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{8, 64 * time.Minute},
+		{20, maxBackoff},
+	}
+	for _, tt := range tests {
+		if got := svc.backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// sendUntil keeps sending due deliveries until d reaches status, the way
+// Run does on its ticker
+func sendUntil(t *testing.T, svc *Service, id string, status DeliveryStatus) *Delivery {
+	t.Helper()
+	ctx := context.Background()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		svc.sendDue(ctx)
+		d, err := svc.GetDelivery(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d.Status == status {
+			return d
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery is %s after %d attempts, want %s", d.Status, d.Attempts, status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestRetriesAndRedeliver(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			rc := newReceiver(t)
+			rc.answer(http.StatusInternalServerError)
+			svc := newTestService(store)
+			if err := svc.SetRetryPolicy(3, time.Millisecond); err != nil {
+				t.Fatal(err)
+			}
+			hook, err := svc.Create(ctx, CreateWebhookInput{URL: rc.URL})
+			if err != nil {
+				t.Fatal(err)
+			}
+			queued, err := svc.Ping(ctx, hook.ID.Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			dead := sendUntil(t, svc, queued.ID.Hex(), DeliveryDead)
+			if dead.Attempts != 3 || dead.LastStatus != http.StatusInternalServerError || dead.LastError != "HTTP 500: boom" {
+				t.Errorf("dead delivery = %+v, want 3 attempts ending in HTTP 500: boom", dead)
+			}
+			if n := len(rc.received()); n != 3 {
+				t.Errorf("endpoint got %d requests, want 3", n)
+			}
+			letters, err := svc.Deliveries(ctx, primitive.NilObjectID, DeliveryDead, 0)
+			if err != nil || len(letters) != 1 || letters[0].ID != queued.ID {
+				t.Errorf("dead letters = %v, %v; want the delivery", letters, err)
+			}
+
+			// Once the endpoint is fixed, a redelivery starts over and
+			// sends the same body
+			rc.answer(http.StatusOK)
+			redelivered, err := svc.Redeliver(ctx, queued.ID.Hex())
+			if err != nil || redelivered.Status != DeliveryPending || redelivered.Attempts != 0 {
+				t.Fatalf("redeliver = %+v, %v; want it pending with no attempts", redelivered, err)
+			}
+			done := sendUntil(t, svc, queued.ID.Hex(), DeliverySucceeded)
+			if done.Attempts != 1 || done.LastError != "" {
+				t.Errorf("redelivery = %+v, want success on its first attempt", done)
+			}
+			reqs := rc.received()
+			if len(reqs) != 4 || string(reqs[3].body) != string(reqs[0].body) {
+				t.Errorf("got %d requests, want a 4th with the original body", len(reqs))
+			}
+			if _, err := svc.Redeliver(ctx, primitive.NewObjectID().Hex()); !errors.Is(err, ErrDeliveryNotFound) {
+				t.Errorf("redeliver unknown: err = %v, want %v", err, ErrDeliveryNotFound)
+			}
+		})
+	}
+}
+
+func TestClaimDue(t *testing.T) {
+	for name, store := range newTestStores(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now().Truncate(time.Millisecond)
+			team := workspace.With(ctx, "team")
+			hooks := map[string]*Webhook{}
+			for _, ws := range []string{workspace.Default, "team"} {
+				hook := &Webhook{URL: "http://example.com", Secret: "a-secret-of-16-chars"}
+				if err := store.Insert(workspace.With(ctx, ws), hook); err != nil {
+					t.Fatal(err)
+				}
+				hooks[ws] = hook
+			}
+
+			delivery := func(ws string, status DeliveryStatus, due time.Duration) *Delivery {
+				return &Delivery{
+					WebhookID: hooks[ws].ID, Workspace: ws, EventID: "e", Event: EventPing,
+					URL: "http://example.com", Payload: json.RawMessage(`{}`), Status: status,
+					NextAttemptAt: now.Add(due), CreatedAt: now, UpdatedAt: now,
+				}
+			}
+			oldest := delivery(workspace.Default, DeliveryPending, -time.Hour)
+			other := delivery("team", DeliveryPending, -time.Minute)
+			newest := delivery(workspace.Default, DeliveryPending, 0)
+			later := delivery(workspace.Default, DeliveryPending, 20*time.Second)
+			dead := delivery(workspace.Default, DeliveryDead, -time.Hour)
+			done := delivery(workspace.Default, DeliverySucceeded, -time.Hour)
+			if err := store.InsertDeliveries(ctx, []*Delivery{newest, later, dead, done, oldest}); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.InsertDeliveries(team, []*Delivery{other}); err != nil {
+				t.Fatal(err)
+			}
+
+			lease := 30 * time.Second
+			claimed, err := store.ClaimDue(ctx, now, lease, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := deliveryIDs(claimed); !slices.Equal(got, deliveryIDs([]*Delivery{oldest, other})) {
+				t.Errorf("first claim = %v, want the two most overdue, across workspaces", got)
+			}
+			claimed, _ = store.ClaimDue(ctx, now, lease, 10)
+			if got := deliveryIDs(claimed); !slices.Equal(got, deliveryIDs([]*Delivery{newest})) {
+				t.Errorf("second claim = %v, want only what the first left", got)
+			}
+			if claimed, _ := store.ClaimDue(ctx, now, lease, 10); len(claimed) != 0 {
+				t.Errorf("claimed %v again while leased", deliveryIDs(claimed))
+			}
+
+			// Leases run out, and later deliveries come due, with time
+			claimed, _ = store.ClaimDue(ctx, now.Add(lease), lease, 10)
+			if got := deliveryIDs(claimed); !slices.Equal(got, deliveryIDs([]*Delivery{oldest, other, newest, later})) {
+				t.Errorf("claim after the lease = %v, want every pending delivery", got)
+			}
+			for _, d := range claimed {
+				if !d.NextAttemptAt.Equal(now.Add(2 * lease)) {
+					t.Errorf("%s: next attempt at %v, want it pushed back by the lease", d.ID.Hex(), d.NextAttemptAt)
+				}
+			}
+		})
+	}
+}
+
+func deliveryIDs(ds []*Delivery) []string {
+	ids := make([]string, len(ds))
+	for i, d := range ds {
+		ids[i] = d.ID.Hex()
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestWants(t *testing.T) {
+	note := func(category string) *notes.Note { return &notes.Note{Category: category} }
+	tests := []struct {
+		name  string
+		hook  Webhook
+		event notes.Event
+		want  bool
+	}{
+		{"no filters", Webhook{}, notes.Event{Type: notes.EventNoteDeleted, Note: note("x")}, true},
+		{"event listed", Webhook{Events: []notes.EventType{notes.EventNoteCreated}}, notes.Event{Type: notes.EventNoteCreated, Note: note("x")}, true},
+		{"event not listed", Webhook{Events: []notes.EventType{notes.EventNoteCreated}}, notes.Event{Type: notes.EventNoteUpdated, Note: note("x")}, false},
+		{"category listed", Webhook{Categories: []string{"research"}}, notes.Event{Type: notes.EventNoteCreated, Note: note("research")}, true},
+		{"category not listed", Webhook{Categories: []string{"research"}}, notes.Event{Type: notes.EventNoteCreated, Note: note("ideas")}, false},
+		{"moved out of a watched category", Webhook{Categories: []string{"research"}}, notes.Event{Type: notes.EventNoteUpdated, Note: note("archive"), PrevCategory: "research"}, true},
+		{"moved between others", Webhook{Categories: []string{"research"}}, notes.Event{Type: notes.EventNoteUpdated, Note: note("archive"), PrevCategory: "ideas"}, false},
+		{"both filters pass", Webhook{Events: []notes.EventType{notes.EventNoteUpdated}, Categories: []string{"research"}}, notes.Event{Type: notes.EventNoteUpdated, Note: note("research")}, true},
+		{"only the category passes", Webhook{Events: []notes.EventType{notes.EventNoteCreated}, Categories: []string{"research"}}, notes.Event{Type: notes.EventNoteUpdated, Note: note("research")}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.hook.wants(tt.event); got != tt.want {
+				t.Errorf("wants = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnqueueMatchesWorkspaceHooks(t *testing.T) {
+	ctx := context.Background()
+	svc := newTestService(NewMemoryRepo())
+	research, err := svc.Create(ctx, CreateWebhookInput{URL: "http://example.com/a", Categories: []string{"Research"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Create(ctx, CreateWebhookInput{URL: "http://example.com/b", Events: []notes.EventType{notes.EventNoteDeleted}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Create(workspace.With(ctx, "team"), CreateWebhookInput{URL: "http://example.com/c"}); err != nil {
+		t.Fatal(err)
+	}
+
+	e := notes.Event{Type: notes.EventNoteCreated, Note: &notes.Note{Category: "research"}, At: time.Now()}
+	if err := svc.enqueue(ctx, e, Payload{ID: "evt", Type: e.Type}, []byte(`{}`)); err != nil {
+		t.Fatal(err)
+	}
+	queued, err := svc.Deliveries(ctx, primitive.NilObjectID, DeliveryPending, 0)
+	if err != nil || len(queued) != 1 || queued[0].WebhookID != research.ID {
+		t.Errorf("queued %v, %v; want one delivery to the research webhook", queued, err)
+	}
+}
+
+func TestCreateValidation(t *testing.T) {
+	svc := newTestService(NewMemoryRepo())
+	tests := []struct {
+		name  string
+		input CreateWebhookInput
+	}{
+		{"relative URL", CreateWebhookInput{URL: "/hook"}},
+		{"other scheme", CreateWebhookInput{URL: "ftp://example.com"}},
+		{"unknown event", CreateWebhookInput{URL: "http://example.com", Events: []notes.EventType{"note.viewed"}}},
+		{"short secret", CreateWebhookInput{URL: "http://example.com", Secret: "short"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.Create(context.Background(), tt.input); !errors.Is(err, ErrInvalidInput) {
+				t.Errorf("err = %v, want %v", err, ErrInvalidInput)
+			}
+		})
+	}
+
+	created, err := svc.Create(context.Background(), CreateWebhookInput{URL: "https://example.com/hook", Categories: []string{"Work Log", "work-log"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(created.Secret, secretPrefix) || !slices.Equal(created.Categories, []string{"work-log"}) {
+		t.Errorf("created %+v with secret %q, want a generated secret and normalized categories", created.Webhook, created.Secret)
+	}
+}
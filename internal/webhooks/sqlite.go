@@ -0,0 +1,327 @@
+package webhooks
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"scratchpad/internal/notes"
+	"scratchpad/internal/workspace"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// The notes store owns PRAGMA user_version for the shared database, so the
+// webhook tables are created idempotently instead of through a migration.
+const sqliteWebhookSchema = `CREATE TABLE IF NOT EXISTS webhooks (
+	id         TEXT    PRIMARY KEY,
+	workspace  TEXT    NOT NULL,
+	url        TEXT    NOT NULL,
+	events     TEXT    NOT NULL DEFAULT '[]',
+	categories TEXT    NOT NULL DEFAULT '[]',
+	secret     TEXT    NOT NULL,
+	created_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webhooks_workspace ON webhooks(workspace, created_at);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+	id              TEXT    PRIMARY KEY,
+	webhook_id      TEXT    NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+	workspace       TEXT    NOT NULL,
+	event_id        TEXT    NOT NULL,
+	event           TEXT    NOT NULL,
+	url             TEXT    NOT NULL,
+	payload         TEXT    NOT NULL,
+	status          TEXT    NOT NULL,
+	attempts        INTEGER NOT NULL DEFAULT 0,
+	last_status     INTEGER NOT NULL DEFAULT 0,
+	last_error      TEXT    NOT NULL DEFAULT '',
+	next_attempt_at INTEGER NOT NULL,
+	created_at      INTEGER NOT NULL,
+	updated_at      INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_webhook ON webhook_deliveries(workspace, webhook_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_status ON webhook_deliveries(workspace, status, created_at);
+CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);`
+
+const (
+	sqliteWebhookColumns  = "id, workspace, url, events, categories, secret, created_at"
+	sqliteDeliveryColumns = "id, webhook_id, workspace, event_id, event, url, payload, status, attempts, " +
+		"last_status, last_error, next_attempt_at, created_at, updated_at"
+)
+
+// SQLiteRepo is a Store kept in the same SQLite database as the notes
+type SQLiteRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteRepo(db *sql.DB) *SQLiteRepo {
+	return &SQLiteRepo{db: db}
+}
+
+// EnsureIndexes creates the webhook tables
+func (r *SQLiteRepo) EnsureIndexes(ctx context.Context) error {
+	if _, err := r.db.ExecContext(ctx, sqliteWebhookSchema); err != nil {
+		return fmt.Errorf("create webhook tables: %w", err)
+	}
+	return nil
+}
+
+// Insert stores a new webhook
+func (r *SQLiteRepo) Insert(ctx context.Context, w *Webhook) error {
+	w.ID = primitive.NewObjectID()
+	w.Workspace = workspace.FromContext(ctx)
+	w.CreatedAt = time.Now()
+
+	events, _ := json.Marshal(w.Events)
+	categories, _ := json.Marshal(w.Categories)
+	_, err := r.db.ExecContext(ctx,
+		"INSERT INTO webhooks ("+sqliteWebhookColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)",
+		w.ID.Hex(), w.Workspace, w.URL, string(events), string(categories), w.Secret, w.CreatedAt.UnixMilli(),
+	)
+	if err != nil {
+		return fmt.Errorf("insert webhook: %w", err)
+	}
+	return nil
+}
+
+// Find retrieves a webhook by ID
+func (r *SQLiteRepo) Find(ctx context.Context, id primitive.ObjectID) (*Webhook, error) {
+	row := r.db.QueryRowContext(ctx,
+		"SELECT "+sqliteWebhookColumns+" FROM webhooks WHERE id = ? AND workspace = ?",
+		id.Hex(), workspace.FromContext(ctx))
+
+	w, err := scanSQLiteWebhook(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find webhook: %w", err)
+	}
+	return w, nil
+}
+
+// List returns the workspace's webhooks, oldest first
+func (r *SQLiteRepo) List(ctx context.Context) ([]*Webhook, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+sqliteWebhookColumns+" FROM webhooks WHERE workspace = ? ORDER BY created_at, id",
+		workspace.FromContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	var hooks []*Webhook
+	for rows.Next() {
+		w, err := scanSQLiteWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("decode webhook: %w", err)
+		}
+		hooks = append(hooks, w)
+	}
+	return hooks, rows.Err()
+}
+
+// Delete removes a webhook; its deliveries go with it by cascade
+func (r *SQLiteRepo) Delete(ctx context.Context, id primitive.ObjectID) error {
+	res, err := r.db.ExecContext(ctx,
+		"DELETE FROM webhooks WHERE id = ? AND workspace = ?", id.Hex(), workspace.FromContext(ctx))
+	if err != nil {
+		return fmt.Errorf("delete webhook %s: %w", id.Hex(), err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// InsertDeliveries stores new deliveries in one transaction
+func (r *SQLiteRepo) InsertDeliveries(ctx context.Context, ds []*Delivery) error {
+	if len(ds) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin insert webhook deliveries: %w", err)
+	}
+	defer tx.Rollback()
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", 14), ", ")
+	stmt, err := tx.PrepareContext(ctx, "INSERT INTO webhook_deliveries ("+sqliteDeliveryColumns+") VALUES ("+placeholders+")")
+	if err != nil {
+		return fmt.Errorf("prepare insert webhook delivery: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, d := range ds {
+		d.ID = primitive.NewObjectID()
+		_, err := stmt.ExecContext(ctx,
+			d.ID.Hex(), d.WebhookID.Hex(), d.Workspace, d.EventID, string(d.Event), d.URL, string(d.Payload),
+			string(d.Status), d.Attempts, d.LastStatus, d.LastError,
+			d.NextAttemptAt.UnixMilli(), d.CreatedAt.UnixMilli(), d.UpdatedAt.UnixMilli(),
+		)
+		if err != nil {
+			return fmt.Errorf("insert webhook delivery: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+// FindDelivery retrieves a delivery by ID
+func (r *SQLiteRepo) FindDelivery(ctx context.Context, id primitive.ObjectID) (*Delivery, error) {
+	row := r.db.QueryRowContext(ctx,
+		"SELECT "+sqliteDeliveryColumns+" FROM webhook_deliveries WHERE id = ? AND workspace = ?",
+		id.Hex(), workspace.FromContext(ctx))
+
+	d, err := scanSQLiteDelivery(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("find webhook delivery: %w", err)
+	}
+	return d, nil
+}
+
+// ListDeliveries returns deliveries matching q, newest first
+func (r *SQLiteRepo) ListDeliveries(ctx context.Context, q DeliveryQuery) ([]*Delivery, error) {
+	where := []string{"workspace = ?"}
+	args := []any{workspace.FromContext(ctx)}
+	if !q.WebhookID.IsZero() {
+		where = append(where, "webhook_id = ?")
+		args = append(args, q.WebhookID.Hex())
+	}
+	if q.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, string(q.Status))
+	}
+	args = append(args, q.Limit)
+
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT "+sqliteDeliveryColumns+" FROM webhook_deliveries WHERE "+strings.Join(where, " AND ")+
+			" ORDER BY created_at DESC, id DESC LIMIT ?", args...)
+	if err != nil {
+		return nil, fmt.Errorf("list webhook deliveries: %w", err)
+	}
+	return collectSQLiteDeliveries(rows)
+}
+
+// ClaimDue leases due deliveries in a single statement
+func (r *SQLiteRepo) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*Delivery, error) {
+	rows, err := r.db.QueryContext(ctx,
+		`UPDATE webhook_deliveries SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM webhook_deliveries
+			WHERE status = ? AND next_attempt_at <= ?
+			ORDER BY next_attempt_at LIMIT ?
+		)
+		RETURNING `+sqliteDeliveryColumns,
+		now.Add(lease).UnixMilli(), string(DeliveryPending), now.UnixMilli(), limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("claim webhook deliveries: %w", err)
+	}
+	return collectSQLiteDeliveries(rows)
+}
+
+// SaveDelivery records the outcome of an attempt
+func (r *SQLiteRepo) SaveDelivery(ctx context.Context, d *Delivery) error {
+	d.UpdatedAt = time.Now()
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, last_status = ?, last_error = ?, next_attempt_at = ?, updated_at = ?
+		WHERE id = ?`,
+		string(d.Status), d.Attempts, d.LastStatus, d.LastError,
+		d.NextAttemptAt.UnixMilli(), d.UpdatedAt.UnixMilli(), d.ID.Hex(),
+	)
+	if err != nil {
+		return fmt.Errorf("save webhook delivery %s: %w", d.ID.Hex(), err)
+	}
+	return nil
+}
+
+// PruneDeliveries removes old succeeded deliveries
+func (r *SQLiteRepo) PruneDeliveries(ctx context.Context, cutoff time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx,
+		"DELETE FROM webhook_deliveries WHERE status = ? AND updated_at < ?",
+		string(DeliverySucceeded), cutoff.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("prune webhook deliveries: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+type sqliteScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanSQLiteWebhook(s sqliteScanner) (*Webhook, error) {
+	var w Webhook
+	var id, events, categories string
+	var createdAt int64
+	if err := s.Scan(&id, &w.Workspace, &w.URL, &events, &categories, &w.Secret, &createdAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(events), &w.Events); err != nil {
+		return nil, fmt.Errorf("decode webhook events: %w", err)
+	}
+	if err := json.Unmarshal([]byte(categories), &w.Categories); err != nil {
+		return nil, fmt.Errorf("decode webhook categories: %w", err)
+	}
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("decode webhook id %q: %w", id, err)
+	}
+	w.ID = oid
+	w.CreatedAt = time.UnixMilli(createdAt).UTC()
+	return &w, nil
+}
+
+func scanSQLiteDelivery(s sqliteScanner) (*Delivery, error) {
+	var d Delivery
+	var id, webhookID, event, payload, status string
+	var nextAttemptAt, createdAt, updatedAt int64
+	err := s.Scan(&id, &webhookID, &d.Workspace, &d.EventID, &event, &d.URL, &payload, &status, &d.Attempts,
+		&d.LastStatus, &d.LastError, &nextAttemptAt, &createdAt, &updatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	oid, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return nil, fmt.Errorf("decode webhook delivery id %q: %w", id, err)
+	}
+	hookID, err := primitive.ObjectIDFromHex(webhookID)
+	if err != nil {
+		return nil, fmt.Errorf("decode webhook id %q: %w", webhookID, err)
+	}
+	d.ID, d.WebhookID = oid, hookID
+	d.Event = notes.EventType(event)
+	d.Payload = json.RawMessage(payload)
+	d.Status = DeliveryStatus(status)
+	d.NextAttemptAt = time.UnixMilli(nextAttemptAt).UTC()
+	d.CreatedAt = time.UnixMilli(createdAt).UTC()
+	d.UpdatedAt = time.UnixMilli(updatedAt).UTC()
+	return &d, nil
+}
+
+func collectSQLiteDeliveries(rows *sql.Rows) ([]*Delivery, error) {
+	defer rows.Close()
+	var ds []*Delivery
+	for rows.Next() {
+		d, err := scanSQLiteDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("decode webhook delivery: %w", err)
+		}
+		ds = append(ds, d)
+	}
+	return ds, rows.Err()
+}
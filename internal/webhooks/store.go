@@ -0,0 +1,55 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	ErrWebhookNotFound  = errors.New("webhook not found")
+	ErrDeliveryNotFound = errors.New("delivery not found")
+	ErrInvalidInput     = errors.New("invalid input")
+)
+
+// Store persists webhooks and their deliveries. Repo (MongoDB), SQLiteRepo
+// and MemoryRepo implement it alongside the matching notes stores. Methods
+// other than ClaimDue and SaveDelivery act on the workspace in ctx.
+type Store interface {
+	// EnsureIndexes prepares the underlying storage (indexes, tables)
+	EnsureIndexes(ctx context.Context) error
+	// Insert assigns ID, Workspace and CreatedAt and stores a new webhook
+	Insert(ctx context.Context, w *Webhook) error
+	// Find returns ErrWebhookNotFound when no webhook has the ID
+	Find(ctx context.Context, id primitive.ObjectID) (*Webhook, error)
+	// List returns the webhooks, oldest first
+	List(ctx context.Context) ([]*Webhook, error)
+	// Delete removes a webhook and its deliveries, returning
+	// ErrWebhookNotFound when no webhook has the ID
+	Delete(ctx context.Context, id primitive.ObjectID) error
+
+	// InsertDeliveries assigns IDs and stores new deliveries as given
+	InsertDeliveries(ctx context.Context, ds []*Delivery) error
+	// FindDelivery returns ErrDeliveryNotFound when no delivery has the ID
+	FindDelivery(ctx context.Context, id primitive.ObjectID) (*Delivery, error)
+	// ListDeliveries returns deliveries matching q, newest first
+	ListDeliveries(ctx context.Context, q DeliveryQuery) ([]*Delivery, error)
+	// ClaimDue returns up to limit pending deliveries, in any workspace,
+	// whose next attempt is due by now, pushing that attempt back by lease
+	// so no other worker picks them up meanwhile
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]*Delivery, error)
+	// SaveDelivery records the outcome of an attempt: status, attempts,
+	// last status and error, and next attempt time
+	SaveDelivery(ctx context.Context, d *Delivery) error
+	// PruneDeliveries removes succeeded deliveries last updated before
+	// cutoff, in any workspace, returning how many went
+	PruneDeliveries(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+var (
+	_ Store = (*Repo)(nil)
+	_ Store = (*SQLiteRepo)(nil)
+	_ Store = (*MemoryRepo)(nil)
+)
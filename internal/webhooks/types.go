@@ -0,0 +1,97 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"time"
+
+	"scratchpad/internal/notes"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// EventPing is sent by POST /api/webhooks/{id}/ping to check an endpoint;
+// subscriptions receive it whatever their event filter
+const EventPing notes.EventType = "webhook.ping"
+
+// AllEvents lists the note events a webhook can subscribe to
+var AllEvents = []notes.EventType{
+	notes.EventNoteCreated,
+	notes.EventNoteUpdated,
+	notes.EventNoteDeleted,
+	notes.EventNoteRestored,
+}
+
+// Webhook is a subscription to note events in one workspace. The secret
+// signs every delivery; it is shown once, when the webhook is created.
+type Webhook struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Workspace  string             `bson:"workspace" json:"workspace"`
+	URL        string             `bson:"url" json:"url"`
+	Events     []notes.EventType  `bson:"events,omitempty" json:"events,omitempty"`         // empty means all events
+	Categories []string           `bson:"categories,omitempty" json:"categories,omitempty"` // empty means all categories
+	Secret     string             `bson:"secret" json:"-"`
+	CreatedAt  time.Time          `bson:"created_at" json:"createdAt"`
+}
+
+// CreateWebhookInput is the input for creating a webhook. A secret is
+// generated when none is given.
+type CreateWebhookInput struct {
+	URL        string            `json:"url"`
+	Events     []notes.EventType `json:"events,omitempty"`
+	Categories []string          `json:"categories,omitempty"`
+	Secret     string            `json:"secret,omitempty"`
+}
+
+// CreatedWebhook is a new webhook together with its signing secret
+type CreatedWebhook struct {
+	*Webhook
+	Secret string `json:"secret"`
+}
+
+// DeliveryStatus is where a delivery stands
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"   // waiting for its first or next attempt
+	DeliverySucceeded DeliveryStatus = "succeeded" // the endpoint answered 2xx
+	DeliveryDead      DeliveryStatus = "dead"      // out of attempts; retry it by hand
+)
+
+// Delivery is one event on its way to one webhook, and the log of how
+// that went. The payload is fixed when the event happens, so retries send
+// exactly the same body.
+type Delivery struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	WebhookID     primitive.ObjectID `bson:"webhook_id" json:"webhookId"`
+	Workspace     string             `bson:"workspace" json:"workspace"`
+	EventID       string             `bson:"event_id" json:"eventId"`
+	Event         notes.EventType    `bson:"event" json:"event"`
+	URL           string             `bson:"url" json:"url"`
+	Payload       json.RawMessage    `bson:"payload" json:"payload"`
+	Status        DeliveryStatus     `bson:"status" json:"status"`
+	Attempts      int                `bson:"attempts" json:"attempts"`
+	LastStatus    int                `bson:"last_status,omitempty" json:"lastStatus,omitempty"` // HTTP status of the last attempt
+	LastError     string             `bson:"last_error,omitempty" json:"lastError,omitempty"`
+	NextAttemptAt time.Time          `bson:"next_attempt_at" json:"nextAttemptAt"`
+	CreatedAt     time.Time          `bson:"created_at" json:"createdAt"`
+	UpdatedAt     time.Time          `bson:"updated_at" json:"updatedAt"`
+}
+
+// DeliveryQuery filters the delivery log
+type DeliveryQuery struct {
+	WebhookID primitive.ObjectID // zero means every webhook in the workspace
+	Status    DeliveryStatus     // empty means any status
+	Limit     int
+}
+
+// Payload is the JSON body POSTed to a webhook
+type Payload struct {
+	ID        string          `json:"id"` // the same for every webhook the event goes to
+	Type      notes.EventType `json:"type"`
+	Workspace string          `json:"workspace"`
+	CreatedAt time.Time       `json:"createdAt"`
+	// Note is the note after the change; for deletes, as it was when it went
+	// to the trash. Pings carry none.
+	Note         *notes.Note `json:"note,omitempty"`
+	PrevCategory string      `json:"prevCategory,omitempty"`
+}
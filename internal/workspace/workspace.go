@@ -0,0 +1,120 @@
+// Package workspace carries the workspace a request works in. Every note
+// belongs to exactly one workspace, and stores only ever see the notes of
+// the workspace in their context.
+package workspace
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const (
+	// Default is used when a request doesn't pick a workspace; notes
+	// written before workspaces existed belong to it
+	Default = "default"
+	// Header selects a workspace when the URL has no /w/{workspace} prefix
+	Header = "X-Workspace"
+	// pathPrefix starts URLs addressed to a workspace, e.g. /w/team/api/notes
+	pathPrefix = "/w/"
+)
+
+var validID = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+type contextKey struct{}
+
+type selection struct {
+	id       string
+	fromPath bool // chosen by the /w/{workspace} prefix, which links must keep
+}
+
+// Normalize lowercases and trims a workspace ID, returning an error when it
+// isn't 1-64 letters, digits, '-' or '_'
+func Normalize(id string) (string, error) {
+	id = strings.ToLower(strings.TrimSpace(id))
+	if !validID.MatchString(id) {
+		return "", fmt.Errorf("invalid workspace %q: use 1-64 letters, digits, '-' or '_'", id)
+	}
+	return id, nil
+}
+
+// With selects workspace id for ctx. id must already be normalized.
+func With(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, selection{id: id})
+}
+
+// FromContext returns the workspace selected for ctx, or Default
+func FromContext(ctx context.Context) string {
+	if id, ok := Selected(ctx); ok {
+		return id
+	}
+	return Default
+}
+
+// Selected returns the workspace explicitly selected for ctx. ok is false
+// when the request didn't name one.
+func Selected(ctx context.Context) (id string, ok bool) {
+	sel, ok := ctx.Value(contextKey{}).(selection)
+	return sel.id, ok
+}
+
+// Path returns p as seen from the client: prefixed with /w/{workspace} when
+// the request came in through that prefix, unchanged otherwise. Use it for
+// every link and redirect the web UI emits.
+func Path(ctx context.Context, p string) string {
+	if sel, ok := ctx.Value(contextKey{}).(selection); ok && sel.fromPath {
+		return pathPrefix + sel.id + p
+	}
+	return p
+}
+
+// Middleware selects the workspace from a /w/{workspace}/ URL prefix, which
+// it strips before routing, or from the X-Workspace header. Invalid or
+// conflicting selections are rejected with 400.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get(Header)
+
+		rest, prefixed := strings.CutPrefix(r.URL.Path, pathPrefix)
+		if !prefixed {
+			if header == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			id, err := Normalize(header)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(With(r.Context(), id)))
+			return
+		}
+
+		raw, _, hasSlash := strings.Cut(rest, "/")
+		id, err := Normalize(raw)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if header != "" && strings.ToLower(strings.TrimSpace(header)) != id {
+			http.Error(w, "X-Workspace header does not match the URL", http.StatusBadRequest)
+			return
+		}
+		if !hasSlash {
+			http.Redirect(w, r, pathPrefix+id+"/", http.StatusMovedPermanently)
+			return
+		}
+
+		// Same rewrite as http.StripPrefix
+		prefix := pathPrefix + raw
+		r2 := r.WithContext(context.WithValue(r.Context(), contextKey{}, selection{id: id, fromPath: true}))
+		r2.URL = new(url.URL)
+		*r2.URL = *r.URL
+		r2.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+		r2.URL.RawPath = strings.TrimPrefix(r.URL.RawPath, prefix)
+		next.ServeHTTP(w, r2)
+	})
+}
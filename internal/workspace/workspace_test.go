@@ -0,0 +1,50 @@
+package workspace
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		header   string
+		want     int
+		wantWS   string
+		wantPath string
+		wantLink string // what Path makes of "/note/1"
+	}{
+		{"nothing selected", "/api/notes", "", http.StatusOK, Default, "/api/notes", "/note/1"},
+		{"header", "/api/notes", " Team ", http.StatusOK, "team", "/api/notes", "/note/1"},
+		{"path prefix", "/w/team/api/notes", "", http.StatusOK, "team", "/api/notes", "/w/team/note/1"},
+		{"path and matching header", "/w/team/api/notes", "TEAM", http.StatusOK, "team", "/api/notes", "/w/team/note/1"},
+		{"path and other header", "/w/team/api/notes", "other", http.StatusBadRequest, "", "", ""},
+		{"invalid header", "/api/notes", "no spaces", http.StatusBadRequest, "", "", ""},
+		{"invalid path", "/w/-team/api/notes", "", http.StatusBadRequest, "", "", ""},
+		{"bare prefix", "/w/team", "", http.StatusMovedPermanently, "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotWS, gotPath, gotLink string
+			h := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotWS, gotPath, gotLink = FromContext(r.Context()), r.URL.Path, Path(r.Context(), "/note/1")
+			}))
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.header != "" {
+				r.Header.Set(Header, tt.header)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if gotWS != tt.wantWS || gotPath != tt.wantPath || gotLink != tt.wantLink {
+				t.Errorf("got workspace %q, path %q, link %q; want %q, %q, %q",
+					gotWS, gotPath, gotLink, tt.wantWS, tt.wantPath, tt.wantLink)
+			}
+		})
+	}
+}
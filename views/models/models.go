@@ -7,8 +7,79 @@ type NoteView struct {
 	ID        string
 	Category  string
 	Content   string
+	Tags      []string
 	CreatedAt time.Time
 	UpdatedAt time.Time
+	Version   int64
+	DeletedAt *time.Time
+}
+
+// SearchResultView represents a page of search results
+type SearchResultView struct {
+	Hits    []SearchHitView
+	Total   int64
+	Offset  int
+	Limit   int
+	HasMore bool
+	Facets  []FacetView
+}
+
+// FacetView is one breakdown of the search results, e.g. by category
+type FacetView struct {
+	Name   string
+	Values []FacetValueView
+}
+
+// FacetValueView is a facet value with its count; Refine is the query term
+// that narrows the search down to it
+type FacetValueView struct {
+	Label  string
+	Count  int64
+	Refine string
+}
+
+// SearchHitView represents a search result: the note, its relevance and
+// excerpts of where it matched
+type SearchHitView struct {
+	Note     NoteView
+	Score    float64
+	Snippets []SnippetView
+}
+
+// SnippetView is an excerpt split into plain and matched (Mark) parts
+type SnippetView struct {
+	Parts []SnippetPart
+}
+
+type SnippetPart struct {
+	Text string
+	Mark bool
+}
+
+// RelatedNoteView represents a note related to the one shown: the terms
+// they share and excerpts of where it uses them
+type RelatedNoteView struct {
+	Note     NoteView
+	Terms    []string
+	Snippets []SnippetView
+}
+
+// RevisionView represents one version of a note with its diff against the
+// version before it
+type RevisionView struct {
+	Version      int64
+	Category     string
+	PrevCategory string // empty for the first version
+	Author       string
+	CreatedAt    time.Time
+	Current      bool
+	Diff         []DiffLineView
+}
+
+// DiffLineView is one line of a rendered diff; Op is equal, insert or delete
+type DiffLineView struct {
+	Op   string
+	Text string
 }
 
 // CategoryView represents a category for template rendering